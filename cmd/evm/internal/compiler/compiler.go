@@ -24,16 +24,24 @@ import (
 )
 
 func Compile(fn string, src []byte, debug bool) (string, error) {
+	bin, _, err := CompileWithSourceMap(fn, src, debug)
+	return bin, err
+}
+
+// CompileWithSourceMap compiles the source and additionally returns the
+// location in the source of every instruction, keyed by program counter.
+func CompileWithSourceMap(fn string, src []byte, debug bool) (string, map[uint64]asm.SourceLocation, error) {
 	compiler := asm.NewCompiler(debug)
+	compiler.SetSource(fn)
 	compiler.Feed(asm.Lex(src, debug))
 
 	bin, compileErrors := compiler.Compile()
 	if len(compileErrors) > 0 {
-		// report errors
+		// report errors, they carry the file and line they occurred at
 		for _, err := range compileErrors {
-			fmt.Printf("%s:%v\n", fn, err)
+			fmt.Println(err)
 		}
-		return "", errors.New("compiling failed")
+		return "", nil, errors.New("compiling failed")
 	}
-	return bin, nil
+	return bin, compiler.SourceMap(), nil
 }
@@ -0,0 +1,277 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// Package debugger implements an interactive step debugger on top of the
+// EVM logger hooks.
+package debugger
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/core/asm"
+	"github.com/foreverbit/biternal/core/vm"
+)
+
+const help = `Commands:
+  step, s                 execute the current instruction and stop at the next
+  continue, c             run until the next breakpoint
+  break, b <pc|OPCODE>    stop before executing the given pc or opcode
+  delete, d <pc|OPCODE>   remove a breakpoint
+  breakpoints, bl         list the breakpoints
+  stack                   show the stack, top first
+  memory                  show the memory
+  storage [slot]          show the storage slots accessed so far, or a given slot
+  returndata              show the return data of the last call
+  quit, q                 run to completion without stopping
+  help, h                 show this help`
+
+// Debugger is an EVM logger which stops the execution before instructions
+// matching a breakpoint, or after every instruction while single-stepping,
+// and lets the user inspect the state of the VM.
+type Debugger struct {
+	in  *bufio.Scanner
+	out io.Writer
+
+	env    *vm.EVM
+	srcmap map[uint64]asm.SourceLocation // source locations of the top level code, if any
+
+	pcs      map[uint64]bool    // program counters to stop at
+	ops      map[vm.OpCode]bool // opcodes to stop at
+	stepping bool               // whether to stop before the next instruction
+	detached bool               // whether the user quit, running to completion
+
+	storage map[common.Address]map[common.Hash]struct{} // storage slots accessed per contract
+}
+
+// New creates a debugger reading commands from in and writing its output to
+// out. The execution is stopped before the first instruction.
+func New(in io.Reader, out io.Writer) *Debugger {
+	return &Debugger{
+		in:       bufio.NewScanner(in),
+		out:      out,
+		pcs:      make(map[uint64]bool),
+		ops:      make(map[vm.OpCode]bool),
+		stepping: true,
+		storage:  make(map[common.Address]map[common.Hash]struct{}),
+	}
+}
+
+// SetSourceMap sets the source locations of the top level code, which are
+// displayed alongside the instructions.
+func (d *Debugger) SetSourceMap(srcmap map[uint64]asm.SourceLocation) {
+	d.srcmap = srcmap
+}
+
+// Break adds a breakpoint on a program counter or an opcode.
+func (d *Debugger) Break(target string) error {
+	if pc, err := parsePC(target); err == nil {
+		d.pcs[pc] = true
+		return nil
+	}
+	op := vm.StringToOp(strings.ToUpper(target))
+	if op == vm.STOP && !strings.EqualFold(target, vm.STOP.String()) {
+		return fmt.Errorf("invalid breakpoint %q, want pc or opcode", target)
+	}
+	d.ops[op] = true
+	return nil
+}
+
+// Delete removes a breakpoint on a program counter or an opcode.
+func (d *Debugger) Delete(target string) error {
+	if pc, err := parsePC(target); err == nil && d.pcs[pc] {
+		delete(d.pcs, pc)
+		return nil
+	}
+	if op := vm.StringToOp(strings.ToUpper(target)); d.ops[op] {
+		delete(d.ops, op)
+		return nil
+	}
+	return fmt.Errorf("no breakpoint at %q", target)
+}
+
+func (d *Debugger) CaptureTxStart(gasLimit uint64) {}
+
+func (d *Debugger) CaptureTxEnd(restGas uint64) {}
+
+func (d *Debugger) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	d.env = env
+	fmt.Fprintf(d.out, "start: from=%v to=%v create=%v gas=%d value=%v input=%x\n", from, to, create, gas, value, input)
+}
+
+func (d *Debugger) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) {
+	fmt.Fprintf(d.out, "end: gasUsed=%d output=%x", gasUsed, output)
+	if err != nil {
+		fmt.Fprintf(d.out, " error=%v", err)
+	}
+	fmt.Fprintln(d.out)
+}
+
+func (d *Debugger) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	if !d.detached {
+		fmt.Fprintf(d.out, "enter %v: from=%v to=%v gas=%d value=%v input=%x\n", typ, from, to, gas, value, input)
+	}
+}
+
+func (d *Debugger) CaptureExit(output []byte, gasUsed uint64, err error) {
+	if d.detached {
+		return
+	}
+	fmt.Fprintf(d.out, "exit: gasUsed=%d output=%x", gasUsed, output)
+	if err != nil {
+		fmt.Fprintf(d.out, " error=%v", err)
+	}
+	fmt.Fprintln(d.out)
+}
+
+// CaptureState stops the execution if the instruction about to be executed
+// matches a breakpoint, or if single-stepping, and processes the user commands
+// until the execution is resumed.
+func (d *Debugger) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	// Track the accessed storage slots before the instruction is executed
+	if stack := scope.Stack.Data(); (op == vm.SLOAD || op == vm.SSTORE) && len(stack) > 0 {
+		addr := scope.Contract.Address()
+		if d.storage[addr] == nil {
+			d.storage[addr] = make(map[common.Hash]struct{})
+		}
+		d.storage[addr][common.Hash(stack[len(stack)-1].Bytes32())] = struct{}{}
+	}
+	if d.detached || !(d.stepping || d.pcs[pc] || d.ops[op]) {
+		return
+	}
+	fmt.Fprintf(d.out, "[%d] %v pc=%d op=%v gas=%d cost=%d\n", depth, scope.Contract.Address(), pc, op, gas, cost)
+	if loc, ok := d.srcmap[pc]; ok && depth == 1 {
+		fmt.Fprintf(d.out, "     %s:%d\n", loc.File, loc.Line)
+	}
+	for {
+		fmt.Fprint(d.out, "> ")
+		if !d.in.Scan() {
+			// Input exhausted, nobody left to step through the execution
+			fmt.Fprintln(d.out)
+			d.detached = true
+			return
+		}
+		fields := strings.Fields(d.in.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch cmd, args := fields[0], fields[1:]; cmd {
+		case "step", "s":
+			d.stepping = true
+			return
+		case "continue", "c":
+			d.stepping = false
+			return
+		case "quit", "q":
+			d.detached = true
+			return
+		case "break", "b":
+			for _, arg := range args {
+				if err := d.Break(arg); err != nil {
+					fmt.Fprintln(d.out, err)
+				}
+			}
+		case "delete", "d":
+			for _, arg := range args {
+				if err := d.Delete(arg); err != nil {
+					fmt.Fprintln(d.out, err)
+				}
+			}
+		case "breakpoints", "bl":
+			d.printBreakpoints()
+		case "stack":
+			stack := scope.Stack.Data()
+			for i := len(stack) - 1; i >= 0; i-- {
+				fmt.Fprintf(d.out, "%08d  %s\n", len(stack)-i-1, stack[i].Hex())
+			}
+		case "memory":
+			fmt.Fprint(d.out, hex.Dump(scope.Memory.Data()))
+		case "storage":
+			d.printStorage(scope.Contract.Address(), args)
+		case "returndata":
+			fmt.Fprint(d.out, hex.Dump(rData))
+		case "help", "h":
+			fmt.Fprintln(d.out, help)
+		default:
+			fmt.Fprintf(d.out, "unknown command %q, type help for the list of commands\n", cmd)
+		}
+	}
+}
+
+func (d *Debugger) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+	if !d.detached {
+		fmt.Fprintf(d.out, "[%d] fault pc=%d op=%v: %v\n", depth, pc, op, err)
+	}
+}
+
+// printBreakpoints lists the breakpoints, program counters first.
+func (d *Debugger) printBreakpoints() {
+	pcs := make([]uint64, 0, len(d.pcs))
+	for pc := range d.pcs {
+		pcs = append(pcs, pc)
+	}
+	sort.Slice(pcs, func(i, j int) bool { return pcs[i] < pcs[j] })
+	for _, pc := range pcs {
+		fmt.Fprintf(d.out, "pc %d\n", pc)
+	}
+	ops := make([]string, 0, len(d.ops))
+	for op := range d.ops {
+		ops = append(ops, op.String())
+	}
+	sort.Strings(ops)
+	for _, op := range ops {
+		fmt.Fprintf(d.out, "op %s\n", op)
+	}
+}
+
+// printStorage shows the current value of the given storage slots of a
+// contract, or of all the slots accessed so far if none are given.
+func (d *Debugger) printStorage(addr common.Address, args []string) {
+	var slots []common.Hash
+	if len(args) == 0 {
+		for slot := range d.storage[addr] {
+			slots = append(slots, slot)
+		}
+		sort.Slice(slots, func(i, j int) bool { return slots[i].Big().Cmp(slots[j].Big()) < 0 })
+	}
+	for _, arg := range args {
+		slot, ok := new(big.Int).SetString(arg, 0)
+		if !ok {
+			fmt.Fprintf(d.out, "invalid storage slot %q\n", arg)
+			return
+		}
+		slots = append(slots, common.BigToHash(slot))
+	}
+	for _, slot := range slots {
+		fmt.Fprintf(d.out, "%x: %x\n", slot, d.env.StateDB.GetState(addr, slot))
+	}
+}
+
+// parsePC parses a program counter in decimal or 0x prefixed hexadecimal form.
+func parsePC(s string) (uint64, error) {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		return strconv.ParseUint(s[2:], 16, 64)
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
@@ -0,0 +1,65 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package debugger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/core/vm"
+	"github.com/foreverbit/biternal/core/vm/runtime"
+)
+
+// Tests that the debugger stops at breakpoints and single steps, and shows the
+// state of the VM at each stop.
+func TestDebugger(t *testing.T) {
+	// PUSH1 1 PUSH1 0 SSTORE PUSH1 2 STOP
+	code := common.Hex2Bytes("6001600055600200")
+	script := strings.Join([]string{
+		"b SSTORE", // break on the store
+		"b 0x7",    // and on the final STOP
+		"bl",
+		"c",
+		"stack",
+		"s",
+		"storage",
+		"d SSTORE",
+		"c",
+		"q",
+	}, "\n")
+	var out bytes.Buffer
+	dbg := New(strings.NewReader(script), &out)
+	if _, _, err := runtime.Execute(code, nil, &runtime.Config{EVMConfig: vm.Config{Debug: true, Tracer: dbg}}); err != nil {
+		t.Fatalf("execution failed: %v", err)
+	}
+	for _, want := range []string{
+		"pc=0 op=PUSH1",
+		"pc 7\nop SSTORE\n",
+		"pc=4 op=SSTORE",
+		"00000000  0x0\n00000001  0x1\n",
+		"pc=5 op=PUSH1",
+		"0000000000000000000000000000000000000000000000000000000000000000: 0000000000000000000000000000000000000000000000000000000000000001",
+		"pc=7 op=STOP",
+		"end: gasUsed=",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
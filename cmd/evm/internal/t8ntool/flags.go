@@ -52,6 +52,10 @@ var (
 		Name:  "trace.returndata",
 		Usage: "Enable return data output in traces",
 	}
+	DebugTxFlag = &cli.StringFlag{
+		Name:  "debug.tx",
+		Usage: "Index or hash of a transaction to step through in an interactive debugger",
+	}
 	OutputBasedir = &cli.StringFlag{
 		Name:  "output.basedir",
 		Usage: "Specifies where output files are placed. Will be created if it does not exist.",
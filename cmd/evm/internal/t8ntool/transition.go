@@ -24,8 +24,10 @@ import (
 	"math/big"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 
+	"github.com/foreverbit/biternal/cmd/evm/internal/debugger"
 	"github.com/foreverbit/biternal/common"
 	"github.com/foreverbit/biternal/common/hexutil"
 	"github.com/foreverbit/biternal/core"
@@ -141,6 +143,23 @@ func Transition(ctx *cli.Context) error {
 			return nil, nil
 		}
 	}
+	if target := ctx.String(DebugTxFlag.Name); target != "" {
+		if ctx.Bool(TraceFlag.Name) {
+			return NewError(ErrorConfig, fmt.Errorf("can't use both flags --%s and --%s", TraceFlag.Name, DebugTxFlag.Name))
+		}
+		// The debugger is driven from stdin, so the inputs must come from files
+		for _, flag := range []*cli.StringFlag{InputAllocFlag, InputEnvFlag, InputTxsFlag} {
+			if ctx.String(flag.Name) == stdinSelector {
+				return NewError(ErrorConfig, fmt.Errorf("can't use --%s with --%s read from stdin", DebugTxFlag.Name, flag.Name))
+			}
+		}
+		getTracer = func(txIndex int, txHash common.Hash) (vm.EVMLogger, error) {
+			if target == strconv.Itoa(txIndex) || strings.EqualFold(target, txHash.Hex()) {
+				return debugger.New(os.Stdin, os.Stderr), nil
+			}
+			return nil, nil
+		}
+	}
 	// We need to load three things: alloc, env and transactions. May be either in
 	// stdin input or in files.
 	// Check if anything needs to be read from stdin
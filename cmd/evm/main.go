@@ -35,6 +35,10 @@ var (
 )
 
 var (
+	InteractiveFlag = &cli.BoolFlag{
+		Name:  "interactive",
+		Usage: "step through the execution in an interactive debugger",
+	}
 	DebugFlag = &cli.BoolFlag{
 		Name:  "debug",
 		Usage: "output full trace logs",
@@ -146,6 +150,7 @@ var stateTransitionCommand = &cli.Command{
 		t8ntool.TraceDisableStackFlag,
 		t8ntool.TraceDisableReturnDataFlag,
 		t8ntool.TraceEnableReturnDataFlag,
+		t8ntool.DebugTxFlag,
 		t8ntool.OutputBasedir,
 		t8ntool.OutputAllocFlag,
 		t8ntool.OutputResultFlag,
@@ -197,6 +202,7 @@ func init() {
 		BenchFlag,
 		CreateFlag,
 		DebugFlag,
+		InteractiveFlag,
 		VerbosityFlag,
 		CodeFlag,
 		CodeFileFlag,
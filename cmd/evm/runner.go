@@ -25,13 +25,16 @@ import (
 	"os"
 	goruntime "runtime"
 	"runtime/pprof"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/foreverbit/biternal/cmd/evm/internal/compiler"
+	"github.com/foreverbit/biternal/cmd/evm/internal/debugger"
 	"github.com/foreverbit/biternal/cmd/utils"
 	"github.com/foreverbit/biternal/common"
 	"github.com/foreverbit/biternal/core"
+	"github.com/foreverbit/biternal/core/asm"
 	"github.com/foreverbit/biternal/core/rawdb"
 	"github.com/foreverbit/biternal/core/state"
 	"github.com/foreverbit/biternal/core/vm"
@@ -105,6 +108,29 @@ func timedExec(bench bool, execFunc func() ([]byte, uint64, error)) (output []by
 	return output, gasLeft, stats, err
 }
 
+// writeSourceTrace writes the trace of an execution in a readable format, with
+// each top level step preceded by the source line it was compiled from.
+func writeSourceTrace(writer io.Writer, logs []logger.StructLog, srcmap map[uint64]asm.SourceLocation) {
+	sources := make(map[string][]string)
+	for _, log := range logs {
+		if loc, ok := srcmap[log.Pc]; ok && log.Depth == 1 {
+			lines, ok := sources[loc.File]
+			if !ok {
+				if src, err := os.ReadFile(loc.File); err == nil {
+					lines = strings.Split(string(src), "\n")
+				}
+				sources[loc.File] = lines
+			}
+			var text string
+			if loc.Line <= len(lines) {
+				text = strings.TrimSpace(lines[loc.Line-1])
+			}
+			fmt.Fprintf(writer, "%s:%d: %s\n", loc.File, loc.Line, text)
+		}
+		logger.WriteTrace(writer, []logger.StructLog{log})
+	}
+}
+
 func runCmd(ctx *cli.Context) error {
 	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
 	glogger.Verbosity(log.Lvl(ctx.Int(VerbosityFlag.Name)))
@@ -120,6 +146,7 @@ func runCmd(ctx *cli.Context) error {
 	var (
 		tracer        vm.EVMLogger
 		debugLogger   *logger.StructLogger
+		stepDebugger  *debugger.Debugger
 		statedb       *state.StateDB
 		chainConfig   *params.ChainConfig
 		sender        = common.BytesToAddress([]byte("sender"))
@@ -128,6 +155,9 @@ func runCmd(ctx *cli.Context) error {
 	)
 	if ctx.Bool(MachineFlag.Name) {
 		tracer = logger.NewJSONLogger(logconfig, os.Stdout)
+	} else if ctx.Bool(InteractiveFlag.Name) {
+		stepDebugger = debugger.New(os.Stdin, os.Stderr)
+		tracer = stepDebugger
 	} else if ctx.Bool(DebugFlag.Name) {
 		debugLogger = logger.NewStructLogger(logconfig)
 		tracer = debugLogger
@@ -154,7 +184,10 @@ func runCmd(ctx *cli.Context) error {
 		receiver = common.HexToAddress(ctx.String(ReceiverFlag.Name))
 	}
 
-	var code []byte
+	var (
+		code   []byte
+		srcmap map[uint64]asm.SourceLocation
+	)
 	codeFileFlag := ctx.String(CodeFileFlag.Name)
 	codeFlag := ctx.String(CodeFlag.Name)
 
@@ -192,11 +225,14 @@ func runCmd(ctx *cli.Context) error {
 		if err != nil {
 			return err
 		}
-		bin, err := compiler.Compile(fn, src, false)
+		bin, locations, err := compiler.CompileWithSourceMap(fn, src, false)
 		if err != nil {
 			return err
 		}
-		code = common.Hex2Bytes(bin)
+		code, srcmap = common.Hex2Bytes(bin), locations
+	}
+	if stepDebugger != nil {
+		stepDebugger.SetSourceMap(srcmap)
 	}
 	initialGas := ctx.Uint64(GasFlag.Name)
 	if genesisConfig.GasLimit != 0 {
@@ -214,7 +250,7 @@ func runCmd(ctx *cli.Context) error {
 		BlockNumber: new(big.Int).SetUint64(genesisConfig.Number),
 		EVMConfig: vm.Config{
 			Tracer: tracer,
-			Debug:  ctx.Bool(DebugFlag.Name) || ctx.Bool(MachineFlag.Name),
+			Debug:  ctx.Bool(DebugFlag.Name) || ctx.Bool(MachineFlag.Name) || ctx.Bool(InteractiveFlag.Name),
 		},
 	}
 
@@ -295,7 +331,11 @@ func runCmd(ctx *cli.Context) error {
 	if ctx.Bool(DebugFlag.Name) {
 		if debugLogger != nil {
 			fmt.Fprintln(os.Stderr, "#### TRACE ####")
-			logger.WriteTrace(os.Stderr, debugLogger.StructLogs())
+			if srcmap != nil {
+				writeSourceTrace(os.Stderr, debugLogger.StructLogs(), srcmap)
+			} else {
+				logger.WriteTrace(os.Stderr, debugLogger.StructLogs())
+			}
 		}
 		fmt.Fprintln(os.Stderr, "#### LOGS ####")
 		logger.WriteLogs(os.Stderr, statedb.Logs())
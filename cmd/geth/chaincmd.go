@@ -35,6 +35,7 @@ import (
 	"github.com/foreverbit/biternal/core/types"
 	"github.com/foreverbit/biternal/crypto"
 	"github.com/foreverbit/biternal/ethdb"
+	"github.com/foreverbit/biternal/internal/era"
 	"github.com/foreverbit/biternal/internal/flags"
 	"github.com/foreverbit/biternal/log"
 	"github.com/foreverbit/biternal/metrics"
@@ -48,7 +49,9 @@ var (
 		Name:      "init",
 		Usage:     "Bootstrap and initialize a new genesis block",
 		ArgsUsage: "<genesisPath>",
-		Flags:     utils.DatabasePathFlags,
+		Flags: flags.Merge([]cli.Flag{
+			utils.StateSchemeFlag,
+		}, utils.DatabasePathFlags),
 		Description: `
 The init command initializes a new genesis block and definition for the network.
 This is a destructive action and changes the network in which you will be
@@ -75,6 +78,8 @@ The dumpgenesis command dumps the genesis block configuration in JSON format to
 			utils.SyncModeFlag,
 			utils.GCModeFlag,
 			utils.SnapshotFlag,
+			utils.StateSchemeFlag,
+			utils.StateReverseDiffsFlag,
 			utils.CacheDatabaseFlag,
 			utils.CacheGCFlag,
 			utils.MetricsEnabledFlag,
@@ -115,6 +120,39 @@ Optional second and third arguments control the first and
 last block to write. In this mode, the file will be appended
 if already existing. If the file ends with .gz, the output will
 be gzipped.`,
+	}
+	importHistoryCommand = &cli.Command{
+		Action:    importHistory,
+		Name:      "import-history",
+		Usage:     "Import blockchain history from archive files",
+		ArgsUsage: "<dir>",
+		Flags: flags.Merge([]cli.Flag{
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+			utils.TxLookupLimitFlag,
+		}, utils.DatabasePathFlags, utils.NetworkFlags),
+		Description: `
+The import-history command imports blocks along with their receipts from the
+archive files of the selected network in the given directory, as produced by
+export-history. Every file is checked against checksums.txt and its accumulator
+root is verified before any of its blocks is imported. The blocks are inserted
+without being executed, so the state must be synced separately.`,
+	}
+	exportHistoryCommand = &cli.Command{
+		Action:    exportHistory,
+		Name:      "export-history",
+		Usage:     "Export blockchain history to archive files",
+		ArgsUsage: "<dir> <blockNumFirst> <blockNumLast>",
+		Flags: flags.Merge([]cli.Flag{
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+		}, utils.DatabasePathFlags, utils.NetworkFlags),
+		Description: `
+The export-history command exports the blocks in the given range along with
+their receipts and total difficulties into archive files in the given directory.
+Every file holds up to 8192 blocks, aligned to multiples of 8192, together with
+an index for random access and an accumulator root for verification. The
+checksums of the files are written into checksums.txt.`,
 	}
 	importPreimagesCommand = &cli.Command{
 		Action:    importPreimages,
@@ -192,6 +230,11 @@ func initGenesis(ctx *cli.Context) error {
 		if err != nil {
 			utils.Fatalf("Failed to open database: %v", err)
 		}
+		if name == "chaindata" {
+			if _, err := rawdb.SetupStateScheme(chaindb, ctx.String(utils.StateSchemeFlag.Name)); err != nil {
+				utils.Fatalf("Failed to setup state scheme: %v", err)
+			}
+		}
 		_, hash, err := core.SetupGenesisBlock(chaindb, genesis)
 		if err != nil {
 			utils.Fatalf("Failed to write genesis block: %v", err)
@@ -331,6 +374,66 @@ func exportChain(ctx *cli.Context) error {
 	return nil
 }
 
+// historyNetwork returns the name of the network the history archives are
+// named after.
+func historyNetwork(ctx *cli.Context) string {
+	for _, flag := range utils.TestnetFlags {
+		if name := flag.Names()[0]; ctx.IsSet(name) {
+			return name
+		}
+	}
+	return "mainnet"
+}
+
+func importHistory(ctx *cli.Context) error {
+	if ctx.Args().Len() != 1 {
+		utils.Fatalf("usage: %s", ctx.Command.ArgsUsage)
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chain, db := utils.MakeChain(ctx, stack)
+	defer db.Close()
+
+	start := time.Now()
+	if err := utils.ImportHistory(chain, ctx.Args().First(), historyNetwork(ctx)); err != nil {
+		utils.Fatalf("Import error: %v\n", err)
+	}
+	chain.Stop()
+	fmt.Printf("Import done in %v\n", time.Since(start))
+	return nil
+}
+
+func exportHistory(ctx *cli.Context) error {
+	if ctx.Args().Len() != 3 {
+		utils.Fatalf("usage: %s", ctx.Command.ArgsUsage)
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chain, _ := utils.MakeChain(ctx, stack)
+	start := time.Now()
+
+	first, ferr := strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+	last, lerr := strconv.ParseUint(ctx.Args().Get(2), 10, 64)
+	if ferr != nil || lerr != nil {
+		utils.Fatalf("Export error in parsing parameters: block number not an integer\n")
+	}
+	if first > last {
+		utils.Fatalf("Export error: first block %d larger than last block %d\n", first, last)
+	}
+	if head := chain.CurrentFastBlock(); last > head.NumberU64() {
+		utils.Fatalf("Export error: block number %d larger than head block %d\n", last, head.NumberU64())
+	}
+	// Align the archives to multiples of the archive size
+	first -= first % era.MaxSize
+	if err := utils.ExportHistory(chain, ctx.Args().First(), historyNetwork(ctx), first, last, era.MaxSize); err != nil {
+		utils.Fatalf("Export error: %v\n", err)
+	}
+	fmt.Printf("Export done in %v\n", time.Since(start))
+	return nil
+}
+
 // importPreimages imports preimage data from the specified file.
 func importPreimages(ctx *cli.Context) error {
 	if ctx.Args().Len() < 1 {
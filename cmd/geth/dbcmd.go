@@ -19,9 +19,11 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
@@ -31,6 +33,7 @@ import (
 	"github.com/foreverbit/biternal/common"
 	"github.com/foreverbit/biternal/common/hexutil"
 	"github.com/foreverbit/biternal/console/prompt"
+	"github.com/foreverbit/biternal/core"
 	"github.com/foreverbit/biternal/core/rawdb"
 	"github.com/foreverbit/biternal/core/state/snapshot"
 	"github.com/foreverbit/biternal/core/types"
@@ -38,12 +41,58 @@ import (
 	"github.com/foreverbit/biternal/ethdb"
 	"github.com/foreverbit/biternal/internal/flags"
 	"github.com/foreverbit/biternal/log"
+	"github.com/foreverbit/biternal/params"
 	"github.com/foreverbit/biternal/trie"
 	"github.com/olekukonko/tablewriter"
 	"github.com/urfave/cli/v2"
 )
 
 var (
+	freezerTablesFlag = &cli.StringFlag{
+		Name:  "tables",
+		Usage: "Comma separated list of the freezer tables to compress",
+		Value: "receipts",
+	}
+	freezerCodecFlag = &cli.StringFlag{
+		Name:  "codec",
+		Usage: `Compression codec to convert the freezer tables to ("none", "snappy" or "zstd")`,
+	}
+	freezerLevelFlag = &cli.IntFlag{
+		Name:  "level",
+		Usage: "Compression level of zstd (1-22, default = 0, the default level of zstd)",
+	}
+	freezerDictFlag = &cli.BoolFlag{
+		Name:  "dict",
+		Usage: "Compress with a zstd dictionary sampled from the content of each converted table",
+	}
+	verifyStartFlag = &cli.Uint64Flag{
+		Name:  "start",
+		Usage: "Number of the block to start (or resume) the verification at",
+	}
+	verifyRepairFlag = &cli.BoolFlag{
+		Name:  "repair",
+		Usage: "Rebuild the derivable data and indices found to be inconsistent",
+	}
+	verifySkipStateFlag = &cli.BoolFlag{
+		Name:  "skip-state",
+		Usage: "Skip verifying the head state and snapshot",
+	}
+	reindexTxLookupFlag = &cli.BoolFlag{
+		Name:  "txlookup",
+		Usage: "Rebuild the transaction lookup index",
+	}
+	reindexBloomBitsFlag = &cli.BoolFlag{
+		Name:  "bloombits",
+		Usage: "Rebuild the bloombits index",
+	}
+	reindexRangeFlag = &cli.StringFlag{
+		Name:  "range",
+		Usage: `Range of blocks to reindex ("from-to", either side optional, default = the entire chain)`,
+	}
+	reindexDryRunFlag = &cli.BoolFlag{
+		Name:  "dry-run",
+		Usage: "Only count the index entries that are missing or wrong, without writing",
+	}
 	removedbCommand = &cli.Command{
 		Action:    removeDB,
 		Name:      "removedb",
@@ -71,6 +120,8 @@ Remove blockchain and state databases`,
 			dbMetadataCmd,
 			dbMigrateFreezerCmd,
 			dbCheckStateContentCmd,
+			dbVerifyCmd,
+			dbReindexCmd,
 		},
 	}
 	dbInspectCmd = &cli.Command{
@@ -92,6 +143,42 @@ Remove blockchain and state databases`,
 		Description: `This command iterates the entire database for 32-byte keys, looking for rlp-encoded trie nodes.
 For each trie node encountered, it checks that the key corresponds to the keccak256(value). If this is not true, this indicates
 a data corruption.`,
+	}
+	dbVerifyCmd = &cli.Command{
+		Action: verifyDatabase,
+		Name:   "verify",
+		Flags: flags.Merge([]cli.Flag{
+			verifyStartFlag,
+			verifyRepairFlag,
+			verifySkipStateFlag,
+		}, utils.NetworkFlags, utils.DatabasePathFlags),
+		Usage: "Cross-check the consistency of the chain data and its indices",
+		Description: `This command iterates the canonical chain in both leveldb and the freezer,
+cross-checking the canonical hash mappings against the headers, bodies and receipts,
+the total difficulties, the transaction lookup indices and the bloombits sections.
+Finally the head state is checked against the snapshot.
+
+The verification can be interrupted and resumed with --start. With --repair, the
+inconsistent data derivable from the rest of the chain is rebuilt. An inconsistent
+snapshot is dropped and regenerated on the next startup.`,
+	}
+	dbReindexCmd = &cli.Command{
+		Action: reindexDatabase,
+		Name:   "reindex",
+		Flags: flags.Merge([]cli.Flag{
+			reindexTxLookupFlag,
+			reindexBloomBitsFlag,
+			reindexRangeFlag,
+			reindexDryRunFlag,
+		}, utils.NetworkFlags, utils.DatabasePathFlags),
+		Usage: "Rebuild the transaction lookup and bloombits indices from the chain data",
+		Description: `This command rebuilds the transaction lookup entries and the bloombits sections
+of the canonical blocks in the given range from the chain data, e.g. after a change of
+--txlookuplimit or after corruption. Blocks with pruned bodies are skipped, and only
+bloombits sections fully in the range are rebuilt.
+
+The rebuild can be interrupted and resumed with --range. With --dry-run, the index
+entries that are missing or wrong are only counted.`,
 	}
 	dbStatCmd = &cli.Command{
 		Action: dbStats,
@@ -202,8 +289,20 @@ WARNING: This is a low-level operation which may cause database corruption!`,
 		ArgsUsage: "",
 		Flags: flags.Merge([]cli.Flag{
 			utils.SyncModeFlag,
+			freezerTablesFlag,
+			freezerCodecFlag,
+			freezerLevelFlag,
+			freezerDictFlag,
 		}, utils.NetworkFlags, utils.DatabasePathFlags),
 		Description: `The freezer-migrate command checks your database for receipts in a legacy format and updates those.
+
+If --codec is given, the freezer tables listed in --tables are additionally rewritten in place
+with the given compression codec. Tables of different codecs can coexist, the codec of each
+table is recorded in its metadata. For example, to compress the receipts with zstd using a
+dictionary sampled from their content:
+
+    geth db freezer-migrate --codec zstd --level 3 --dict
+
 WARNING: please back-up the receipt files in your ancients before running this command.`,
 	}
 )
@@ -353,6 +452,162 @@ func checkStateContent(ctx *cli.Context) error {
 	return nil
 }
 
+func verifyDatabase(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	repair := ctx.Bool(verifyRepairFlag.Name)
+	db := utils.MakeChainDatabase(ctx, stack, !repair)
+	defer db.Close()
+
+	var (
+		interrupt = make(chan os.Signal, 1)
+		stop      = make(chan struct{})
+	)
+	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+	defer close(interrupt)
+	go func() {
+		if _, ok := <-interrupt; ok {
+			log.Info("Interrupted during verification, stopping at next block")
+		}
+		close(stop)
+	}()
+	result, err := core.VerifyDatabase(db, &core.VerifyConfig{
+		Start:     ctx.Uint64(verifyStartFlag.Name),
+		SkipState: ctx.Bool(verifySkipStateFlag.Name),
+		Repair:    repair,
+		Interrupt: stop,
+	})
+	if err != nil {
+		return err
+	}
+	kinds := make([]string, 0, len(result.Issues))
+	for kind := range result.Issues {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Kind", "Issues", "Repaired"})
+	for _, kind := range kinds {
+		table.Append([]string{kind, strconv.Itoa(result.Issues[kind]), strconv.Itoa(result.Repaired[kind])})
+	}
+	table.Render()
+
+	select {
+	case <-stop:
+		log.Info("Resume the verification with --start", "next", result.Next)
+	default:
+	}
+	if n := result.Unrepaired(); n > 0 {
+		return fmt.Errorf("found %d unrepaired inconsistencies", n)
+	}
+	return nil
+}
+
+// parseReindexRange parses a block range of the form "from-to", either side of
+// which may be omitted.
+func parseReindexRange(arg string) (uint64, uint64, error) {
+	from, to := uint64(0), uint64(math.MaxUint64)
+	if arg == "" {
+		return from, to, nil
+	}
+	parts := strings.Split(arg, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid range %q, want from-to", arg)
+	}
+	var err error
+	if parts[0] != "" {
+		if from, err = strconv.ParseUint(parts[0], 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("invalid range start %q: %v", parts[0], err)
+		}
+	}
+	if parts[1] != "" {
+		if to, err = strconv.ParseUint(parts[1], 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("invalid range end %q: %v", parts[1], err)
+		}
+	}
+	if from > to {
+		return 0, 0, fmt.Errorf("invalid range %q, start after end", arg)
+	}
+	return from, to, nil
+}
+
+func reindexDatabase(ctx *cli.Context) error {
+	txlookup, bloombits := ctx.Bool(reindexTxLookupFlag.Name), ctx.Bool(reindexBloomBitsFlag.Name)
+	if !txlookup && !bloombits {
+		return fmt.Errorf("no index to rebuild, specify --%s and/or --%s", reindexTxLookupFlag.Name, reindexBloomBitsFlag.Name)
+	}
+	from, to, err := parseReindexRange(ctx.String(reindexRangeFlag.Name))
+	if err != nil {
+		return err
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	dryrun := ctx.Bool(reindexDryRunFlag.Name)
+	db := utils.MakeChainDatabase(ctx, stack, dryrun)
+	defer db.Close()
+
+	var (
+		interrupt = make(chan os.Signal, 1)
+		stop      = make(chan struct{})
+	)
+	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+	defer close(interrupt)
+	go func() {
+		if _, ok := <-interrupt; ok {
+			log.Info("Interrupted during reindexing, stopping at next block")
+		}
+		close(stop)
+	}()
+	config := &core.ReindexConfig{
+		From:          from,
+		To:            to,
+		BloomConfirms: params.BloomConfirms,
+		DryRun:        dryrun,
+		Interrupt:     stop,
+	}
+	interrupted := func(index string, result *core.ReindexResult) bool {
+		select {
+		case <-stop:
+			resume := fmt.Sprintf("%d-", result.Next)
+			if to != math.MaxUint64 {
+				resume += strconv.FormatUint(to, 10)
+			}
+			log.Info("Resume the reindexing with --range", "index", index, "range", resume)
+			return true
+		default:
+			return false
+		}
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Index", "Blocks", "Entries", "Changed"})
+	defer table.Render()
+
+	if txlookup {
+		result, err := core.ReindexTransactions(db, config)
+		if err != nil {
+			return err
+		}
+		table.Append([]string{"txlookup", strconv.FormatUint(result.Blocks, 10), strconv.FormatUint(result.Entries, 10), strconv.FormatUint(result.Changed, 10)})
+		if interrupted("txlookup", result) {
+			return nil
+		}
+	}
+	if bloombits {
+		result, err := core.ReindexBloomBits(db, config)
+		if err != nil {
+			return err
+		}
+		table.Append([]string{"bloombits", strconv.FormatUint(result.Blocks, 10), strconv.FormatUint(result.Entries, 10), strconv.FormatUint(result.Changed, 10)})
+		interrupted("bloombits", result)
+	}
+	return nil
+}
+
 func showLeveldbStats(db ethdb.KeyValueStater) {
 	if stats, err := db.Stat("leveldb.stats"); err != nil {
 		log.Warn("Failed to read database stats", "error", err)
@@ -753,6 +1008,34 @@ func freezerMigrate(ctx *cli.Context) error {
 	db := utils.MakeChainDatabase(ctx, stack, false)
 	defer db.Close()
 
+	if err := migrateLegacyReceipts(db); err != nil {
+		return err
+	}
+	if ctx.IsSet(freezerCodecFlag.Name) {
+		var (
+			codec = ctx.String(freezerCodecFlag.Name)
+			level = ctx.Int(freezerLevelFlag.Name)
+			dict  = ctx.Bool(freezerDictFlag.Name)
+		)
+		for _, table := range strings.Split(ctx.String(freezerTablesFlag.Name), ",") {
+			table = strings.TrimSpace(table)
+			if table == "" {
+				continue
+			}
+			log.Info("Starting table compression", "table", table, "codec", codec)
+			start := time.Now()
+			if err := db.CompressTable(table, codec, level, dict); err != nil {
+				return fmt.Errorf("failed to compress table %s: %v", table, err)
+			}
+			log.Info("Table compression finished", "table", table, "duration", time.Since(start))
+		}
+	}
+	return db.Close()
+}
+
+// migrateLegacyReceipts checks the freezer for receipts in the legacy format and
+// converts them to the current one.
+func migrateLegacyReceipts(db ethdb.Database) error {
 	// Check first block for legacy receipt format
 	numAncients, err := db.Ancients()
 	if err != nil {
@@ -777,9 +1060,6 @@ func freezerMigrate(ctx *cli.Context) error {
 	if err := db.MigrateTable("receipts", types.ConvertLegacyStoredReceipts); err != nil {
 		return err
 	}
-	if err := db.Close(); err != nil {
-		return err
-	}
 	log.Info("Migration finished", "duration", time.Since(start))
 
 	return nil
@@ -806,9 +1086,11 @@ func dbHasLegacyReceipts(db ethdb.Database, firstIdx uint64) (bool, uint64, erro
 		emptyRLPList = []byte{192}
 	)
 	// Find first block with non-empty receipt, only if
-	// the index is not already provided.
+	// the index is not already provided. Pruned receipts
+	// are skipped.
 	if firstIdx == 0 {
-		for i := uint64(0); i < numAncients; i++ {
+		firstIdx = rawdb.ReadHistoryTail(db)
+		for i := firstIdx; i < numAncients; i++ {
 			blob, err = db.Ancient("receipts", i)
 			if err != nil {
 				return false, 0, err
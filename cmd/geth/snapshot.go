@@ -21,6 +21,7 @@ import (
 	"encoding/json"
 	"errors"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/foreverbit/biternal/cmd/utils"
@@ -159,6 +160,41 @@ as the backend data source, making this command a lot faster.
 
 The argument is interpreted as block number or hash. If none is provided, the latest
 block is used.
+`,
+			},
+			{
+				Name:      "export",
+				Usage:     "Export the state of a block from the snapshot into a file",
+				ArgsUsage: "<filename> [? <blockHash> | <blockNum>]",
+				Action:    exportSnapshot,
+				Flags:     flags.Merge(utils.NetworkFlags, utils.DatabasePathFlags),
+				Description: `
+geth snapshot export <filename> [? <blockHash> | <blockNum>]
+
+This command exports the state of the given block from the snapshot into a
+chunked, checksummed file, which can be loaded into another node with
+'geth snapshot import'. If no block is provided, the latest block is used.
+The state must be covered by the snapshot, which must be fully generated.
+If the file ends with .gz, the output will be gzipped.
+`,
+			},
+			{
+				Name:      "import",
+				Usage:     "Import the state from a snapshot export",
+				ArgsUsage: "<filename>",
+				Action:    importSnapshot,
+				Flags:     flags.Merge(utils.NetworkFlags, utils.DatabasePathFlags),
+				Description: `
+geth snapshot import <filename>
+
+This command imports the state from a file produced by 'geth snapshot export',
+replacing the existing snapshot. The checksum of every chunk is verified and
+the state tries are regenerated from the imported data, so the resulting state
+root is checked against the one recorded in the file.
+
+If the block of the exported state is known, e.g. after 'geth import-history',
+it becomes the head block and the node continues from there without syncing
+the state. This is not supported with the path scheme.
 `,
 			},
 		},
@@ -572,3 +608,66 @@ func checkAccount(ctx *cli.Context) error {
 	log.Info("Checked the snapshot journalled storage", "time", common.PrettyDuration(time.Since(start)))
 	return nil
 }
+
+// exportSnapshot exports the state of a block from the snapshot into a file.
+func exportSnapshot(ctx *cli.Context) error {
+	if ctx.NArg() < 1 || ctx.NArg() > 2 {
+		return errors.New("need <filename> and optional <blockHash | blockNum> args")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, true)
+	defer db.Close()
+
+	var header *types.Header
+	if ctx.NArg() == 2 {
+		arg := ctx.Args().Get(1)
+		if hashish(arg) {
+			hash := common.HexToHash(arg)
+			if number := rawdb.ReadHeaderNumber(db, hash); number != nil {
+				header = rawdb.ReadHeader(db, hash, *number)
+			}
+		} else {
+			number, err := strconv.ParseUint(arg, 10, 64)
+			if err != nil {
+				return err
+			}
+			if hash := rawdb.ReadCanonicalHash(db, number); hash != (common.Hash{}) {
+				header = rawdb.ReadHeader(db, hash, number)
+			}
+		}
+	} else if block := rawdb.ReadHeadBlock(db); block != nil {
+		header = block.Header()
+	}
+	if header == nil {
+		return errors.New("block not found")
+	}
+	start := time.Now()
+	if err := utils.ExportSnapshot(db, ctx.Args().First(), header); err != nil {
+		log.Error("Failed to export snapshot", "err", err)
+		return err
+	}
+	log.Info("Snapshot export done", "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// importSnapshot imports the state from a snapshot export.
+func importSnapshot(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return errors.New("need <filename> arg")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, false)
+	defer db.Close()
+
+	start := time.Now()
+	if err := utils.ImportSnapshot(db, ctx.Args().First()); err != nil {
+		log.Error("Failed to import snapshot", "err", err)
+		return err
+	}
+	log.Info("Snapshot import done", "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
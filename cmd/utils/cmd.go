@@ -20,11 +20,15 @@ package utils
 import (
 	"bufio"
 	"compress/gzip"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"math/big"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"syscall"
@@ -33,14 +37,17 @@ import (
 	"github.com/foreverbit/biternal/common"
 	"github.com/foreverbit/biternal/core"
 	"github.com/foreverbit/biternal/core/rawdb"
+	"github.com/foreverbit/biternal/core/state/snapshot"
 	"github.com/foreverbit/biternal/core/types"
 	"github.com/foreverbit/biternal/crypto"
 	"github.com/foreverbit/biternal/eth/ethconfig"
 	"github.com/foreverbit/biternal/ethdb"
 	"github.com/foreverbit/biternal/internal/debug"
+	"github.com/foreverbit/biternal/internal/era"
 	"github.com/foreverbit/biternal/log"
 	"github.com/foreverbit/biternal/node"
 	"github.com/foreverbit/biternal/rlp"
+	"github.com/foreverbit/biternal/trie"
 	"github.com/urfave/cli/v2"
 )
 
@@ -287,6 +294,318 @@ func ExportAppendChain(blockchain *core.BlockChain, fn string, first uint64, las
 	return nil
 }
 
+// ExportHistory exports the blocks in the given range along with their receipts
+// and total difficulties into archives of at most step blocks in the given
+// directory. A checksums.txt file listing the sha256 checksum of every archive
+// is written alongside them.
+func ExportHistory(bc *core.BlockChain, dir string, network string, first, last, step uint64) error {
+	log.Info("Exporting blockchain history", "dir", dir)
+	if head := bc.CurrentFastBlock().NumberU64(); head < last {
+		log.Warn("Last block beyond the head, limiting export", "head", head, "last", last)
+		last = head
+	}
+	if first > last {
+		return fmt.Errorf("invalid range: first block #%d beyond last #%d", first, last)
+	}
+	if step == 0 || step > era.MaxSize {
+		return fmt.Errorf("invalid archive size %d, must be within [1, %d]", step, era.MaxSize)
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("error creating output directory: %w", err)
+	}
+	var (
+		start     = time.Now()
+		reported  = time.Now()
+		checksums []string
+	)
+	for i := first; i <= last; i += step {
+		root, checksum, err := exportHistoryArchive(bc, dir, network, i, step, last)
+		if err != nil {
+			return err
+		}
+		checksums = append(checksums, checksum)
+
+		if time.Since(reported) > 8*time.Second {
+			log.Info("Exporting blockchain history", "exported", i-first, "archives", len(checksums), "root", root, "elapsed", common.PrettyDuration(time.Since(start)))
+			reported = time.Now()
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "checksums.txt"), []byte(strings.Join(checksums, "\n")), os.ModePerm); err != nil {
+		return err
+	}
+	log.Info("Exported blockchain history", "dir", dir, "archives", len(checksums))
+	return nil
+}
+
+// exportHistoryArchive writes the archive of the blocks starting at first,
+// returning its accumulator root and checksum.
+func exportHistoryArchive(bc *core.BlockChain, dir string, network string, first, step, last uint64) (common.Hash, string, error) {
+	// Write the archive under a temporary name until its root is known
+	tmp := filepath.Join(dir, fmt.Sprintf("%s-%05d.era1.tmp", network, first/step))
+	fh, err := os.Create(tmp)
+	if err != nil {
+		return common.Hash{}, "", err
+	}
+	defer os.Remove(tmp)
+	defer fh.Close()
+
+	builder := era.NewBuilder(fh)
+	for n := first; n < first+step && n <= last; n++ {
+		block := bc.GetBlockByNumber(n)
+		if block == nil {
+			return common.Hash{}, "", fmt.Errorf("export failed on #%d: not found", n)
+		}
+		receipts := bc.GetReceiptsByHash(block.Hash())
+		if receipts == nil {
+			return common.Hash{}, "", fmt.Errorf("export failed on #%d: receipts not found", n)
+		}
+		td := bc.GetTd(block.Hash(), n)
+		if td == nil {
+			return common.Hash{}, "", fmt.Errorf("export failed on #%d: total difficulty not found", n)
+		}
+		if err := builder.Add(block, receipts, td); err != nil {
+			return common.Hash{}, "", err
+		}
+	}
+	root, err := builder.Finalize()
+	if err != nil {
+		return common.Hash{}, "", err
+	}
+	if err := fh.Sync(); err != nil {
+		return common.Hash{}, "", err
+	}
+	if _, err := fh.Seek(0, io.SeekStart); err != nil {
+		return common.Hash{}, "", err
+	}
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, fh); err != nil {
+		return common.Hash{}, "", err
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, era.Filename(network, int(first/step), root))); err != nil {
+		return common.Hash{}, "", err
+	}
+	return root, common.Bytes2Hex(hasher.Sum(nil)), nil
+}
+
+// ImportHistory imports the blocks along with their receipts from the archives
+// of the given network in the given directory. Every archive is checked
+// against its checksum and verified before any of its blocks is imported.
+// Blocks already present in the chain are skipped.
+func ImportHistory(chain *core.BlockChain, dir string, network string) error {
+	names, err := era.ReadDir(dir, network)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no %s archives found in %s", network, dir)
+	}
+	blob, err := os.ReadFile(filepath.Join(dir, "checksums.txt"))
+	if err != nil {
+		return err
+	}
+	checksums := strings.Split(strings.TrimSpace(string(blob)), "\n")
+	if len(checksums) != len(names) {
+		return fmt.Errorf("checksum count mismatch: have %d archives, %d checksums", len(names), len(checksums))
+	}
+	var (
+		start    = time.Now()
+		reported = time.Now()
+		imported uint64
+	)
+	for i, name := range names {
+		path := filepath.Join(dir, name)
+		n, err := importHistoryArchive(chain, path, checksums[i])
+		if err != nil {
+			return fmt.Errorf("error importing %s: %w", name, err)
+		}
+		imported += n
+
+		if time.Since(reported) > 8*time.Second {
+			log.Info("Importing blockchain history", "file", name, "imported", imported, "elapsed", common.PrettyDuration(time.Since(start)))
+			reported = time.Now()
+		}
+	}
+	log.Info("Imported blockchain history", "dir", dir, "blocks", imported, "head", chain.CurrentFastBlock().NumberU64(), "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// importHistoryArchive verifies and imports a single archive, returning the
+// number of blocks imported.
+func importHistoryArchive(chain *core.BlockChain, path string, checksum string) (uint64, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer fh.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, fh); err != nil {
+		return 0, err
+	}
+	if have := common.Bytes2Hex(hasher.Sum(nil)); have != checksum {
+		return 0, fmt.Errorf("checksum mismatch: have %s, want %s", have, checksum)
+	}
+	e, err := era.From(fh)
+	if err != nil {
+		return 0, err
+	}
+	if err := e.Verify(); err != nil {
+		return 0, err
+	}
+	var (
+		blocks   = make([]*types.Block, 0, importBatchSize)
+		receipts = make([]types.Receipts, 0, importBatchSize)
+		tds      = make([]*big.Int, 0, importBatchSize)
+		imported uint64
+	)
+	flush := func() error {
+		if len(blocks) == 0 {
+			return nil
+		}
+		headers := make([]*types.Header, len(blocks))
+		for i, block := range blocks {
+			headers[i] = block.Header()
+		}
+		if _, err := chain.InsertHeaderChain(headers, 100); err != nil {
+			return err
+		}
+		// The archived total difficulty is covered by the accumulator, make sure
+		// it matches the one of the local chain.
+		last := blocks[len(blocks)-1]
+		if td := chain.GetTd(last.Hash(), last.NumberU64()); td == nil || td.Cmp(tds[len(tds)-1]) != 0 {
+			return fmt.Errorf("total difficulty mismatch at #%d: have %v, want %v", last.NumberU64(), td, tds[len(tds)-1])
+		}
+		if _, err := chain.InsertReceiptChain(blocks, receipts, math.MaxUint64); err != nil {
+			return err
+		}
+		imported += uint64(len(blocks))
+		blocks, receipts, tds = blocks[:0], receipts[:0], tds[:0]
+		return nil
+	}
+	for n := e.Start(); n < e.Start()+e.Count(); n++ {
+		block, rs, td, err := e.GetBlockByNumber(n)
+		if err != nil {
+			return 0, err
+		}
+		// Skip the blocks already present, they must match the local chain
+		if n <= chain.CurrentFastBlock().NumberU64() {
+			if hash := chain.GetCanonicalHash(n); hash != block.Hash() {
+				return 0, fmt.Errorf("block #%d mismatch: have %x, local %x", n, block.Hash(), hash)
+			}
+			continue
+		}
+		blocks, receipts, tds = append(blocks, block), append(receipts, rs), append(tds, td)
+		if len(blocks) == importBatchSize {
+			if err := flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return 0, err
+	}
+	return imported, nil
+}
+
+// ExportSnapshot exports the state of the given block from the snapshot into the
+// specified file, truncating any data already present in the file. If the file
+// ends with .gz, the output will be gzipped.
+func ExportSnapshot(db ethdb.Database, fn string, header *types.Header) error {
+	log.Info("Exporting state snapshot", "file", fn, "number", header.Number, "hash", header.Hash())
+
+	head := rawdb.ReadHeadBlock(db)
+	if head == nil {
+		return errors.New("no head block")
+	}
+	snaptree, err := snapshot.New(db, trie.NewDatabase(db), 256, head.Root(), false, false, false)
+	if err != nil {
+		return err
+	}
+	// Open the file handle and potentially wrap with a gzip stream
+	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	writer := bufio.NewWriter(fh)
+	var out io.Writer = writer
+	if strings.HasSuffix(fn, ".gz") {
+		out = gzip.NewWriter(writer)
+	}
+	if err := snapshot.Export(out, snaptree, db, &snapshot.ExportHeader{
+		Root:   header.Root,
+		Number: header.Number.Uint64(),
+		Hash:   header.Hash(),
+	}); err != nil {
+		return err
+	}
+	if gz, ok := out.(*gzip.Writer); ok {
+		if err := gz.Close(); err != nil {
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ImportSnapshot imports the state from the specified snapshot export into the
+// database, verifying it against the state root of the export. If the block the
+// state belongs to is known, it's made the head block, so that the node can
+// resume from it.
+func ImportSnapshot(db ethdb.Database, fn string) error {
+	log.Info("Importing state snapshot", "file", fn)
+
+	// The imported tries are regenerated keyed by hash
+	if rawdb.ReadStateScheme(db) == rawdb.PathScheme {
+		return errors.New("snapshot import is not supported by the path scheme")
+	}
+	// Open the file handle and potentially unwrap the gzip stream
+	fh, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var reader io.Reader = bufio.NewReader(fh)
+	if strings.HasSuffix(fn, ".gz") {
+		if reader, err = gzip.NewReader(reader); err != nil {
+			return err
+		}
+	}
+	// Refuse the state of a different chain before the local snapshot is dropped
+	check := func(header *snapshot.ExportHeader) error {
+		if local := rawdb.ReadHeader(db, header.Hash, header.Number); local != nil && local.Root != header.Root {
+			return fmt.Errorf("state root mismatch with local block %d: have %#x, want %#x", header.Number, header.Root, local.Root)
+		}
+		return nil
+	}
+	header, err := snapshot.Import(reader, db, check)
+	if err != nil {
+		return err
+	}
+	// Move the chain head to the block of the imported state, if available
+	if hash := rawdb.ReadCanonicalHash(db, header.Number); hash != header.Hash || !rawdb.HasBody(db, hash, header.Number) {
+		log.Warn("Block of imported state unavailable, import the chain history first", "number", header.Number, "hash", header.Hash)
+		return nil
+	}
+	if block := rawdb.ReadHeadBlock(db); block != nil && block.NumberU64() >= header.Number {
+		log.Warn("Chain head beyond imported state, keeping it", "head", block.NumberU64(), "number", header.Number)
+		return nil
+	}
+	rawdb.WriteHeadBlockHash(db, header.Hash)
+	if number := rawdb.ReadHeaderNumber(db, rawdb.ReadHeadFastBlockHash(db)); number == nil || *number < header.Number {
+		rawdb.WriteHeadFastBlockHash(db, header.Hash)
+	}
+	if number := rawdb.ReadHeaderNumber(db, rawdb.ReadHeadHeaderHash(db)); number == nil || *number < header.Number {
+		rawdb.WriteHeadHeaderHash(db, header.Hash)
+	}
+	log.Info("Set head block to imported state", "number", header.Number, "hash", header.Hash)
+	return nil
+}
+
 // ImportPreimages imports a batch of exported hash preimages into the database.
 // It's a part of the deprecated functionality, should be removed in the future.
 func ImportPreimages(db ethdb.Database, fn string) error {
@@ -0,0 +1,125 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/consensus/ethash"
+	"github.com/foreverbit/biternal/core"
+	"github.com/foreverbit/biternal/core/rawdb"
+	"github.com/foreverbit/biternal/core/types"
+	"github.com/foreverbit/biternal/core/vm"
+	"github.com/foreverbit/biternal/crypto"
+	"github.com/foreverbit/biternal/internal/era"
+	"github.com/foreverbit/biternal/params"
+	"github.com/foreverbit/biternal/trie"
+)
+
+// Tests that the chain history exported into archives can be imported into a
+// fresh database, and that tampered archives are rejected.
+func TestHistoryExportImport(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		genesis = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{address: {Balance: big.NewInt(1000000000000000000)}},
+		}
+		signer = types.LatestSigner(genesis.Config)
+		engine = ethash.NewFaker()
+		db     = rawdb.NewMemoryDatabase()
+		gblock = genesis.MustCommit(db)
+	)
+	blocks, _ := core.GenerateChain(genesis.Config, gblock, engine, db, 40, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(address), common.Address{0x01}, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), signer, key)
+		b.AddTx(tx)
+	})
+	chain, err := core.NewBlockChain(db, nil, genesis.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	// Export the history into archives of 16 blocks
+	dir := t.TempDir()
+	if err := ExportHistory(chain, dir, "mainnet", 0, 40, 16); err != nil {
+		t.Fatalf("failed to export history: %v", err)
+	}
+	names, err := era.ReadDir(dir, "mainnet")
+	if err != nil {
+		t.Fatalf("failed to list archives: %v", err)
+	}
+	if len(names) != 3 {
+		t.Fatalf("archive count mismatch: have %d, want 3", len(names))
+	}
+	// Import the archives into a fresh freezer backed database
+	fresh, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	defer fresh.Close()
+	genesis.MustCommit(fresh)
+
+	imported, err := core.NewBlockChain(fresh, nil, genesis.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer imported.Stop()
+	if err := ImportHistory(imported, dir, "mainnet"); err != nil {
+		t.Fatalf("failed to import history: %v", err)
+	}
+	if head := imported.CurrentFastBlock().NumberU64(); head != 40 {
+		t.Fatalf("head mismatch: have %d, want 40", head)
+	}
+	for _, block := range blocks {
+		have := imported.GetBlockByNumber(block.NumberU64())
+		if have == nil || have.Hash() != block.Hash() {
+			t.Fatalf("block #%d mismatch", block.NumberU64())
+		}
+		want := chain.GetReceiptsByHash(block.Hash())
+		receipts := imported.GetReceiptsByHash(block.Hash())
+		if len(receipts) != len(want) || types.DeriveSha(receipts, trie.NewStackTrie(nil)) != types.DeriveSha(want, trie.NewStackTrie(nil)) {
+			t.Fatalf("receipts of block #%d mismatch", block.NumberU64())
+		}
+	}
+	// Importing again is a no-op
+	if err := ImportHistory(imported, dir, "mainnet"); err != nil {
+		t.Fatalf("failed to reimport history: %v", err)
+	}
+	// Tampering with an archive must be detected by the checksum
+	path := filepath.Join(dir, names[1])
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read archive: %v", err)
+	}
+	blob[len(blob)/2] ^= 0xff
+	if err := os.WriteFile(path, blob, 0644); err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+	err = ImportHistory(imported, dir, "mainnet")
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("tampered archive not rejected: %v", err)
+	}
+}
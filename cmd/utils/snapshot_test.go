@@ -0,0 +1,119 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/consensus/ethash"
+	"github.com/foreverbit/biternal/core"
+	"github.com/foreverbit/biternal/core/rawdb"
+	"github.com/foreverbit/biternal/core/types"
+	"github.com/foreverbit/biternal/core/vm"
+	"github.com/foreverbit/biternal/crypto"
+	"github.com/foreverbit/biternal/params"
+)
+
+// Tests that a fresh node can be bootstrapped from the chain history archives
+// and a snapshot export, and continue processing blocks on top.
+func TestSnapshotExportImport(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		genesis = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{address: {Balance: big.NewInt(1000000000000000000)}},
+		}
+		signer = types.LatestSigner(genesis.Config)
+		engine = ethash.NewFaker()
+		db     = rawdb.NewMemoryDatabase()
+		gblock = genesis.MustCommit(db)
+	)
+	blocks, _ := core.GenerateChain(genesis.Config, gblock, engine, db, 41, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(address), common.Address{byte(i)}, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), signer, key)
+		b.AddTx(tx)
+	})
+	chain, err := core.NewBlockChain(db, nil, genesis.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks[:40]); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	// Export the history and the state of the head block
+	dir := t.TempDir()
+	if err := ExportHistory(chain, dir, "mainnet", 0, 40, 16); err != nil {
+		t.Fatalf("failed to export history: %v", err)
+	}
+	chain.Stop()
+
+	fn := filepath.Join(dir, "state.snap.gz")
+	if err := ExportSnapshot(db, fn, blocks[39].Header()); err != nil {
+		t.Fatalf("failed to export snapshot: %v", err)
+	}
+	// The tries of the path scheme can't be regenerated from the export
+	pathdb := rawdb.NewMemoryDatabase()
+	rawdb.WriteStateScheme(pathdb, rawdb.PathScheme)
+	if err := ImportSnapshot(pathdb, fn); err == nil {
+		t.Fatalf("snapshot imported into a path scheme database")
+	}
+	// Bootstrap a fresh node from the exported history and state
+	fresh, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	defer fresh.Close()
+	genesis.MustCommit(fresh)
+
+	imported, err := core.NewBlockChain(fresh, nil, genesis.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	if err := ImportHistory(imported, dir, "mainnet"); err != nil {
+		t.Fatalf("failed to import history: %v", err)
+	}
+	imported.Stop()
+
+	if err := ImportSnapshot(fresh, fn); err != nil {
+		t.Fatalf("failed to import snapshot: %v", err)
+	}
+	imported, err = core.NewBlockChain(fresh, nil, genesis.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer imported.Stop()
+
+	if head := imported.CurrentBlock(); head.Hash() != blocks[39].Hash() {
+		t.Fatalf("head mismatch: have #%d, want #%d", head.NumberU64(), blocks[39].NumberU64())
+	}
+	if _, err := imported.InsertChain(blocks[40:]); err != nil {
+		t.Fatalf("failed to process block on imported state: %v", err)
+	}
+	statedb, err := imported.State()
+	if err != nil {
+		t.Fatalf("failed to open head state: %v", err)
+	}
+	if have, want := statedb.GetBalance(common.Address{40}), big.NewInt(1000); have.Cmp(want) != 0 {
+		t.Fatalf("balance mismatch: have %v, want %v", have, want)
+	}
+	if imported.Snapshots() == nil || imported.Snapshots().Snapshot(blocks[40].Root()) == nil {
+		t.Fatalf("imported snapshot not in use")
+	}
+}
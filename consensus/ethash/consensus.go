@@ -650,6 +650,17 @@ var (
 // reward. The total reward consists of the static block reward and rewards for
 // included uncles. The coinbase of each uncle block is also rewarded.
 func accumulateRewards(config *params.ChainConfig, state *state.StateDB, header *types.Header, uncles []*types.Header) {
+	reward, uncleRewards := BlockRewards(config, header, uncles)
+	for i, uncle := range uncles {
+		state.AddBalance(uncle.Coinbase, uncleRewards[i])
+	}
+	state.AddBalance(header.Coinbase, reward)
+}
+
+// BlockRewards returns the mining reward of the coinbase of the given block,
+// including the rewards for the included uncles, and the rewards of the uncle
+// coinbases.
+func BlockRewards(config *params.ChainConfig, header *types.Header, uncles []*types.Header) (*big.Int, []*big.Int) {
 	// Select the correct block reward based on chain progression
 	blockReward := FrontierBlockReward
 	if config.IsByzantium(header.Number) {
@@ -659,17 +670,18 @@ func accumulateRewards(config *params.ChainConfig, state *state.StateDB, header
 		blockReward = ConstantinopleBlockReward
 	}
 	// Accumulate the rewards for the miner and any included uncles
-	reward := new(big.Int).Set(blockReward)
-	r := new(big.Int)
-	for _, uncle := range uncles {
-		r.Add(uncle.Number, big8)
+	var (
+		reward       = new(big.Int).Set(blockReward)
+		uncleRewards = make([]*big.Int, len(uncles))
+	)
+	for i, uncle := range uncles {
+		r := new(big.Int).Add(uncle.Number, big8)
 		r.Sub(r, header.Number)
 		r.Mul(r, blockReward)
 		r.Div(r, big8)
-		state.AddBalance(uncle.Coinbase, r)
+		uncleRewards[i] = r
 
-		r.Div(blockReward, big32)
-		reward.Add(reward, r)
+		reward.Add(reward, new(big.Int).Div(blockReward, big32))
 	}
-	state.AddBalance(header.Coinbase, reward)
+	return reward, uncleRewards
 }
@@ -17,24 +17,120 @@
 package asm
 
 import (
+	"encoding/hex"
 	"fmt"
 	"math/big"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 
+	"github.com/foreverbit/biternal/common"
 	"github.com/foreverbit/biternal/common/math"
 	"github.com/foreverbit/biternal/core/vm"
 )
 
+// maxExpansions is the maximum number of macro expansions and file inclusions
+// a single program may perform, protecting against recursive definitions.
+const maxExpansions = 4096
+
+// instructionKind are the different types of items a program is built from.
+type instructionKind int
+
+const (
+	opcodeInstr instructionKind = iota // a single opcode
+	pushInstr                          // a push of an operand expression
+	dataInstr                          // raw bytes of a data section
+	markInstr                          // zero sized marker of a label or section start
+)
+
+// instruction is a single item of the compiled program.
+type instruction struct {
+	kind  instructionKind
+	op    vm.OpCode // opcode of plain instructions
+	data  []byte    // raw bytes of data instructions
+	expr  []token   // operand expression of pushes
+	width int       // operand width of pushes
+	auto  bool      // whether the push width is picked by the compiler
+
+	section *section // section the instruction belongs to, nil for the main code
+	pc      int      // offset of the instruction in the binary
+	src     token    // token the instruction was compiled from
+}
+
+// size returns the number of bytes the instruction occupies in the binary.
+func (ins *instruction) size() int {
+	switch ins.kind {
+	case pushInstr:
+		return 1 + ins.width
+	case dataInstr:
+		return len(ins.data)
+	case markInstr:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// section is a named block of code or data within the program.
+type section struct {
+	name   string
+	code   bool // code sections have their labels relative to the section start
+	offset int  // offset of the section in the binary
+	size   int  // number of bytes in the section
+}
+
+// constant is a named expression, evaluated wherever it is referenced.
+type constant struct {
+	expr []token
+	def  token
+}
+
+// macro is a named sequence of lines, expanded wherever it is invoked.
+type macro struct {
+	params []string        // parameter names, substituted by the invocation arguments
+	body   []token         // tokens of the macro lines
+	labels map[string]bool // labels defined by the macro, scoped to each expansion
+}
+
+// SourceLocation is the position of a compiled instruction in the source.
+type SourceLocation struct {
+	File string // name of the source file, empty if unnamed
+	Line int    // line number, starting at 1
+}
+
 // Compiler contains information about the parsed source
 // and holds the tokens for the program.
+//
+// Besides opcodes, numbers, strings and labels, the compiler understands the
+// following directives:
+//
+//	.const NAME expr         defines a named constant
+//	.macro NAME [PARAM ...]  starts a macro definition, ended by .endm
+//	.include "file"          includes another source file
+//	.code NAME               starts a code section, ended by .end
+//	.data NAME               starts a data section, ended by .end
+//
+// Push and jump operands are expressions of numbers, constants, labels and the
+// size(section) and offset(section) functions, combined with +, - and
+// parentheses. Unless an explicit PUSHn is used, the smallest push fitting the
+// operand is picked. Labels within code sections are relative to the start of
+// the section, so the section can be deployed as a contract of its own.
 type Compiler struct {
 	tokens []token
-	binary []interface{}
+	pos    int
+
+	file       string               // name of the fed source
+	consts     map[string]*constant // named constants
+	macros     map[string]*macro    // macro definitions
+	expansions int                  // number of macro expansions and inclusions so far
 
-	labels map[string]int
+	instrs   []*instruction          // instructions of the program
+	labels   map[string]*instruction // label definitions
+	sections map[string]*section     // sections of the program
+	section  *section                // section currently being compiled
 
-	pc, pos int
+	srcmap map[uint64]SourceLocation
 
 	debug bool
 }
@@ -42,49 +138,33 @@ type Compiler struct {
 // NewCompiler returns a new allocated compiler.
 func NewCompiler(debug bool) *Compiler {
 	return &Compiler{
-		labels: make(map[string]int),
-		debug:  debug,
+		consts:   make(map[string]*constant),
+		macros:   make(map[string]*macro),
+		labels:   make(map[string]*instruction),
+		sections: make(map[string]*section),
+		debug:    debug,
 	}
 }
 
+// SetSource sets the name of the source file fed to the compiler. It is used
+// to resolve the paths of included files and is reported in errors and in the
+// source map.
+func (c *Compiler) SetSource(name string) {
+	c.file = name
+}
+
 // Feed feeds tokens in to ch and are interpreted by
 // the compiler.
 //
 // feed is the first pass in the compile stage as it
-// collects the used labels in the program and keeps a
-// program counter which is used to determine the locations
-// of the jump dests. The labels can than be used in the
-// second stage to push labels and determine the right
-// position.
+// collects the tokens of the program. Labels, macros and
+// includes are resolved during compilation.
 func (c *Compiler) Feed(ch <-chan token) {
-	var prev token
 	for i := range ch {
-		switch i.typ {
-		case number:
-			num := math.MustParseBig256(i.text).Bytes()
-			if len(num) == 0 {
-				num = []byte{0}
-			}
-			c.pc += len(num)
-		case stringValue:
-			c.pc += len(i.text) - 2
-		case element:
-			c.pc++
-		case labelDef:
-			c.labels[i.text] = c.pc
-			c.pc++
-		case label:
-			c.pc += 4
-			if prev.typ == element && isJump(prev.text) {
-				c.pc++
-			}
+		if i.file == "" {
+			i.file = c.file
 		}
-
 		c.tokens = append(c.tokens, i)
-		prev = i
-	}
-	if c.debug {
-		fmt.Fprintln(os.Stderr, "found", len(c.labels), "labels")
 	}
 }
 
@@ -93,7 +173,9 @@ func (c *Compiler) Feed(ch <-chan token) {
 // and an error if it failed.
 //
 // compile is the second stage in the compile phase
-// which compiles the tokens to EVM instructions.
+// which compiles the tokens to EVM instructions. Once
+// all the instructions are known, the widths of the
+// pushes are grown until all the operands fit.
 func (c *Compiler) Compile() (string, []error) {
 	var errors []error
 	// continue looping over the tokens until
@@ -103,20 +185,57 @@ func (c *Compiler) Compile() (string, []error) {
 			errors = append(errors, err)
 		}
 	}
-
+	if c.section != nil {
+		errors = append(errors, compileErr(c.tokens[len(c.tokens)-1], eof.String(), ".end"))
+	}
+	if c.debug {
+		fmt.Fprintln(os.Stderr, "found", len(c.labels), "labels")
+	}
+	// Grow the automatically sized pushes until all operands fit. Pushes only
+	// ever grow, so the layout is guaranteed to settle.
+	for changed := true; changed; {
+		c.layout()
+
+		changed = false
+		for _, ins := range c.instrs {
+			if ins.kind != pushInstr || !ins.auto {
+				continue
+			}
+			if v, err := c.eval(ins.expr, nil); err == nil {
+				if n := len(v.Bytes()); n > ins.width {
+					ins.width, changed = n, true
+				}
+			}
+		}
+	}
 	// turn the binary to hex
 	var bin strings.Builder
-	for _, v := range c.binary {
-		switch v := v.(type) {
-		case vm.OpCode:
-			bin.WriteString(fmt.Sprintf("%x", []byte{byte(v)}))
-		case []byte:
-			bin.WriteString(fmt.Sprintf("%x", v))
+
+	c.srcmap = make(map[uint64]SourceLocation)
+	for _, ins := range c.instrs {
+		code, err := c.assemble(ins)
+		if err != nil {
+			errors = append(errors, err)
+			continue
+		}
+		if len(code) == 0 {
+			continue
 		}
+		if c.debug {
+			fmt.Printf("%d: %x\n", ins.pc, code)
+		}
+		c.srcmap[uint64(ins.pc)] = SourceLocation{File: ins.src.file, Line: ins.src.lineno + 1}
+		bin.WriteString(hex.EncodeToString(code))
 	}
 	return bin.String(), errors
 }
 
+// SourceMap returns the source locations of the compiled instructions, keyed
+// by their offset in the binary.
+func (c *Compiler) SourceMap() map[uint64]SourceLocation {
+	return c.srcmap
+}
+
 // next returns the next token and increments the
 // position.
 func (c *Compiler) next() token {
@@ -125,119 +244,539 @@ func (c *Compiler) next() token {
 	return token
 }
 
+// operand returns the remaining tokens of the current line, leaving the
+// position at the end of the line.
+func (c *Compiler) operand() []token {
+	start := c.pos
+	for c.pos < len(c.tokens) && !isLineEnd(c.tokens[c.pos]) {
+		c.pos++
+	}
+	return c.tokens[start:c.pos]
+}
+
+// skipLine skips the remaining tokens of the current line.
+func (c *Compiler) skipLine() {
+	c.operand()
+	if c.pos < len(c.tokens) {
+		c.pos++
+	}
+}
+
+// splice inserts the given lines after the current line.
+func (c *Compiler) splice(lines []token) {
+	i := c.pos
+	for i < len(c.tokens) && !isLineEnd(c.tokens[i]) {
+		i++
+	}
+	if i < len(c.tokens) {
+		i++
+	}
+	c.tokens = append(c.tokens[:i], append(lines, c.tokens[i:]...)...)
+}
+
+// add appends an instruction to the current section of the program.
+func (c *Compiler) add(ins *instruction) {
+	ins.section = c.section
+	c.instrs = append(c.instrs, ins)
+}
+
 // compileLine compiles a single line instruction e.g.
 // "push 1", "jump @label".
 func (c *Compiler) compileLine() error {
 	n := c.next()
+	if n.typ == eof {
+		return nil
+	}
 	if n.typ != lineStart {
+		c.skipLine()
 		return compileErr(n, n.typ.String(), lineStart.String())
 	}
 
-	lvalue := c.next()
-	switch lvalue.typ {
-	case eof:
+	var err error
+	switch lvalue := c.next(); lvalue.typ {
+	case eof, lineEnd:
 		return nil
 	case element:
-		if err := c.compileElement(lvalue); err != nil {
-			return err
-		}
+		err = c.compileElement(lvalue)
 	case labelDef:
-		c.compileLabel()
-	case lineEnd:
-		return nil
+		err = c.compileLabel(lvalue)
+	case directive:
+		err = c.compileDirective(lvalue)
+	case number, stringValue:
+		err = c.compileData(lvalue)
 	default:
-		return compileErr(lvalue, lvalue.text, fmt.Sprintf("%v or %v", labelDef, element))
+		err = compileErr(lvalue, lvalue.text, fmt.Sprintf("%v, %v or %v", labelDef, element, directive))
 	}
-
-	if n := c.next(); n.typ != lineEnd {
+	if err != nil {
+		c.skipLine()
+		return err
+	}
+	if n := c.next(); !isLineEnd(n) {
+		c.skipLine()
 		return compileErr(n, n.text, lineEnd.String())
 	}
-
 	return nil
 }
 
-// compileNumber compiles the number to bytes
-func (c *Compiler) compileNumber(element token) (int, error) {
-	num := math.MustParseBig256(element.text).Bytes()
-	if len(num) == 0 {
-		num = []byte{0}
-	}
-	c.pushBin(num)
-	return len(num), nil
-}
-
 // compileElement compiles the element (push & label or both)
 // to a binary representation and may error if incorrect statements
 // where fed.
 func (c *Compiler) compileElement(element token) error {
-	// check for a jump. jumps must be read and compiled
-	// from right to left.
-	if isJump(element.text) {
-		rvalue := c.next()
-		switch rvalue.typ {
-		case number:
-			// TODO figure out how to return the error properly
-			c.compileNumber(rvalue)
-		case stringValue:
-			// strings are quoted, remove them.
-			c.pushBin(rvalue.text[1 : len(rvalue.text)-2])
-		case label:
-			c.pushBin(vm.PUSH4)
-			pos := big.NewInt(int64(c.labels[rvalue.text])).Bytes()
-			pos = append(make([]byte, 4-len(pos)), pos...)
-			c.pushBin(pos)
-		case lineEnd:
-			c.pos--
-		default:
-			return compileErr(rvalue, rvalue.text, "number, string or label")
+	if m, ok := c.macros[element.text]; ok {
+		return c.expandMacro(element, m)
+	}
+	if c.section != nil && !c.section.code {
+		return compileErr(element, element.text, "data")
+	}
+	switch {
+	case isPush(element.text):
+		// handle pushes, the width is picked by the compiler.
+		expr := c.operand()
+		if len(expr) == 0 {
+			return compileErr(c.tokens[c.pos], c.tokens[c.pos].text, "number, string or label")
 		}
-		// push the operation
-		c.pushBin(toBinary(element.text))
+		return c.compilePush(element, expr, 0)
+
+	case isJump(element.text):
+		// jumps may carry the destination to push before jumping.
+		if expr := c.operand(); len(expr) > 0 {
+			if err := c.compilePush(element, expr, 0); err != nil {
+				return err
+			}
+		}
+		c.add(&instruction{kind: opcodeInstr, op: toBinary(element.text), src: element})
 		return nil
-	} else if isPush(element.text) {
-		// handle pushes. pushes are read from left to right.
-		var value []byte
+	}
+	op := toBinary(element.text)
+	if op == vm.STOP && !strings.EqualFold(element.text, vm.STOP.String()) {
+		return compileErr(element, element.text, "instruction or macro")
+	}
+	// explicitly sized pushes may carry their operand.
+	if op.IsPush() {
+		if expr := c.operand(); len(expr) > 0 {
+			return c.compilePush(element, expr, int(op-vm.PUSH1)+1)
+		}
+	}
+	c.add(&instruction{kind: opcodeInstr, op: op, src: element})
+	return nil
+}
 
-		rvalue := c.next()
-		switch rvalue.typ {
+// compilePush adds a push of the given operand expression. A zero width means
+// the smallest width fitting the operand is used.
+func (c *Compiler) compilePush(element token, expr []token, width int) error {
+	ins := &instruction{kind: pushInstr, expr: expr, width: width, src: element}
+	if len(expr) == 1 && expr[0].typ == stringValue {
+		// strings are pushed as they are, pick their width right away.
+		str := unquote(expr[0].text)
+		if len(str) == 0 || len(str) > 32 {
+			return fmt.Errorf("%s type error: unsupported string or number with size > 32", location(expr[0]))
+		}
+		if ins.width == 0 {
+			ins.width = len(str)
+		}
+	}
+	if ins.width == 0 {
+		ins.width, ins.auto = 1, true
+	}
+	c.add(ins)
+	return nil
+}
+
+// compileLabel pushes a jumpdest to the binary slice, or marks the
+// position within a data section.
+func (c *Compiler) compileLabel(def token) error {
+	if _, ok := c.labels[def.text]; ok {
+		return fmt.Errorf("%s label error: %s already defined", location(def), def.text)
+	}
+	ins := &instruction{kind: opcodeInstr, op: vm.JUMPDEST, src: def}
+	if c.section != nil && !c.section.code {
+		ins.kind = markInstr
+	}
+	c.labels[def.text] = ins
+	c.add(ins)
+	return nil
+}
+
+// compileData adds the raw numbers and strings of a data section line.
+func (c *Compiler) compileData(first token) error {
+	if c.section == nil || c.section.code {
+		return compileErr(first, first.text, fmt.Sprintf("%v or %v", labelDef, element))
+	}
+	for _, tok := range append([]token{first}, c.operand()...) {
+		var data []byte
+		switch tok.typ {
+		case stringValue:
+			data = []byte(unquote(tok.text))
 		case number:
-			value = math.MustParseBig256(rvalue.text).Bytes()
-			if len(value) == 0 {
-				value = []byte{0}
+			// hex numbers are kept as written, including leading zeroes.
+			if text := strings.ToLower(tok.text); strings.HasPrefix(text, "0x") {
+				if len(text)%2 == 1 {
+					text = "0" + text[2:]
+				} else {
+					text = text[2:]
+				}
+				data, _ = hex.DecodeString(text)
+			} else {
+				num, ok := math.ParseBig256(tok.text)
+				if !ok {
+					return compileErr(tok, tok.text, "number of 32 bytes at most")
+				}
+				if data = num.Bytes(); len(data) == 0 {
+					data = []byte{0}
+				}
 			}
-		case stringValue:
-			value = []byte(rvalue.text[1 : len(rvalue.text)-1])
-		case label:
-			value = big.NewInt(int64(c.labels[rvalue.text])).Bytes()
-			value = append(make([]byte, 4-len(value)), value...)
 		default:
-			return compileErr(rvalue, rvalue.text, "number, string or label")
+			return compileErr(tok, tok.text, fmt.Sprintf("%v or %v", number, stringValue))
+		}
+		c.add(&instruction{kind: dataInstr, data: data, src: tok})
+	}
+	return nil
+}
+
+// compileDirective compiles a compiler directive, e.g. ".const".
+func (c *Compiler) compileDirective(dir token) error {
+	switch strings.ToLower(dir.text) {
+	case ".const":
+		name := c.next()
+		if name.typ != element {
+			return compileErr(name, name.text, "constant name")
+		}
+		if _, ok := c.consts[name.text]; ok {
+			return fmt.Errorf("%s constant error: %s already defined", location(name), name.text)
+		}
+		expr := c.operand()
+		if len(expr) == 0 {
+			return compileErr(c.tokens[c.pos], c.tokens[c.pos].text, "expression")
+		}
+		c.consts[name.text] = &constant{expr: expr, def: name}
+		return nil
+
+	case ".macro":
+		return c.compileMacro(dir)
+
+	case ".include":
+		return c.compileInclude(dir)
+
+	case ".code", ".data":
+		if c.section != nil {
+			return compileErr(dir, dir.text, ".end")
+		}
+		name := c.next()
+		if name.typ != element {
+			return compileErr(name, name.text, "section name")
+		}
+		if _, ok := c.sections[name.text]; ok {
+			return fmt.Errorf("%s section error: %s already defined", location(name), name.text)
 		}
+		c.section = &section{name: name.text, code: strings.EqualFold(dir.text, ".code")}
+		c.sections[name.text] = c.section
 
-		if len(value) > 32 {
-			return fmt.Errorf("%d type error: unsupported string or number with size > 32", rvalue.lineno)
+		// mark the start of the section, even if it's empty
+		c.add(&instruction{kind: markInstr, src: dir})
+		return nil
+
+	case ".end":
+		if c.section == nil {
+			return compileErr(dir, dir.text, ".code or .data")
 		}
+		c.section = nil
+		return nil
+	}
+	return compileErr(dir, dir.text, ".const, .macro, .include, .code, .data or .end")
+}
 
-		c.pushBin(vm.OpCode(int(vm.PUSH1) - 1 + len(value)))
-		c.pushBin(value)
+// compileMacro collects the lines of a macro definition up to the closing
+// .endm directive.
+func (c *Compiler) compileMacro(dir token) error {
+	name := c.next()
+	if name.typ != element {
+		return compileErr(name, name.text, "macro name")
+	}
+	if _, ok := c.macros[name.text]; ok {
+		return fmt.Errorf("%s macro error: %s already defined", location(name), name.text)
+	}
+	m := &macro{labels: make(map[string]bool)}
+	for _, param := range c.operand() {
+		if param.typ != element {
+			return compileErr(param, param.text, "parameter name")
+		}
+		m.params = append(m.params, param.text)
+	}
+	if n := c.next(); n.typ != lineEnd {
+		return compileErr(n, n.typ.String(), ".endm")
+	}
+	start := c.pos
+	for ; c.pos < len(c.tokens); c.pos++ {
+		tok := c.tokens[c.pos]
+		switch {
+		case tok.typ == eof:
+			return compileErr(tok, tok.typ.String(), ".endm")
+		case tok.typ == directive && strings.EqualFold(tok.text, ".macro"):
+			return compileErr(tok, tok.text, ".endm")
+		case tok.typ == directive && strings.EqualFold(tok.text, ".endm"):
+			// the macro ends with the line before, without the .endm line start
+			m.body = c.tokens[start : c.pos-1]
+			c.macros[name.text] = m
+			c.pos++
+			return nil
+		case tok.typ == labelDef:
+			m.labels[tok.text] = true
+		}
+	}
+	return compileErr(dir, eof.String(), ".endm")
+}
+
+// expandMacro inserts the lines of a macro after the current line, with the
+// parameters replaced by the invocation arguments. Labels defined within the
+// macro are renamed, so that each expansion has its own.
+func (c *Compiler) expandMacro(call token, m *macro) error {
+	args := c.operand()
+	if len(args) != len(m.params) {
+		return fmt.Errorf("%s macro error: %s expects %d arguments, got %d", location(call), call.text, len(m.params), len(args))
+	}
+	for _, arg := range args {
+		if arg.typ == operator {
+			return compileErr(arg, arg.text, "number, string, label or constant")
+		}
+	}
+	if c.expansions++; c.expansions > maxExpansions {
+		return fmt.Errorf("%s macro error: too many expansions, recursive macro %s?", location(call), call.text)
+	}
+	suffix := "#" + strconv.Itoa(c.expansions)
+
+	body := make([]token, len(m.body))
+	for i, tok := range m.body {
+		switch {
+		case (tok.typ == labelDef || tok.typ == label) && m.labels[tok.text]:
+			tok.text += suffix
+		case tok.typ == element:
+			for j, param := range m.params {
+				if tok.text == param {
+					tok.typ, tok.text = args[j].typ, args[j].text
+					break
+				}
+			}
+		}
+		body[i] = tok
+	}
+	c.splice(body)
+	return nil
+}
+
+// compileInclude lexes the given file and inserts its lines after the current
+// line. Relative paths are resolved from the including file.
+func (c *Compiler) compileInclude(dir token) error {
+	path := c.next()
+	if path.typ != stringValue {
+		return compileErr(path, path.text, "file name")
+	}
+	if c.expansions++; c.expansions > maxExpansions {
+		return fmt.Errorf("%s include error: too many inclusions, recursive include?", location(path))
+	}
+	name := unquote(path.text)
+	if !filepath.IsAbs(name) && path.file != "" {
+		name = filepath.Join(filepath.Dir(path.file), name)
+	}
+	src, err := os.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("%s include error: %v", location(path), err)
+	}
+	var lines []token
+	for tok := range Lex(src, c.debug) {
+		// the included file ends with the line it's included from
+		if tok.typ == eof {
+			tok.typ = lineEnd
+		}
+		tok.file = name
+		lines = append(lines, tok)
+	}
+	c.splice(lines)
+	return nil
+}
+
+// layout assigns the offsets of the instructions and sections with the
+// current push widths.
+func (c *Compiler) layout() {
+	for _, sec := range c.sections {
+		sec.offset, sec.size = -1, 0
+	}
+	pc := 0
+	for _, ins := range c.instrs {
+		ins.pc = pc
+		if sec := ins.section; sec != nil {
+			if sec.offset < 0 {
+				sec.offset = pc
+			}
+			sec.size += ins.size()
+		}
+		pc += ins.size()
+	}
+}
+
+// assemble returns the binary representation of an instruction.
+func (c *Compiler) assemble(ins *instruction) ([]byte, error) {
+	switch ins.kind {
+	case opcodeInstr:
+		return []byte{byte(ins.op)}, nil
+	case dataInstr:
+		return ins.data, nil
+	case markInstr:
+		return nil, nil
+	}
+	var value []byte
+	if len(ins.expr) == 1 && ins.expr[0].typ == stringValue {
+		value = []byte(unquote(ins.expr[0].text))
 	} else {
-		c.pushBin(toBinary(element.text))
+		v, err := c.eval(ins.expr, nil)
+		if err != nil {
+			return nil, err
+		}
+		value = v.Bytes()
 	}
+	if len(value) > ins.width {
+		return nil, fmt.Errorf("%s type error: value %#x does not fit in PUSH%d", location(ins.src), value, ins.width)
+	}
+	return append([]byte{byte(vm.PUSH1) + byte(ins.width-1)}, common.LeftPadBytes(value, ins.width)...), nil
+}
 
+// eval evaluates an operand expression with the current layout. The seen set
+// holds the constants being evaluated, to detect recursive definitions.
+func (c *Compiler) eval(expr []token, seen map[string]bool) (*big.Int, error) {
+	if seen == nil {
+		seen = make(map[string]bool)
+	}
+	p := &exprParser{c: c, tokens: expr, seen: seen}
+	v, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, compileErr(p.tokens[p.pos], p.tokens[p.pos].text, "operator")
+	}
+	if v.Sign() < 0 || v.BitLen() > 256 {
+		return nil, fmt.Errorf("%s type error: value %v out of range", location(expr[0]), v)
+	}
+	return v, nil
+}
+
+// exprParser is a recursive descent parser evaluating operand expressions.
+type exprParser struct {
+	c      *Compiler
+	tokens []token
+	pos    int
+	seen   map[string]bool
+}
+
+// next returns the next token of the expression, or an end of line token if
+// the expression is exhausted.
+func (p *exprParser) next() token {
+	if p.pos >= len(p.tokens) {
+		end := token{typ: lineEnd}
+		if len(p.tokens) > 0 {
+			end.file, end.lineno = p.tokens[len(p.tokens)-1].file, p.tokens[len(p.tokens)-1].lineno
+		}
+		return end
+	}
+	p.pos++
+	return p.tokens[p.pos-1]
+}
+
+// expect consumes the next token, which must be the given operator.
+func (p *exprParser) expect(op string) error {
+	if tok := p.next(); tok.typ != operator || tok.text != op {
+		return compileErr(tok, tok.text, op)
+	}
 	return nil
 }
 
-// compileLabel pushes a jumpdest to the binary slice.
-func (c *Compiler) compileLabel() {
-	c.pushBin(vm.JUMPDEST)
+// sum evaluates a sequence of terms added or subtracted from left to right.
+func (p *exprParser) sum() (*big.Int, error) {
+	v, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for p.pos < len(p.tokens) {
+		op := p.tokens[p.pos]
+		if op.typ != operator || (op.text != "+" && op.text != "-") {
+			break
+		}
+		p.pos++
+
+		w, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		if op.text == "+" {
+			v = new(big.Int).Add(v, w)
+		} else {
+			v = new(big.Int).Sub(v, w)
+		}
+	}
+	return v, nil
 }
 
-// pushBin pushes the value v to the binary stack.
-func (c *Compiler) pushBin(v interface{}) {
-	if c.debug {
-		fmt.Printf("%d: %v\n", len(c.binary), v)
+// term evaluates a single number, constant, label, function or parenthesised
+// expression.
+func (p *exprParser) term() (*big.Int, error) {
+	tok := p.next()
+	switch tok.typ {
+	case number:
+		v, ok := math.ParseBig256(tok.text)
+		if !ok {
+			return nil, compileErr(tok, tok.text, "number of 32 bytes at most")
+		}
+		return v, nil
+
+	case label:
+		ins, ok := p.c.labels[tok.text]
+		if !ok {
+			return nil, fmt.Errorf("%s label error: %s not defined", location(tok), tok.text)
+		}
+		pc := ins.pc
+		if ins.section != nil && ins.section.code {
+			pc -= ins.section.offset
+		}
+		return big.NewInt(int64(pc)), nil
+
+	case operator:
+		if tok.text != "(" {
+			return nil, compileErr(tok, tok.text, "number, label, constant or (")
+		}
+		v, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		return v, p.expect(")")
+
+	case element:
+		if p.pos < len(p.tokens) && p.tokens[p.pos].text == "(" && (tok.text == "size" || tok.text == "offset") {
+			p.pos++
+			name := p.next()
+			sec, ok := p.c.sections[name.text]
+			if name.typ != element || !ok {
+				return nil, fmt.Errorf("%s section error: %s not defined", location(name), name.text)
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			if tok.text == "size" {
+				return big.NewInt(int64(sec.size)), nil
+			}
+			return big.NewInt(int64(sec.offset)), nil
+		}
+		def, ok := p.c.consts[tok.text]
+		if !ok {
+			return nil, fmt.Errorf("%s constant error: %s not defined", location(tok), tok.text)
+		}
+		if p.seen[tok.text] {
+			return nil, fmt.Errorf("%s constant error: %s defined recursively", location(def.def), tok.text)
+		}
+		p.seen[tok.text] = true
+		defer delete(p.seen, tok.text)
+
+		return p.c.eval(def.expr, p.seen)
 	}
-	c.binary = append(c.binary, v)
+	return nil, compileErr(tok, tok.text, "number, label or constant")
 }
 
 // isPush returns whether the string op is either any of
@@ -251,26 +790,46 @@ func isJump(op string) bool {
 	return strings.EqualFold(op, "JUMPI") || strings.EqualFold(op, "JUMP")
 }
 
+// isLineEnd returns whether the token terminates a line.
+func isLineEnd(t token) bool {
+	return t.typ == lineEnd || t.typ == eof
+}
+
 // toBinary converts text to a vm.OpCode
 func toBinary(text string) vm.OpCode {
 	return vm.StringToOp(strings.ToUpper(text))
 }
 
+// unquote strips the quotes of a string token.
+func unquote(text string) string {
+	return text[1 : len(text)-1]
+}
+
+// location returns the source position of a token in file:line format.
+func location(t token) string {
+	if t.file == "" {
+		return fmt.Sprintf("%d:", t.lineno+1)
+	}
+	return fmt.Sprintf("%s:%d:", t.file, t.lineno+1)
+}
+
 type compileError struct {
 	got  string
 	want string
 
+	file   string
 	lineno int
 }
 
 func (err compileError) Error() string {
-	return fmt.Sprintf("%d syntax error: unexpected %v, expected %v", err.lineno, err.got, err.want)
+	return fmt.Sprintf("%s syntax error: unexpected %v, expected %v", location(token{file: err.file, lineno: err.lineno}), err.got, err.want)
 }
 
 func compileErr(c token, got, want string) error {
 	return compileError{
 		got:    got,
 		want:   want,
+		file:   c.file,
 		lineno: c.lineno,
 	}
 }
@@ -17,6 +17,10 @@
 package asm
 
 import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -30,14 +34,14 @@ func TestCompiler(t *testing.T) {
 	label:
 	PUSH @label
 `,
-			output: "5a5b6300000001",
+			output: "5a5b6001",
 		},
 		{
 			input: `
 	PUSH @label
 	label:
 `,
-			output: "63000000055b",
+			output: "60025b",
 		},
 		{
 			input: `
@@ -45,14 +49,83 @@ func TestCompiler(t *testing.T) {
 	JUMP
 	label:
 `,
-			output: "6300000006565b",
+			output: "6003565b",
 		},
 		{
 			input: `
 	JUMP @label
 	label:
 `,
-			output: "6300000006565b",
+			output: "6003565b",
+		},
+		{
+			input: `
+	PUSH4 @label
+	label:
+`,
+			output: "63000000055b",
+		},
+		{
+			input: `
+	.const SIZE 0x20
+	.const DOUBLE SIZE + SIZE
+	PUSH DOUBLE - (SIZE - 0x1f)
+`,
+			output: "603f",
+		},
+		{
+			input: `
+.macro countdown N
+	PUSH N
+	start:
+	PUSH 1
+	SWAP1
+	SUB
+	DUP1
+	JUMPI @start
+.endm
+	countdown 3
+	countdown 2
+`,
+			output: "60035b600190038060025760025b6001900380600d57",
+		},
+		{
+			input: `
+	PUSH size(runtime)
+	DUP1
+	PUSH offset(runtime)
+	PUSH 0
+	CODECOPY
+	PUSH 0
+	RETURN
+.code runtime
+	JUMP @end
+	end:
+	STOP
+.end
+`,
+			output: "600580600b6000396000f36003565b00",
+		},
+		{
+			input: `
+	PUSH @msg
+	PUSH size(greeting)
+.data greeting
+	msg:
+	"hi" 0x0001 255
+.end
+`,
+			output: "6004600568690001ff",
+		},
+		{
+			input: `
+	PUSH @end
+.data padding
+	0x` + strings.Repeat("00", 256) + `
+.end
+	end:
+`,
+			output: "610103" + strings.Repeat("00", 256) + "5b",
 		},
 	}
 	for _, test := range tests {
@@ -69,3 +142,78 @@ func TestCompiler(t *testing.T) {
 		}
 	}
 }
+
+func TestCompilerErrors(t *testing.T) {
+	tests := []struct {
+		input, err string
+	}{
+		{
+			input: "\tPUSH @missing\n",
+			err:   "1: label error: missing not defined",
+		},
+		{
+			input: "\tFOO\n",
+			err:   "1: syntax error: unexpected FOO, expected instruction or macro",
+		},
+		{
+			input: "\t.const A B\n\t.const B A\n\tPUSH A\n",
+			err:   "1: constant error: A defined recursively",
+		},
+		{
+			input: ".macro loop\n\tloop\n.endm\n\tloop\n",
+			err:   "2: macro error: too many expansions, recursive macro loop?",
+		},
+		{
+			input: ".code runtime\n\tSTOP\n",
+			err:   "3: syntax error: unexpected EOF, expected .end",
+		},
+		{
+			input: "\tPUSH1 0x0100\n",
+			err:   "1: type error: value 0x0100 does not fit in PUSH1",
+		},
+	}
+	for _, test := range tests {
+		c := NewCompiler(false)
+		c.Feed(Lex([]byte(test.input), false))
+		_, errs := c.Compile()
+		if len(errs) != 1 {
+			t.Errorf("input %q: error count mismatch: have %d (%v), want 1", test.input, len(errs), errs)
+			continue
+		}
+		if errs[0].Error() != test.err {
+			t.Errorf("input %q: error mismatch: have %q, want %q", test.input, errs[0], test.err)
+		}
+	}
+}
+
+// Tests that included files are resolved relative to the including source, and
+// that the source map points every instruction back to its file and line.
+func TestCompilerSourceMap(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.easm")
+	if err := os.WriteFile(lib, []byte(".macro ret\n\tPUSH 0\n\tDUP1\n\tRETURN\n.endm\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	main := filepath.Join(dir, "main.easm")
+	src := ".include \"lib.easm\"\n\tCALLER\n\tret\n"
+
+	c := NewCompiler(false)
+	c.SetSource(main)
+	c.Feed(Lex([]byte(src), false))
+	output, errs := c.Compile()
+	if len(errs) != 0 {
+		t.Fatalf("compile error: %v", errs)
+	}
+	if output != "33600080f3" {
+		t.Fatalf("incorrect output: have %s, want %s", output, "33600080f3")
+	}
+	want := map[uint64]SourceLocation{
+		0: {File: main, Line: 2},
+		1: {File: lib, Line: 2},
+		3: {File: lib, Line: 3},
+		4: {File: lib, Line: 4},
+	}
+	if have := c.SourceMap(); !reflect.DeepEqual(have, want) {
+		t.Errorf("source map mismatch:\nhave %v\nwant %v", have, want)
+	}
+}
@@ -72,6 +72,14 @@ func TestLexer(t *testing.T) {
 			input:  "@label123",
 			tokens: []token{{typ: lineStart}, {typ: label, text: "label123"}, {typ: eof}},
 		},
+		{
+			input:  ".const size 0x20",
+			tokens: []token{{typ: lineStart}, {typ: directive, text: ".const"}, {typ: element, text: "size"}, {typ: number, text: "0x20"}, {typ: eof}},
+		},
+		{
+			input:  "(@foo+2)-1",
+			tokens: []token{{typ: lineStart}, {typ: operator, text: "("}, {typ: label, text: "foo"}, {typ: operator, text: "+"}, {typ: number, text: "2"}, {typ: operator, text: ")"}, {typ: operator, text: "-"}, {typ: number, text: "1"}, {typ: eof}},
+		},
 	}
 
 	for _, test := range tests {
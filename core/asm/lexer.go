@@ -36,6 +36,7 @@ type token struct {
 	typ    tokenType
 	lineno int
 	text   string
+	file   string // source file, set by the compiler
 }
 
 // tokenType are the different types the lexer
@@ -52,10 +53,13 @@ const (
 	labelDef                          // label definition is emitted when a new label is found
 	number                            // number is emitted when a number is found
 	stringValue                       // stringValue is emitted when a string has been found
+	directive                         // directive is emitted when a compiler directive is found
+	operator                          // operator is emitted when an expression operator is found
 
 	Numbers            = "1234567890"                                           // characters representing any decimal number
 	HexadecimalNumbers = Numbers + "aAbBcCdDeEfF"                               // characters representing any hexadecimal
 	Alpha              = "abcdefghijklmnopqrstuwvxyzABCDEFGHIJKLMNOPQRSTUWVXYZ" // characters representing alphanumeric
+	Operators          = "+-()"                                                 // characters representing expression operators
 )
 
 // String implements stringer
@@ -76,6 +80,8 @@ var stringtokenTypes = []string{
 	labelDef:         "label definition",
 	number:           "number",
 	stringValue:      "string",
+	directive:        "directive",
+	operator:         "operator",
 }
 
 // lexer is the basic construct for parsing
@@ -182,7 +188,7 @@ func (l *lexer) blob() string {
 
 // Emits a new token on to token channel for processing
 func (l *lexer) emit(t tokenType) {
-	token := token{t, l.lineno, l.blob()}
+	token := token{typ: t, lineno: l.lineno, text: l.blob()}
 
 	if l.debug {
 		fmt.Fprintf(os.Stderr, "%04d: (%-20v) %s\n", token.lineno, token.typ, token.text)
@@ -215,8 +221,14 @@ func lexLine(l *lexer) stateFn {
 			return lexLabel
 		case r == '"':
 			return lexInsideString
-		default:
+		case r == '.':
+			return lexDirective
+		case strings.ContainsRune(Operators, r):
+			l.emit(operator)
+		case r == 0:
 			return nil
+		default:
+			l.emit(invalidStatement)
 		}
 	}
 }
@@ -252,6 +264,16 @@ func lexInsideString(l *lexer) stateFn {
 	return lexLine
 }
 
+// lexDirective parses the current compiler directive (e.g. ".macro"), emits
+// and returns the lex text state function.
+func lexDirective(l *lexer) stateFn {
+	l.acceptRun(Alpha + "_" + Numbers)
+
+	l.emit(directive)
+
+	return lexLine
+}
+
 func lexNumber(l *lexer) stateFn {
 	acceptance := Numbers
 	if l.accept("xX") {
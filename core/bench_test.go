@@ -68,6 +68,18 @@ func BenchmarkInsertChain_ring1000_memdb(b *testing.B) {
 func BenchmarkInsertChain_ring1000_diskdb(b *testing.B) {
 	benchInsertChain(b, true, genTxRing(1000))
 }
+func BenchmarkInsertChain_contracts_serial_memdb(b *testing.B) {
+	benchInsertContracts(b, false, 1)
+}
+func BenchmarkInsertChain_contracts_serial_diskdb(b *testing.B) {
+	benchInsertContracts(b, true, 1)
+}
+func BenchmarkInsertChain_contracts_parallel_memdb(b *testing.B) {
+	benchInsertContracts(b, false, 0)
+}
+func BenchmarkInsertChain_contracts_parallel_diskdb(b *testing.B) {
+	benchInsertContracts(b, true, 0)
+}
 
 var (
 	// This is the content of the genesis block used by the benchmarks.
@@ -158,6 +170,31 @@ func genTxRing(naccounts int) func(int, *BlockGen) {
 	}
 }
 
+var (
+	// benchContracts is the number of contracts called in each block of the
+	// contract benchmarks, each with benchContractSlots slots of storage.
+	benchContracts     = 100
+	benchContractSlots = 512
+
+	// benchContractCode sets the storage slots 0-31 to the block number.
+	benchContractCode = common.Hex2Bytes("60205b6001900343815580600257")
+)
+
+// genContractCalls is a block generator calling every benchmark contract once,
+// updating the storage of all of them.
+func genContractCalls(i int, gen *BlockGen) {
+	signer := types.MakeSigner(gen.config, big.NewInt(int64(i)))
+	for j := 0; j < benchContracts; j++ {
+		tx, _ := types.SignNewTx(benchRootKey, signer, &types.LegacyTx{
+			Nonce:    gen.TxNonce(benchRootAddr),
+			To:       &common.Address{0xcc, byte(j >> 8), byte(j)},
+			Gas:      200000,
+			GasPrice: gen.header.BaseFee,
+		})
+		gen.AddTx(tx)
+	}
+}
+
 // genUncles generates blocks with two uncle headers.
 func genUncles(i int, gen *BlockGen) {
 	if i >= 7 {
@@ -205,6 +242,56 @@ func benchInsertChain(b *testing.B, disk bool, gen func(int, *BlockGen)) {
 	}
 }
 
+// benchInsertContracts measures the insertion of blocks updating the storage of
+// many contracts, whose storage tries are hashed and committed by the given
+// number of workers (0 = GOMAXPROCS).
+func benchInsertContracts(b *testing.B, disk bool, workers int) {
+	// Create the database in memory or in a temporary directory.
+	var db ethdb.Database
+	var err error
+	if !disk {
+		db = rawdb.NewMemoryDatabase()
+	} else {
+		dir := b.TempDir()
+		db, err = rawdb.NewLevelDBDatabase(dir, 128, 128, "", false)
+		if err != nil {
+			b.Fatalf("cannot create temporary database: %v", err)
+		}
+		defer db.Close()
+	}
+	// Generate a chain of b.N blocks calling all the contracts.
+	gspec := Genesis{
+		Config:   params.TestChainConfig,
+		GasLimit: 30_000_000,
+		Alloc:    GenesisAlloc{benchRootAddr: {Balance: benchRootFunds}},
+	}
+	for i := 0; i < benchContracts; i++ {
+		storage := make(map[common.Hash]common.Hash)
+		for j := 0; j < benchContractSlots; j++ {
+			storage[common.BigToHash(big.NewInt(int64(j)))] = common.Hash{0xff}
+		}
+		gspec.Alloc[common.Address{0xcc, byte(i >> 8), byte(i)}] = GenesisAccount{
+			Balance: common.Big0,
+			Code:    benchContractCode,
+			Storage: storage,
+		}
+	}
+	genesis := gspec.MustCommit(db)
+	chain, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, b.N, genContractCalls)
+
+	// Time the insertion of the new chain.
+	config := *defaultCacheConfig
+	config.StateWorkers = workers
+
+	chainman, _ := NewBlockChain(db, &config, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer chainman.Stop()
+	b.ReportAllocs()
+	b.ResetTimer()
+	if i, err := chainman.InsertChain(chain); err != nil {
+		b.Fatalf("insert error (block %d): %v\n", i, err)
+	}
+}
+
 func BenchmarkChainRead_header_10k(b *testing.B) {
 	benchReadChain(b, false, 10000)
 }
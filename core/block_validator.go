@@ -83,6 +83,25 @@ func (v *BlockValidator) ValidateState(block *types.Block, statedb *state.StateD
 	if block.GasUsed() != usedGas {
 		return fmt.Errorf("invalid gas used (remote: %d local: %d)", block.GasUsed(), usedGas)
 	}
+	// Validate that reserve and instant transactions kept within their own
+	// share of the block gas limit.
+	if v.config.IsEternal(header.Number) {
+		var reserveUsed, instantUsed uint64
+		for i, tx := range block.Transactions() {
+			if tx.IsReserve() {
+				reserveUsed += receipts[i].GasUsed
+			} else {
+				instantUsed += receipts[i].GasUsed
+			}
+		}
+		reserveLimit, instantLimit := CalcGasBudgets(header.GasLimit)
+		if reserveUsed > reserveLimit {
+			return fmt.Errorf("invalid reserve gas used (used: %d budget: %d)", reserveUsed, reserveLimit)
+		}
+		if instantUsed > instantLimit {
+			return fmt.Errorf("invalid instant gas used (used: %d budget: %d)", instantUsed, instantLimit)
+		}
+	}
 	// Validate the received block's bloom with the one derived from the generated receipts.
 	// For valid blocks this should always validate to true.
 	rbloom := types.CreateBloom(receipts)
@@ -102,6 +121,13 @@ func (v *BlockValidator) ValidateState(block *types.Block, statedb *state.StateD
 	return nil
 }
 
+// CalcGasBudgets splits the gas limit of an Eternal Live block into the budget
+// available to reserve transactions and the one available to instant ones.
+func CalcGasBudgets(gasLimit uint64) (reserve uint64, instant uint64) {
+	reserve = gasLimit / params.ReserveGasLimitDivisor
+	return reserve, gasLimit - reserve
+}
+
 // CalcGasLimit computes the gas limit of the next block after parent. It aims
 // to keep the baseline gas close to the provided target, and increase it towards
 // the target if the baseline gas is lower.
@@ -29,10 +29,12 @@ import (
 	"github.com/foreverbit/biternal/consensus/clique"
 	"github.com/foreverbit/biternal/consensus/ethash"
 	"github.com/foreverbit/biternal/core/rawdb"
+	"github.com/foreverbit/biternal/core/state"
 	"github.com/foreverbit/biternal/core/types"
 	"github.com/foreverbit/biternal/core/vm"
 	"github.com/foreverbit/biternal/crypto"
 	"github.com/foreverbit/biternal/params"
+	"github.com/foreverbit/biternal/trie"
 )
 
 // Tests that simple header verification works, for both good and bad blocks.
@@ -403,3 +405,67 @@ func TestCalcGasLimit(t *testing.T) {
 		}
 	}
 }
+
+func TestCalcGasBudgets(t *testing.T) {
+	for i, tc := range []struct {
+		gasLimit uint64
+		reserve  uint64
+		instant  uint64
+	}{
+		{0, 0, 0},
+		{30000000, 15000000, 15000000},
+		{30000001, 15000000, 15000001},
+	} {
+		reserve, instant := CalcGasBudgets(tc.gasLimit)
+		if reserve != tc.reserve || instant != tc.instant {
+			t.Errorf("test %d: have %d/%d want %d/%d", i, reserve, instant, tc.reserve, tc.instant)
+		}
+		if reserve+instant != tc.gasLimit {
+			t.Errorf("test %d: budgets %d+%d don't add up to %d", i, reserve, instant, tc.gasLimit)
+		}
+	}
+}
+
+// Tests that blocks exceeding the reserve or instant gas budget are rejected,
+// while blocks keeping within both are accepted.
+func TestValidateStateGasBudgets(t *testing.T) {
+	var (
+		statedb, _ = state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		root       = statedb.IntermediateRoot(true)
+		validator  = NewBlockValidator(eternalConfig, nil, nil)
+		gasLimit   = 4 * params.TxGas // 2 transfers per budget
+	)
+	reserve := func(nonce uint64) *types.Transaction {
+		return types.NewTx(&types.ReserveTx{Nonce: nonce, Gas: params.TxGas, To: &common.Address{}, At: 1})
+	}
+	instant := func(nonce uint64) *types.Transaction {
+		return types.NewTx(&types.DynamicFeeTx{Nonce: nonce, Gas: params.TxGas, To: &common.Address{}})
+	}
+	for i, tt := range []struct {
+		txs types.Transactions
+		err string
+	}{
+		{types.Transactions{reserve(0), reserve(1), instant(0), instant(1)}, ""},
+		{types.Transactions{reserve(0), reserve(1), reserve(2)}, "invalid reserve gas used (used: 63000 budget: 42000)"},
+		{types.Transactions{instant(0), instant(1), instant(2)}, "invalid instant gas used (used: 63000 budget: 42000)"},
+	} {
+		var (
+			receipts = make(types.Receipts, len(tt.txs))
+			usedGas  uint64
+		)
+		for j, tx := range tt.txs {
+			usedGas += tx.Gas()
+			receipts[j] = &types.Receipt{Type: tx.Type(), Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: usedGas, GasUsed: tx.Gas(), Logs: []*types.Log{}}
+		}
+		header := &types.Header{Number: big.NewInt(1), GasLimit: gasLimit, GasUsed: usedGas, Root: root, BaseFee: big.NewInt(params.InitialBaseFee)}
+		block := types.NewBlock(header, tt.txs, nil, receipts, trie.NewStackTrie(nil))
+
+		err := validator.ValidateState(block, statedb, receipts, usedGas)
+		if tt.err == "" && err != nil {
+			t.Errorf("test %d: valid block rejected: %v", i, err)
+		}
+		if tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %s", i, err, tt.err)
+		}
+	}
+}
@@ -131,6 +131,12 @@ type CacheConfig struct {
 	TrieTimeLimit       time.Duration // Time limit after which to flush the current in-memory trie to disk
 	SnapshotLimit       int           // Memory allowance (MB) to use for caching snapshot entries in memory
 	Preimages           bool          // Whether to store preimage of trie key to the disk
+	ReverseDiffs        uint64        // Number of reverse diffs to retain with the path scheme
+	HistoryRetention    uint64        // Number of recent blocks to retain bodies and receipts for (0 = entire chain)
+	StateHistory        uint64        // Number of recent blocks to retain state history for (0 = disabled)
+	StateWorkers        int           // Number of goroutines hashing storage tries concurrently (0 = GOMAXPROCS)
+
+	LiveTrace *LiveTraceConfig // Tracer run on the transactions of the imported blocks, nil if disabled
 
 	SnapshotWait bool // Wait for snapshot construction on startup. TODO(karalabe): This is a dirty hack for testing, nuke it
 }
@@ -163,10 +169,11 @@ type BlockChain struct {
 	chainConfig *params.ChainConfig // Chain & network configuration
 	cacheConfig *CacheConfig        // Cache configuration for pruning
 
-	db     ethdb.Database // Low level persistent database to store final content in
-	snaps  *snapshot.Tree // Snapshot tree for fast trie leaf access
-	triegc *prque.Prque   // Priority queue mapping block numbers to tries to gc
-	gcproc time.Duration  // Accumulates canonical block processing for trie dumping
+	db      ethdb.Database // Low level persistent database to store final content in
+	snaps   *snapshot.Tree // Snapshot tree for fast trie leaf access
+	history *stateHistory  // State history of the recent blocks, nil if disabled
+	triegc  *prque.Prque   // Priority queue mapping block numbers to tries to gc
+	gcproc  time.Duration  // Accumulates canonical block processing for trie dumping
 
 	// txLookupLimit is the maximum number of blocks from head whose tx indices
 	// are reserved:
@@ -235,9 +242,10 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		db:          db,
 		triegc:      prque.New(nil),
 		stateCache: state.NewDatabaseWithConfig(db, &trie.Config{
-			Cache:     cacheConfig.TrieCleanLimit,
-			Journal:   cacheConfig.TrieCleanJournal,
-			Preimages: cacheConfig.Preimages,
+			Cache:        cacheConfig.TrieCleanLimit,
+			Journal:      cacheConfig.TrieCleanJournal,
+			Preimages:    cacheConfig.Preimages,
+			ReverseDiffs: cacheConfig.ReverseDiffs,
 		}),
 		quit:          make(chan struct{}),
 		chainmu:       syncx.NewClosableMutex(),
@@ -383,6 +391,15 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		}
 		bc.snaps, _ = snapshot.New(bc.db, bc.stateCache.TrieDB(), bc.cacheConfig.SnapshotLimit, head.Root(), !bc.cacheConfig.SnapshotWait, true, recover)
 	}
+	// Open the state history on top of the snapshot
+	if bc.cacheConfig.StateHistory > 0 {
+		if bc.snaps == nil {
+			log.Warn("State history requires snapshots, disabling")
+		} else if bc.history, err = newStateHistory(bc.db, bc.snaps, bc.cacheConfig.StateHistory, bc.CurrentBlock()); err != nil {
+			log.Warn("Failed to open state history, disabling", "err", err)
+			bc.history = nil
+		}
+	}
 
 	// Start future block processor.
 	bc.wg.Add(1)
@@ -395,6 +412,11 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		bc.wg.Add(1)
 		go bc.maintainTxIndex(txIndexBlock)
 	}
+	// Start chain history pruner.
+	if bc.cacheConfig.HistoryRetention > 0 {
+		bc.wg.Add(1)
+		go bc.maintainHistory()
+	}
 
 	// If periodic cache journal is required, spin it up.
 	if bc.cacheConfig.TrieCleanRejournal > 0 {
@@ -572,6 +594,13 @@ func (bc *BlockChain) setHeadBeyondRoot(head uint64, root common.Hash, repair bo
 					if root != (common.Hash{}) && !beyondRoot && newHeadBlock.Root() == root {
 						beyondRoot, rootNumber = true, newHeadBlock.NumberU64()
 					}
+					// The path scheme can revert the persisted state with the retained
+					// reverse diffs if the state is gone
+					if triedb := bc.stateCache.TrieDB(); triedb.Recoverable(newHeadBlock.Root()) {
+						if err := triedb.Recover(newHeadBlock.Root()); err != nil {
+							log.Error("Failed to revert state", "number", newHeadBlock.NumberU64(), "root", newHeadBlock.Root(), "err", err)
+						}
+					}
 					if _, err := state.New(newHeadBlock.Root(), bc.stateCache, bc.snaps); err != nil {
 						log.Trace("Block state missing, rewinding further", "number", newHeadBlock.NumberU64(), "hash", newHeadBlock.Hash())
 						if pivot == nil || newHeadBlock.NumberU64() > *pivot {
@@ -695,7 +724,20 @@ func (bc *BlockChain) setHeadBeyondRoot(head uint64, root common.Hash, repair bo
 		bc.SetFinalized(nil)
 	}
 
-	return rootNumber, bc.loadLastState()
+	if err := bc.loadLastState(); err != nil {
+		return rootNumber, err
+	}
+	// Rewind the state history along with the chain
+	if bc.history != nil {
+		head := bc.CurrentBlock()
+		if err := bc.history.truncate(head.NumberU64()); err != nil {
+			log.Warn("Restarting state history", "number", head.NumberU64(), "reason", err)
+			if err := bc.history.reset(head.NumberU64(), head.Hash(), head.Root()); err != nil {
+				return rootNumber, err
+			}
+		}
+	}
+	return rootNumber, nil
 }
 
 // SnapSyncCommitHead sets the current head block to the one defined by the hash
@@ -716,6 +758,13 @@ func (bc *BlockChain) SnapSyncCommitHead(hash common.Hash) error {
 	}
 	bc.currentBlock.Store(block)
 	headBlockGauge.Update(int64(block.NumberU64()))
+
+	// The synced state has no history, restart it from the new head
+	if bc.history != nil {
+		if err := bc.history.reset(block.NumberU64(), block.Hash(), block.Root()); err != nil {
+			log.Error("Failed to restart state history", "number", block.NumberU64(), "err", err)
+		}
+	}
 	bc.chainmu.Unlock()
 
 	// Destroy any existing state snapshot and regenerate it in the background,
@@ -828,6 +877,15 @@ func (bc *BlockChain) writeHeadBlock(block *types.Block) {
 
 	bc.currentBlock.Store(block)
 	headBlockGauge.Update(int64(block.NumberU64()))
+
+	// Record the state changes of the new head
+	if bc.history != nil {
+		var parent *types.Header
+		if number := block.NumberU64(); number > 0 {
+			parent = bc.GetHeader(block.ParentHash(), number-1)
+		}
+		bc.history.update(block, parent)
+	}
 }
 
 // Stop stops the blockchain service. If any imports are currently in progress
@@ -853,9 +911,21 @@ func (bc *BlockChain) Stop() {
 	bc.chainmu.Close()
 	bc.wg.Wait()
 
+	// Close the state history, it's only modified along with the chain
+	if bc.history != nil {
+		bc.history.close()
+	}
+
 	// Ensure that the entirety of the state snapshot is journalled to disk.
 	var snapBase common.Hash
 	if bc.snaps != nil {
+		// The path scheme only retains the head state on disk, flatten the
+		// snapshot onto it as well so that they match after a restart.
+		if root := bc.CurrentBlock().Root(); bc.stateCache.TrieDB().Scheme() == rawdb.PathScheme && bc.snaps.DiskRoot() != root {
+			if err := bc.snaps.Cap(root, 0); err != nil {
+				log.Error("Failed to flatten state snapshot", "err", err)
+			}
+		}
 		var err error
 		if snapBase, err = bc.snaps.Journal(bc.CurrentBlock().Root()); err != nil {
 			log.Error("Failed to journal state snapshot", "err", err)
@@ -867,7 +937,16 @@ func (bc *BlockChain) Stop() {
 	//  - HEAD:     So we don't need to reprocess any blocks in the general case
 	//  - HEAD-1:   So we don't do large reorgs if our HEAD becomes an uncle
 	//  - HEAD-127: So we have a hard limit on the number of blocks reexecuted
-	if !bc.cacheConfig.TrieDirtyDisabled {
+	//
+	// The path scheme retains a single state on disk, which is the one of HEAD.
+	if triedb := bc.stateCache.TrieDB(); triedb.Scheme() == rawdb.PathScheme {
+		recent := bc.CurrentBlock()
+
+		log.Info("Writing cached state to disk", "block", recent.Number(), "hash", recent.Hash(), "root", recent.Root())
+		if err := triedb.Commit(recent.Root(), true, nil); err != nil {
+			log.Error("Failed to commit recent state trie", "err", err)
+		}
+	} else if !bc.cacheConfig.TrieDirtyDisabled {
 		triedb := bc.stateCache.TrieDB()
 
 		for _, offset := range []uint64{0, 1, TriesInMemory - 1} {
@@ -1272,6 +1351,11 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	}
 	triedb := bc.stateCache.TrieDB()
 
+	// The path scheme keeps a bounded number of recent states in memory by
+	// itself and overwrites the stale nodes on disk, nothing to collect here.
+	if triedb.Scheme() == rawdb.PathScheme {
+		return nil
+	}
 	// If we're running an archive node, always flush
 	if bc.cacheConfig.TrieDirtyDisabled {
 		return triedb.Commit(root, false, nil)
@@ -1640,6 +1724,9 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals, setHead bool)
 			return it.index, err
 		}
 
+		// Hash the storage tries of imported blocks in parallel
+		statedb.SetWorkers(bc.cacheConfig.StateWorkers)
+
 		// Enable prefetching to pull in trie node paths while processing transactions
 		statedb.StartPrefetcher("chain")
 		activeState = statedb
@@ -1662,9 +1749,18 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals, setHead bool)
 			}
 		}
 
-		// Process block using the parent state as reference point
+		// Process block using the parent state as reference point, tracing the
+		// transactions if live tracing is enabled
+		var (
+			vmConfig = bc.vmConfig
+			tracer   *liveTracer
+		)
+		if bc.cacheConfig.LiveTrace != nil {
+			tracer = newLiveTracer(bc.cacheConfig.LiveTrace, block)
+			vmConfig.Debug, vmConfig.Tracer = true, tracer
+		}
 		substart := time.Now()
-		receipts, logs, usedGas, err := bc.processor.Process(block, statedb, bc.vmConfig)
+		receipts, logs, usedGas, err := bc.processor.Process(block, statedb, vmConfig)
 		if err != nil {
 			bc.reportBlock(block, receipts, err)
 			atomic.StoreUint32(&followupInterrupt, 1)
@@ -1711,6 +1807,9 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals, setHead bool)
 		if err != nil {
 			return it.index, err
 		}
+		if tracer != nil {
+			bc.writeTxTraces(block, tracer)
+		}
 		// Update the metrics touched during block commit
 		accountCommitTimer.Update(statedb.AccountCommits)   // Account commits are complete, we can mark them
 		storageCommitTimer.Update(statedb.StorageCommits)   // Storage commits are complete, we can mark them
@@ -2362,6 +2461,74 @@ func (bc *BlockChain) maintainTxIndex(ancients uint64) {
 	}
 }
 
+// maintainHistory is responsible for pruning the bodies and receipts of the
+// blocks falling out of the history retention window. Only the blocks already
+// moved into the ancient store are pruned, and never the ones with indexed
+// transactions, since unindexing them requires the bodies.
+func (bc *BlockChain) maintainHistory() {
+	defer bc.wg.Done()
+
+	// pruneHistory moves the history tail to the retention window of the head
+	pruneHistory := func(head uint64, done chan struct{}) {
+		defer func() { done <- struct{}{} }()
+
+		if head < bc.cacheConfig.HistoryRetention {
+			return
+		}
+		tail := head - bc.cacheConfig.HistoryRetention + 1
+		frozen, err := bc.db.Ancients()
+		if err != nil {
+			return // No ancient store, nothing to prune
+		}
+		if tail > frozen {
+			tail = frozen
+		}
+		txtail := rawdb.ReadTxIndexTail(bc.db)
+		if txtail == nil {
+			return
+		}
+		if tail > *txtail {
+			tail = *txtail
+		}
+		if tail <= rawdb.ReadHistoryTail(bc.db) {
+			return
+		}
+		start := time.Now()
+		if err := rawdb.PruneHistory(bc.db, tail); err != nil {
+			log.Error("Failed to prune chain history", "tail", tail, "err", err)
+			return
+		}
+		log.Info("Pruned chain history", "tail", tail, "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+	var (
+		done   chan struct{}                  // Non-nil if background pruning routine is active.
+		headCh = make(chan ChainHeadEvent, 1) // Buffered to avoid locking up the event feed
+	)
+	sub := bc.SubscribeChainHeadEvent(headCh)
+	if sub == nil {
+		return
+	}
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case head := <-headCh:
+			if done == nil {
+				done = make(chan struct{})
+				go pruneHistory(head.Block.NumberU64(), done)
+			}
+		case <-done:
+			done = nil
+		case <-bc.quit:
+			if done != nil {
+				log.Info("Waiting background history pruner to exit")
+				<-done
+			}
+			return
+		}
+	}
+}
+
 // reportBlock logs a bad block error.
 func (bc *BlockChain) reportBlock(block *types.Block, receipts types.Receipts, err error) {
 	rawdb.WriteBadBlock(bc.db, block)
@@ -288,6 +288,7 @@ func (bc *BlockChain) HasBlockAndState(hash common.Hash, number uint64) bool {
 
 // TrieNode retrieves a blob of data associated with a trie node
 // either from ephemeral in-memory cache, or from persistent storage.
+// It fails with trie.ErrPathSchemeNode if the state uses the path scheme.
 func (bc *BlockChain) TrieNode(hash common.Hash) ([]byte, error) {
 	return bc.stateCache.TrieDB().Node(hash)
 }
@@ -317,7 +318,28 @@ func (bc *BlockChain) State() (*state.StateDB, error) {
 
 // StateAt returns a new mutable state based on a particular point in time.
 func (bc *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
-	return state.New(root, bc.stateCache, bc.snaps)
+	statedb, err := state.New(root, bc.stateCache, bc.snaps)
+	if err != nil && bc.history != nil {
+		// Fall back to the state history, the tries of the state may be gone
+		if historic, herr := bc.HistoricStateAt(root); herr == nil {
+			return historic, nil
+		}
+	}
+	return statedb, err
+}
+
+// HistoricStateAt returns a state reconstructed from the state history for the
+// given root. Its tries are not available, so proofs can't be generated and the
+// state can only be modified in memory, not committed.
+func (bc *BlockChain) HistoricStateAt(root common.Hash) (*state.StateDB, error) {
+	if bc.history == nil {
+		return nil, errStateHistoryDisabled
+	}
+	snap, err := bc.history.snapshot(root)
+	if err != nil {
+		return nil, err
+	}
+	return state.NewHistoric(snap, bc.stateCache), nil
 }
 
 // Config retrieves the chain's fork configuration.
@@ -373,6 +395,12 @@ func (bc *BlockChain) TxLookupLimit() uint64 {
 	return bc.txLookupLimit
 }
 
+// HistoryTail retrieves the number of the oldest block whose body and receipts
+// are retained, zero if the chain history is complete.
+func (bc *BlockChain) HistoryTail() uint64 {
+	return rawdb.ReadHistoryTail(bc.db)
+}
+
 // SubscribeRemovedLogsEvent registers a subscription of RemovedLogsEvent.
 func (bc *BlockChain) SubscribeRemovedLogsEvent(ch chan<- RemovedLogsEvent) event.Subscription {
 	return bc.scope.Track(bc.rmLogsFeed.Subscribe(ch))
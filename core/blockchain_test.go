@@ -3865,3 +3865,81 @@ func TestCanonicalHashMarker(t *testing.T) {
 		}
 	}
 }
+
+// Tests that a chain using the path scheme keeps the head state across restarts
+// and can rewind below the persisted state with the reverse diffs.
+func TestPathSchemeStateRewind(t *testing.T) {
+	var (
+		gendb   = rawdb.NewMemoryDatabase()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		funds   = big.NewInt(100000000000000000)
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{address: {Balance: funds}}}
+		genesis = gspec.MustCommit(gendb)
+		signer  = types.LatestSigner(gspec.Config)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 2*TriesInMemory, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{0x00}, big.NewInt(1000), params.TxGas, block.header.BaseFee, nil), signer, key)
+		if err != nil {
+			panic(err)
+		}
+		block.AddTx(tx)
+	})
+	db := rawdb.NewMemoryDatabase()
+	if _, err := rawdb.SetupStateScheme(db, rawdb.PathScheme); err != nil {
+		t.Fatalf("failed to setup state scheme: %v", err)
+	}
+	gspec.MustCommit(db)
+
+	cacheConfig := *defaultCacheConfig
+	cacheConfig.SnapshotLimit = 0
+	cacheConfig.ReverseDiffs = 2 * TriesInMemory
+
+	chain, err := NewBlockChain(db, &cacheConfig, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	head := blocks[len(blocks)-1]
+	if !chain.HasState(head.Root()) {
+		t.Fatalf("head state missing")
+	}
+	if chain.HasState(blocks[0].Root()) {
+		t.Fatalf("overwritten state still available")
+	}
+	chain.Stop()
+
+	// Reopen the chain, the head state must have been persisted
+	chain, err = NewBlockChain(db, &cacheConfig, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to reopen blockchain: %v", err)
+	}
+	defer chain.Stop()
+
+	if have := chain.CurrentBlock().Hash(); have != head.Hash() {
+		t.Fatalf("head block mismatch: have %x, want %x", have, head.Hash())
+	}
+	if !chain.HasState(head.Root()) {
+		t.Fatalf("head state missing after restart")
+	}
+	// Rewind well below the persisted state
+	target := blocks[TriesInMemory/2]
+	if err := chain.SetHead(target.NumberU64()); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	if have := chain.CurrentBlock().Hash(); have != target.Hash() {
+		t.Fatalf("rewound head mismatch: have %x, want %x", have, target.Hash())
+	}
+	if !chain.HasState(target.Root()) {
+		t.Fatalf("rewound state missing")
+	}
+	// The chain must be extendable on top of the rewound state
+	if n, err := chain.InsertChain(blocks[target.NumberU64():]); err != nil {
+		t.Fatalf("block %d: failed to reinsert into chain: %v", n, err)
+	}
+	if have := chain.CurrentBlock().Hash(); have != head.Hash() {
+		t.Fatalf("head block mismatch after reinsert: have %x, want %x", have, head.Hash())
+	}
+}
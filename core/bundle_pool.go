@@ -0,0 +1,257 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/core/types"
+	"github.com/foreverbit/biternal/crypto"
+	"github.com/foreverbit/biternal/log"
+	"github.com/foreverbit/biternal/params"
+)
+
+const (
+	// bundleMaxSize is the maximum number of transactions a single bundle may
+	// contain.
+	bundleMaxSize = 64
+
+	// bundleMaxFutureBlocks is the maximum number of blocks ahead of the chain
+	// head a bundle may target.
+	bundleMaxFutureBlocks = 32
+
+	// bundleBlockSlots is the maximum number of bundles tracked for a single
+	// target block.
+	bundleBlockSlots = 128
+
+	// bundlePoolSlots is the maximum number of bundles tracked by the pool.
+	bundlePoolSlots = 1024
+)
+
+var (
+	// ErrBundleEmpty is returned if a bundle without any transactions is submitted.
+	ErrBundleEmpty = errors.New("empty bundle")
+
+	// ErrBundleOversized is returned if a bundle contains more transactions than
+	// the pool is willing to simulate.
+	ErrBundleOversized = errors.New("oversized bundle")
+
+	// ErrBundleBlockPassed is returned if a bundle targets a block that is not
+	// in the future anymore.
+	ErrBundleBlockPassed = errors.New("bundle block already passed")
+
+	// ErrBundleBlockTooFar is returned if a bundle targets a block too far ahead
+	// of the chain head.
+	ErrBundleBlockTooFar = errors.New("bundle block too far in the future")
+
+	// ErrBundlePoolOverflow is returned if the bundle pool, or the slots of the
+	// target block, are full of bundles paying at least as much as the new one.
+	ErrBundlePoolOverflow = errors.New("bundle pool is full")
+)
+
+// Bundle is an ordered group of signed transactions that must be included in
+// the target block together, in order, or not at all.
+type Bundle struct {
+	Txs         types.Transactions // Transactions to include, in execution order
+	BlockNumber uint64             // Block the bundle is eligible for
+
+	hash  common.Hash // Cached bundle identifier
+	price *big.Int    // Cached average gas tip of the transactions
+	seq   uint64      // Arrival order within the bundle pool
+}
+
+// NewBundle creates a bundle of the given transactions targeting a block.
+func NewBundle(txs types.Transactions, number uint64) *Bundle {
+	bundle := &Bundle{Txs: txs, BlockNumber: number, price: new(big.Int)}
+
+	var (
+		hashes = make([][]byte, len(txs)+1)
+		gas    = new(big.Int)
+	)
+	for i, tx := range txs {
+		hashes[i] = tx.Hash().Bytes()
+
+		gas.Add(gas, new(big.Int).SetUint64(tx.Gas()))
+		bundle.price.Add(bundle.price, new(big.Int).Mul(tx.GasTipCap(), new(big.Int).SetUint64(tx.Gas())))
+	}
+	hashes[len(txs)] = new(big.Int).SetUint64(number).Bytes()
+	bundle.hash = crypto.Keccak256Hash(hashes...)
+
+	if gas.Sign() > 0 {
+		bundle.price.Div(bundle.price, gas)
+	}
+	return bundle
+}
+
+// Hash returns the identifier of the bundle, derived from the hashes of its
+// transactions and the target block.
+func (b *Bundle) Hash() common.Hash {
+	return b.hash
+}
+
+// Price returns the average gas tip offered by the transactions of the bundle,
+// weighted by their gas limits. The cheapest bundles are dropped first when the
+// pool is full.
+func (b *Bundle) Price() *big.Int {
+	return new(big.Int).Set(b.price)
+}
+
+// BundlePool tracks transaction bundles submitted for atomic inclusion until
+// the block they target is sealed. Unlike the TxPool, the bundle pool doesn't
+// check the bundles against the state: whether a bundle is executable can only
+// be decided by simulating it on top of the block being built, which is left
+// to the miner.
+type BundlePool struct {
+	config *params.ChainConfig
+	chain  blockChain
+
+	bundles map[common.Hash]*Bundle // All tracked bundles, keyed by bundle hash
+	seq     uint64                  // Arrival counter to order the bundles by
+	mu      sync.RWMutex
+}
+
+// NewBundlePool creates a new bundle pool tracking bundles on top of the given
+// chain.
+func NewBundlePool(config *params.ChainConfig, chain blockChain) *BundlePool {
+	return &BundlePool{
+		config:  config,
+		chain:   chain,
+		bundles: make(map[common.Hash]*Bundle),
+	}
+}
+
+// Add validates a bundle and starts tracking it if it is eligible for a future
+// block.
+func (pool *BundlePool) Add(bundle *Bundle) error {
+	if len(bundle.Txs) == 0 {
+		return ErrBundleEmpty
+	}
+	if len(bundle.Txs) > bundleMaxSize {
+		return ErrBundleOversized
+	}
+	head := pool.chain.CurrentBlock().NumberU64()
+	if bundle.BlockNumber <= head {
+		return ErrBundleBlockPassed
+	}
+	if bundle.BlockNumber > head+bundleMaxFutureBlocks {
+		return ErrBundleBlockTooFar
+	}
+	// Make sure all the transactions are properly signed and scheduled
+	signer := types.MakeSigner(pool.config, new(big.Int).SetUint64(bundle.BlockNumber))
+	for _, tx := range bundle.Txs {
+		if _, err := types.Sender(signer, tx); err != nil {
+			return ErrInvalidSender
+		}
+		if tx.IsReserve() && tx.At() != bundle.BlockNumber {
+			return ErrReserveBlockMismatch
+		}
+	}
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.prune(head + 1)
+	if pool.bundles[bundle.Hash()] != nil {
+		return ErrAlreadyKnown
+	}
+	// Make room for the bundle if either its block or the whole pool is full
+	sameBlock := func(b *Bundle) bool { return b.BlockNumber == bundle.BlockNumber }
+	if !pool.makeRoom(bundle, bundleBlockSlots, sameBlock) || !pool.makeRoom(bundle, bundlePoolSlots, nil) {
+		return ErrBundlePoolOverflow
+	}
+	pool.seq++
+	bundle.seq = pool.seq
+	pool.bundles[bundle.Hash()] = bundle
+
+	log.Trace("Pooled new bundle", "hash", bundle.Hash(), "block", bundle.BlockNumber, "txs", len(bundle.Txs))
+	return nil
+}
+
+// Pending retrieves all the bundles eligible for the given block, in the order
+// they were first seen. Bundles targeting earlier blocks are dropped.
+func (pool *BundlePool) Pending(number uint64) []*Bundle {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.prune(number)
+
+	var pending []*Bundle
+	for _, bundle := range pool.bundles {
+		if bundle.BlockNumber == number {
+			pending = append(pending, bundle)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].seq < pending[j].seq
+	})
+	return pending
+}
+
+// Stats retrieves the number of bundles currently tracked by the pool.
+func (pool *BundlePool) Stats() int {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return len(pool.bundles)
+}
+
+// makeRoom ensures fewer than limit bundles matching the filter (all if nil) are
+// tracked, evicting the cheapest one if the given bundle pays more. Among equally
+// priced bundles the most recent one is evicted. It returns false if there is no
+// room for the bundle.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *BundlePool) makeRoom(bundle *Bundle, limit int, filter func(*Bundle) bool) bool {
+	var (
+		count    int
+		cheapest *Bundle
+	)
+	for _, b := range pool.bundles {
+		if filter != nil && !filter(b) {
+			continue
+		}
+		count++
+		if cheapest == nil {
+			cheapest = b
+		} else if cmp := b.price.Cmp(cheapest.price); cmp < 0 || (cmp == 0 && b.seq > cheapest.seq) {
+			cheapest = b
+		}
+	}
+	if count < limit {
+		return true
+	}
+	if bundle.price.Cmp(cheapest.price) <= 0 {
+		return false
+	}
+	delete(pool.bundles, cheapest.Hash())
+	log.Trace("Evicted cheaper bundle", "hash", cheapest.Hash(), "block", cheapest.BlockNumber, "price", cheapest.price)
+	return true
+}
+
+// prune drops all the bundles targeting blocks before the given one.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *BundlePool) prune(number uint64) {
+	for hash, bundle := range pool.bundles {
+		if bundle.BlockNumber < number {
+			delete(pool.bundles, hash)
+		}
+	}
+}
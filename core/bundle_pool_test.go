@@ -0,0 +1,148 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/core/rawdb"
+	"github.com/foreverbit/biternal/core/state"
+	"github.com/foreverbit/biternal/core/types"
+	"github.com/foreverbit/biternal/crypto"
+	"github.com/foreverbit/biternal/event"
+)
+
+// Tests that bundles are validated on submission and tracked until the block
+// they target passes.
+func TestBundlePool(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{10000000, statedb, new(event.Feed)}
+	pool := NewBundlePool(eternalConfig, blockchain)
+
+	key, _ := crypto.GenerateKey()
+	txs := types.Transactions{transaction(0, 100000, key), transaction(1, 100000, key)}
+
+	oversized := make(types.Transactions, bundleMaxSize+1)
+	for i := range oversized {
+		oversized[i] = transaction(uint64(i), 100000, key)
+	}
+
+	tests := []struct {
+		bundle *Bundle
+		err    error
+	}{
+		{NewBundle(nil, 1), ErrBundleEmpty},
+		{NewBundle(oversized, 1), ErrBundleOversized},
+		{NewBundle(txs, 0), ErrBundleBlockPassed},
+		{NewBundle(txs, bundleMaxFutureBlocks+1), ErrBundleBlockTooFar},
+		{NewBundle(types.Transactions{reserveTx(0, 100000, big.NewInt(1), big.NewInt(1), 2, key)}, 1), ErrReserveBlockMismatch},
+		{NewBundle(txs, 1), nil},
+		{NewBundle(txs, 1), ErrAlreadyKnown},
+		{NewBundle(txs[:1], 1), nil},
+		{NewBundle(txs, 2), nil},
+	}
+	for i, tt := range tests {
+		if err := pool.Add(tt.bundle); !errors.Is(err, tt.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+	if have := pool.Stats(); have != 3 {
+		t.Fatalf("tracked bundle count mismatch: have %d, want %d", have, 3)
+	}
+	// Only the bundles targeting the requested block should be returned, in order
+	pending := pool.Pending(1)
+	if len(pending) != 2 {
+		t.Fatalf("pending bundle count mismatch: have %d, want %d", len(pending), 2)
+	}
+	if pending[0].Hash() != NewBundle(txs, 1).Hash() || pending[1].Hash() != NewBundle(txs[:1], 1).Hash() {
+		t.Errorf("pending bundles not in arrival order")
+	}
+	// Once the target block passes, the bundles should be dropped
+	if pending := pool.Pending(2); len(pending) != 1 {
+		t.Fatalf("pending bundle count mismatch: have %d, want %d", len(pending), 1)
+	}
+	if have := pool.Stats(); have != 1 {
+		t.Fatalf("tracked bundle count mismatch: have %d, want %d", have, 1)
+	}
+}
+
+// Tests that the cheapest bundles are evicted once either the slots of a block
+// or the whole pool are full, and that no bundle is accepted without outbidding
+// one already tracked.
+func TestBundlePoolEviction(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{10000000, statedb, new(event.Feed)}
+	pool := NewBundlePool(eternalConfig, blockchain)
+
+	key, _ := crypto.GenerateKey()
+	txs := make(types.Transactions, bundleBlockSlots)
+	for i := range txs {
+		txs[i] = pricedTransaction(uint64(i), 100000, big.NewInt(int64(2+i%2)), key)
+	}
+	// Fill up the slots of the first block, half of them paying less
+	for _, tx := range txs {
+		if err := pool.Add(NewBundle(types.Transactions{tx}, 1)); err != nil {
+			t.Fatalf("failed to add bundle: %v", err)
+		}
+	}
+	cheap := pricedTransaction(bundleBlockSlots, 100000, big.NewInt(2), key)
+	if err := pool.Add(NewBundle(types.Transactions{cheap}, 1)); err != ErrBundlePoolOverflow {
+		t.Fatalf("cheap bundle error mismatch: have %v, want %v", err, ErrBundlePoolOverflow)
+	}
+	pricey := pricedTransaction(bundleBlockSlots, 100000, big.NewInt(4), key)
+	if err := pool.Add(NewBundle(types.Transactions{pricey}, 1)); err != nil {
+		t.Fatalf("failed to add outbidding bundle: %v", err)
+	}
+	// The most recent of the cheapest bundles should have been evicted
+	pending := pool.Pending(1)
+	if len(pending) != bundleBlockSlots {
+		t.Fatalf("pending bundle count mismatch: have %d, want %d", len(pending), bundleBlockSlots)
+	}
+	evicted := NewBundle(types.Transactions{txs[bundleBlockSlots-2]}, 1).Hash()
+	for _, bundle := range pending {
+		if bundle.Hash() == evicted {
+			t.Fatalf("cheapest bundle not evicted")
+		}
+	}
+	// Fill up the whole pool over the next blocks, then the pool limit applies
+	for number := uint64(2); pool.Stats() < bundlePoolSlots; number++ {
+		for _, tx := range txs {
+			if pool.Stats() == bundlePoolSlots {
+				break
+			}
+			if err := pool.Add(NewBundle(types.Transactions{tx}, number)); err != nil {
+				t.Fatalf("failed to add bundle: %v", err)
+			}
+		}
+	}
+	if err := pool.Add(NewBundle(types.Transactions{cheap}, bundleMaxFutureBlocks)); err != ErrBundlePoolOverflow {
+		t.Fatalf("cheap bundle error mismatch: have %v, want %v", err, ErrBundlePoolOverflow)
+	}
+	if err := pool.Add(NewBundle(types.Transactions{pricey}, bundleMaxFutureBlocks)); err != nil {
+		t.Fatalf("failed to add outbidding bundle: %v", err)
+	}
+	if have := pool.Stats(); have != bundlePoolSlots {
+		t.Fatalf("tracked bundle count mismatch: have %d, want %d", have, bundlePoolSlots)
+	}
+}
@@ -39,10 +39,11 @@ type BlockGen struct {
 	header  *types.Header
 	statedb *state.StateDB
 
-	gasPool  *GasPool
-	txs      []*types.Transaction
-	receipts []*types.Receipt
-	uncles   []*types.Header
+	gasPool     *GasPool
+	reservePool *GasPool // gas available to reserve transactions, nil before Eternal Live
+	txs         []*types.Transaction
+	receipts    []*types.Receipt
+	uncles      []*types.Header
 
 	config *params.ChainConfig
 	engine consensus.Engine
@@ -59,6 +60,10 @@ func (b *BlockGen) SetCoinbase(addr common.Address) {
 	}
 	b.header.Coinbase = addr
 	b.gasPool = new(GasPool).AddGas(b.header.GasLimit)
+	if b.config.IsEternal(b.header.Number) {
+		reserve, instant := CalcGasBudgets(b.header.GasLimit)
+		b.gasPool, b.reservePool = new(GasPool).AddGas(instant), new(GasPool).AddGas(reserve)
+	}
 }
 
 // SetExtra sets the extra data field of the generated block.
@@ -102,8 +107,12 @@ func (b *BlockGen) AddTxWithChain(bc *BlockChain, tx *types.Transaction) {
 	if b.gasPool == nil {
 		b.SetCoinbase(common.Address{})
 	}
+	gp := b.gasPool
+	if b.reservePool != nil && tx.IsReserve() {
+		gp = b.reservePool
+	}
 	b.statedb.Prepare(tx.Hash(), len(b.txs))
-	receipt, err := ApplyTransaction(b.config, bc, &b.header.Coinbase, b.gasPool, b.statedb, b.header, tx, &b.header.GasUsed, vm.Config{})
+	receipt, err := ApplyTransaction(b.config, bc, &b.header.Coinbase, gp, b.statedb, b.header, tx, &b.header.GasUsed, vm.Config{})
 	if err != nil {
 		panic(err)
 	}
@@ -33,6 +33,10 @@ var (
 	ErrNoGenesis = errors.New("genesis not found in chain")
 
 	errSideChainReceipts = errors.New("side blocks can't be accepted as ancient chain data")
+
+	// ErrHistoryPruned is returned if the body or receipts of a block were
+	// discarded since the block fell out of the history retention window.
+	ErrHistoryPruned = errors.New("history pruned")
 )
 
 // List of evm-call-message pre-checking errors. All state transition messages will
@@ -96,4 +100,8 @@ var (
 
 	// ErrSenderNoEOA is returned if the sender of a transaction is a contract.
 	ErrSenderNoEOA = errors.New("sender not an eoa")
+
+	// ErrReserveBlockMismatch is returned if a reserve transaction is executed
+	// in a block other than the one it was scheduled for.
+	ErrReserveBlockMismatch = errors.New("reserve transaction outside its block")
 )
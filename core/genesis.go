@@ -281,9 +281,11 @@ func SetupGenesisBlockWithOverride(db ethdb.Database, genesis *Genesis, override
 		return genesis.Config, block.Hash(), nil
 	}
 	// We have the genesis block in database(perhaps in ancient database)
-	// but the corresponding state is missing.
+	// but the corresponding state is missing. The path scheme only retains
+	// the recent states, the genesis one is gone once the chain progressed.
 	header := rawdb.ReadHeader(db, stored, 0)
-	if _, err := state.New(header.Root, state.NewDatabaseWithConfig(db, nil), nil); err != nil {
+	pruned := rawdb.ReadStateScheme(db) == rawdb.PathScheme && rawdb.ReadHeadBlockHash(db) != stored
+	if _, err := state.New(header.Root, state.NewDatabaseWithConfig(db, nil), nil); err != nil && !pruned {
 		if genesis == nil {
 			genesis = DefaultGenesisBlock()
 		}
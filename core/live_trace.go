@@ -0,0 +1,186 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/core/rawdb"
+	"github.com/foreverbit/biternal/core/types"
+	"github.com/foreverbit/biternal/core/vm"
+	"github.com/foreverbit/biternal/log"
+)
+
+// defaultLiveTraceTimeout is the amount of time a single transaction can be
+// traced during block import by default.
+const defaultLiveTraceTimeout = 5 * time.Second
+
+// errLiveTraceTimeout is the reason live tracers are stopped with once they run
+// out of time.
+var errLiveTraceTimeout = errors.New("execution timeout")
+
+// TxTracer is a tracer run on a single transaction during block import, whose
+// result is persisted.
+type TxTracer interface {
+	vm.EVMLogger
+	GetResult() (json.RawMessage, error)
+	// Stop terminates execution of the tracer at the first opportune moment.
+	Stop(err error)
+}
+
+// LiveTraceConfig configures the tracing of the transactions of the imported
+// blocks. The tracer name and config are stored along the results, so that they
+// are only served for the same tracer. Blocks written without being processed,
+// such as the locally mined ones, are not traced.
+type LiveTraceConfig struct {
+	Tracer    string          // Name of the tracer
+	Config    json.RawMessage // Json-encoded config of the tracer
+	Retention uint64          // Number of recent blocks to retain the traces for (0 = all blocks)
+	Timeout   time.Duration   // Maximum time spent tracing a single transaction (0 = 5s)
+
+	// New creates the tracer of the transaction at the given index of the block.
+	New func(block *types.Block, index int) (TxTracer, error)
+}
+
+// liveTracer is the EVM logger used while processing a block with live tracing
+// enabled. It creates a new tracer for every transaction and collects their
+// results in order. Tracers running out of time are stopped, which fails the
+// tracing of the block without interrupting its processing.
+type liveTracer struct {
+	config  *LiveTraceConfig
+	block   *types.Block
+	tracer  TxTracer    // Tracer of the transaction being processed
+	timer   *time.Timer // Timer stopping the tracer once it runs out of time
+	results [][]byte
+	err     error // Error aborting the tracing of the block
+}
+
+// newLiveTracer creates an EVM logger tracing the transactions of the block.
+func newLiveTracer(config *LiveTraceConfig, block *types.Block) *liveTracer {
+	return &liveTracer{
+		config:  config,
+		block:   block,
+		results: make([][]byte, 0, len(block.Transactions())),
+	}
+}
+
+// traces returns the traces of the block, or nil if tracing any transaction
+// failed.
+func (t *liveTracer) traces() *rawdb.TxTraces {
+	if t.err != nil || len(t.results) != len(t.block.Transactions()) {
+		return nil
+	}
+	return &rawdb.TxTraces{
+		Tracer:  t.config.Tracer,
+		Config:  t.config.Config,
+		Results: t.results,
+	}
+}
+
+func (t *liveTracer) CaptureTxStart(gasLimit uint64) {
+	if t.err != nil {
+		return
+	}
+	if t.tracer, t.err = t.config.New(t.block, len(t.results)); t.err != nil {
+		return
+	}
+	timeout := t.config.Timeout
+	if timeout == 0 {
+		timeout = defaultLiveTraceTimeout
+	}
+	tracer := t.tracer
+	t.timer = time.AfterFunc(timeout, func() { tracer.Stop(errLiveTraceTimeout) })
+
+	t.tracer.CaptureTxStart(gasLimit)
+}
+
+func (t *liveTracer) CaptureTxEnd(restGas uint64) {
+	if t.tracer == nil {
+		return
+	}
+	t.timer.Stop()
+	t.tracer.CaptureTxEnd(restGas)
+
+	res, err := t.tracer.GetResult()
+	if err != nil {
+		t.err = err
+	}
+	t.results = append(t.results, res)
+	t.tracer = nil
+}
+
+func (t *liveTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	if t.tracer != nil {
+		t.tracer.CaptureStart(env, from, to, create, input, gas, value)
+	}
+}
+
+func (t *liveTracer) CaptureEnd(output []byte, gasUsed uint64, elapsed time.Duration, err error) {
+	if t.tracer != nil {
+		t.tracer.CaptureEnd(output, gasUsed, elapsed, err)
+	}
+}
+
+func (t *liveTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	if t.tracer != nil {
+		t.tracer.CaptureEnter(typ, from, to, input, gas, value)
+	}
+}
+
+func (t *liveTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
+	if t.tracer != nil {
+		t.tracer.CaptureExit(output, gasUsed, err)
+	}
+}
+
+func (t *liveTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	if t.tracer != nil {
+		t.tracer.CaptureState(pc, op, gas, cost, scope, rData, depth, err)
+	}
+}
+
+func (t *liveTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+	if t.tracer != nil {
+		t.tracer.CaptureFault(pc, op, gas, cost, scope, depth, err)
+	}
+}
+
+// writeTxTraces stores the traces collected while processing the block and
+// prunes the ones falling out of the retention window.
+func (bc *BlockChain) writeTxTraces(block *types.Block, tracer *liveTracer) {
+	traces := tracer.traces()
+	if traces == nil {
+		log.Warn("Failed to trace block transactions", "number", block.Number(), "hash", block.Hash(), "err", tracer.err)
+		return
+	}
+	rawdb.WriteTxTraces(bc.db, block.Hash(), block.NumberU64(), traces)
+
+	retention := bc.cacheConfig.LiveTrace.Retention
+	if retention == 0 || block.NumberU64() < retention {
+		return
+	}
+	tail := block.NumberU64() - retention + 1
+	if deleted, err := rawdb.PruneTxTraces(bc.db, tail); err != nil {
+		log.Error("Failed to prune transaction traces", "tail", tail, "err", err)
+	} else if deleted > 0 {
+		log.Debug("Pruned transaction traces", "tail", tail, "blocks", deleted)
+	}
+}
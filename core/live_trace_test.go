@@ -0,0 +1,185 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/consensus/ethash"
+	"github.com/foreverbit/biternal/core/rawdb"
+	"github.com/foreverbit/biternal/core/types"
+	"github.com/foreverbit/biternal/core/vm"
+	"github.com/foreverbit/biternal/params"
+)
+
+// testTxTracer records the recipient of the transaction and the number of
+// executed opcodes.
+type testTxTracer struct {
+	index int
+	to    common.Address
+	ops   int
+}
+
+func (t *testTxTracer) CaptureTxStart(gasLimit uint64) {}
+func (t *testTxTracer) CaptureTxEnd(restGas uint64)    {}
+func (t *testTxTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.to = to
+}
+func (t *testTxTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) {}
+func (t *testTxTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+}
+func (t *testTxTracer) CaptureExit(output []byte, gasUsed uint64, err error) {}
+func (t *testTxTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	t.ops++
+}
+func (t *testTxTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
+
+func (t *testTxTracer) GetResult() (json.RawMessage, error) {
+	return json.RawMessage(fmt.Sprintf(`{"index":%d,"to":"%#x","ops":%d}`, t.index, t.to, t.ops)), nil
+}
+
+func (t *testTxTracer) Stop(err error) {}
+
+// slowTxTracer blocks at the end of every transaction until it's stopped.
+type slowTxTracer struct {
+	testTxTracer
+	stop chan struct{}
+	err  error
+}
+
+func (t *slowTxTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) {
+	select {
+	case <-t.stop:
+	case <-time.After(5 * time.Second):
+	}
+}
+
+func (t *slowTxTracer) GetResult() (json.RawMessage, error) {
+	return nil, t.err
+}
+
+func (t *slowTxTracer) Stop(err error) {
+	t.err = err
+	close(t.stop)
+}
+
+// Tests that the transactions of the imported blocks are traced and the results
+// of the blocks falling out of the retention window are pruned.
+func TestLiveTracing(t *testing.T) {
+	genesis, blocks := newHistoryTestChain(8, 0)
+
+	db := rawdb.NewMemoryDatabase()
+	genesis.MustCommit(db)
+	config := &LiveTraceConfig{
+		Tracer:    "testTracer",
+		Config:    json.RawMessage(`{"foo":"bar"}`),
+		Retention: 3,
+		New: func(block *types.Block, index int) (TxTracer, error) {
+			return &testTxTracer{index: index}, nil
+		},
+	}
+	cacheConfig := *defaultCacheConfig
+	cacheConfig.LiveTrace = config
+
+	chain, err := NewBlockChain(db, &cacheConfig, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	for _, block := range blocks {
+		traces := rawdb.ReadTxTraces(db, block.Hash(), block.NumberU64())
+		if block.NumberU64() <= 5 {
+			if traces != nil {
+				t.Errorf("block %d: traces not pruned", block.NumberU64())
+			}
+			continue
+		}
+		if traces == nil {
+			t.Fatalf("block %d: traces missing", block.NumberU64())
+		}
+		if traces.Tracer != config.Tracer || string(traces.Config) != string(config.Config) {
+			t.Errorf("block %d: tracer mismatch: have %s %s, want %s %s", block.NumberU64(), traces.Tracer, traces.Config, config.Tracer, config.Config)
+		}
+		if len(traces.Results) != len(block.Transactions()) {
+			t.Fatalf("block %d: result count mismatch: have %d, want %d", block.NumberU64(), len(traces.Results), len(block.Transactions()))
+		}
+		for i, tx := range block.Transactions() {
+			var res struct {
+				Index int
+				To    common.Address
+				Ops   int
+			}
+			if err := json.Unmarshal(traces.Results[i], &res); err != nil {
+				t.Fatalf("block %d, tx %d: failed to decode result: %v", block.NumberU64(), i, err)
+			}
+			if res.Index != i || res.To != *tx.To() {
+				t.Errorf("block %d, tx %d: result mismatch: have %d %x, want %d %x", block.NumberU64(), i, res.Index, res.To, i, *tx.To())
+			}
+			// Only the call to the counter contract executes code
+			if (res.Ops > 0) != (*tx.To() == historyTestCounter) {
+				t.Errorf("block %d, tx %d: unexpected opcode count %d", block.NumberU64(), i, res.Ops)
+			}
+		}
+	}
+	if tail := rawdb.ReadTxTraceTail(db); tail != 6 {
+		t.Errorf("trace tail mismatch: have %d, want 6", tail)
+	}
+}
+
+// Tests that live tracers running out of time are stopped, failing the tracing
+// of the block but not its import.
+func TestLiveTracingTimeout(t *testing.T) {
+	genesis, blocks := newHistoryTestChain(2, 0)
+
+	db := rawdb.NewMemoryDatabase()
+	genesis.MustCommit(db)
+	cacheConfig := *defaultCacheConfig
+	cacheConfig.LiveTrace = &LiveTraceConfig{
+		Tracer:  "slowTracer",
+		Timeout: 10 * time.Millisecond,
+		New: func(block *types.Block, index int) (TxTracer, error) {
+			return &slowTxTracer{stop: make(chan struct{})}, nil
+		},
+	}
+	chain, err := NewBlockChain(db, &cacheConfig, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	for _, block := range blocks {
+		if len(block.Transactions()) == 0 {
+			continue
+		}
+		if traces := rawdb.ReadTxTraces(db, block.Hash(), block.NumberU64()); traces != nil {
+			t.Errorf("block %d: traces of stopped tracers written", block.NumberU64())
+		}
+	}
+}
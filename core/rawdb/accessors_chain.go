@@ -278,6 +278,45 @@ func WriteTxIndexTail(db ethdb.KeyValueWriter, number uint64) {
 	}
 }
 
+// ReadHistoryTail retrieves the number of the oldest block whose body and
+// receipts are retained, zero if the chain history was never pruned.
+func ReadHistoryTail(db ethdb.KeyValueReader) uint64 {
+	data, _ := db.Get(historyTailKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// WriteHistoryTail stores the number of the oldest block whose body and
+// receipts are retained.
+func WriteHistoryTail(db ethdb.KeyValueWriter, number uint64) {
+	if err := db.Put(historyTailKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store the history tail", "err", err)
+	}
+}
+
+// PruneHistory discards the bodies and receipts of the ancient blocks below the
+// given number from the chain freezer and records the new history tail. The
+// headers, hashes and total difficulties are retained.
+func PruneHistory(db ethdb.Database, tail uint64) error {
+	if tail <= ReadHistoryTail(db) {
+		return nil
+	}
+	if frozen, err := db.Ancients(); err != nil {
+		return err
+	} else if tail > frozen {
+		return fmt.Errorf("history tail %d above the frozen blocks %d", tail, frozen)
+	}
+	for _, kind := range []string{chainFreezerBodiesTable, chainFreezerReceiptTable} {
+		if err := db.TruncateTableTail(kind, tail); err != nil {
+			return err
+		}
+	}
+	WriteHistoryTail(db, tail)
+	return nil
+}
+
 // ReadFastTxLookupLimit retrieves the tx lookup limit used in fast sync.
 func ReadFastTxLookupLimit(db ethdb.KeyValueReader) *uint64 {
 	data, _ := db.Get(fastTxLookupLimitKey)
@@ -496,6 +496,68 @@ func TestAncientStorage(t *testing.T) {
 	}
 }
 
+// Tests that pruning the history drops the ancient bodies and receipts below the
+// tail, retaining the headers and the rest of the chain.
+func TestPruneHistory(t *testing.T) {
+	db, err := NewDatabaseWithFreezer(NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatalf("failed to create database with ancient backend")
+	}
+	defer db.Close()
+
+	var (
+		chain    []*types.Block
+		receipts = make([]types.Receipts, 10)
+		parent   common.Hash
+	)
+	for i := 0; i < 10; i++ {
+		block := types.NewBlockWithHeader(&types.Header{
+			Number:      big.NewInt(int64(i)),
+			Extra:       []byte("test block"),
+			UncleHash:   types.EmptyUncleHash,
+			TxHash:      types.EmptyRootHash,
+			ReceiptHash: types.EmptyRootHash,
+			ParentHash:  parent,
+		})
+		chain = append(chain, block)
+		parent = block.Hash()
+	}
+	WriteAncientBlocks(db, chain, receipts, big.NewInt(100))
+
+	if err := PruneHistory(db, 11); err == nil {
+		t.Fatalf("pruned history beyond the frozen blocks")
+	}
+	if err := PruneHistory(db, 5); err != nil {
+		t.Fatalf("failed to prune history: %v", err)
+	}
+	if tail := ReadHistoryTail(db); tail != 5 {
+		t.Fatalf("history tail mismatch: have %d, want 5", tail)
+	}
+	for i, block := range chain {
+		hash, number := block.Hash(), block.NumberU64()
+		if blob := ReadHeaderRLP(db, hash, number); len(blob) == 0 {
+			t.Fatalf("block %d: header missing", i)
+		}
+		if blob := ReadTdRLP(db, hash, number); len(blob) == 0 {
+			t.Fatalf("block %d: td missing", i)
+		}
+		pruned := i < 5
+		if blob := ReadBodyRLP(db, hash, number); (len(blob) == 0) != pruned {
+			t.Fatalf("block %d: body presence mismatch, pruned %v", i, pruned)
+		}
+		if blob := ReadReceiptsRLP(db, hash, number); (len(blob) == 0) != pruned {
+			t.Fatalf("block %d: receipts presence mismatch, pruned %v", i, pruned)
+		}
+	}
+	// Pruning below the current tail is a noop
+	if err := PruneHistory(db, 3); err != nil {
+		t.Fatalf("failed to prune history: %v", err)
+	}
+	if tail := ReadHistoryTail(db); tail != 5 {
+		t.Fatalf("history tail mismatch: have %d, want 5", tail)
+	}
+}
+
 func TestCanonicalHashIteration(t *testing.T) {
 	var cases = []struct {
 		from, to uint64
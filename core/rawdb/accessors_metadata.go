@@ -53,6 +53,20 @@ func WriteDatabaseVersion(db ethdb.KeyValueWriter, version uint64) {
 	}
 }
 
+// ReadDatabaseEngine retrieves the key-value engine the database was created
+// with, or the empty string if it was not recorded.
+func ReadDatabaseEngine(db ethdb.KeyValueReader) string {
+	data, _ := db.Get(databaseEngineKey)
+	return string(data)
+}
+
+// WriteDatabaseEngine stores the key-value engine the database was created with.
+func WriteDatabaseEngine(db ethdb.KeyValueWriter, engine string) {
+	if err := db.Put(databaseEngineKey, []byte(engine)); err != nil {
+		log.Crit("Failed to store the database engine", "err", err)
+	}
+}
+
 // ReadChainConfig retrieves the consensus settings based on the given genesis hash.
 func ReadChainConfig(db ethdb.KeyValueReader, hash common.Hash) *params.ChainConfig {
 	data, _ := db.Get(configKey(hash))
@@ -208,3 +208,20 @@ func WriteSnapshotSyncStatus(db ethdb.KeyValueWriter, status []byte) {
 		log.Crit("Failed to store snapshot sync status", "err", err)
 	}
 }
+
+// WriteSnapshotImportNode records that the trie node with the given hash was
+// written by the running snapshot import, so it can be removed if the import
+// fails.
+func WriteSnapshotImportNode(db ethdb.KeyValueWriter, hash common.Hash) {
+	if err := db.Put(snapshotImportKey(hash), nil); err != nil {
+		log.Crit("Failed to store snapshot import node", "err", err)
+	}
+}
+
+// DeleteSnapshotImportNode removes the record of a trie node written by the
+// snapshot import.
+func DeleteSnapshotImportNode(db ethdb.KeyValueWriter, hash common.Hash) {
+	if err := db.Delete(snapshotImportKey(hash)); err != nil {
+		log.Crit("Failed to remove snapshot import node", "err", err)
+	}
+}
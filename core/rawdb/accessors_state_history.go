@@ -0,0 +1,199 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/ethdb"
+	"github.com/foreverbit/biternal/log"
+)
+
+// ReadStateHistoryMeta retrieves the encoded metadata of the state history item
+// of the given block from the state freezer.
+func ReadStateHistoryMeta(db ethdb.AncientReaderOp, number uint64) []byte {
+	blob, err := db.Ancient(stateHistoryMetaTable, number)
+	if err != nil {
+		return nil
+	}
+	return blob
+}
+
+// ReadStateHistory retrieves the encoded reverse diff of the state history item
+// of the given block from the state freezer.
+func ReadStateHistory(db ethdb.AncientReaderOp, number uint64) []byte {
+	blob, err := db.Ancient(stateHistoryDataTable, number)
+	if err != nil {
+		return nil
+	}
+	return blob
+}
+
+// WriteStateHistory appends the state history item of the given block to the
+// state freezer.
+func WriteStateHistory(db ethdb.AncientWriter, number uint64, meta, data []byte) error {
+	_, err := db.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		if err := op.AppendRaw(stateHistoryMetaTable, number, meta); err != nil {
+			return err
+		}
+		return op.AppendRaw(stateHistoryDataTable, number, data)
+	})
+	return err
+}
+
+// ReadStateHistoryHead retrieves the number of the newest block recorded in the
+// state history, or nil if there is no history.
+func ReadStateHistoryHead(db ethdb.KeyValueReader) *uint64 {
+	data, _ := db.Get(stateHistoryHeadKey)
+	if len(data) != 8 {
+		return nil
+	}
+	number := binary.BigEndian.Uint64(data)
+	return &number
+}
+
+// WriteStateHistoryHead stores the number of the newest block recorded in the
+// state history.
+func WriteStateHistoryHead(db ethdb.KeyValueWriter, number uint64) {
+	if err := db.Put(stateHistoryHeadKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store the state history head", "err", err)
+	}
+}
+
+// DeleteStateHistoryHead deletes the number of the newest block recorded in
+// the state history.
+func DeleteStateHistoryHead(db ethdb.KeyValueWriter) {
+	if err := db.Delete(stateHistoryHeadKey); err != nil {
+		log.Crit("Failed to delete the state history head", "err", err)
+	}
+}
+
+// ReadStateHistoryNumber retrieves the number of the block with the given state
+// root in the state history, or nil if the state is not covered.
+func ReadStateHistoryNumber(db ethdb.KeyValueReader, root common.Hash) *uint64 {
+	data, _ := db.Get(stateHistoryRootKey(root))
+	if len(data) != 8 {
+		return nil
+	}
+	number := binary.BigEndian.Uint64(data)
+	return &number
+}
+
+// WriteStateHistoryNumber stores the number of the block with the given state
+// root in the state history.
+func WriteStateHistoryNumber(db ethdb.KeyValueWriter, root common.Hash, number uint64) {
+	if err := db.Put(stateHistoryRootKey(root), encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store state history root", "err", err)
+	}
+}
+
+// DeleteStateHistoryNumber deletes the number of the block with the given state
+// root in the state history.
+func DeleteStateHistoryNumber(db ethdb.KeyValueWriter, root common.Hash) {
+	if err := db.Delete(stateHistoryRootKey(root)); err != nil {
+		log.Crit("Failed to delete state history root", "err", err)
+	}
+}
+
+// WriteStateHistoryAccountIndex marks the account as modified by the block with
+// the given number.
+func WriteStateHistoryAccountIndex(db ethdb.KeyValueWriter, accountHash common.Hash, number uint64) {
+	if err := db.Put(stateHistoryAccountKey(accountHash, number), nil); err != nil {
+		log.Crit("Failed to store state history account index", "err", err)
+	}
+}
+
+// DeleteStateHistoryAccountIndex unmarks the account as modified by the block
+// with the given number.
+func DeleteStateHistoryAccountIndex(db ethdb.KeyValueWriter, accountHash common.Hash, number uint64) {
+	if err := db.Delete(stateHistoryAccountKey(accountHash, number)); err != nil {
+		log.Crit("Failed to delete state history account index", "err", err)
+	}
+}
+
+// WriteStateHistoryStorageIndex marks the storage slot as modified by the block
+// with the given number.
+func WriteStateHistoryStorageIndex(db ethdb.KeyValueWriter, accountHash, storageHash common.Hash, number uint64) {
+	if err := db.Put(stateHistoryStorageKey(accountHash, storageHash, number), nil); err != nil {
+		log.Crit("Failed to store state history storage index", "err", err)
+	}
+}
+
+// DeleteStateHistoryStorageIndex unmarks the storage slot as modified by the
+// block with the given number.
+func DeleteStateHistoryStorageIndex(db ethdb.KeyValueWriter, accountHash, storageHash common.Hash, number uint64) {
+	if err := db.Delete(stateHistoryStorageKey(accountHash, storageHash, number)); err != nil {
+		log.Crit("Failed to delete state history storage index", "err", err)
+	}
+}
+
+// FindStateHistoryAccountIndex returns the number of the first block from the
+// given one on, which modified the account.
+func FindStateHistoryAccountIndex(db ethdb.Iteratee, accountHash common.Hash, from uint64) (uint64, bool) {
+	prefix := append(append([]byte{}, stateHistoryAccountPrefix...), accountHash.Bytes()...)
+	return findStateHistoryIndex(db, prefix, from)
+}
+
+// FindStateHistoryStorageIndex returns the number of the first block from the
+// given one on, which modified the storage slot.
+func FindStateHistoryStorageIndex(db ethdb.Iteratee, accountHash, storageHash common.Hash, from uint64) (uint64, bool) {
+	prefix := append(append([]byte{}, stateHistoryStoragePrefix...), accountHash.Bytes()...)
+	prefix = append(prefix, storageHash.Bytes()...)
+	return findStateHistoryIndex(db, prefix, from)
+}
+
+// findStateHistoryIndex returns the first block number not below the given one
+// in the index entries with the given prefix.
+func findStateHistoryIndex(db ethdb.Iteratee, prefix []byte, from uint64) (uint64, bool) {
+	it := NewKeyLengthIterator(db.NewIterator(prefix, encodeBlockNumber(from)), len(prefix)+8)
+	defer it.Release()
+
+	if !it.Next() {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(it.Key()[len(prefix):]), true
+}
+
+// DeleteStateHistoryIndex deletes the entire state history index, including the
+// state root lookups and the head marker.
+func DeleteStateHistoryIndex(db ethdb.KeyValueStore) error {
+	var (
+		batch    = db.NewBatch()
+		prefixes = [][]byte{stateHistoryAccountPrefix, stateHistoryStoragePrefix, stateHistoryRootPrefix}
+		lengths  = []int{common.HashLength + 8, 2*common.HashLength + 8, common.HashLength}
+	)
+	for i, prefix := range prefixes {
+		it := NewKeyLengthIterator(db.NewIterator(prefix, nil), len(prefix)+lengths[i])
+		for it.Next() {
+			if err := batch.Delete(it.Key()); err != nil {
+				it.Release()
+				return err
+			}
+			if batch.ValueSize() > ethdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					it.Release()
+					return err
+				}
+				batch.Reset()
+			}
+		}
+		it.Release()
+	}
+	DeleteStateHistoryHead(batch)
+	return batch.Write()
+}
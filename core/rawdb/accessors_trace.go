@@ -0,0 +1,119 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/ethdb"
+	"github.com/foreverbit/biternal/log"
+	"github.com/foreverbit/biternal/rlp"
+)
+
+// TxTraces are the results of the tracer run on the transactions of a block
+// during its import.
+type TxTraces struct {
+	Tracer  string   // Name of the tracer producing the results
+	Config  []byte   // Json-encoded config the tracer was created with
+	Results [][]byte // Json-encoded result of each transaction, in block order
+}
+
+// ReadTxTraces retrieves the transaction traces of the block with the given
+// hash and number, or nil if the block wasn't traced.
+func ReadTxTraces(db ethdb.KeyValueReader, hash common.Hash, number uint64) *TxTraces {
+	data, _ := db.Get(txTraceKey(number, hash))
+	if len(data) == 0 {
+		return nil
+	}
+	traces := new(TxTraces)
+	if err := rlp.DecodeBytes(data, traces); err != nil {
+		log.Error("Invalid transaction traces RLP", "hash", hash, "err", err)
+		return nil
+	}
+	return traces
+}
+
+// WriteTxTraces stores the transaction traces of a block.
+func WriteTxTraces(db ethdb.KeyValueWriter, hash common.Hash, number uint64, traces *TxTraces) {
+	data, err := rlp.EncodeToBytes(traces)
+	if err != nil {
+		log.Crit("Failed to encode transaction traces", "err", err)
+	}
+	if err := db.Put(txTraceKey(number, hash), data); err != nil {
+		log.Crit("Failed to store transaction traces", "err", err)
+	}
+}
+
+// DeleteTxTraces removes the transaction traces of a block.
+func DeleteTxTraces(db ethdb.KeyValueWriter, hash common.Hash, number uint64) {
+	if err := db.Delete(txTraceKey(number, hash)); err != nil {
+		log.Crit("Failed to delete transaction traces", "err", err)
+	}
+}
+
+// ReadTxTraceTail retrieves the number of the oldest block whose transaction
+// traces are retained, zero if the traces were never pruned.
+func ReadTxTraceTail(db ethdb.KeyValueReader) uint64 {
+	data, _ := db.Get(txTraceTailKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// WriteTxTraceTail stores the number of the oldest block whose transaction
+// traces are retained.
+func WriteTxTraceTail(db ethdb.KeyValueWriter, number uint64) {
+	if err := db.Put(txTraceTailKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store the transaction trace tail", "err", err)
+	}
+}
+
+// PruneTxTraces deletes the transaction traces of all blocks below the given
+// number, canonical or not, and records the new trace tail. It returns the
+// number of blocks whose traces were deleted.
+func PruneTxTraces(db ethdb.KeyValueStore, tail uint64) (int, error) {
+	from := ReadTxTraceTail(db)
+	if tail <= from {
+		return 0, nil
+	}
+	var (
+		batch   = db.NewBatch()
+		deleted int
+		it      = NewKeyLengthIterator(db.NewIterator(txTracePrefix, encodeBlockNumber(from)), len(txTracePrefix)+8+common.HashLength)
+	)
+	defer it.Release()
+
+	for it.Next() {
+		if binary.BigEndian.Uint64(it.Key()[len(txTracePrefix):]) >= tail {
+			break
+		}
+		if err := batch.Delete(it.Key()); err != nil {
+			return deleted, err
+		}
+		deleted++
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return deleted, err
+			}
+			batch.Reset()
+		}
+	}
+	WriteTxTraceTail(batch, tail)
+	return deleted, batch.Write()
+}
@@ -0,0 +1,214 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/ethdb"
+	"github.com/foreverbit/biternal/log"
+)
+
+// The list of node schemes the state trie can be stored with.
+const (
+	// HashScheme stores the trie nodes keyed by their hash. Stale nodes can
+	// only be removed from disk by offline pruning.
+	HashScheme = "hash"
+
+	// PathScheme stores the trie nodes keyed by their owner and path in the
+	// trie. Stale nodes are overwritten in place, which keeps a single state
+	// on disk along with a bounded set of reverse diffs.
+	PathScheme = "path"
+)
+
+// ReadStateScheme retrieves the node scheme the state trie was created with,
+// or the empty string if it was not recorded.
+func ReadStateScheme(db ethdb.KeyValueReader) string {
+	data, _ := db.Get(stateSchemeKey)
+	return string(data)
+}
+
+// WriteStateScheme stores the node scheme the state trie was created with.
+func WriteStateScheme(db ethdb.KeyValueWriter, scheme string) {
+	if err := db.Put(stateSchemeKey, []byte(scheme)); err != nil {
+		log.Crit("Failed to store the state scheme", "err", err)
+	}
+}
+
+// SetupStateScheme validates the requested state scheme against the one the
+// database was created with and returns the scheme to use. An empty request
+// selects the recorded scheme, or the hash scheme for new databases. The
+// scheme is recorded if the database didn't have it yet. Databases created
+// before the scheme was recorded are using the hash scheme.
+func SetupStateScheme(db ethdb.Database, provided string) (string, error) {
+	if provided != "" && provided != HashScheme && provided != PathScheme {
+		return "", fmt.Errorf("invalid state scheme %q, want %q or %q", provided, HashScheme, PathScheme)
+	}
+	stored := ReadStateScheme(db)
+	if stored == "" && ReadCanonicalHash(db, 0) != (common.Hash{}) {
+		stored = HashScheme
+	}
+	scheme := provided
+	switch {
+	case stored == "" && provided == "":
+		scheme = HashScheme
+	case stored != "" && provided == "":
+		scheme = stored
+	case stored != "" && provided != stored:
+		return "", fmt.Errorf("incompatible state scheme, stored: %s, provided: %s", stored, provided)
+	}
+	if ReadStateScheme(db) == "" {
+		WriteStateScheme(db, scheme)
+	}
+	return scheme, nil
+}
+
+// ReadAccountTrieNode retrieves the account trie node stored at the given path.
+func ReadAccountTrieNode(db ethdb.KeyValueReader, path []byte) []byte {
+	data, _ := db.Get(accountTrieNodeKey(path))
+	return data
+}
+
+// WriteAccountTrieNode writes the provided account trie node at the given path.
+func WriteAccountTrieNode(db ethdb.KeyValueWriter, path []byte, node []byte) {
+	if err := db.Put(accountTrieNodeKey(path), node); err != nil {
+		log.Crit("Failed to store account trie node", "err", err)
+	}
+}
+
+// DeleteAccountTrieNode deletes the account trie node stored at the given path.
+func DeleteAccountTrieNode(db ethdb.KeyValueWriter, path []byte) {
+	if err := db.Delete(accountTrieNodeKey(path)); err != nil {
+		log.Crit("Failed to delete account trie node", "err", err)
+	}
+}
+
+// ReadStorageTrieNode retrieves the storage trie node of the given account
+// stored at the given path.
+func ReadStorageTrieNode(db ethdb.KeyValueReader, accountHash common.Hash, path []byte) []byte {
+	data, _ := db.Get(storageTrieNodeKey(accountHash, path))
+	return data
+}
+
+// WriteStorageTrieNode writes the provided storage trie node of the given
+// account at the given path.
+func WriteStorageTrieNode(db ethdb.KeyValueWriter, accountHash common.Hash, path []byte, node []byte) {
+	if err := db.Put(storageTrieNodeKey(accountHash, path), node); err != nil {
+		log.Crit("Failed to store storage trie node", "err", err)
+	}
+}
+
+// DeleteStorageTrieNode deletes the storage trie node of the given account
+// stored at the given path.
+func DeleteStorageTrieNode(db ethdb.KeyValueWriter, accountHash common.Hash, path []byte) {
+	if err := db.Delete(storageTrieNodeKey(accountHash, path)); err != nil {
+		log.Crit("Failed to delete storage trie node", "err", err)
+	}
+}
+
+// IterateStorageTrieNodes calls fn with the path and content of every stored
+// storage trie node of the given account.
+func IterateStorageTrieNodes(db ethdb.Iteratee, accountHash common.Hash, fn func(path []byte, node []byte)) error {
+	prefix := storageTrieNodeKey(accountHash, nil)
+
+	it := db.NewIterator(prefix, nil)
+	defer it.Release()
+
+	for it.Next() {
+		fn(common.CopyBytes(it.Key()[len(prefix):]), common.CopyBytes(it.Value()))
+	}
+	return it.Error()
+}
+
+// ReadPersistentStateID retrieves the id of the state persisted in the path
+// scheme, zero if nothing was persisted yet.
+func ReadPersistentStateID(db ethdb.KeyValueReader) uint64 {
+	data, _ := db.Get(persistentStateIDKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// WritePersistentStateID stores the id of the state persisted in the path scheme.
+func WritePersistentStateID(db ethdb.KeyValueWriter, id uint64) {
+	if err := db.Put(persistentStateIDKey, encodeBlockNumber(id)); err != nil {
+		log.Crit("Failed to store the persistent state id", "err", err)
+	}
+}
+
+// ReadStateID retrieves the id of the state with the given root, or nil if
+// the state can't be reverted to.
+func ReadStateID(db ethdb.KeyValueReader, root common.Hash) *uint64 {
+	data, _ := db.Get(stateIDKey(root))
+	if len(data) != 8 {
+		return nil
+	}
+	id := binary.BigEndian.Uint64(data)
+	return &id
+}
+
+// WriteStateID stores the id of the state with the given root.
+func WriteStateID(db ethdb.KeyValueWriter, root common.Hash, id uint64) {
+	if err := db.Put(stateIDKey(root), encodeBlockNumber(id)); err != nil {
+		log.Crit("Failed to store state id", "err", err)
+	}
+}
+
+// DeleteStateID deletes the id of the state with the given root.
+func DeleteStateID(db ethdb.KeyValueWriter, root common.Hash) {
+	if err := db.Delete(stateIDKey(root)); err != nil {
+		log.Crit("Failed to delete state id", "err", err)
+	}
+}
+
+// ReadReverseDiff retrieves the encoded reverse diff reverting the state with
+// the given id to its parent.
+func ReadReverseDiff(db ethdb.KeyValueReader, id uint64) []byte {
+	data, _ := db.Get(reverseDiffKey(id))
+	return data
+}
+
+// ReadReverseDiffTail retrieves the id of the oldest reverse diff in the
+// database, zero if there are none.
+func ReadReverseDiffTail(db ethdb.Iteratee) uint64 {
+	it := NewKeyLengthIterator(db.NewIterator(reverseDiffPrefix, nil), len(reverseDiffPrefix)+8)
+	defer it.Release()
+
+	if !it.Next() {
+		return 0
+	}
+	return binary.BigEndian.Uint64(it.Key()[len(reverseDiffPrefix):])
+}
+
+// WriteReverseDiff stores the encoded reverse diff reverting the state with
+// the given id to its parent.
+func WriteReverseDiff(db ethdb.KeyValueWriter, id uint64, blob []byte) {
+	if err := db.Put(reverseDiffKey(id), blob); err != nil {
+		log.Crit("Failed to store reverse diff", "err", err)
+	}
+}
+
+// DeleteReverseDiff deletes the reverse diff reverting the state with the
+// given id to its parent.
+func DeleteReverseDiff(db ethdb.KeyValueWriter, id uint64) {
+	if err := db.Delete(reverseDiffKey(id)); err != nil {
+		log.Crit("Failed to delete reverse diff", "err", err)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"testing"
+
+	"github.com/foreverbit/biternal/common"
+)
+
+// Tests that the state scheme is recorded on first use and enforced afterwards.
+func TestSetupStateScheme(t *testing.T) {
+	tests := []struct {
+		stored   string
+		legacy   bool
+		provided string
+		want     string
+		fail     bool
+	}{
+		{provided: "", want: HashScheme},
+		{provided: PathScheme, want: PathScheme},
+		{provided: "invalid", fail: true},
+		{legacy: true, provided: "", want: HashScheme},
+		{legacy: true, provided: PathScheme, fail: true},
+		{stored: PathScheme, provided: "", want: PathScheme},
+		{stored: PathScheme, provided: PathScheme, want: PathScheme},
+		{stored: PathScheme, provided: HashScheme, fail: true},
+	}
+	for i, tt := range tests {
+		db := NewMemoryDatabase()
+		if tt.stored != "" {
+			WriteStateScheme(db, tt.stored)
+		}
+		if tt.legacy {
+			WriteCanonicalHash(db, common.Hash{0x01}, 0)
+		}
+		scheme, err := SetupStateScheme(db, tt.provided)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("test %d: expected failure, got scheme %q", i, scheme)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+			continue
+		}
+		if scheme != tt.want {
+			t.Errorf("test %d: scheme mismatch: have %q, want %q", i, scheme, tt.want)
+		}
+		if stored := ReadStateScheme(db); stored != tt.want {
+			t.Errorf("test %d: stored scheme mismatch: have %q, want %q", i, stored, tt.want)
+		}
+	}
+}
+
+// Tests that the oldest retained reverse diff is reported as the tail.
+func TestReverseDiffTail(t *testing.T) {
+	db := NewMemoryDatabase()
+	if tail := ReadReverseDiffTail(db); tail != 0 {
+		t.Fatalf("tail mismatch on empty database: have %d, want 0", tail)
+	}
+	for id := uint64(5); id < 10; id++ {
+		WriteReverseDiff(db, id, []byte{0x01})
+	}
+	if tail := ReadReverseDiffTail(db); tail != 5 {
+		t.Fatalf("tail mismatch: have %d, want 5", tail)
+	}
+	DeleteReverseDiff(db, 5)
+	if tail := ReadReverseDiffTail(db); tail != 6 {
+		t.Fatalf("tail mismatch after deletion: have %d, want 6", tail)
+	}
+}
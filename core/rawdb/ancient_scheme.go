@@ -16,7 +16,10 @@
 
 package rawdb
 
-import "fmt"
+import (
+	"fmt"
+	"path/filepath"
+)
 
 // The list of table names of chain freezer.
 const (
@@ -36,23 +39,43 @@ const (
 	chainFreezerDifficultyTable = "diffs"
 )
 
-// chainFreezerNoSnappy configures whether compression is disabled for the ancient-tables.
-// Hashes and difficulties don't compress well.
-var chainFreezerNoSnappy = map[string]bool{
-	chainFreezerHeaderTable:     false,
-	chainFreezerHashTable:       true,
-	chainFreezerBodiesTable:     false,
-	chainFreezerReceiptTable:    false,
-	chainFreezerDifficultyTable: true,
+// chainFreezerTableConfigs configures the compression of newly created ancient
+// tables. Hashes and difficulties don't compress well, receipts compress much
+// better with zstd than with snappy.
+var chainFreezerTableConfigs = map[string]freezerTableConfig{
+	chainFreezerHeaderTable:     {codec: freezerCodecSnappy},
+	chainFreezerHashTable:       {codec: freezerCodecNone},
+	chainFreezerBodiesTable:     {codec: freezerCodecSnappy},
+	chainFreezerReceiptTable:    {codec: freezerCodecZstd, level: 3},
+	chainFreezerDifficultyTable: {codec: freezerCodecNone},
+}
+
+// The list of table names of state freezer.
+const (
+	// stateHistoryMetaTable indicates the name of the freezer table storing
+	// the block hash and state root of each state history item.
+	stateHistoryMetaTable = "history.meta"
+
+	// stateHistoryDataTable indicates the name of the freezer table storing
+	// the reverse diff of each state history item.
+	stateHistoryDataTable = "history.data"
+)
+
+// stateFreezerTableConfigs configures the compression of the state freezer
+// tables. The metadata consists of hashes only.
+var stateFreezerTableConfigs = map[string]freezerTableConfig{
+	stateHistoryMetaTable: {codec: freezerCodecNone},
+	stateHistoryDataTable: {codec: freezerCodecZstd, level: 3},
 }
 
 // The list of identifiers of ancient stores.
 var (
 	chainFreezerName = "chain" // the folder name of chain segment ancient store.
+	stateFreezerName = "state" // the folder name of state history ancient store.
 )
 
 // freezers the collections of all builtin freezers.
-var freezers = []string{chainFreezerName}
+var freezers = []string{chainFreezerName, stateFreezerName}
 
 // InspectFreezerTable dumps out the index of a specific freezer table. The passed
 // ancient indicates the path of root ancient directory where the chain freezer can
@@ -61,15 +84,17 @@ var freezers = []string{chainFreezerName}
 func InspectFreezerTable(ancient string, freezerName string, tableName string, start, end int64) error {
 	var (
 		path   string
-		tables map[string]bool
+		tables map[string]freezerTableConfig
 	)
 	switch freezerName {
 	case chainFreezerName:
-		path, tables = resolveChainFreezerDir(ancient), chainFreezerNoSnappy
+		path, tables = resolveChainFreezerDir(ancient), chainFreezerTableConfigs
+	case stateFreezerName:
+		path, tables = filepath.Join(ancient, stateFreezerName), stateFreezerTableConfigs
 	default:
 		return fmt.Errorf("unknown freezer, supported ones: %v", freezers)
 	}
-	noSnappy, exist := tables[tableName]
+	config, exist := tables[tableName]
 	if !exist {
 		var names []string
 		for name := range tables {
@@ -77,7 +102,7 @@ func InspectFreezerTable(ancient string, freezerName string, tableName string, s
 		}
 		return fmt.Errorf("unknown table, supported ones: %v", names)
 	}
-	table, err := newFreezerTable(path, tableName, noSnappy, true)
+	table, err := newFreezerTable(path, tableName, resolveTableConfig(path, tableName, config), true)
 	if err != nil {
 		return err
 	}
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"path/filepath"
 	"sync/atomic"
 	"time"
 
@@ -131,6 +132,11 @@ func (db *nofreezedb) TruncateTail(items uint64) error {
 	return errNotSupported
 }
 
+// TruncateTableTail returns an error as we don't have a backing chain freezer.
+func (db *nofreezedb) TruncateTableTail(kind string, items uint64) error {
+	return errNotSupported
+}
+
 // Sync returns an error as we don't have a backing chain freezer.
 func (db *nofreezedb) Sync() error {
 	return errNotSupported
@@ -158,6 +164,11 @@ func (db *nofreezedb) MigrateTable(kind string, convert convertLegacyFn) error {
 	return errNotSupported
 }
 
+// CompressTable returns an error as we don't have a backing chain freezer.
+func (db *nofreezedb) CompressTable(kind string, codec string, level int, dict bool) error {
+	return errNotSupported
+}
+
 // AncientDatadir returns an error as we don't have a backing chain freezer.
 func (db *nofreezedb) AncientDatadir() (string, error) {
 	return "", errNotSupported
@@ -198,7 +209,7 @@ func resolveChainFreezerDir(ancient string) string {
 // where the chain freezer can be opened.
 func NewDatabaseWithFreezer(db ethdb.KeyValueStore, ancient string, namespace string, readonly bool) (ethdb.Database, error) {
 	// Create the idle freezer instance
-	frdb, err := newChainFreezer(resolveChainFreezerDir(ancient), namespace, readonly, freezerTableSize, chainFreezerNoSnappy)
+	frdb, err := newChainFreezer(resolveChainFreezerDir(ancient), namespace, readonly, freezerTableSize, chainFreezerTableConfigs)
 	if err != nil {
 		return nil, err
 	}
@@ -321,6 +332,113 @@ func NewLevelDBDatabaseWithFreezer(file string, cache int, handles int, ancient
 	return frdb, nil
 }
 
+const (
+	// DBLeveldb is the identifier of the leveldb key-value database engine.
+	DBLeveldb = "leveldb"
+
+	// DBPebble is the identifier of the pebble key-value database engine.
+	DBPebble = "pebble"
+)
+
+// OpenOptions contains the options to apply when opening a database. If the
+// AncientsDirectory is empty, no freezer is attached to the database.
+type OpenOptions struct {
+	Type              string // "leveldb" | "pebble", empty to detect or default to leveldb
+	Directory         string // the datadir
+	AncientsDirectory string // the ancients-dir
+	Namespace         string // the namespace for database relevant metrics
+	Cache             int    // the capacity(in megabytes) of the data caching
+	Handles           int    // number of files to be open simultaneously
+	ReadOnly          bool
+}
+
+// PreexistingDatabase checks the given data directory whether a database is
+// already instantiated at that location, and if so, returns the type of
+// database (or the empty string).
+func PreexistingDatabase(path string) string {
+	if _, err := os.Stat(filepath.Join(path, "CURRENT")); err != nil {
+		return "" // No pre-existing db
+	}
+	// Pebble keeps its configuration in OPTIONS-<number> files, leveldb doesn't
+	if matches, err := filepath.Glob(filepath.Join(path, "OPTIONS*")); len(matches) > 0 || err != nil {
+		if err != nil {
+			panic(err) // only possible if the pattern is malformed
+		}
+		return DBPebble
+	}
+	return DBLeveldb
+}
+
+// openKeyValueDatabase opens a disk-based key-value database, e.g. leveldb or
+// pebble.
+//
+//	                      type == null          type != null
+//	                   +----------------------------------------
+//	db is non-existent |  leveldb default  |  specified type
+//	db is existent     |  from db          |  specified type (if compatible)
+func openKeyValueDatabase(o OpenOptions) (ethdb.Database, error) {
+	existing := PreexistingDatabase(o.Directory)
+	if existing != "" && o.Type != "" && o.Type != existing {
+		return nil, fmt.Errorf("db.engine choice was %v but found pre-existing %v database in specified data directory", o.Type, existing)
+	}
+	engine := o.Type
+	if engine == "" {
+		engine = existing
+	}
+	if engine == "" {
+		engine = DBLeveldb
+	}
+	var (
+		db  ethdb.Database
+		err error
+	)
+	switch engine {
+	case DBPebble:
+		if !PebbleEnabled {
+			return nil, errors.New("db.engine 'pebble' not supported on this platform")
+		}
+		log.Info("Using pebble as the backing database")
+		db, err = NewPebbleDBDatabase(o.Directory, o.Cache, o.Handles, o.Namespace, o.ReadOnly)
+	case DBLeveldb:
+		log.Info("Using leveldb as the backing database")
+		db, err = NewLevelDBDatabase(o.Directory, o.Cache, o.Handles, o.Namespace, o.ReadOnly)
+	default:
+		return nil, fmt.Errorf("unknown db.engine %v", engine)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Cross-check the engine against the one recorded in the database itself,
+	// recording it if the database was just created.
+	switch stored := ReadDatabaseEngine(db); {
+	case stored == "" && !o.ReadOnly:
+		WriteDatabaseEngine(db, engine)
+	case stored != "" && stored != engine:
+		db.Close()
+		return nil, fmt.Errorf("database created with engine %v, opened with %v", stored, engine)
+	}
+	return db, nil
+}
+
+// Open opens a disk-based key-value database such as leveldb or pebble and, if
+// an ancients directory is configured, attaches a chain freezer to it moving
+// immutable chain segments into cold storage.
+func Open(o OpenOptions) (ethdb.Database, error) {
+	kvdb, err := openKeyValueDatabase(o)
+	if err != nil {
+		return nil, err
+	}
+	if len(o.AncientsDirectory) == 0 {
+		return kvdb, nil
+	}
+	frdb, err := NewDatabaseWithFreezer(kvdb, o.AncientsDirectory, o.Namespace, o.ReadOnly)
+	if err != nil {
+		kvdb.Close()
+		return nil, err
+	}
+	return frdb, nil
+}
+
 type counter uint64
 
 func (c counter) String() string {
@@ -370,8 +488,14 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		numHashPairings stat
 		hashNumPairings stat
 		tries           stat
+		pathTries       stat
+		reverseDiffs    stat
+		stateIDs        stat
+		historyIndex    stat
+		historyRoots    stat
 		codes           stat
 		txLookups       stat
+		txTraces        stat
 		accountSnaps    stat
 		storageSnaps    stat
 		preimages       stat
@@ -419,10 +543,26 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 			hashNumPairings.Add(size)
 		case len(key) == common.HashLength:
 			tries.Add(size)
+		case bytes.HasPrefix(key, TrieNodeAccountPrefix) && len(key) <= len(TrieNodeAccountPrefix)+2*common.HashLength:
+			pathTries.Add(size)
+		case bytes.HasPrefix(key, TrieNodeStoragePrefix) && len(key) >= len(TrieNodeStoragePrefix)+common.HashLength && len(key) <= len(TrieNodeStoragePrefix)+3*common.HashLength:
+			pathTries.Add(size)
+		case bytes.HasPrefix(key, reverseDiffPrefix) && len(key) == len(reverseDiffPrefix)+8:
+			reverseDiffs.Add(size)
+		case bytes.HasPrefix(key, stateIDPrefix) && len(key) == len(stateIDPrefix)+common.HashLength:
+			stateIDs.Add(size)
+		case bytes.HasPrefix(key, stateHistoryAccountPrefix) && len(key) == len(stateHistoryAccountPrefix)+common.HashLength+8:
+			historyIndex.Add(size)
+		case bytes.HasPrefix(key, stateHistoryStoragePrefix) && len(key) == len(stateHistoryStoragePrefix)+2*common.HashLength+8:
+			historyIndex.Add(size)
+		case bytes.HasPrefix(key, stateHistoryRootPrefix) && len(key) == len(stateHistoryRootPrefix)+common.HashLength:
+			historyRoots.Add(size)
 		case bytes.HasPrefix(key, CodePrefix) && len(key) == len(CodePrefix)+common.HashLength:
 			codes.Add(size)
 		case bytes.HasPrefix(key, txLookupPrefix) && len(key) == (len(txLookupPrefix)+common.HashLength):
 			txLookups.Add(size)
+		case bytes.HasPrefix(key, txTracePrefix) && len(key) == (len(txTracePrefix)+8+common.HashLength):
+			txTraces.Add(size)
 		case bytes.HasPrefix(key, SnapshotAccountPrefix) && len(key) == (len(SnapshotAccountPrefix)+common.HashLength):
 			accountSnaps.Add(size)
 		case bytes.HasPrefix(key, SnapshotStoragePrefix) && len(key) == (len(SnapshotStoragePrefix)+2*common.HashLength):
@@ -452,10 +592,11 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		default:
 			var accounted bool
 			for _, meta := range [][]byte{
-				databaseVersionKey, headHeaderKey, headBlockKey, headFastBlockKey, headFinalizedBlockKey,
+				databaseVersionKey, databaseEngineKey, headHeaderKey, headBlockKey, headFastBlockKey, headFinalizedBlockKey,
 				lastPivotKey, fastTrieProgressKey, snapshotDisabledKey, SnapshotRootKey, snapshotJournalKey,
-				snapshotGeneratorKey, snapshotRecoveryKey, txIndexTailKey, fastTxLookupLimitKey,
+				snapshotGeneratorKey, snapshotRecoveryKey, txIndexTailKey, fastTxLookupLimitKey, historyTailKey,
 				uncleanShutdownKey, badBlockKey, transitionStatusKey, skeletonSyncStatusKey,
+				stateSchemeKey, persistentStateIDKey, stateHistoryHeadKey, txTraceTailKey,
 			} {
 				if bytes.Equal(key, meta) {
 					metadata.Add(size)
@@ -495,9 +636,15 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		{"Key-Value store", "Block number->hash", numHashPairings.Size(), numHashPairings.Count()},
 		{"Key-Value store", "Block hash->number", hashNumPairings.Size(), hashNumPairings.Count()},
 		{"Key-Value store", "Transaction index", txLookups.Size(), txLookups.Count()},
+		{"Key-Value store", "Transaction traces", txTraces.Size(), txTraces.Count()},
 		{"Key-Value store", "Bloombit index", bloomBits.Size(), bloomBits.Count()},
 		{"Key-Value store", "Contract codes", codes.Size(), codes.Count()},
 		{"Key-Value store", "Trie nodes", tries.Size(), tries.Count()},
+		{"Key-Value store", "Path trie nodes", pathTries.Size(), pathTries.Count()},
+		{"Key-Value store", "Reverse diffs", reverseDiffs.Size(), reverseDiffs.Count()},
+		{"Key-Value store", "State id lookups", stateIDs.Size(), stateIDs.Count()},
+		{"Key-Value store", "State history index", historyIndex.Size(), historyIndex.Count()},
+		{"Key-Value store", "State history roots", historyRoots.Size(), historyRoots.Count()},
 		{"Key-Value store", "Trie preimages", preimages.Size(), preimages.Count()},
 		{"Key-Value store", "Account snapshot", accountSnaps.Size(), accountSnaps.Count()},
 		{"Key-Value store", "Storage snapshot", storageSnaps.Size(), storageSnaps.Count()},
@@ -15,3 +15,43 @@
 // along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
 
 package rawdb
+
+import (
+	"strings"
+	"testing"
+)
+
+// Tests that the database engine is detected from an existing datadir, recorded
+// in the database metadata and that opening with another engine is refused.
+func TestOpenDatabaseEngine(t *testing.T) {
+	dir := t.TempDir()
+
+	// A fresh datadir defaults to leveldb and records the engine
+	db, err := Open(OpenOptions{Directory: dir})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if engine := ReadDatabaseEngine(db); engine != DBLeveldb {
+		t.Fatalf("recorded engine mismatch: have %q, want %q", engine, DBLeveldb)
+	}
+	db.Close()
+
+	if engine := PreexistingDatabase(dir); engine != DBLeveldb {
+		t.Fatalf("detected engine mismatch: have %q, want %q", engine, DBLeveldb)
+	}
+	// Reopening the datadir with a different engine must fail
+	if _, err := Open(OpenOptions{Type: DBPebble, Directory: dir}); err == nil {
+		t.Fatalf("opened leveldb database with pebble")
+	}
+	// A recorded engine different from the one on disk must fail too
+	db, err = Open(OpenOptions{Type: DBLeveldb, Directory: dir})
+	if err != nil {
+		t.Fatalf("failed to reopen database: %v", err)
+	}
+	WriteDatabaseEngine(db, DBPebble)
+	db.Close()
+
+	if _, err := Open(OpenOptions{Directory: dir}); err == nil || !strings.Contains(err.Error(), "created with engine pebble") {
+		t.Fatalf("engine mismatch not detected: %v", err)
+	}
+}
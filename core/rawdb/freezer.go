@@ -85,6 +85,12 @@ type Freezer struct {
 // The 'tables' argument defines the data tables. If the value of a map
 // entry is true, snappy compression is disabled for the table.
 func NewFreezer(datadir string, namespace string, readonly bool, maxTableSize uint32, tables map[string]bool) (*Freezer, error) {
+	return newFreezer(datadir, namespace, readonly, maxTableSize, freezerTableConfigs(tables))
+}
+
+// newFreezer creates a freezer instance with the given compression settings
+// for newly created tables.
+func newFreezer(datadir string, namespace string, readonly bool, maxTableSize uint32, tables map[string]freezerTableConfig) (*Freezer, error) {
 	// Create the initial freezer object
 	var (
 		readMeter  = metrics.NewRegisteredMeter(namespace+"ancient/read", nil)
@@ -112,8 +118,9 @@ func NewFreezer(datadir string, namespace string, readonly bool, maxTableSize ui
 	}
 
 	// Create the tables.
-	for name, disableSnappy := range tables {
-		table, err := newTable(datadir, name, readMeter, writeMeter, sizeGauge, maxTableSize, disableSnappy, readonly)
+	for name, config := range tables {
+		config = resolveTableConfig(datadir, name, config)
+		table, err := newTableWithConfig(datadir, name, readMeter, writeMeter, sizeGauge, maxTableSize, config, readonly)
 		if err != nil {
 			for _, table := range freezer.tables {
 				table.Close()
@@ -304,6 +311,44 @@ func (f *Freezer) TruncateTail(tail uint64) error {
 	return nil
 }
 
+// TruncateTableTail discards the data of the given table below the provided
+// threshold number. The tail of the freezer, common to all tables, is unchanged.
+func (f *Freezer) TruncateTableTail(kind string, tail uint64) error {
+	if f.readonly {
+		return errReadOnly
+	}
+	f.writeLock.Lock()
+	defer f.writeLock.Unlock()
+
+	table := f.tables[kind]
+	if table == nil {
+		return errUnknownTable
+	}
+	return table.truncateTail(tail)
+}
+
+// resetTail sets the tail of an empty freezer, the first item appended to it
+// will have the given number.
+func (f *Freezer) resetTail(tail uint64) error {
+	if f.readonly {
+		return errReadOnly
+	}
+	f.writeLock.Lock()
+	defer f.writeLock.Unlock()
+
+	if frozen := atomic.LoadUint64(&f.frozen); frozen != 0 {
+		return fmt.Errorf("freezer not empty: %d items", frozen)
+	}
+	for _, table := range f.tables {
+		if err := table.resetTail(tail); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, tail)
+	atomic.StoreUint64(&f.tail, tail)
+	return nil
+}
+
 // Sync flushes all data tables to disk.
 func (f *Freezer) Sync() error {
 	var errs []error
@@ -346,10 +391,14 @@ func (f *Freezer) validate() error {
 }
 
 // repair truncates all data tables to the same length.
+//
+// The tables may have different tails if some of them were truncated alone, so
+// the common tail is the lowest one. An interrupted tail truncation of all the
+// tables is completed by the next one.
 func (f *Freezer) repair() error {
 	var (
 		head = uint64(math.MaxUint64)
-		tail = uint64(0)
+		tail = uint64(math.MaxUint64)
 	)
 	for _, table := range f.tables {
 		items := atomic.LoadUint64(&table.items)
@@ -357,10 +406,13 @@ func (f *Freezer) repair() error {
 			head = items
 		}
 		hidden := atomic.LoadUint64(&table.itemHidden)
-		if hidden > tail {
+		if hidden < tail {
 			tail = hidden
 		}
 	}
+	if len(f.tables) == 0 {
+		tail = 0
+	}
 	for _, table := range f.tables {
 		if err := table.truncateHead(head); err != nil {
 			return err
@@ -391,6 +443,50 @@ func (f *Freezer) MigrateTable(kind string, convert convertLegacyFn) error {
 	if !ok {
 		return errUnknownTable
 	}
+	return f.rewriteTable(table, table.config, convert)
+}
+
+// CompressTable rewrites all entries of the given table with the given
+// compression codec. The level and the dictionary only apply to
+// zstd, a zero level selecting the default one.
+func (f *Freezer) CompressTable(kind string, codec string, level int, dict bool) error {
+	if f.readonly {
+		return errReadOnly
+	}
+	f.writeLock.Lock()
+	defer f.writeLock.Unlock()
+
+	table, ok := f.tables[kind]
+	if !ok {
+		return errUnknownTable
+	}
+	c, err := parseFreezerCodec(codec)
+	if err != nil {
+		return err
+	}
+	if level < 0 || level > 22 {
+		return fmt.Errorf("invalid compression level %d", level)
+	}
+	config := freezerTableConfig{codec: c}
+	if c == freezerCodecZstd {
+		config.level = level
+		if dict {
+			start := time.Now()
+			if config.dict, err = sampleFreezerDict(table, atomic.LoadUint64(&table.itemHidden), atomic.LoadUint64(&table.items)); err != nil {
+				return err
+			}
+			log.Info("Sampled compression dictionary", "table", kind, "size", common.StorageSize(len(config.dict)), "elapsed", common.PrettyDuration(time.Since(start)))
+		}
+	}
+	log.Info("Compressing freezer table", "table", kind, "from", table.config.codec, "to", config.codec, "level", config.level)
+	return f.rewriteTable(table, config, func(blob []byte) ([]byte, error) { return blob, nil })
+}
+
+// rewriteTable rewrites the entries of the given table into a new table with
+// the given compression settings, converting each entry with the given function,
+// and replaces the table with the new one. The entries removed from the tail of
+// the table are not carried over. It assumes the write lock is held.
+func (f *Freezer) rewriteTable(table *freezerTable, config freezerTableConfig, convert convertLegacyFn) error {
 	// forEach iterates every entry in the table serially and in order, calling `fn`
 	// with the item as argument. If `fn` returns an error the iteration stops
 	// and that error will be returned.
@@ -417,19 +513,25 @@ func (f *Freezer) MigrateTable(kind string, convert convertLegacyFn) error {
 		}
 		return nil
 	}
-	// TODO(s1na): This is a sanity-check since as of now no process does tail-deletion. But the migration
-	// process assumes no deletion at tail and needs to be modified to account for that.
-	if table.itemOffset > 0 || table.itemHidden > 0 {
-		return fmt.Errorf("migration not supported for tail-deleted freezers")
-	}
-	ancientsPath := filepath.Dir(table.index.Name())
+	var (
+		kind          = table.name
+		ancientsPath  = filepath.Dir(table.index.Name())
+		migrationPath = filepath.Join(ancientsPath, "migration")
+	)
 	// Set up new dir for the migrated table, the content of which
 	// we'll at the end move over to the ancients dir.
-	migrationPath := filepath.Join(ancientsPath, "migration")
-	newTable, err := newFreezerTable(migrationPath, kind, table.noCompression, false)
+	newTable, err := newFreezerTable(migrationPath, kind, config, false)
 	if err != nil {
 		return err
 	}
+	// Entries deleted from the tail are not migrated, start the new table at
+	// the tail of the old one.
+	if tail := atomic.LoadUint64(&table.itemHidden); newTable.items == 0 && tail > 0 {
+		if err := newTable.resetTail(tail); err != nil {
+			newTable.Close()
+			return err
+		}
+	}
 	var (
 		batch  = newTable.newBatch()
 		out    []byte
@@ -437,7 +539,7 @@ func (f *Freezer) MigrateTable(kind string, convert convertLegacyFn) error {
 		logged = time.Now()
 		offset = newTable.items
 	)
-	if offset > 0 {
+	if offset > atomic.LoadUint64(&table.itemHidden) {
 		log.Info("found previous migration attempt", "migrated", offset)
 	}
 	// Iterate through entries and transform them
@@ -455,16 +557,13 @@ func (f *Freezer) MigrateTable(kind string, convert convertLegacyFn) error {
 		}
 		return nil
 	}); err != nil {
+		newTable.Close()
 		return err
 	}
 	if err := batch.commit(); err != nil {
+		newTable.Close()
 		return err
 	}
-	log.Info("Replacing old table files with migrated ones", "elapsed", common.PrettyDuration(time.Since(start)))
-	// Release and delete old table files. Note this won't
-	// delete the index file.
-	table.releaseFilesAfter(0, true)
-
 	if err := newTable.Close(); err != nil {
 		return err
 	}
@@ -472,16 +571,52 @@ func (f *Freezer) MigrateTable(kind string, convert convertLegacyFn) error {
 	if err != nil {
 		return err
 	}
-	// Move migrated files to ancients dir.
-	for _, f := range files {
-		// This will replace the old index file as a side-effect.
-		if err := os.Rename(filepath.Join(migrationPath, f.Name()), filepath.Join(ancientsPath, f.Name())); err != nil {
-			return err
-		}
+	log.Info("Replacing old table files with migrated ones", "elapsed", common.PrettyDuration(time.Since(start)))
+
+	// Release and delete old table files. The index file is replaced by the
+	// migrated one, unless the compression of the table changed.
+	size, err := table.sizeNolock()
+	if err != nil {
+		return err
 	}
-	// Delete by now empty dir.
-	if err := os.Remove(migrationPath); err != nil {
+	table.sizeGauge.Dec(int64(size))
+	table.releaseFilesBefore(math.MaxUint32, true)
+
+	index := table.index.Name()
+	migrateErr := table.Close()
+	if migrateErr == nil {
+		migrateErr = func() error {
+			// Move migrated files to ancients dir.
+			for _, f := range files {
+				// This will replace the old index file as a side-effect.
+				if err := os.Rename(filepath.Join(migrationPath, f.Name()), filepath.Join(ancientsPath, f.Name())); err != nil {
+					return err
+				}
+			}
+			if _, err := os.Stat(index); err == nil {
+				if table.config.compressed() != config.compressed() {
+					if err := os.Remove(index); err != nil {
+						return err
+					}
+				}
+			}
+			// Delete by now empty dir.
+			return os.Remove(migrationPath)
+		}()
+	}
+	// Reopen the migrated table in place of the old one, or the old one if the
+	// files could not be replaced, so that no closed table is left behind.
+	reopen := config
+	if migrateErr != nil {
+		reopen = table.config
+	}
+	replaced, err := newTableWithConfig(table.path, kind, table.readMeter, table.writeMeter, table.sizeGauge, table.maxFileSize, reopen, false)
+	if err != nil {
+		if migrateErr != nil {
+			return migrateErr
+		}
 		return err
 	}
-	return nil
+	f.tables[kind] = replaced
+	return migrateErr
 }
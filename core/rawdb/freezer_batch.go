@@ -22,7 +22,6 @@ import (
 
 	"github.com/foreverbit/biternal/common/math"
 	"github.com/foreverbit/biternal/rlp"
-	"github.com/golang/snappy"
 )
 
 // This is the maximum amount of data that will be buffered in memory
@@ -85,7 +84,8 @@ func (batch *freezerBatch) commit() (item uint64, writeSize int64, err error) {
 type freezerTableBatch struct {
 	t *freezerTable
 
-	sb          *snappyBuffer
+	codec       itemCodec
+	compBuffer  []byte
 	encBuffer   writeBuffer
 	dataBuffer  []byte
 	indexBuffer []byte
@@ -95,10 +95,7 @@ type freezerTableBatch struct {
 
 // newBatch creates a new batch for the freezer table.
 func (t *freezerTable) newBatch() *freezerTableBatch {
-	batch := &freezerTableBatch{t: t}
-	if !t.noCompression {
-		batch.sb = new(snappyBuffer)
-	}
+	batch := &freezerTableBatch{t: t, codec: t.codec}
 	batch.reset()
 	return batch
 }
@@ -124,11 +121,7 @@ func (batch *freezerTableBatch) Append(item uint64, data interface{}) error {
 	if err := rlp.Encode(&batch.encBuffer, data); err != nil {
 		return err
 	}
-	encItem := batch.encBuffer.data
-	if batch.sb != nil {
-		encItem = batch.sb.compress(encItem)
-	}
-	return batch.appendItem(encItem)
+	return batch.appendItem(batch.compress(batch.encBuffer.data))
 }
 
 // AppendRaw injects a binary blob at the end of the freezer table. The item number is a
@@ -139,11 +132,17 @@ func (batch *freezerTableBatch) AppendRaw(item uint64, blob []byte) error {
 		return fmt.Errorf("%w: have %d want %d", errOutOrderInsertion, item, batch.curItem)
 	}
 
-	encItem := blob
-	if batch.sb != nil {
-		encItem = batch.sb.compress(blob)
+	return batch.appendItem(batch.compress(blob))
+}
+
+// compress compresses the item with the codec of the table, reusing the
+// compression buffer of the batch.
+func (batch *freezerTableBatch) compress(item []byte) []byte {
+	if batch.codec == nil {
+		return item
 	}
-	return batch.appendItem(encItem)
+	batch.compBuffer = batch.codec.encode(batch.compBuffer, item)
+	return batch.compBuffer
 }
 
 func (batch *freezerTableBatch) appendItem(data []byte) error {
@@ -209,30 +208,6 @@ func (batch *freezerTableBatch) commit() error {
 	return nil
 }
 
-// snappyBuffer writes snappy in block format, and can be reused. It is
-// reset when WriteTo is called.
-type snappyBuffer struct {
-	dst []byte
-}
-
-// compress snappy-compresses the data.
-func (s *snappyBuffer) compress(data []byte) []byte {
-	// The snappy library does not care what the capacity of the buffer is,
-	// but only checks the length. If the length is too small, it will
-	// allocate a brand new buffer.
-	// To avoid that, we check the required size here, and grow the size of the
-	// buffer to utilize the full capacity.
-	if n := snappy.MaxEncodedLen(len(data)); len(s.dst) < n {
-		if cap(s.dst) < n {
-			s.dst = make([]byte, n)
-		}
-		s.dst = s.dst[:n]
-	}
-
-	s.dst = snappy.Encode(s.dst, data)
-	return s.dst
-}
-
 // writeBuffer implements io.Writer for a byte slice.
 type writeBuffer struct {
 	data []byte
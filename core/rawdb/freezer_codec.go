@@ -0,0 +1,237 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"fmt"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// freezerCodec is the compression algorithm of the items in a freezer table.
+type freezerCodec uint8
+
+const (
+	// freezerCodecLegacy indicates that the codec isn't recorded in the table
+	// metadata. Such tables are snappy compressed, unless they are raw tables.
+	freezerCodecLegacy freezerCodec = iota
+
+	freezerCodecNone   // Items are stored uncompressed
+	freezerCodecSnappy // Items are compressed with snappy in block format
+	freezerCodecZstd   // Items are compressed with zstd, optionally with a dictionary
+)
+
+// String implements fmt.Stringer.
+func (c freezerCodec) String() string {
+	switch c {
+	case freezerCodecLegacy:
+		return "legacy"
+	case freezerCodecNone:
+		return "none"
+	case freezerCodecSnappy:
+		return "snappy"
+	case freezerCodecZstd:
+		return "zstd"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(c))
+	}
+}
+
+// parseFreezerCodec parses the name of a codec.
+func parseFreezerCodec(name string) (freezerCodec, error) {
+	switch name {
+	case "none":
+		return freezerCodecNone, nil
+	case "snappy":
+		return freezerCodecSnappy, nil
+	case "zstd":
+		return freezerCodecZstd, nil
+	default:
+		return 0, fmt.Errorf("unknown freezer codec %q, want none, snappy or zstd", name)
+	}
+}
+
+const (
+	// freezerDictID is the identifier of zstd dictionaries of freezer tables.
+	// A table has at most one dictionary, so it needs to be non-zero only to
+	// make the decoder verify that items were compressed with a dictionary.
+	freezerDictID = 1
+
+	// freezerDictSize is the size of the zstd dictionaries sampled from the
+	// content of freezer tables.
+	freezerDictSize = 112 * 1024
+
+	// freezerDictSamples is the maximum number of items sampled from a freezer
+	// table to build a zstd dictionary.
+	freezerDictSamples = 4096
+)
+
+// freezerTableConfig contains the compression settings of a freezer table.
+// The settings only apply to newly created tables, existing tables keep using
+// the settings recorded in their metadata.
+type freezerTableConfig struct {
+	codec freezerCodec // Compression algorithm of the items
+	level int          // Compression level, only used by zstd
+	dict  []byte       // Raw content dictionary, only used by zstd
+}
+
+// compressed returns whether the table is stored in compressed files.
+func (c freezerTableConfig) compressed() bool {
+	return c.codec != freezerCodecNone
+}
+
+// freezerTableConfigs converts the legacy table definitions, whether snappy
+// compression is disabled for each table, into table configs.
+func freezerTableConfigs(tables map[string]bool) map[string]freezerTableConfig {
+	configs := make(map[string]freezerTableConfig, len(tables))
+	for name, noSnappy := range tables {
+		if noSnappy {
+			configs[name] = freezerTableConfig{codec: freezerCodecNone}
+		} else {
+			configs[name] = freezerTableConfig{codec: freezerCodecSnappy}
+		}
+	}
+	return configs
+}
+
+// itemCodec compresses and decompresses the items of a freezer table.
+type itemCodec interface {
+	// encode compresses the item, reusing the given buffer if it's large enough.
+	encode(buf []byte, item []byte) []byte
+
+	// decode decompresses the item.
+	decode(item []byte) ([]byte, error)
+
+	// close releases any resources held by the codec.
+	close()
+}
+
+// newItemCodec creates the codec for the given table settings, or nil if the
+// items are not compressed.
+func newItemCodec(config freezerTableConfig) (itemCodec, error) {
+	switch config.codec {
+	case freezerCodecNone:
+		return nil, nil
+	case freezerCodecSnappy:
+		return snappyCodec{}, nil
+	case freezerCodecZstd:
+		return newZstdCodec(config.level, config.dict)
+	default:
+		return nil, fmt.Errorf("unsupported freezer codec %v", config.codec)
+	}
+}
+
+// snappyCodec compresses items with snappy in block format.
+type snappyCodec struct{}
+
+func (snappyCodec) encode(buf []byte, item []byte) []byte {
+	// The snappy library does not care what the capacity of the buffer is,
+	// but only checks the length. If the length is too small, it will
+	// allocate a brand new buffer.
+	// To avoid that, we check the required size here, and grow the size of the
+	// buffer to utilize the full capacity.
+	if n := snappy.MaxEncodedLen(len(item)); len(buf) < n {
+		if cap(buf) < n {
+			buf = make([]byte, n)
+		}
+		buf = buf[:n]
+	}
+	return snappy.Encode(buf, item)
+}
+
+func (snappyCodec) decode(item []byte) ([]byte, error) {
+	return snappy.Decode(nil, item)
+}
+
+func (snappyCodec) close() {}
+
+// zstdCodec compresses items with zstd, optionally with a dictionary. Every
+// item is compressed into an individual frame.
+type zstdCodec struct {
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+// newZstdCodec creates a zstd codec with the given compression level and raw
+// content dictionary.
+func newZstdCodec(level int, dict []byte) (*zstdCodec, error) {
+	var (
+		eopts = []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+		dopts = []zstd.DOption{zstd.WithDecoderConcurrency(1)}
+	)
+	if level != 0 {
+		eopts = append(eopts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+	}
+	if len(dict) > 0 {
+		eopts = append(eopts, zstd.WithEncoderDictRaw(freezerDictID, dict))
+		dopts = append(dopts, zstd.WithDecoderDictRaw(freezerDictID, dict))
+	}
+	encoder, err := zstd.NewWriter(nil, eopts...)
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := zstd.NewReader(nil, dopts...)
+	if err != nil {
+		encoder.Close()
+		return nil, err
+	}
+	return &zstdCodec{encoder: encoder, decoder: decoder}, nil
+}
+
+func (c *zstdCodec) encode(buf []byte, item []byte) []byte {
+	return c.encoder.EncodeAll(item, buf[:0])
+}
+
+func (c *zstdCodec) decode(item []byte) ([]byte, error) {
+	return c.decoder.DecodeAll(item, nil)
+}
+
+func (c *zstdCodec) close() {
+	c.encoder.Close()
+	c.decoder.Close()
+}
+
+// sampleFreezerDict builds a raw content dictionary by concatenating the prefixes
+// of items sampled evenly across the given range of the table. The dictionary is
+// not trained, zstd only references its content as if it preceded every item.
+// Content at the end of a raw dictionary is the cheapest to reference, so the
+// samples are ordered from the oldest to the newest item.
+func sampleFreezerDict(t *freezerTable, start, end uint64) ([]byte, error) {
+	if start >= end {
+		return nil, nil
+	}
+	var (
+		dict  = make([]byte, 0, freezerDictSize)
+		step  = (end - start + freezerDictSamples - 1) / freezerDictSamples
+		limit = freezerDictSize / 64 // Keep samples short to cover more items
+	)
+	for i := start; i < end && len(dict) < freezerDictSize; i += step {
+		item, err := t.Retrieve(i)
+		if err != nil {
+			return nil, err
+		}
+		if len(item) > limit {
+			item = item[:limit]
+		}
+		if free := freezerDictSize - len(dict); len(item) > free {
+			item = item[:free]
+		}
+		dict = append(dict, item...)
+	}
+	return dict, nil
+}
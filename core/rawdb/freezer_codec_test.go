@@ -0,0 +1,199 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/foreverbit/biternal/ethdb"
+	"github.com/foreverbit/biternal/metrics"
+)
+
+// checkChunks verifies that the table contains the test chunks in [from, to).
+func checkChunks(t *testing.T, ft *freezerTable, from, to int, length int) {
+	t.Helper()
+
+	items, err := ft.RetrieveItems(uint64(from), uint64(to-from), 1<<30)
+	if err != nil {
+		t.Fatalf("failed to retrieve items: %v", err)
+	}
+	if len(items) != to-from {
+		t.Fatalf("item count mismatch: have %d, want %d", len(items), to-from)
+	}
+	for i, item := range items {
+		if want := getChunk(length, from+i); !bytes.Equal(item, want) {
+			t.Fatalf("item %d mismatch: have %x, want %x", from+i, item, want)
+		}
+	}
+}
+
+// Tests that items are stored and retrieved with every codec, and that tables
+// keep using the codec they were created with.
+func TestFreezerTableCodecs(t *testing.T) {
+	t.Parallel()
+
+	configs := []freezerTableConfig{
+		{codec: freezerCodecNone},
+		{codec: freezerCodecSnappy},
+		{codec: freezerCodecZstd},
+		{codec: freezerCodecZstd, level: 9, dict: getChunk(1024, 7)},
+	}
+	for i, config := range configs {
+		var (
+			dir         = t.TempDir()
+			rm, wm, sg  = metrics.NewMeter(), metrics.NewMeter(), metrics.NewGauge()
+			fname       = "codec"
+			reopenWith  = freezerTableConfig{codec: freezerCodecSnappy}
+			expectCodec = config.codec
+		)
+		if !config.compressed() {
+			reopenWith = config
+		}
+		f, err := newTableWithConfig(dir, fname, rm, wm, sg, 1024, config, false)
+		if err != nil {
+			t.Fatalf("test %d: failed to create table: %v", i, err)
+		}
+		writeChunks(t, f, 64, 100)
+		checkChunks(t, f, 0, 64, 100)
+		f.Close()
+
+		// Reopen the table with different settings, the recorded ones must be used
+		f, err = newTableWithConfig(dir, fname, rm, wm, sg, 1024, reopenWith, false)
+		if err != nil {
+			t.Fatalf("test %d: failed to reopen table: %v", i, err)
+		}
+		if f.config.codec != expectCodec || f.config.level != config.level || !bytes.Equal(f.config.dict, config.dict) {
+			t.Fatalf("test %d: config mismatch: have %v/%d, want %v/%d", i, f.config.codec, f.config.level, expectCodec, config.level)
+		}
+		checkChunks(t, f, 0, 64, 100)
+		f.Close()
+	}
+}
+
+// Tests that tables with metadata predating the codec record are treated as
+// snappy compressed, regardless of the configured codec.
+func TestFreezerTableLegacyMeta(t *testing.T) {
+	t.Parallel()
+
+	var (
+		dir        = t.TempDir()
+		rm, wm, sg = metrics.NewMeter(), metrics.NewMeter(), metrics.NewGauge()
+		fname      = "legacy"
+	)
+	f, err := newTable(dir, fname, rm, wm, sg, 1024, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeChunks(t, f, 32, 50)
+	if err := writeMetadata(f.meta, newMetadata(0)); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	f, err = newTableWithConfig(dir, fname, rm, wm, sg, 1024, freezerTableConfig{codec: freezerCodecZstd}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if f.config.codec != freezerCodecSnappy {
+		t.Fatalf("codec mismatch: have %v, want %v", f.config.codec, freezerCodecSnappy)
+	}
+	checkChunks(t, f, 0, 32, 50)
+}
+
+// Tests that the tables of a freezer can be converted to other codecs in place,
+// including tables with deleted tail items.
+func TestFreezerCompressTable(t *testing.T) {
+	t.Parallel()
+
+	tables := map[string]bool{"a": false, "b": true}
+	f, dir := newFreezerForTesting(t, tables)
+
+	_, err := f.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		for i := 0; i < 100; i++ {
+			if err := op.AppendRaw("a", uint64(i), getChunk(256, i)); err != nil {
+				return err
+			}
+			if err := op.AppendRaw("b", uint64(i), getChunk(256, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal("ModifyAncients failed:", err)
+	}
+	if err := f.TruncateTableTail("a", 30); err != nil {
+		t.Fatal("TruncateTableTail failed:", err)
+	}
+	if err := f.CompressTable("a", "brotli", 0, false); err == nil {
+		t.Fatal("unknown codec accepted")
+	}
+	if err := f.CompressTable("a", "zstd", 23, false); err == nil {
+		t.Fatal("invalid level accepted")
+	}
+	if err := f.CompressTable("c", "zstd", 0, false); err != errUnknownTable {
+		t.Fatalf("wrong error for unknown table: %v", err)
+	}
+	if err := f.CompressTable("a", "zstd", 3, true); err != nil {
+		t.Fatal("failed to compress table:", err)
+	}
+	if err := f.CompressTable("b", "zstd", 0, false); err != nil {
+		t.Fatal("failed to compress table:", err)
+	}
+	check := func(f *Freezer) {
+		t.Helper()
+
+		a, b := f.tables["a"], f.tables["b"]
+		if a.config.codec != freezerCodecZstd || a.config.level != 3 || len(a.config.dict) == 0 {
+			t.Fatalf("table a config mismatch: %v/%d, dict %d bytes", a.config.codec, a.config.level, len(a.config.dict))
+		}
+		if b.config.codec != freezerCodecZstd || len(b.config.dict) != 0 {
+			t.Fatalf("table b config mismatch: %v, dict %d bytes", b.config.codec, len(b.config.dict))
+		}
+		if ok, _ := f.HasAncient("a", 29); ok {
+			t.Fatal("truncated item still available")
+		}
+		checkChunks(t, a, 30, 100, 256)
+		checkChunks(t, b, 0, 100, 256)
+		checkAncientCount(t, f, "a", 100)
+		checkAncientCount(t, f, "b", 100)
+	}
+	check(f)
+
+	// The raw index of the converted table must be gone
+	if _, err := os.Stat(filepath.Join(dir, "b.ridx")); !os.IsNotExist(err) {
+		t.Fatalf("raw index not removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "migration")); !os.IsNotExist(err) {
+		t.Fatalf("migration directory not removed: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Reopen the freezer with the legacy definitions, the recorded codecs must
+	// be used
+	f, err = NewFreezer(dir, "", false, 2049, tables)
+	if err != nil {
+		t.Fatal("can't reopen freezer after compression", err)
+	}
+	defer f.Close()
+	check(f)
+}
@@ -24,7 +24,10 @@ import (
 	"github.com/foreverbit/biternal/rlp"
 )
 
-const freezerVersion = 1 // The initial version tag of freezer table metadata
+const (
+	freezerVersion      = 1 // The initial version tag of freezer table metadata
+	freezerCodecVersion = 2 // The version tag of freezer table metadata recording the codec
+)
 
 // freezerTableMeta wraps all the metadata of the freezer table.
 type freezerTableMeta struct {
@@ -36,6 +39,13 @@ type freezerTableMeta struct {
 	// plus the number of items hidden in the table, so it should never
 	// be lower than the "actual tail".
 	VirtualTail uint64
+
+	// Codec, Level and Dict record the compression settings of the table since
+	// version 2. Tables with older metadata are snappy compressed, unless they
+	// are raw tables.
+	Codec uint8  `rlp:"optional"`
+	Level uint8  `rlp:"optional"`
+	Dict  []byte `rlp:"optional"`
 }
 
 // newMetadata initializes the metadata object with the given virtual tail.
@@ -46,6 +56,31 @@ func newMetadata(tail uint64) *freezerTableMeta {
 	}
 }
 
+// newCodecMetadata initializes the metadata object with the given virtual tail,
+// recording the given compression settings.
+func newCodecMetadata(tail uint64, config freezerTableConfig) *freezerTableMeta {
+	return &freezerTableMeta{
+		Version:     freezerCodecVersion,
+		VirtualTail: tail,
+		Codec:       uint8(config.codec),
+		Level:       uint8(config.level),
+		Dict:        config.dict,
+	}
+}
+
+// config returns the compression settings recorded in the metadata, or false
+// if the metadata predates the codec record.
+func (m *freezerTableMeta) config() (freezerTableConfig, bool) {
+	if m.Version < freezerCodecVersion {
+		return freezerTableConfig{}, false
+	}
+	return freezerTableConfig{
+		codec: freezerCodec(m.Codec),
+		level: int(m.Level),
+		dict:  m.Dict,
+	}, true
+}
+
 // readMetadata reads the metadata of the freezer table from the
 // given metadata file.
 func readMetadata(file *os.File) (*freezerTableMeta, error) {
@@ -74,6 +109,12 @@ func writeMetadata(file *os.File, meta *freezerTableMeta) error {
 // Initializes the metadata file with the given "actual tail" if
 // it's empty.
 func loadMetadata(file *os.File, tail uint64) (*freezerTableMeta, error) {
+	return loadMetadataWithInit(file, tail, newMetadata(tail))
+}
+
+// loadMetadataWithInit loads the metadata from the given metadata file.
+// Initializes the metadata file with the given metadata if it's empty.
+func loadMetadataWithInit(file *os.File, tail uint64, init *freezerTableMeta) (*freezerTableMeta, error) {
 	stat, err := file.Stat()
 	if err != nil {
 		return nil, err
@@ -85,11 +126,10 @@ func loadMetadata(file *os.File, tail uint64) (*freezerTableMeta, error) {
 	// In both cases, write the meta into the file with the actual tail
 	// as the virtual tail.
 	if stat.Size() == 0 {
-		m := newMetadata(tail)
-		if err := writeMetadata(file, m); err != nil {
+		if err := writeMetadata(file, init); err != nil {
 			return nil, err
 		}
-		return m, nil
+		return init, nil
 	}
 	m, err := readMetadata(file)
 	if err != nil {
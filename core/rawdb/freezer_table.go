@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"sync"
@@ -30,7 +31,6 @@ import (
 	"github.com/foreverbit/biternal/common"
 	"github.com/foreverbit/biternal/log"
 	"github.com/foreverbit/biternal/metrics"
-	"github.com/golang/snappy"
 )
 
 var (
@@ -85,8 +85,8 @@ func (i *indexEntry) bounds(end *indexEntry) (startOffset, endOffset, fileId uin
 }
 
 // freezerTable represents a single chained data table within the freezer (e.g. blocks).
-// It consists of a data file (snappy encoded arbitrary data blobs) and an indexEntry
-// file (uncompressed 64 bit indices into the data file).
+// It consists of a data file (optionally compressed arbitrary data blobs) and an
+// indexEntry file (uncompressed 64 bit indices into the data file).
 type freezerTable struct {
 	// WARNING: The `items` field is accessed atomically. On 32 bit platforms, only
 	// 64-bit aligned fields can be atomic. The struct is guaranteed to be so aligned,
@@ -101,18 +101,20 @@ type freezerTable struct {
 	// should never be lower than itemOffset.
 	itemHidden uint64
 
-	noCompression bool // if true, disables snappy compression. Note: does not work retroactively
-	readonly      bool
-	maxFileSize   uint32 // Max file size for data-files
-	name          string
-	path          string
-
-	head   *os.File            // File descriptor for the data head of the table
-	index  *os.File            // File descriptor for the indexEntry file of the table
-	meta   *os.File            // File descriptor for metadata of the table
-	files  map[uint32]*os.File // open files
-	headId uint32              // number of the currently active head file
-	tailId uint32              // number of the earliest file
+	config      freezerTableConfig // Compression settings, as recorded in the metadata
+	codec       itemCodec          // Codec of the items, nil if they are not compressed
+	readonly    bool
+	maxFileSize uint32 // Max file size for data-files
+	name        string
+	path        string
+
+	head     *os.File            // File descriptor for the data head of the table
+	index    *os.File            // File descriptor for the indexEntry file of the table
+	meta     *os.File            // File descriptor for metadata of the table
+	metadata *freezerTableMeta   // Metadata of the table, only the virtual tail changes
+	files    map[uint32]*os.File // open files
+	headId   uint32              // number of the currently active head file
+	tailId   uint32              // number of the earliest file
 
 	headBytes  int64         // Number of bytes written to the head file
 	readMeter  metrics.Meter // Meter for measuring the effective amount of data read
@@ -124,20 +126,49 @@ type freezerTable struct {
 }
 
 // newFreezerTable opens the given path as a freezer table.
-func newFreezerTable(path, name string, disableSnappy, readonly bool) (*freezerTable, error) {
-	return newTable(path, name, metrics.NilMeter{}, metrics.NilMeter{}, metrics.NilGauge{}, freezerTableSize, disableSnappy, readonly)
+func newFreezerTable(path, name string, config freezerTableConfig, readonly bool) (*freezerTable, error) {
+	return newTableWithConfig(path, name, metrics.NilMeter{}, metrics.NilMeter{}, metrics.NilGauge{}, freezerTableSize, config, readonly)
 }
 
 // newTable opens a freezer table, creating the data and index files if they are
 // non-existent. Both files are truncated to the shortest common length to ensure
 // they don't go out of sync.
 func newTable(path string, name string, readMeter metrics.Meter, writeMeter metrics.Meter, sizeGauge metrics.Gauge, maxFilesize uint32, noCompression, readonly bool) (*freezerTable, error) {
+	config := freezerTableConfig{codec: freezerCodecSnappy}
+	if noCompression {
+		config.codec = freezerCodecNone
+	}
+	return newTableWithConfig(path, name, readMeter, writeMeter, sizeGauge, maxFilesize, config, readonly)
+}
+
+// resolveTableConfig returns the settings to open the given table with. Tables
+// converted to another codec may have changed from raw to compressed files or
+// vice versa, so the variant that exists on disk takes precedence over the
+// given settings. The rest of the settings are resolved from the metadata.
+func resolveTableConfig(path, name string, config freezerTableConfig) freezerTableConfig {
+	var (
+		raw  = common.FileExist(filepath.Join(path, fmt.Sprintf("%s.ridx", name)))
+		comp = common.FileExist(filepath.Join(path, fmt.Sprintf("%s.cidx", name)))
+	)
+	switch {
+	case !config.compressed() && !raw && comp:
+		return freezerTableConfig{codec: freezerCodecSnappy}
+	case config.compressed() && !comp && raw:
+		return freezerTableConfig{codec: freezerCodecNone}
+	}
+	return config
+}
+
+// newTableWithConfig opens a freezer table like newTable, creating it with the
+// given compression settings if it's non-existent. Existing tables keep the
+// settings they were created with.
+func newTableWithConfig(path string, name string, readMeter metrics.Meter, writeMeter metrics.Meter, sizeGauge metrics.Gauge, maxFilesize uint32, config freezerTableConfig, readonly bool) (*freezerTable, error) {
 	// Ensure the containing directory exists and open the indexEntry file
 	if err := os.MkdirAll(path, 0755); err != nil {
 		return nil, err
 	}
 	var idxName string
-	if noCompression {
+	if !config.compressed() {
 		idxName = fmt.Sprintf("%s.ridx", name) // raw index file
 	} else {
 		idxName = fmt.Sprintf("%s.cidx", name) // compressed index file
@@ -177,23 +208,27 @@ func newTable(path string, name string, readMeter metrics.Meter, writeMeter metr
 	}
 	// Create the table and repair any past inconsistency
 	tab := &freezerTable{
-		index:         index,
-		meta:          meta,
-		files:         make(map[uint32]*os.File),
-		readMeter:     readMeter,
-		writeMeter:    writeMeter,
-		sizeGauge:     sizeGauge,
-		name:          name,
-		path:          path,
-		logger:        log.New("database", path, "table", name),
-		noCompression: noCompression,
-		readonly:      readonly,
-		maxFileSize:   maxFilesize,
+		index:       index,
+		meta:        meta,
+		files:       make(map[uint32]*os.File),
+		readMeter:   readMeter,
+		writeMeter:  writeMeter,
+		sizeGauge:   sizeGauge,
+		name:        name,
+		path:        path,
+		logger:      log.New("database", path, "table", name),
+		config:      config,
+		readonly:    readonly,
+		maxFileSize: maxFilesize,
 	}
 	if err := tab.repair(); err != nil {
 		tab.Close()
 		return nil, err
 	}
+	if tab.codec, err = newItemCodec(tab.config); err != nil {
+		tab.Close()
+		return nil, err
+	}
 	// Initialize the starting size counter
 	size, err := tab.sizeNolock()
 	if err != nil {
@@ -250,12 +285,30 @@ func (t *freezerTable) repair() error {
 	t.tailId = firstIndex.filenum
 	t.itemOffset = uint64(firstIndex.offset)
 
-	// Load metadata from the file
-	meta, err := loadMetadata(t.meta, t.itemOffset)
+	// Load metadata from the file. New tables record their compression settings,
+	// while tables predating the metadata file are initialized without them.
+	init := newCodecMetadata(t.itemOffset, t.config)
+	if offsetsSize > indexEntrySize || t.itemOffset > 0 {
+		init = newMetadata(t.itemOffset)
+	}
+	meta, err := loadMetadataWithInit(t.meta, t.itemOffset, init)
 	if err != nil {
 		return err
 	}
 	t.itemHidden = meta.VirtualTail
+	t.metadata = meta
+
+	// Use the recorded compression settings. Tables without them are snappy
+	// compressed unless they are raw tables. The metadata file is shared by the
+	// raw and compressed variants of a table, so ignore the settings if they
+	// belong to the other variant.
+	if config, ok := meta.config(); !ok {
+		if t.config.compressed() {
+			t.config = freezerTableConfig{codec: freezerCodecSnappy}
+		}
+	} else if config.compressed() == t.config.compressed() {
+		t.config = config
+	}
 
 	// Read the last index, use the default value in case the freezer is empty
 	if offsetsSize == indexEntrySize {
@@ -481,7 +534,8 @@ func (t *freezerTable) truncateTail(items uint64) error {
 	}
 	// Update the virtual tail marker and hidden these entries in table.
 	atomic.StoreUint64(&t.itemHidden, items)
-	if err := writeMetadata(t.meta, newMetadata(items)); err != nil {
+	t.metadata.VirtualTail = items
+	if err := writeMetadata(t.meta, t.metadata); err != nil {
 		return err
 	}
 	// Hidden items still fall in the current tail file, no data file
@@ -555,6 +609,34 @@ func (t *freezerTable) truncateTail(items uint64) error {
 	return nil
 }
 
+// resetTail moves the tail of an empty table to the given item number, so that
+// the next appended item is the one with that number. The items before it are
+// regarded as deleted.
+func (t *freezerTable) resetTail(tail uint64) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if items := atomic.LoadUint64(&t.items); items != 0 {
+		return fmt.Errorf("table not empty: %d items", items)
+	}
+	if tail > math.MaxUint32 {
+		return fmt.Errorf("tail %d out of range", tail)
+	}
+	// The first index entry carries the number of deleted items
+	entry := indexEntry{filenum: t.tailId, offset: uint32(tail)}
+	if _, err := t.index.WriteAt(entry.append(nil), 0); err != nil {
+		return err
+	}
+	t.metadata.VirtualTail = tail
+	if err := writeMetadata(t.meta, t.metadata); err != nil {
+		return err
+	}
+	atomic.StoreUint64(&t.itemOffset, tail)
+	atomic.StoreUint64(&t.itemHidden, tail)
+	atomic.StoreUint64(&t.items, tail)
+	return nil
+}
+
 // Close closes all opened files.
 func (t *freezerTable) Close() error {
 	t.lock.Lock()
@@ -578,6 +660,9 @@ func (t *freezerTable) Close() error {
 	}
 	t.head = nil
 
+	if t.codec != nil {
+		t.codec.close()
+	}
 	if errs != nil {
 		return fmt.Errorf("%v", errs)
 	}
@@ -589,7 +674,7 @@ func (t *freezerTable) openFile(num uint32, opener func(string) (*os.File, error
 	var exist bool
 	if f, exist = t.files[num]; !exist {
 		var name string
-		if t.noCompression {
+		if !t.config.compressed() {
 			name = fmt.Sprintf("%s.%04d.rdat", t.name, num)
 		} else {
 			name = fmt.Sprintf("%s.%04d.cdat", t.name, num)
@@ -703,23 +788,16 @@ func (t *freezerTable) RetrieveItems(start, count, maxBytes uint64) ([][]byte, e
 	for i, diskSize := range sizes {
 		item := diskData[offset : offset+diskSize]
 		offset += diskSize
-		decompressedSize := diskSize
-		if !t.noCompression {
-			decompressedSize, _ = snappy.DecodedLen(item)
-		}
-		if i > 0 && uint64(outputSize+decompressedSize) > maxBytes {
-			break
-		}
-		if !t.noCompression {
-			data, err := snappy.Decode(nil, item)
-			if err != nil {
+		if t.codec != nil {
+			if item, err = t.codec.decode(item); err != nil {
 				return nil, err
 			}
-			output = append(output, data)
-		} else {
-			output = append(output, item)
 		}
-		outputSize += decompressedSize
+		if i > 0 && uint64(outputSize+len(item)) > maxBytes {
+			break
+		}
+		output = append(output, item)
+		outputSize += len(item)
 	}
 	return output, nil
 }
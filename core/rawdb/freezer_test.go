@@ -283,6 +283,64 @@ func TestFreezerReadonlyValidate(t *testing.T) {
 	}
 }
 
+// This checks that truncating the tail of a single table leaves the others
+// intact, also after reopening the freezer.
+func TestFreezerTruncateTableTail(t *testing.T) {
+	t.Parallel()
+
+	tables := map[string]bool{"a": true, "b": true}
+	f, dir := newFreezerForTesting(t, tables)
+
+	_, err := f.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		for i := 0; i < 100; i++ {
+			if err := op.AppendRaw("a", uint64(i), getChunk(256, i)); err != nil {
+				return err
+			}
+			if err := op.AppendRaw("b", uint64(i), getChunk(256, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal("ModifyAncients failed:", err)
+	}
+	if err := f.TruncateTableTail("b", 50); err != nil {
+		t.Fatal("TruncateTableTail failed:", err)
+	}
+	if err := f.TruncateTableTail("c", 50); err != errUnknownTable {
+		t.Fatalf("wrong error for unknown table: %v", err)
+	}
+	check := func(f *Freezer) {
+		t.Helper()
+
+		if tail, _ := f.Tail(); tail != 0 {
+			t.Fatalf("wrong freezer tail: have %d, want 0", tail)
+		}
+		if ok, _ := f.HasAncient("a", 0); !ok {
+			t.Fatal("untruncated table lost its tail")
+		}
+		if ok, _ := f.HasAncient("b", 49); ok {
+			t.Fatal("truncated item still available")
+		}
+		if _, err := f.Ancient("b", 50); err != nil {
+			t.Fatal("retained item unavailable:", err)
+		}
+		checkAncientCount(t, f, "a", 100)
+		checkAncientCount(t, f, "b", 100)
+	}
+	check(f)
+	require.NoError(t, f.Close())
+
+	// Reopen the freezer, the tails must not be aligned
+	f, err = NewFreezer(dir, "", false, 2049, tables)
+	if err != nil {
+		t.Fatal("can't reopen freezer after truncation", err)
+	}
+	defer f.Close()
+	check(f)
+}
+
 func newFreezerForTesting(t *testing.T, tables map[string]bool) (*Freezer, string) {
 	t.Helper()
 
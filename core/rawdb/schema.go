@@ -30,6 +30,9 @@ var (
 	// databaseVersionKey tracks the current database version.
 	databaseVersionKey = []byte("DatabaseVersion")
 
+	// databaseEngineKey tracks the key-value engine the database was created with.
+	databaseEngineKey = []byte("DatabaseEngine")
+
 	// headHeaderKey tracks the latest known header's hash.
 	headHeaderKey = []byte("LastHeader")
 
@@ -75,6 +78,9 @@ var (
 	// fastTxLookupLimitKey tracks the transaction lookup limit during fast sync.
 	fastTxLookupLimitKey = []byte("FastTransactionLookupLimit")
 
+	// historyTailKey tracks the oldest block whose body and receipts are retained.
+	historyTailKey = []byte("HistoryTail")
+
 	// badBlockKey tracks the list of bad blocks seen by local
 	badBlockKey = []byte("InvalidBlock")
 
@@ -84,6 +90,18 @@ var (
 	// transitionStatusKey tracks the eth2 transition status.
 	transitionStatusKey = []byte("eth2-transition")
 
+	// stateSchemeKey tracks the node scheme the state trie was created with.
+	stateSchemeKey = []byte("StateScheme")
+
+	// persistentStateIDKey tracks the id of the state persisted in the path scheme.
+	persistentStateIDKey = []byte("LastStateID")
+
+	// stateHistoryHeadKey tracks the newest block recorded in the state history.
+	stateHistoryHeadKey = []byte("StateHistoryHead")
+
+	// txTraceTailKey tracks the oldest block whose live transaction traces are retained.
+	txTraceTailKey = []byte("TransactionTraceTail")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	headerPrefix       = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	headerTDSuffix     = []byte("t") // headerPrefix + num (uint64 big endian) + hash + headerTDSuffix -> td
@@ -99,10 +117,20 @@ var (
 	SnapshotStoragePrefix = []byte("o") // SnapshotStoragePrefix + account hash + storage hash -> storage trie value
 	CodePrefix            = []byte("c") // CodePrefix + code hash -> account code
 	skeletonHeaderPrefix  = []byte("S") // skeletonHeaderPrefix + num (uint64 big endian) -> header
+	TrieNodeAccountPrefix = []byte("A") // TrieNodeAccountPrefix + hexPath -> trie node
+	TrieNodeStoragePrefix = []byte("O") // TrieNodeStoragePrefix + accountHash + hexPath -> trie node
+	reverseDiffPrefix     = []byte("R") // reverseDiffPrefix + id (uint64 big endian) -> reverse diff
+	stateIDPrefix         = []byte("L") // stateIDPrefix + state root -> state id
+
+	stateHistoryAccountPrefix = []byte("m") // stateHistoryAccountPrefix + account hash + num (uint64 big endian) -> empty
+	stateHistoryStoragePrefix = []byte("M") // stateHistoryStoragePrefix + account hash + storage hash + num (uint64 big endian) -> empty
+	stateHistoryRootPrefix    = []byte("N") // stateHistoryRootPrefix + state root -> num (uint64 big endian)
 
-	PreimagePrefix = []byte("secure-key-")       // PreimagePrefix + hash -> preimage
-	configPrefix   = []byte("ethereum-config-")  // config prefix for the db
-	genesisPrefix  = []byte("ethereum-genesis-") // genesis state prefix for the db
+	PreimagePrefix       = []byte("secure-key-")       // PreimagePrefix + hash -> preimage
+	configPrefix         = []byte("ethereum-config-")  // config prefix for the db
+	genesisPrefix        = []byte("ethereum-genesis-") // genesis state prefix for the db
+	SnapshotImportPrefix = []byte("snapshot-import-")  // SnapshotImportPrefix + node hash -> empty, trie node written by an unfinished snapshot import
+	txTracePrefix        = []byte("tx-trace-")         // txTracePrefix + num (uint64 big endian) + hash -> block transaction traces
 
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
 	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
@@ -161,6 +189,11 @@ func blockReceiptsKey(number uint64, hash common.Hash) []byte {
 	return append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
 }
 
+// txTraceKey = txTracePrefix + num (uint64 big endian) + hash
+func txTraceKey(number uint64, hash common.Hash) []byte {
+	return append(append(txTracePrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
 // txLookupKey = txLookupPrefix + hash
 func txLookupKey(hash common.Hash) []byte {
 	return append(txLookupPrefix, hash.Bytes()...)
@@ -215,6 +248,47 @@ func IsCodeKey(key []byte) (bool, []byte) {
 	return false, nil
 }
 
+// accountTrieNodeKey = TrieNodeAccountPrefix + nodePath.
+func accountTrieNodeKey(path []byte) []byte {
+	return append(TrieNodeAccountPrefix, path...)
+}
+
+// storageTrieNodeKey = TrieNodeStoragePrefix + accountHash + nodePath.
+func storageTrieNodeKey(accountHash common.Hash, path []byte) []byte {
+	return append(append(TrieNodeStoragePrefix, accountHash.Bytes()...), path...)
+}
+
+// snapshotImportKey = SnapshotImportPrefix + node hash
+func snapshotImportKey(hash common.Hash) []byte {
+	return append(SnapshotImportPrefix, hash.Bytes()...)
+}
+
+// reverseDiffKey = reverseDiffPrefix + id (uint64 big endian)
+func reverseDiffKey(id uint64) []byte {
+	return append(reverseDiffPrefix, encodeBlockNumber(id)...)
+}
+
+// stateIDKey = stateIDPrefix + root
+func stateIDKey(root common.Hash) []byte {
+	return append(stateIDPrefix, root.Bytes()...)
+}
+
+// stateHistoryAccountKey = stateHistoryAccountPrefix + account hash + num (uint64 big endian)
+func stateHistoryAccountKey(accountHash common.Hash, number uint64) []byte {
+	return append(append(stateHistoryAccountPrefix, accountHash.Bytes()...), encodeBlockNumber(number)...)
+}
+
+// stateHistoryStorageKey = stateHistoryStoragePrefix + account hash + storage hash + num (uint64 big endian)
+func stateHistoryStorageKey(accountHash, storageHash common.Hash, number uint64) []byte {
+	key := append(append(stateHistoryStoragePrefix, accountHash.Bytes()...), storageHash.Bytes()...)
+	return append(key, encodeBlockNumber(number)...)
+}
+
+// stateHistoryRootKey = stateHistoryRootPrefix + root
+func stateHistoryRootKey(root common.Hash) []byte {
+	return append(stateHistoryRootPrefix, root.Bytes()...)
+}
+
 // configKey = configPrefix + hash
 func configKey(hash common.Hash) []byte {
 	return append(configPrefix, hash.Bytes()...)
@@ -0,0 +1,55 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// stateFreezerNamespace is the metrics namespace of the state freezer.
+const stateFreezerNamespace = "eth/db/state/"
+
+// NewStateFreezer opens the freezer storing the state history, located in the
+// given root ancient directory. Items are numbered by block.
+func NewStateFreezer(ancientDir string, readonly bool) (*Freezer, error) {
+	dir := filepath.Join(ancientDir, stateFreezerName)
+	if !readonly {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	return newFreezer(dir, stateFreezerNamespace, readonly, freezerTableSize, stateFreezerTableConfigs)
+}
+
+// ResetStateFreezer deletes the entire state history freezer in the given root
+// ancient directory and recreates it empty, with the first item to be appended
+// numbered tail. Any open instance of the freezer must be closed beforehand.
+func ResetStateFreezer(ancientDir string, tail uint64) (*Freezer, error) {
+	if err := os.RemoveAll(filepath.Join(ancientDir, stateFreezerName)); err != nil {
+		return nil, err
+	}
+	freezer, err := NewStateFreezer(ancientDir, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := freezer.resetTail(tail); err != nil {
+		freezer.Close()
+		return nil, err
+	}
+	return freezer, nil
+}
@@ -107,6 +107,12 @@ func (t *table) TruncateTail(items uint64) error {
 	return t.db.TruncateTail(items)
 }
 
+// TruncateTableTail is a noop passthrough that just forwards the request to the
+// underlying database.
+func (t *table) TruncateTableTail(kind string, items uint64) error {
+	return t.db.TruncateTableTail(kind, items)
+}
+
 // Sync is a noop passthrough that just forwards the request to the underlying
 // database.
 func (t *table) Sync() error {
@@ -119,6 +125,12 @@ func (t *table) MigrateTable(kind string, convert convertLegacyFn) error {
 	return t.db.MigrateTable(kind, convert)
 }
 
+// CompressTable is a noop passthrough that just forwards the request to the
+// underlying database.
+func (t *table) CompressTable(kind string, codec string, level int, dict bool) error {
+	return t.db.CompressTable(kind, codec, level, dict)
+}
+
 // AncientDatadir returns the ancient datadir of the underlying database.
 func (t *table) AncientDatadir() (string, error) {
 	return t.db.AncientDatadir()
@@ -0,0 +1,258 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/common/bitutil"
+	"github.com/foreverbit/biternal/core/rawdb"
+	"github.com/foreverbit/biternal/core/types"
+	"github.com/foreverbit/biternal/ethdb"
+	"github.com/foreverbit/biternal/log"
+	"github.com/foreverbit/biternal/params"
+)
+
+// ReindexConfig contains the settings of an index rebuild.
+type ReindexConfig struct {
+	From          uint64          // Number of the first block to reindex
+	To            uint64          // Number of the last block to reindex (capped at the head)
+	BloomSize     uint64          // Number of blocks per bloombits section (0 = params.BloomBitsBlocks)
+	BloomConfirms uint64          // Number of confirmations before a bloombits section is indexed
+	DryRun        bool            // Whether to only count the entries to be rebuilt
+	Interrupt     <-chan struct{} // Channel to abort the rebuild, which can be resumed later
+}
+
+// ReindexResult contains the outcome of an index rebuild.
+type ReindexResult struct {
+	Next    uint64 // Number of the first block not reindexed, to resume an interrupted rebuild
+	Blocks  uint64 // Number of blocks processed
+	Entries uint64 // Number of index entries (transactions or sections) processed
+	Changed uint64 // Number of index entries missing or different before the rebuild
+}
+
+// reindexRange clamps the configured range of blocks to reindex to the given
+// head block.
+func reindexRange(config *ReindexConfig, head uint64) (uint64, uint64, error) {
+	to := config.To
+	if to > head {
+		to = head
+	}
+	if config.From > to {
+		return 0, 0, fmt.Errorf("invalid range %d-%d, head %d", config.From, to, head)
+	}
+	return config.From, to, nil
+}
+
+// ReindexTransactions rebuilds the transaction lookup entries of the canonical
+// blocks in the configured range, skipping the blocks whose bodies are pruned.
+// If the rebuilt blocks extend the indexed range downwards, the index tail is
+// moved to the first of them.
+//
+// The blocks are processed in ascending order. If interrupted, the rebuild can
+// be resumed from the returned next block.
+func ReindexTransactions(db ethdb.Database, config *ReindexConfig) (*ReindexResult, error) {
+	// Bodies are only available up to the head (snap) block
+	var head uint64
+	for _, hash := range []common.Hash{rawdb.ReadHeadBlockHash(db), rawdb.ReadHeadFastBlockHash(db)} {
+		if number := rawdb.ReadHeaderNumber(db, hash); number != nil && *number > head {
+			head = *number
+		}
+	}
+	from, to, err := reindexRange(config, head)
+	if err != nil {
+		return nil, err
+	}
+	if tail := rawdb.ReadHistoryTail(db); from < tail {
+		log.Info("Skipping blocks with pruned bodies", "from", from, "tail", tail)
+		from = tail
+	}
+	var (
+		result = &ReindexResult{Next: from}
+		batch  = db.NewBatch()
+		start  = time.Now()
+		logged = time.Now()
+	)
+	log.Info("Reindexing transactions", "from", from, "to", to, "dryrun", config.DryRun)
+	for number := from; number <= to; number++ {
+		select {
+		case <-config.Interrupt:
+			log.Warn("Transaction reindexing interrupted", "next", number)
+			return result, batch.Write()
+		default:
+		}
+		hash := rawdb.ReadCanonicalHash(db, number)
+		body := rawdb.ReadBody(db, hash, number)
+		if body == nil {
+			return result, fmt.Errorf("missing body of block %d", number)
+		}
+		hashes := make([]common.Hash, len(body.Transactions))
+		for i, tx := range body.Transactions {
+			hashes[i] = tx.Hash()
+			if entry := rawdb.ReadTxLookupEntry(db, hashes[i]); entry == nil || *entry != number {
+				result.Changed++
+			}
+		}
+		if !config.DryRun {
+			rawdb.WriteTxLookupEntries(batch, number, hashes)
+			if batch.ValueSize() > ethdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					return result, err
+				}
+				batch.Reset()
+			}
+		}
+		result.Blocks++
+		result.Entries += uint64(len(hashes))
+		result.Next = number + 1
+
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Reindexing transactions", "number", number, "to", to, "txs", result.Entries, "changed", result.Changed, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if !config.DryRun {
+		// Move the tail down if the rebuilt blocks are contiguous with the index
+		tail := rawdb.ReadTxIndexTail(db)
+		if (tail == nil && to == head) || (tail != nil && from < *tail && to+1 >= *tail) {
+			rawdb.WriteTxIndexTail(batch, from)
+		}
+		if err := batch.Write(); err != nil {
+			return result, err
+		}
+	}
+	log.Info("Reindexed transactions", "blocks", result.Blocks, "txs", result.Entries, "changed", result.Changed, "dryrun", config.DryRun, "elapsed", common.PrettyDuration(time.Since(start)))
+	return result, nil
+}
+
+// ReindexBloomBits rebuilds the bloombits sections fully within the configured
+// range of blocks that are confirmed, as the bloombits chain indexer would. The
+// number of indexed sections is extended if the rebuilt ones are contiguous with
+// them.
+//
+// The sections are processed in ascending order. If interrupted, the rebuild
+// can be resumed from the returned next block.
+func ReindexBloomBits(db ethdb.Database, config *ReindexConfig) (*ReindexResult, error) {
+	header := rawdb.ReadHeadHeader(db)
+	if header == nil {
+		return nil, errors.New("missing head header")
+	}
+	head := header.Number.Uint64()
+	from, to, err := reindexRange(config, head)
+	if err != nil {
+		return nil, err
+	}
+	size := config.BloomSize
+	if size == 0 {
+		size = params.BloomBitsBlocks
+	}
+	var (
+		first = (from + size - 1) / size // First section starting in the range
+		last  = (to + 1) / size          // First section not ending in the range
+	)
+	if head < config.BloomConfirms {
+		last = 0
+	} else if confirmed := (head - config.BloomConfirms + 1) / size; last > confirmed {
+		last = confirmed
+	}
+	var (
+		result  = &ReindexResult{Next: first * size}
+		table   = rawdb.NewTable(db, string(rawdb.BloomBitsIndexPrefix))
+		indexed = readValidSections(table)
+		backend = &BloomIndexer{db: db, size: size}
+		start   = time.Now()
+		logged  = time.Now()
+	)
+	if first >= last {
+		log.Info("No bloombits sections to reindex", "from", from, "to", to, "head", head)
+		return result, nil
+	}
+	log.Info("Reindexing bloombits", "from", first, "to", last-1, "indexed", indexed, "dryrun", config.DryRun)
+	for section := first; section < last; section++ {
+		select {
+		case <-config.Interrupt:
+			log.Warn("Bloombits reindexing interrupted", "next", section*size)
+			return result, nil
+		default:
+		}
+		if err := backend.Reset(context.Background(), section, common.Hash{}); err != nil {
+			return result, err
+		}
+		for number := section * size; number < (section+1)*size; number++ {
+			header := rawdb.ReadHeader(db, rawdb.ReadCanonicalHash(db, number), number)
+			if header == nil {
+				return result, fmt.Errorf("missing header of block %d", number)
+			}
+			backend.Process(context.Background(), header)
+		}
+		changed, err := bloomSectionChanged(table, backend)
+		if err != nil {
+			return result, err
+		}
+		if changed {
+			result.Changed++
+		}
+		if !config.DryRun && changed {
+			// Drop the bits of the section, whatever head they were stored for
+			for i := 0; i < types.BloomBitLength; i++ {
+				rawdb.DeleteBloombits(db, uint(i), section, section+1)
+			}
+			if err := backend.Commit(); err != nil {
+				return result, err
+			}
+			writeSectionHead(table, section, backend.head)
+		}
+		if !config.DryRun && section == indexed {
+			indexed++
+			writeValidSections(table, indexed)
+		}
+		result.Blocks += size
+		result.Entries++
+		result.Next = (section + 1) * size
+
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Reindexing bloombits", "section", section, "to", last-1, "changed", result.Changed, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	log.Info("Reindexed bloombits", "sections", result.Entries, "changed", result.Changed, "indexed", indexed, "dryrun", config.DryRun, "elapsed", common.PrettyDuration(time.Since(start)))
+	return result, nil
+}
+
+// bloomSectionChanged reports whether the bloom bits accumulated by the indexer
+// backend differ from the ones stored for its section.
+func bloomSectionChanged(table ethdb.KeyValueReader, backend *BloomIndexer) (bool, error) {
+	if readSectionHead(table, backend.section) != backend.head {
+		return true, nil
+	}
+	for i := 0; i < types.BloomBitLength; i++ {
+		bits, err := backend.gen.Bitset(uint(i))
+		if err != nil {
+			return false, err
+		}
+		stored, err := rawdb.ReadBloomBits(backend.db, uint(i), backend.section, backend.head)
+		if err != nil || !bytes.Equal(stored, bitutil.CompressBytes(bits)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"testing"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/core/rawdb"
+)
+
+// Tests that the transaction lookup entries are rebuilt, the tail is extended
+// and a dry run leaves the database untouched.
+func TestReindexTransactions(t *testing.T) {
+	db, blocks := newVerifyTestChain(t, 63, 16)
+
+	// Drop the index below block 20 and break a few entries above it
+	for _, block := range blocks[:19] {
+		for _, tx := range block.Transactions() {
+			rawdb.DeleteTxLookupEntry(db, tx.Hash())
+		}
+	}
+	rawdb.WriteTxIndexTail(db, 20)
+	rawdb.DeleteTxLookupEntry(db, blocks[29].Transactions()[0].Hash())
+	rawdb.WriteTxLookupEntries(db, 1, []common.Hash{blocks[39].Transactions()[0].Hash()})
+	rawdb.DeleteTxLookupEntry(db, blocks[49].Transactions()[0].Hash())
+
+	want := uint64(0)
+	for _, block := range blocks[:19] {
+		want += uint64(len(block.Transactions()))
+	}
+	want += 3
+
+	result, err := ReindexTransactions(db, &ReindexConfig{From: 0, To: 100, DryRun: true})
+	if err != nil {
+		t.Fatalf("failed to reindex: %v", err)
+	}
+	if result.Changed != want || result.Blocks != 64 || result.Next != 64 {
+		t.Fatalf("dry run mismatch: changed %d/%d, blocks %d, next %d", result.Changed, want, result.Blocks, result.Next)
+	}
+	if tail := rawdb.ReadTxIndexTail(db); tail == nil || *tail != 20 {
+		t.Fatalf("dry run moved tail: %v", tail)
+	}
+	if entry := rawdb.ReadTxLookupEntry(db, blocks[0].Transactions()[0].Hash()); entry != nil {
+		t.Fatalf("dry run wrote entries")
+	}
+	// Interrupt the rebuild and resume it from where it stopped
+	interrupt := make(chan struct{})
+	close(interrupt)
+	result, err = ReindexTransactions(db, &ReindexConfig{From: 0, To: 100, Interrupt: interrupt})
+	if err != nil {
+		t.Fatalf("failed to reindex: %v", err)
+	}
+	if result.Next != 0 || result.Blocks != 0 {
+		t.Fatalf("interrupted rebuild progressed: next %d, blocks %d", result.Next, result.Blocks)
+	}
+	result, err = ReindexTransactions(db, &ReindexConfig{From: result.Next, To: 100})
+	if err != nil {
+		t.Fatalf("failed to reindex: %v", err)
+	}
+	if result.Changed != want {
+		t.Fatalf("changed entries mismatch: have %d, want %d", result.Changed, want)
+	}
+	if tail := rawdb.ReadTxIndexTail(db); tail == nil || *tail != 0 {
+		t.Fatalf("tail not extended: %v", tail)
+	}
+	check, err := VerifyDatabase(db, &VerifyConfig{BloomSize: 16, SkipState: true})
+	if err != nil {
+		t.Fatalf("failed to verify database: %v", err)
+	}
+	if len(check.Issues) != 0 {
+		t.Fatalf("issues left after rebuild: %v", check.Issues)
+	}
+}
+
+// Tests that the bloombits sections are rebuilt and the indexed section count
+// extended, skipping the sections only partially in the range.
+func TestReindexBloomBits(t *testing.T) {
+	db, blocks := newVerifyTestChain(t, 63, 16)
+
+	// Corrupt the second section and drop the last one
+	table := rawdb.NewTable(db, string(rawdb.BloomBitsIndexPrefix))
+	rawdb.WriteBloomBits(db, 5, 1, blocks[30].Hash(), []byte{0xde, 0xad})
+	writeValidSections(table, 3)
+
+	// A range not covering whole sections only touches the full ones within
+	result, err := ReindexBloomBits(db, &ReindexConfig{From: 8, To: 40, BloomSize: 16, DryRun: true})
+	if err != nil {
+		t.Fatalf("failed to reindex: %v", err)
+	}
+	if result.Entries != 1 || result.Changed != 1 || result.Next != 32 {
+		t.Fatalf("dry run mismatch: sections %d, changed %d, next %d", result.Entries, result.Changed, result.Next)
+	}
+	if sections := readValidSections(table); sections != 3 {
+		t.Fatalf("dry run changed section count: %d", sections)
+	}
+	result, err = ReindexBloomBits(db, &ReindexConfig{BloomSize: 16, To: 100})
+	if err != nil {
+		t.Fatalf("failed to reindex: %v", err)
+	}
+	if result.Entries != 4 || result.Changed != 1 || result.Next != 64 {
+		t.Fatalf("rebuild mismatch: sections %d, changed %d, next %d", result.Entries, result.Changed, result.Next)
+	}
+	if sections := readValidSections(table); sections != 4 {
+		t.Fatalf("section count mismatch: have %d, want 4", sections)
+	}
+	check, err := VerifyDatabase(db, &VerifyConfig{BloomSize: 16, SkipState: true})
+	if err != nil {
+		t.Fatalf("failed to verify database: %v", err)
+	}
+	if len(check.Issues) != 0 {
+		t.Fatalf("issues left after rebuild: %v", check.Issues)
+	}
+	// Sections without enough confirmations are left alone
+	result, err = ReindexBloomBits(db, &ReindexConfig{BloomSize: 16, To: 100, BloomConfirms: 10})
+	if err != nil {
+		t.Fatalf("failed to reindex: %v", err)
+	}
+	if result.Entries != 3 || result.Changed != 0 {
+		t.Fatalf("confirmed rebuild mismatch: sections %d, changed %d", result.Entries, result.Changed)
+	}
+}
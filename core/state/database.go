@@ -111,6 +111,10 @@ type Trie interface {
 	// nodes of the longest existing prefix of the key (at least the root), ending
 	// with the node that proves the absence of the key.
 	Prove(key []byte, fromLevel uint, proofDb ethdb.KeyValueWriter) error
+
+	// ProveMulti constructs a Merkle multiproof for the given keys, containing
+	// every node on the paths to the values at the keys once.
+	ProveMulti(keys [][]byte, fromLevel uint, proofDb ethdb.KeyValueWriter) error
 }
 
 // NewDatabase creates a backing store for state. The returned database is safe for
@@ -0,0 +1,145 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"errors"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/core/rawdb"
+	"github.com/foreverbit/biternal/core/state/snapshot"
+	"github.com/foreverbit/biternal/core/types"
+	"github.com/foreverbit/biternal/crypto"
+	"github.com/foreverbit/biternal/ethdb"
+	"github.com/foreverbit/biternal/trie"
+)
+
+// errHistoricState is returned when accessing the tries of a historic state,
+// which are not available.
+var errHistoricState = errors.New("trie access unsupported on historic state")
+
+// NewHistoric creates a state from a snapshot of a state whose tries are not
+// available, such as a state reconstructed from the state history. All reads
+// are served by the snapshot; failed snapshot reads and trie accesses, such as
+// proofs and iteration, result in errors. The state can be modified in memory,
+// but the computed roots are meaningless and it can't be committed.
+func NewHistoric(snap snapshot.Snapshot, db Database) *StateDB {
+	db = &historicDatabase{
+		Database: db,
+		scratch:  trie.NewDatabase(rawdb.NewMemoryDatabase()),
+	}
+	tr, _ := db.OpenTrie(snap.Root())
+	return &StateDB{
+		db:                  db,
+		trie:                tr,
+		originalRoot:        snap.Root(),
+		snap:                snap,
+		snapDestructs:       make(map[common.Hash]struct{}),
+		snapAccounts:        make(map[common.Hash][]byte),
+		snapStorage:         make(map[common.Hash]map[common.Hash][]byte),
+		stateObjects:        make(map[common.Address]*stateObject),
+		stateObjectsPending: make(map[common.Address]struct{}),
+		stateObjectsDirty:   make(map[common.Address]struct{}),
+		logs:                make(map[common.Hash][]*types.Log),
+		preimages:           make(map[common.Hash][]byte),
+		journal:             newJournal(),
+		accessList:          newAccessList(),
+		hasher:              crypto.NewKeccakState(),
+	}
+}
+
+// Historic returns whether the state was created by NewHistoric.
+func (s *StateDB) Historic() bool {
+	_, ok := s.db.(*historicDatabase)
+	return ok
+}
+
+// historicDatabase is the state database of historic states. Tries are opened
+// empty in a scratch database and refuse to serve reads, which would otherwise
+// silently return wrong data.
+type historicDatabase struct {
+	Database
+	scratch *trie.Database
+}
+
+// OpenTrie opens an empty account trie, the root is ignored.
+func (db *historicDatabase) OpenTrie(root common.Hash) (Trie, error) {
+	tr, err := trie.NewStateTrie(common.Hash{}, common.Hash{}, db.scratch)
+	if err != nil {
+		return nil, err
+	}
+	return &historicTrie{tr}, nil
+}
+
+// OpenStorageTrie opens an empty storage trie, the root is ignored.
+func (db *historicDatabase) OpenStorageTrie(addrHash, root common.Hash) (Trie, error) {
+	tr, err := trie.NewStateTrie(addrHash, common.Hash{}, db.scratch)
+	if err != nil {
+		return nil, err
+	}
+	return &historicTrie{tr}, nil
+}
+
+// CopyTrie returns an independent copy of the given trie.
+func (db *historicDatabase) CopyTrie(t Trie) Trie {
+	if t, ok := t.(*historicTrie); ok {
+		return &historicTrie{t.StateTrie.Copy()}
+	}
+	return db.Database.CopyTrie(t)
+}
+
+// historicTrie is a trie of a historic state. Modifications are accepted, but
+// reads, proofs and iteration fail.
+type historicTrie struct {
+	*trie.StateTrie
+}
+
+// TryGet implements Trie, returning an error.
+func (t *historicTrie) TryGet(key []byte) ([]byte, error) {
+	return nil, errHistoricState
+}
+
+// TryGetAccount implements Trie, returning an error.
+func (t *historicTrie) TryGetAccount(key []byte) (*types.StateAccount, error) {
+	return nil, errHistoricState
+}
+
+// NodeIterator implements Trie, returning an iterator which fails immediately.
+func (t *historicTrie) NodeIterator(start []byte) trie.NodeIterator {
+	return historicIterator{t.StateTrie.NodeIterator(start)}
+}
+
+// Prove implements Trie, returning an error.
+func (t *historicTrie) Prove(key []byte, fromLevel uint, proofDb ethdb.KeyValueWriter) error {
+	return errHistoricState
+}
+
+// ProveMulti implements Trie, returning an error.
+func (t *historicTrie) ProveMulti(keys [][]byte, fromLevel uint, proofDb ethdb.KeyValueWriter) error {
+	return errHistoricState
+}
+
+// historicIterator is the node iterator of historic tries, reporting an error
+// on top of the iteration of the empty scratch trie.
+type historicIterator struct {
+	trie.NodeIterator
+}
+
+// Error implements trie.NodeIterator, returning an error.
+func (it historicIterator) Error() error {
+	return errHistoricState
+}
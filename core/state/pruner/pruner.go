@@ -85,6 +85,10 @@ type Pruner struct {
 
 // NewPruner creates the pruner instance.
 func NewPruner(db ethdb.Database, datadir, trieCachePath string, bloomSize uint64) (*Pruner, error) {
+	// The path scheme overwrites stale nodes in place, nothing to prune
+	if rawdb.ReadStateScheme(db) == rawdb.PathScheme {
+		return nil, errors.New("offline pruning is not supported by the path scheme")
+	}
 	headBlock := rawdb.ReadHeadBlock(db)
 	if headBlock == nil {
 		return nil, errors.New("Failed to load head block")
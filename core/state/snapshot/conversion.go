@@ -313,6 +313,7 @@ func generateTrieRoot(db ethdb.KeyValueWriter, it Iterator, account common.Hash,
 				// Fetch the next account and process it concurrently
 				account, err := FullAccount(it.(AccountIterator).Account())
 				if err != nil {
+					results <- nil // stop will drain the results, return the slot we just acquired
 					return stop(err)
 				}
 				go func(hash common.Hash) {
@@ -0,0 +1,70 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/ethdb"
+)
+
+// testAccountIterator iterates over a fixed list of raw account entries.
+type testAccountIterator struct {
+	hashes   []common.Hash
+	accounts [][]byte
+	pos      int
+}
+
+func (it *testAccountIterator) Next() bool {
+	if it.pos >= len(it.hashes) {
+		return false
+	}
+	it.pos++
+	return true
+}
+
+func (it *testAccountIterator) Error() error      { return nil }
+func (it *testAccountIterator) Hash() common.Hash { return it.hashes[it.pos-1] }
+func (it *testAccountIterator) Account() []byte   { return it.accounts[it.pos-1] }
+func (it *testAccountIterator) Release()          {}
+
+// Tests that regenerating a trie fails instead of hanging if an account can't
+// be decoded while the storage tries are regenerated concurrently.
+func TestGenerateTrieRootInvalidAccount(t *testing.T) {
+	it := &testAccountIterator{
+		hashes:   []common.Hash{{0x01}, {0x02}},
+		accounts: [][]byte{SlimAccountRLP(0, common.Big1, emptyRoot, emptyCode.Bytes()), {0xff}},
+	}
+	leafCallback := func(db ethdb.KeyValueWriter, accountHash, codeHash common.Hash, stat *generateStats) (common.Hash, error) {
+		return emptyRoot, nil
+	}
+	errc := make(chan error, 1)
+	go func() {
+		_, err := generateTrieRoot(nil, it, common.Hash{}, stackTrieGenerate, leafCallback, nil, false)
+		errc <- err
+	}()
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Fatal("invalid account accepted")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("trie regeneration hung")
+	}
+}
@@ -0,0 +1,580 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/core/rawdb"
+	"github.com/foreverbit/biternal/crypto"
+	"github.com/foreverbit/biternal/ethdb"
+	"github.com/foreverbit/biternal/log"
+	"github.com/foreverbit/biternal/rlp"
+	"github.com/foreverbit/biternal/trie"
+)
+
+const (
+	// exportMagic is the magic string at the start of snapshot exports.
+	exportMagic = "gethsnapshot"
+
+	// exportVersion is the version of the snapshot export format.
+	exportVersion = 0
+)
+
+const (
+	exportChunkData = 0 // Chunk containing accounts and storage slots
+	exportChunkEnd  = 1 // Final chunk containing the totals of the export
+)
+
+var (
+	// exportChunkSize is the approximate size of the data chunks of snapshot
+	// exports, above which a new chunk is started. It's a variable so tests
+	// can lower it.
+	exportChunkSize = 4 * 1024 * 1024
+
+	// exportCastagnoli is the CRC32 table used for the chunk checksums.
+	exportCastagnoli = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// ExportHeader describes the state contained in a snapshot export.
+type ExportHeader struct {
+	Root   common.Hash // State root of the exported state
+	Number uint64      // Number of the block the state belongs to
+	Hash   common.Hash // Hash of the block the state belongs to
+}
+
+// exportHeader is the first item of a snapshot export.
+type exportHeader struct {
+	Magic   string // Always set to 'gethsnapshot' for disambiguation
+	Version uint64
+	ExportHeader
+}
+
+// exportChunk is a checksummed chunk of a snapshot export, following the header.
+type exportChunk struct {
+	Kind     uint8  // Type of the chunk content
+	Data     []byte // RLP encoded content of the chunk
+	Checksum uint32 // CRC32 (Castagnoli) checksum of the data
+}
+
+// exportAccount is an account along with its storage in a data chunk. The
+// storage of an account may span several chunks, in which case the following
+// entries only contain the storage continuation.
+type exportAccount struct {
+	Hash    common.Hash  // Hash of the account address
+	Account []byte       // Account in slim RLP format, empty for a storage continuation
+	Code    []byte       // Contract code, empty if none or already exported
+	Storage []exportSlot // Storage slots of the account, ordered by hash
+}
+
+// exportSlot is a storage slot in a data chunk.
+type exportSlot struct {
+	Hash  common.Hash // Hash of the storage slot key
+	Value []byte      // RLP encoded slot value
+}
+
+// exportTotals is the content of the final chunk, allowing to detect truncated
+// exports.
+type exportTotals struct {
+	Chunks   uint64 // Number of data chunks
+	Accounts uint64 // Number of accounts
+	Slots    uint64 // Number of storage slots
+	Codes    uint64 // Number of contract codes
+}
+
+// exportWriter accumulates accounts and storage slots into data chunks.
+type exportWriter struct {
+	w      io.Writer
+	chunk  []*exportAccount
+	size   int
+	totals exportTotals
+}
+
+// writeChunk writes a checksummed chunk with the given content.
+func (w *exportWriter) writeChunk(kind uint8, content interface{}) error {
+	data, err := rlp.EncodeToBytes(content)
+	if err != nil {
+		return err
+	}
+	return rlp.Encode(w.w, &exportChunk{
+		Kind:     kind,
+		Data:     data,
+		Checksum: crc32.Checksum(data, exportCastagnoli),
+	})
+}
+
+// flush writes out the accumulated accounts as a data chunk, if any.
+func (w *exportWriter) flush() error {
+	if len(w.chunk) == 0 {
+		return nil
+	}
+	if err := w.writeChunk(exportChunkData, w.chunk); err != nil {
+		return err
+	}
+	w.chunk, w.size = nil, 0
+	w.totals.Chunks++
+	return nil
+}
+
+// addAccount starts a new account entry.
+func (w *exportWriter) addAccount(hash common.Hash, account []byte, code []byte) error {
+	w.chunk = append(w.chunk, &exportAccount{Hash: hash, Account: account, Code: code})
+	w.size += common.HashLength + len(account) + len(code)
+	w.totals.Accounts++
+	if len(code) > 0 {
+		w.totals.Codes++
+	}
+	if w.size >= exportChunkSize {
+		return w.flush()
+	}
+	return nil
+}
+
+// addSlot adds a storage slot to the entry of the given account, continuing it
+// in a new entry if the previous chunk was flushed.
+func (w *exportWriter) addSlot(account common.Hash, hash common.Hash, value []byte) error {
+	if len(w.chunk) == 0 {
+		w.chunk = append(w.chunk, &exportAccount{Hash: account})
+		w.size += common.HashLength
+	}
+	entry := w.chunk[len(w.chunk)-1]
+	entry.Storage = append(entry.Storage, exportSlot{Hash: hash, Value: common.CopyBytes(value)})
+	w.size += common.HashLength + len(value)
+	w.totals.Slots++
+	if w.size >= exportChunkSize {
+		return w.flush()
+	}
+	return nil
+}
+
+// Export writes the state with the given root from the snapshot tree into a
+// chunked, checksummed stream, along with the contract codes read from the
+// database. The snapshot must be fully generated.
+func Export(w io.Writer, snaptree *Tree, db ethdb.KeyValueReader, header *ExportHeader) error {
+	accIt, err := snaptree.AccountIterator(header.Root, common.Hash{})
+	if err != nil {
+		return err
+	}
+	defer accIt.Release()
+
+	if err := rlp.Encode(w, &exportHeader{Magic: exportMagic, Version: exportVersion, ExportHeader: *header}); err != nil {
+		return err
+	}
+	var (
+		writer = &exportWriter{w: w}
+		codes  = make(map[common.Hash]struct{})
+		start  = time.Now()
+		logged = time.Now()
+	)
+	for accIt.Next() {
+		account, err := FullAccount(accIt.Account())
+		if err != nil {
+			return err
+		}
+		// Export every contract code once, along with its first account
+		var code []byte
+		if codeHash := common.BytesToHash(account.CodeHash); codeHash != emptyCode {
+			if _, ok := codes[codeHash]; !ok {
+				if code = rawdb.ReadCode(db, codeHash); len(code) == 0 {
+					return fmt.Errorf("missing code %#x of account %#x", codeHash, accIt.Hash())
+				}
+				codes[codeHash] = struct{}{}
+			}
+		}
+		if err := writer.addAccount(accIt.Hash(), common.CopyBytes(accIt.Account()), code); err != nil {
+			return err
+		}
+		if common.BytesToHash(account.Root) != emptyRoot {
+			stIt, err := snaptree.StorageIterator(header.Root, accIt.Hash(), common.Hash{})
+			if err != nil {
+				return err
+			}
+			for stIt.Next() {
+				if err := writer.addSlot(accIt.Hash(), stIt.Hash(), stIt.Slot()); err != nil {
+					stIt.Release()
+					return err
+				}
+			}
+			err = stIt.Error()
+			stIt.Release()
+			if err != nil {
+				return err
+			}
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Exporting state snapshot", "at", accIt.Hash(), "accounts", writer.totals.Accounts,
+				"slots", writer.totals.Slots, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := accIt.Error(); err != nil {
+		return err
+	}
+	if err := writer.flush(); err != nil {
+		return err
+	}
+	if err := writer.writeChunk(exportChunkEnd, &writer.totals); err != nil {
+		return err
+	}
+	log.Info("Exported state snapshot", "root", header.Root, "accounts", writer.totals.Accounts,
+		"slots", writer.totals.Slots, "codes", writer.totals.Codes, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// exportImporter writes the content of a snapshot export into the database,
+// regenerating the tries on the fly.
+type exportImporter struct {
+	batch  ethdb.Batch
+	nodes  *importNodeWriter // Writer of the regenerated trie nodes into the batch
+	totals exportTotals
+	codes  map[common.Hash]struct{} // Codes imported so far
+
+	accTrie *trie.StackTrie // Account trie being regenerated
+
+	account  common.Hash     // Hash of the account being imported
+	full     *Account        // Account being imported, nil if none
+	stTrie   *trie.StackTrie // Storage trie of the account being imported
+	lastSlot *common.Hash    // Hash of the last imported slot of the account
+}
+
+// startAccount finishes the previous account and starts importing the given one.
+func (imp *exportImporter) startAccount(entry *exportAccount) error {
+	if imp.full != nil && bytes.Compare(entry.Hash[:], imp.account[:]) <= 0 {
+		return fmt.Errorf("account %#x out of order after %#x", entry.Hash, imp.account)
+	}
+	if err := imp.finishAccount(); err != nil {
+		return err
+	}
+	account, err := FullAccount(entry.Account)
+	if err != nil {
+		return fmt.Errorf("invalid account %#x: %v", entry.Hash, err)
+	}
+	codeHash := common.BytesToHash(account.CodeHash)
+	if len(entry.Code) > 0 {
+		if hash := crypto.Keccak256Hash(entry.Code); hash != codeHash {
+			return fmt.Errorf("code hash mismatch of account %#x: have %#x, want %#x", entry.Hash, hash, codeHash)
+		}
+		rawdb.WriteCode(imp.batch, codeHash, entry.Code)
+		imp.codes[codeHash] = struct{}{}
+		imp.totals.Codes++
+	} else if _, ok := imp.codes[codeHash]; !ok && codeHash != emptyCode {
+		return fmt.Errorf("missing code %#x of account %#x", codeHash, entry.Hash)
+	}
+	rawdb.WriteAccountSnapshot(imp.batch, entry.Hash, entry.Account)
+	imp.totals.Accounts++
+
+	imp.account, imp.full, imp.lastSlot = entry.Hash, &account, nil
+	imp.stTrie = trie.NewStackTrieWithOwner(imp.nodes, entry.Hash)
+	return nil
+}
+
+// importSlots imports storage slots of the current account.
+func (imp *exportImporter) importSlots(slots []exportSlot) error {
+	for i := range slots {
+		slot := &slots[i]
+		if imp.lastSlot != nil && bytes.Compare(slot.Hash[:], imp.lastSlot[:]) <= 0 {
+			return fmt.Errorf("slot %#x of account %#x out of order", slot.Hash, imp.account)
+		}
+		if len(slot.Value) == 0 {
+			return fmt.Errorf("empty slot %#x of account %#x", slot.Hash, imp.account)
+		}
+		rawdb.WriteStorageSnapshot(imp.batch, imp.account, slot.Hash, slot.Value)
+		imp.stTrie.TryUpdate(slot.Hash[:], slot.Value)
+		imp.lastSlot = &slot.Hash
+		imp.totals.Slots++
+	}
+	return nil
+}
+
+// finishAccount verifies the storage root of the current account and inserts
+// it into the account trie.
+func (imp *exportImporter) finishAccount() error {
+	if imp.full == nil {
+		return nil
+	}
+	root, err := imp.stTrie.Commit()
+	if err != nil {
+		return err
+	}
+	if root != common.BytesToHash(imp.full.Root) {
+		return fmt.Errorf("storage root mismatch of account %#x: have %#x, want %#x", imp.account, root, common.BytesToHash(imp.full.Root))
+	}
+	blob, err := rlp.EncodeToBytes(imp.full)
+	if err != nil {
+		return err
+	}
+	imp.accTrie.TryUpdate(imp.account[:], blob)
+	imp.full, imp.stTrie = nil, nil
+	return nil
+}
+
+// write flushes the batch into the database once it's large enough, or always
+// if force is set.
+func (imp *exportImporter) write(force bool) error {
+	if !force && imp.batch.ValueSize() < ethdb.IdealBatchSize {
+		return nil
+	}
+	if err := imp.nodes.record(); err != nil {
+		return err
+	}
+	if err := imp.batch.Write(); err != nil {
+		return err
+	}
+	imp.batch.Reset()
+	return nil
+}
+
+// importNodeWriter writes the trie nodes regenerated by the import, recording
+// the ones missing from the database so they can be deleted if it fails. The
+// nodes are looked up together in key order when the batch is flushed, instead
+// of one by one as they are written.
+type importNodeWriter struct {
+	db    ethdb.KeyValueReader
+	batch ethdb.Batch
+	keys  [][]byte // Keys of the nodes written since the last flush
+}
+
+func (w *importNodeWriter) Put(key []byte, value []byte) error {
+	w.keys = append(w.keys, common.CopyBytes(key))
+	return w.batch.Put(key, value)
+}
+
+// record records the nodes written since the last flush which are missing from
+// the database. It must be called before the batch is written.
+func (w *importNodeWriter) record() error {
+	sort.Slice(w.keys, func(i, j int) bool { return bytes.Compare(w.keys[i], w.keys[j]) < 0 })
+	for _, key := range w.keys {
+		has, err := w.db.Has(key)
+		if err != nil {
+			return err
+		}
+		if !has {
+			rawdb.WriteSnapshotImportNode(w.batch, common.BytesToHash(key))
+		}
+	}
+	w.keys = w.keys[:0]
+	return nil
+}
+
+func (w *importNodeWriter) Delete(key []byte) error {
+	return w.batch.Delete(key)
+}
+
+// run imports the chunks of the export from the stream, returning the root of
+// the regenerated state.
+func (imp *exportImporter) run(stream *rlp.Stream) (common.Hash, error) {
+	var (
+		start  = time.Now()
+		logged = time.Now()
+	)
+	for {
+		var chunk exportChunk
+		if err := stream.Decode(&chunk); err != nil {
+			if err == io.EOF {
+				return common.Hash{}, errors.New("truncated export, missing final chunk")
+			}
+			return common.Hash{}, fmt.Errorf("could not decode chunk %d: %v", imp.totals.Chunks, err)
+		}
+		if checksum := crc32.Checksum(chunk.Data, exportCastagnoli); checksum != chunk.Checksum {
+			return common.Hash{}, fmt.Errorf("checksum mismatch of chunk %d: have %08x, want %08x", imp.totals.Chunks, checksum, chunk.Checksum)
+		}
+		if chunk.Kind == exportChunkEnd {
+			var totals exportTotals
+			if err := rlp.DecodeBytes(chunk.Data, &totals); err != nil {
+				return common.Hash{}, fmt.Errorf("could not decode final chunk: %v", err)
+			}
+			if totals != imp.totals {
+				return common.Hash{}, fmt.Errorf("export totals mismatch: have %+v, want %+v", imp.totals, totals)
+			}
+			break
+		}
+		if chunk.Kind != exportChunkData {
+			return common.Hash{}, fmt.Errorf("unknown chunk type %d", chunk.Kind)
+		}
+		var entries []*exportAccount
+		if err := rlp.DecodeBytes(chunk.Data, &entries); err != nil {
+			return common.Hash{}, fmt.Errorf("could not decode chunk %d: %v", imp.totals.Chunks, err)
+		}
+		for _, entry := range entries {
+			if len(entry.Account) == 0 {
+				if imp.full == nil || entry.Hash != imp.account {
+					return common.Hash{}, fmt.Errorf("storage of account %#x without account", entry.Hash)
+				}
+			} else if err := imp.startAccount(entry); err != nil {
+				return common.Hash{}, err
+			}
+			if err := imp.importSlots(entry.Storage); err != nil {
+				return common.Hash{}, err
+			}
+		}
+		imp.totals.Chunks++
+		if err := imp.write(false); err != nil {
+			return common.Hash{}, err
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Importing state snapshot", "at", imp.account, "accounts", imp.totals.Accounts,
+				"slots", imp.totals.Slots, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if _, _, err := stream.Kind(); err != io.EOF {
+		return common.Hash{}, errors.New("trailing data after final chunk")
+	}
+	if err := imp.finishAccount(); err != nil {
+		return common.Hash{}, err
+	}
+	return imp.accTrie.Commit()
+}
+
+// Import reads a snapshot export from r into the database, replacing the
+// snapshot and regenerating the tries of the state. The state root is verified
+// against the one of the export before the snapshot is marked usable. If the
+// import fails, the imported snapshot entries and the trie nodes it added are
+// deleted again. The header of the export is returned.
+//
+// If check is not nil, it's called with the header of the export before the
+// database is modified, aborting the import if it returns an error.
+func Import(r io.Reader, db ethdb.KeyValueStore, check func(*ExportHeader) error) (*ExportHeader, error) {
+	if rawdb.ReadStateScheme(db) == rawdb.PathScheme {
+		return nil, errors.New("snapshot import unsupported with the path scheme")
+	}
+	stream := rlp.NewStream(r, 0)
+
+	var header exportHeader
+	if err := stream.Decode(&header); err != nil {
+		return nil, fmt.Errorf("could not decode header: %v", err)
+	}
+	if header.Magic != exportMagic {
+		return nil, errors.New("incompatible data, wrong magic")
+	}
+	if header.Version != exportVersion {
+		return nil, fmt.Errorf("incompatible version %d, (support only %d)", header.Version, exportVersion)
+	}
+	if check != nil {
+		if err := check(&header.ExportHeader); err != nil {
+			return nil, err
+		}
+	}
+	// Drop the current snapshot, a partial import must not be mistaken for a
+	// valid one
+	rawdb.DeleteSnapshotRoot(db)
+	rawdb.DeleteSnapshotJournal(db)
+	rawdb.DeleteSnapshotGenerator(db)
+	rawdb.DeleteSnapshotRecoveryNumber(db)
+	if err := wipeSnapshot(db); err != nil {
+		return nil, err
+	}
+	// The nodes recorded by an interrupted import might have been written by
+	// the chain since, only forget about them
+	if err := wipeImportNodes(db, false); err != nil {
+		return nil, err
+	}
+	var (
+		batch = db.NewBatch()
+		nodes = &importNodeWriter{db: db, batch: batch}
+	)
+	imp := &exportImporter{
+		batch:   batch,
+		nodes:   nodes,
+		codes:   make(map[common.Hash]struct{}),
+		accTrie: trie.NewStackTrie(nodes),
+	}
+	start := time.Now()
+	root, err := imp.run(stream)
+	if err == nil && root != header.Root {
+		err = fmt.Errorf("state root mismatch: have %#x, want %#x", root, header.Root)
+	}
+	if err != nil {
+		batch.Reset()
+		if err := wipeSnapshot(db); err != nil {
+			log.Error("Failed to delete imported snapshot", "err", err)
+		}
+		if err := wipeImportNodes(db, true); err != nil {
+			log.Error("Failed to delete imported trie nodes", "err", err)
+		}
+		return nil, err
+	}
+	if err := imp.write(true); err != nil {
+		return nil, err
+	}
+	// The state is verified, keep the imported nodes and mark the snapshot
+	// complete
+	if err := wipeImportNodes(db, false); err != nil {
+		return nil, err
+	}
+	rawdb.WriteSnapshotRoot(batch, root)
+	journalProgress(batch, nil, nil)
+	if err := imp.write(true); err != nil {
+		return nil, err
+	}
+	log.Info("Imported state snapshot", "root", root, "accounts", imp.totals.Accounts,
+		"slots", imp.totals.Slots, "codes", imp.totals.Codes, "elapsed", common.PrettyDuration(time.Since(start)))
+	return &header.ExportHeader, nil
+}
+
+// wipeImportNodes deletes the records of the trie nodes written by a snapshot
+// import, along with the nodes themselves if requested.
+func wipeImportNodes(db ethdb.KeyValueStore, nodes bool) error {
+	batch := db.NewBatch()
+	it := rawdb.NewKeyLengthIterator(db.NewIterator(rawdb.SnapshotImportPrefix, nil), len(rawdb.SnapshotImportPrefix)+common.HashLength)
+	defer it.Release()
+
+	for it.Next() {
+		hash := common.BytesToHash(it.Key()[len(rawdb.SnapshotImportPrefix):])
+		if nodes {
+			batch.Delete(hash[:])
+		}
+		rawdb.DeleteSnapshotImportNode(batch, hash)
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	return batch.Write()
+}
+
+// wipeSnapshot deletes all account and storage snapshot entries.
+func wipeSnapshot(db ethdb.KeyValueStore) error {
+	batch := db.NewBatch()
+	for prefix, length := range map[string]int{
+		string(rawdb.SnapshotAccountPrefix): len(rawdb.SnapshotAccountPrefix) + common.HashLength,
+		string(rawdb.SnapshotStoragePrefix): len(rawdb.SnapshotStoragePrefix) + 2*common.HashLength,
+	} {
+		it := rawdb.NewKeyLengthIterator(db.NewIterator([]byte(prefix), nil), length)
+		for it.Next() {
+			batch.Delete(it.Key())
+			if batch.ValueSize() > ethdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					it.Release()
+					return err
+				}
+				batch.Reset()
+			}
+		}
+		it.Release()
+	}
+	return batch.Write()
+}
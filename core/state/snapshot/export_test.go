@@ -0,0 +1,181 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/core/rawdb"
+	"github.com/foreverbit/biternal/crypto"
+	"github.com/foreverbit/biternal/trie"
+)
+
+// newExportTestTree creates a generated snapshot tree of a state with plain
+// accounts, contracts sharing code, and contracts with storage.
+func newExportTestTree(t *testing.T) (*Tree, common.Hash) {
+	var (
+		helper   = newHelper()
+		code     = []byte{0x60, 0x00, 0x60, 0x00, 0xf3}
+		codeHash = crypto.Keccak256Hash(code)
+		keys     []string
+		vals     []string
+	)
+	rawdb.WriteCode(helper.diskdb, codeHash, code)
+	for i := 0; i < 200; i++ {
+		keys = append(keys, fmt.Sprintf("key-%d", i))
+		vals = append(vals, fmt.Sprintf("val-%d", i))
+	}
+	for i := 0; i < 20; i++ {
+		acc := fmt.Sprintf("acc-%d", i)
+		switch i % 4 {
+		case 0:
+			helper.addTrieAccount(acc, &Account{Balance: big.NewInt(int64(i)), Root: emptyRoot.Bytes(), CodeHash: emptyCode.Bytes()})
+		case 1:
+			helper.addTrieAccount(acc, &Account{Balance: big.NewInt(int64(i)), Root: emptyRoot.Bytes(), CodeHash: codeHash.Bytes()})
+		default:
+			root := helper.makeStorageTrie(common.Hash{}, hashData([]byte(acc)), keys[:10*i], vals[:10*i], true)
+			helper.addTrieAccount(acc, &Account{Nonce: uint64(i), Balance: big.NewInt(int64(i)), Root: root, CodeHash: codeHash.Bytes()})
+		}
+	}
+	root, snap := helper.CommitAndGenerate()
+	select {
+	case <-snap.genPending:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("snapshot generation failed")
+	}
+	return &Tree{diskdb: helper.diskdb, triedb: helper.triedb, cache: 16, layers: map[common.Hash]snapshot{root: snap}}, root
+}
+
+// Tests that a snapshot export can be imported into an empty database, with
+// accounts and storage spanning multiple chunks.
+func TestExportImport(t *testing.T) {
+	defer func(size int) { exportChunkSize = size }(exportChunkSize)
+	exportChunkSize = 512
+
+	snaps, root := newExportTestTree(t)
+
+	var buf bytes.Buffer
+	header := &ExportHeader{Root: root, Number: 10, Hash: common.Hash{0x01}}
+	if err := Export(&buf, snaps, snaps.diskdb, header); err != nil {
+		t.Fatalf("failed to export snapshot: %v", err)
+	}
+	db := rawdb.NewMemoryDatabase()
+	imported, err := Import(bytes.NewReader(buf.Bytes()), db, nil)
+	if err != nil {
+		t.Fatalf("failed to import snapshot: %v", err)
+	}
+	if *imported != *header {
+		t.Fatalf("header mismatch: have %+v, want %+v", imported, header)
+	}
+	// The imported state must be usable both as a trie and as a snapshot
+	if _, err := trie.NewStateTrie(common.Hash{}, root, trie.NewDatabase(db)); err != nil {
+		t.Fatalf("failed to open imported state: %v", err)
+	}
+	tree, err := New(db, trie.NewDatabase(db), 16, root, false, false, false)
+	if err != nil {
+		t.Fatalf("failed to open imported snapshot: %v", err)
+	}
+	if err := tree.Verify(root); err != nil {
+		t.Fatalf("failed to verify imported snapshot: %v", err)
+	}
+	code := []byte{0x60, 0x00, 0x60, 0x00, 0xf3}
+	if have := rawdb.ReadCode(db, crypto.Keccak256Hash(code)); !bytes.Equal(have, code) {
+		t.Fatalf("code mismatch: have %x, want %x", have, code)
+	}
+}
+
+// Tests that corrupted or truncated snapshot exports are rejected, without
+// leaving a usable snapshot behind.
+func TestImportCorrupted(t *testing.T) {
+	defer func(size int) { exportChunkSize = size }(exportChunkSize)
+	exportChunkSize = 512
+
+	snaps, root := newExportTestTree(t)
+
+	var buf bytes.Buffer
+	if err := Export(&buf, snaps, snaps.diskdb, &ExportHeader{Root: root}); err != nil {
+		t.Fatalf("failed to export snapshot: %v", err)
+	}
+	export := buf.Bytes()
+
+	flipped := common.CopyBytes(export)
+	flipped[len(flipped)/2] ^= 0xff
+
+	wrong := common.CopyBytes(export)
+	copy(wrong[bytes.Index(wrong, root[:]):], common.Hash{0x01}.Bytes())
+
+	tests := map[string][]byte{
+		"truncated": export[:len(export)*2/3],
+		"corrupted": flipped,
+		"wrongroot": wrong,
+		"trailing":  append(common.CopyBytes(export), 0x80),
+	}
+	for name, data := range tests {
+		db := rawdb.NewMemoryDatabase()
+		rawdb.WriteSnapshotRoot(db, common.Hash{0xff})
+		if _, err := Import(bytes.NewReader(data), db, nil); err == nil {
+			t.Errorf("%s: import succeeded", name)
+		}
+		if snapRoot := rawdb.ReadSnapshotRoot(db); snapRoot != (common.Hash{}) {
+			t.Errorf("%s: snapshot root left behind: %#x", name, snapRoot)
+		}
+		// Neither the snapshot entries nor the trie nodes may be left behind
+		it := db.NewIterator(nil, nil)
+		for it.Next() {
+			if len(it.Key()) == common.HashLength || bytes.HasPrefix(it.Key(), rawdb.SnapshotImportPrefix) ||
+				bytes.HasPrefix(it.Key(), rawdb.SnapshotAccountPrefix) || bytes.HasPrefix(it.Key(), rawdb.SnapshotStoragePrefix) {
+				t.Errorf("%s: imported entry left behind: %x", name, it.Key())
+				break
+			}
+		}
+		it.Release()
+	}
+	// An import refused by the header check must not touch the database
+	refused := errors.New("refused")
+	db := rawdb.NewMemoryDatabase()
+	rawdb.WriteSnapshotRoot(db, common.Hash{0xff})
+	if _, err := Import(bytes.NewReader(export), db, func(*ExportHeader) error { return refused }); err != refused {
+		t.Fatalf("refused import error mismatch: have %v, want %v", err, refused)
+	}
+	if snapRoot := rawdb.ReadSnapshotRoot(db); snapRoot != (common.Hash{0xff}) {
+		t.Fatalf("snapshot root modified by refused import: %#x", snapRoot)
+	}
+	// A failed import must keep the trie nodes which were already present
+	db = rawdb.NewMemoryDatabase()
+	if _, err := Import(bytes.NewReader(export), db, nil); err != nil {
+		t.Fatalf("failed to import snapshot: %v", err)
+	}
+	if _, err := Import(bytes.NewReader(wrong), db, nil); err == nil {
+		t.Fatalf("import with wrong root succeeded")
+	}
+	tr, err := trie.NewStateTrie(common.Hash{}, root, trie.NewDatabase(db))
+	if err != nil {
+		t.Fatalf("failed to open previously imported state: %v", err)
+	}
+	it := trie.NewIterator(tr.NodeIterator(nil))
+	for it.Next() {
+	}
+	if it.Err != nil {
+		t.Fatalf("previously imported state corrupted: %v", it.Err)
+	}
+}
@@ -89,6 +89,20 @@ func ParseGeneratorStatus(generatorBlob []byte) string {
 		generator.Done, generator.Accounts, generator.Slots, generator.Storage, m)
 }
 
+// Generated reports whether the persistent snapshot in the database is fully
+// generated. It doesn't check whether the snapshot matches any state.
+func Generated(db ethdb.KeyValueReader) bool {
+	generatorBlob := rawdb.ReadSnapshotGenerator(db)
+	if len(generatorBlob) == 0 {
+		return false
+	}
+	var generator journalGenerator
+	if err := rlp.DecodeBytes(generatorBlob, &generator); err != nil {
+		return false
+	}
+	return generator.Done
+}
+
 // loadAndParseJournal tries to parse the snapshot journal in latest format.
 func loadAndParseJournal(db ethdb.KeyValueStore, base *diskLayer) (snapshot, journalGenerator, error) {
 	// Retrieve the disk layer generator. It must exist, no matter the
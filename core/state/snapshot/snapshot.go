@@ -355,6 +355,97 @@ func (t *Tree) Update(blockRoot common.Hash, parentRoot common.Hash, destructs m
 	return nil
 }
 
+// Origin returns the original values of the accounts and storage slots modified
+// by the diff layer of the given root, as found in its parent layer. A nil or
+// empty value means the item didn't exist in the parent.
+//
+// The storage of destructed accounts is enumerated from the parent layer, which
+// is only possible if the snapshot is fully generated.
+func (t *Tree) Origin(root common.Hash, parentRoot common.Hash) (map[common.Hash][]byte, map[common.Hash]map[common.Hash][]byte, error) {
+	var (
+		accounts = make(map[common.Hash][]byte)
+		storage  = make(map[common.Hash]map[common.Hash][]byte)
+	)
+	// Empty blocks don't create a new layer, nothing was modified
+	if root == parentRoot {
+		return accounts, storage, nil
+	}
+	snap := t.Snapshot(root)
+	if snap == nil {
+		return nil, nil, fmt.Errorf("snapshot [%#x] missing", root)
+	}
+	diff, ok := snap.(*diffLayer)
+	if !ok {
+		return nil, nil, fmt.Errorf("snapshot [%#x] is not a diff layer", root)
+	}
+	parent := diff.Parent()
+	if parent.Root() != parentRoot {
+		return nil, nil, fmt.Errorf("snapshot [%#x] parent mismatch: have %#x, want %#x", root, parent.Root(), parentRoot)
+	}
+	// Collect the modified items, the maps of a diff layer are never modified
+	// after creation but the lock is still taken for consistency
+	var (
+		destructs []common.Hash
+		slots     = make(map[common.Hash][]common.Hash)
+	)
+	diff.lock.RLock()
+	for hash := range diff.destructSet {
+		accounts[hash] = nil
+		destructs = append(destructs, hash)
+	}
+	for hash := range diff.accountData {
+		accounts[hash] = nil
+	}
+	for hash, data := range diff.storageData {
+		for slot := range data {
+			slots[hash] = append(slots[hash], slot)
+		}
+	}
+	diff.lock.RUnlock()
+
+	for hash := range accounts {
+		blob, err := parent.AccountRLP(hash)
+		if err != nil {
+			return nil, nil, err
+		}
+		accounts[hash] = common.CopyBytes(blob)
+	}
+	// Destructed accounts lose their entire storage
+	for _, hash := range destructs {
+		it, err := t.StorageIterator(parentRoot, hash, common.Hash{})
+		if err != nil {
+			return nil, nil, err
+		}
+		for it.Next() {
+			if storage[hash] == nil {
+				storage[hash] = make(map[common.Hash][]byte)
+			}
+			storage[hash][it.Hash()] = common.CopyBytes(it.Slot())
+		}
+		err = it.Error()
+		it.Release()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	for hash, list := range slots {
+		if storage[hash] == nil {
+			storage[hash] = make(map[common.Hash][]byte)
+		}
+		for _, slot := range list {
+			if _, ok := storage[hash][slot]; ok {
+				continue
+			}
+			blob, err := parent.Storage(hash, slot)
+			if err != nil {
+				return nil, nil, err
+			}
+			storage[hash][slot] = common.CopyBytes(blob)
+		}
+	}
+	return accounts, storage, nil
+}
+
 // Cap traverses downwards the snapshot tree from a head block hash until the
 // number of allowed layers are crossed. All layers beyond the permitted number
 // are flattened downwards.
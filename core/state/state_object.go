@@ -92,6 +92,7 @@ type stateObject struct {
 	dirtyCode bool // true if the code was updated
 	suicided  bool
 	deleted   bool
+	wiped     bool // true if the storage of a previous incarnation needs to be deleted
 }
 
 // empty returns whether the account is considered empty.
@@ -304,22 +305,24 @@ func (s *stateObject) finalise(prefetch bool) {
 }
 
 // updateTrie writes cached storage modifications into the object's storage trie.
-// It will return nil if the trie has not been loaded and no changes have been made
+// It will return nil if the trie has not been loaded and no changes have been made.
+//
+// The storage tries of different objects may be updated concurrently, changes
+// to the state shared between them are guarded by the state's storage lock.
 func (s *stateObject) updateTrie(db Database) Trie {
 	// Make sure all dirty slots are finalized into the pending storage area
 	s.finalise(false) // Don't prefetch anymore, pull directly if need be
 	if len(s.pendingStorage) == 0 {
 		return s.trie
 	}
-	// Track the amount of time wasted on updating the storage trie
-	if metrics.EnabledExpensive {
-		defer func(start time.Time) { s.db.StorageUpdates += time.Since(start) }(time.Now())
-	}
-	// The snapshot storage map for the object
-	var storage map[common.Hash][]byte
+	var (
+		storage map[common.Hash][]byte // The snapshot storage map for the object
+		hasher  crypto.KeccakState
+
+		updated, deleted int
+	)
 	// Insert all the pending updates into the trie
 	tr := s.getTrie(db)
-	hasher := s.db.hasher
 
 	usedStorage := make([][]byte, 0, len(s.pendingStorage))
 	for key, value := range s.pendingStorage {
@@ -332,32 +335,46 @@ func (s *stateObject) updateTrie(db Database) Trie {
 		var v []byte
 		if (value == common.Hash{}) {
 			s.setError(tr.TryDelete(key[:]))
-			s.db.StorageDeleted += 1
+			deleted += 1
 		} else {
 			// Encoding []byte cannot fail, ok to ignore the error.
 			v, _ = rlp.EncodeToBytes(common.TrimLeftZeroes(value[:]))
 			s.setError(tr.TryUpdate(key[:], v))
-			s.db.StorageUpdated += 1
+			updated += 1
 		}
 		// If state snapshotting is active, cache the data til commit
 		if s.db.snap != nil {
 			if storage == nil {
-				// Retrieve the old storage map, if available, create a new one otherwise
-				if storage = s.db.snapStorage[s.addrHash]; storage == nil {
-					storage = make(map[common.Hash][]byte)
-					s.db.snapStorage[s.addrHash] = storage
-				}
+				storage = make(map[common.Hash][]byte)
+				hasher = crypto.NewKeccakState()
 			}
 			storage[crypto.HashData(hasher, key[:])] = v // v will be nil if it's deleted
 		}
 		usedStorage = append(usedStorage, common.CopyBytes(key[:])) // Copy needed for closure
 	}
-	if s.db.prefetcher != nil {
-		s.db.prefetcher.used(s.addrHash, s.data.Root, usedStorage)
-	}
 	if len(s.pendingStorage) > 0 {
 		s.pendingStorage = make(Storage)
 	}
+	// Merge the statistics and snapshot data into the shared state
+	s.db.storageLock.Lock()
+	defer s.db.storageLock.Unlock()
+
+	s.db.StorageUpdated += updated
+	s.db.StorageDeleted += deleted
+
+	if storage != nil {
+		// Extend the old storage map, if available, use the new one otherwise
+		if old := s.db.snapStorage[s.addrHash]; old != nil {
+			for hash, v := range storage {
+				old[hash] = v
+			}
+		} else {
+			s.db.snapStorage[s.addrHash] = storage
+		}
+	}
+	if s.db.prefetcher != nil {
+		s.db.prefetcher.used(s.addrHash, s.data.Root, usedStorage)
+	}
 	return tr
 }
 
@@ -367,10 +384,6 @@ func (s *stateObject) updateRoot(db Database) {
 	if s.updateTrie(db) == nil {
 		return
 	}
-	// Track the amount of time wasted on hashing the storage trie
-	if metrics.EnabledExpensive {
-		defer func(start time.Time) { s.db.StorageHashes += time.Since(start) }(time.Now())
-	}
 	s.data.Root = s.trie.Hash()
 }
 
@@ -384,10 +397,6 @@ func (s *stateObject) CommitTrie(db Database) (*trie.NodeSet, error) {
 	if s.dbErr != nil {
 		return nil, s.dbErr
 	}
-	// Track the amount of time wasted on committing the storage trie
-	if metrics.EnabledExpensive {
-		defer func(start time.Time) { s.db.StorageCommits += time.Since(start) }(time.Now())
-	}
 	root, nodes, err := s.trie.Commit(false)
 	if err == nil {
 		s.data.Root = root
@@ -442,6 +451,7 @@ func (s *stateObject) deepCopy(db *StateDB) *stateObject {
 	stateObject.suicided = s.suicided
 	stateObject.dirtyCode = s.dirtyCode
 	stateObject.deleted = s.deleted
+	stateObject.wiped = s.wiped
 	return stateObject
 }
 
@@ -21,7 +21,9 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"runtime"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/foreverbit/biternal/common"
@@ -77,6 +79,13 @@ type StateDB struct {
 	snapAccounts  map[common.Hash][]byte
 	snapStorage   map[common.Hash]map[common.Hash][]byte
 
+	// Storage tries of independent accounts are updated, hashed and committed
+	// concurrently, by at most the given number of workers. They are processed
+	// serially unless enabled by SetWorkers. The lock guards the state shared
+	// between them.
+	workers     int
+	storageLock sync.Mutex
+
 	// This map holds 'live' objects, which will get modified while processing a state transition.
 	stateObjects        map[common.Address]*stateObject
 	stateObjectsPending map[common.Address]struct{} // State objects finalized but not yet written to the trie
@@ -339,6 +348,34 @@ func (s *StateDB) GetStorageProof(a common.Address, key common.Hash) ([][]byte,
 	return proof, err
 }
 
+// GetMultiProof returns the Merkle multiproof of the given accounts, containing
+// every node of their individual proofs once.
+func (s *StateDB) GetMultiProof(addrs []common.Address) ([][]byte, error) {
+	keys := make([][]byte, len(addrs))
+	for i, addr := range addrs {
+		keys[i] = crypto.Keccak256(addr.Bytes())
+	}
+	var proof proofList
+	err := s.trie.ProveMulti(keys, 0, &proof)
+	return proof, err
+}
+
+// GetStorageMultiProof returns the Merkle multiproof of the given storage slots,
+// containing every node of their individual proofs once.
+func (s *StateDB) GetStorageMultiProof(a common.Address, slots []common.Hash) ([][]byte, error) {
+	var proof proofList
+	trie := s.StorageTrie(a)
+	if trie == nil {
+		return proof, errors.New("storage trie for requested address does not exist")
+	}
+	keys := make([][]byte, len(slots))
+	for i, slot := range slots {
+		keys[i] = crypto.Keccak256(slot.Bytes())
+	}
+	err := trie.ProveMulti(keys, 0, &proof)
+	return proof, err
+}
+
 // GetCommittedState retrieves a value from the given account's committed storage trie.
 func (s *StateDB) GetCommittedState(addr common.Address, hash common.Hash) common.Hash {
 	stateObject := s.getStateObject(addr)
@@ -582,6 +619,9 @@ func (s *StateDB) createObject(addr common.Address) (newobj, prev *stateObject)
 		}
 	}
 	newobj = newObject(s, addr, types.StateAccount{})
+	if prev != nil {
+		newobj.wiped = prev.wiped || prev.data.Root != emptyRoot
+	}
 	if prev == nil {
 		s.journal.append(createObjectChange{account: &addr})
 	} else {
@@ -648,6 +688,7 @@ func (s *StateDB) Copy() *StateDB {
 		db:                  s.db,
 		trie:                s.db.CopyTrie(s.trie),
 		originalRoot:        s.originalRoot,
+		workers:             s.workers,
 		stateObjects:        make(map[common.Address]*stateObject, len(s.journal.dirties)),
 		stateObjectsPending: make(map[common.Address]struct{}, len(s.stateObjectsPending)),
 		stateObjectsDirty:   make(map[common.Address]struct{}, len(s.journal.dirties)),
@@ -713,11 +754,12 @@ func (s *StateDB) Copy() *StateDB {
 	if s.prefetcher != nil {
 		state.prefetcher = s.prefetcher.copy()
 	}
-	if s.snaps != nil {
+	if s.snaps != nil || s.snap != nil {
 		// In order for the miner to be able to use and make additions
 		// to the snapshot tree, we need to copy that aswell.
 		// Otherwise, any block mined by ourselves will cause gaps in the tree,
-		// and force the miner to operate trie-backed only
+		// and force the miner to operate trie-backed only. Historic states
+		// have no tree, but can only be read through their snapshot.
 		state.snaps = s.snaps
 		state.snap = s.snap
 		// deep copy needed
@@ -842,11 +884,28 @@ func (s *StateDB) IntermediateRoot(deleteEmptyObjects bool) common.Hash {
 	// the account prefetcher. Instead, let's process all the storage updates
 	// first, giving the account prefetches just a few more milliseconds of time
 	// to pull useful data from disk.
+	//
+	// The storage tries are independent of each other, update and hash them
+	// concurrently.
+	objs := make([]*stateObject, 0, len(s.stateObjectsPending))
 	for addr := range s.stateObjectsPending {
 		if obj := s.stateObjects[addr]; !obj.deleted {
-			obj.updateRoot(s.db)
+			objs = append(objs, obj)
 		}
 	}
+	var start time.Time
+	if metrics.EnabledExpensive {
+		start = time.Now()
+	}
+	s.parallel(len(objs), func(i int) { objs[i].updateTrie(s.db) })
+	if metrics.EnabledExpensive {
+		s.StorageUpdates += time.Since(start)
+		start = time.Now()
+	}
+	s.parallel(len(objs), func(i int) { objs[i].updateRoot(s.db) })
+	if metrics.EnabledExpensive {
+		s.StorageHashes += time.Since(start)
+	}
 	// Now we're about to start to write changes to the trie. The trie is so far
 	// _untouched_. We can check with the prefetcher, if it can give us a trie
 	// which has the same root, but also has some content loaded into it.
@@ -879,6 +938,49 @@ func (s *StateDB) IntermediateRoot(deleteEmptyObjects bool) common.Hash {
 	return s.trie.Hash()
 }
 
+// SetWorkers sets the maximum number of goroutines updating, hashing and
+// committing storage tries concurrently. Zero means runtime.GOMAXPROCS.
+func (s *StateDB) SetWorkers(workers int) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	s.workers = workers
+}
+
+// parallel runs fn for every index in [0, n) on a bounded pool of workers,
+// returning when all calls are done. The calls for different indices must
+// not interfere with each other.
+func (s *StateDB) parallel(n int, fn func(i int)) {
+	workers := s.workers
+	if workers > n {
+		workers = n
+	}
+	// Don't bother with goroutines if there's nothing to parallelize
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+	tasks := make(chan int, n)
+	for i := 0; i < n; i++ {
+		tasks <- i
+	}
+	close(tasks)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for i := range tasks {
+				fn(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
 // Prepare sets the current transaction hash and index which are
 // used when the EVM emits new state logs.
 func (s *StateDB) Prepare(thash common.Hash, ti int) {
@@ -899,6 +1001,9 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 	if s.dbErr != nil {
 		return common.Hash{}, fmt.Errorf("commit aborted due to earlier error: %v", s.dbErr)
 	}
+	if s.Historic() {
+		return common.Hash{}, errHistoricState
+	}
 	// Finalize any pending changes and merge everything into the tries
 	s.IntermediateRoot(deleteEmptyObjects)
 
@@ -909,25 +1014,60 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 		nodes            = trie.NewMergedNodeSet()
 	)
 	codeWriter := s.db.TrieDB().DiskDB().NewBatch()
+	objs := make([]*stateObject, 0, len(s.stateObjectsDirty))
 	for addr := range s.stateObjectsDirty {
-		if obj := s.stateObjects[addr]; !obj.deleted {
+		obj := s.stateObjects[addr]
+		if obj.deleted {
+			// Wipe the storage trie of the deleted account
+			if obj.wiped || obj.data.Root != emptyRoot {
+				set := trie.NewNodeSet(obj.addrHash)
+				set.MarkWiped()
+				if err := nodes.Merge(set); err != nil {
+					return common.Hash{}, err
+				}
+				obj.wiped = false
+			}
+		} else {
 			// Write any contract code associated with the state object
 			if obj.code != nil && obj.dirtyCode {
 				rawdb.WriteCode(codeWriter, common.BytesToHash(obj.CodeHash()), obj.code)
 				obj.dirtyCode = false
 			}
-			// Write any storage changes in the state object to its storage trie
-			set, err := obj.CommitTrie(s.db)
-			if err != nil {
-				return common.Hash{}, err
+			objs = append(objs, obj)
+		}
+	}
+	// Write any storage changes in the state objects to their storage tries,
+	// committing the independent tries concurrently
+	var (
+		start time.Time
+		sets  = make([]*trie.NodeSet, len(objs))
+		errs  = make([]error, len(objs))
+	)
+	if metrics.EnabledExpensive {
+		start = time.Now()
+	}
+	s.parallel(len(objs), func(i int) { sets[i], errs[i] = objs[i].CommitTrie(s.db) })
+	if metrics.EnabledExpensive {
+		s.StorageCommits += time.Since(start)
+	}
+	for i, set := range sets {
+		if errs[i] != nil {
+			return common.Hash{}, errs[i]
+		}
+		// Wipe the storage trie of the previous incarnation of the account
+		if objs[i].wiped {
+			if set == nil {
+				set = trie.NewNodeSet(objs[i].addrHash)
 			}
-			// Merge the dirty nodes of storage trie into global set
-			if set != nil {
-				if err := nodes.Merge(set); err != nil {
-					return common.Hash{}, err
-				}
-				storageTrieNodes += set.Len()
+			set.MarkWiped()
+			objs[i].wiped = false
+		}
+		// Merge the dirty nodes of storage trie into global set
+		if set != nil {
+			if err := nodes.Merge(set); err != nil {
+				return common.Hash{}, err
 			}
+			storageTrieNodes += set.Len()
 		}
 	}
 	if len(s.stateObjectsDirty) > 0 {
@@ -939,7 +1079,6 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 		}
 	}
 	// Write the account trie changes, measuing the amount of wasted time
-	var start time.Time
 	if metrics.EnabledExpensive {
 		start = time.Now()
 	}
@@ -986,7 +1125,7 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 		}
 		s.snap, s.snapDestructs, s.snapAccounts, s.snapStorage = nil, nil, nil, nil
 	}
-	if err := s.db.TrieDB().Update(nodes); err != nil {
+	if err := s.db.TrieDB().UpdateState(root, s.originalRoot, nodes); err != nil {
 		return common.Hash{}, err
 	}
 	s.originalRoot = root
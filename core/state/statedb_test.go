@@ -32,6 +32,8 @@ import (
 	"github.com/foreverbit/biternal/common"
 	"github.com/foreverbit/biternal/core/rawdb"
 	"github.com/foreverbit/biternal/core/types"
+	"github.com/foreverbit/biternal/crypto"
+	"github.com/foreverbit/biternal/trie"
 )
 
 // Tests that updating a state trie does not leak any database writes prior to
@@ -144,6 +146,68 @@ func TestIntermediateLeaks(t *testing.T) {
 	}
 }
 
+// Tests that updating, hashing and committing the storage tries concurrently
+// results in the same roots and database contents as doing it sequentially.
+func TestParallelStorageCommit(t *testing.T) {
+	modify := func(state *StateDB, round int) {
+		for i := 0; i < 100; i++ {
+			addr := common.Address{byte(i)}
+			state.SetBalance(addr, big.NewInt(int64(i+round)))
+			for j := 0; j < 50; j++ {
+				// Delete some of the slots written by the previous round
+				var value common.Hash
+				if (i+j+round)%7 != 0 {
+					value = common.Hash{byte(round), byte(j), byte(i)}
+				}
+				state.SetState(addr, common.Hash{byte(j), byte(i + round)}, value)
+			}
+		}
+	}
+	var (
+		serialDb     = rawdb.NewMemoryDatabase()
+		parallelDb   = rawdb.NewMemoryDatabase()
+		serialRoot   common.Hash
+		parallelRoot common.Hash
+	)
+	for round := 0; round < 3; round++ {
+		serial, _ := New(serialRoot, NewDatabase(serialDb), nil)
+		serial.SetWorkers(1)
+		concurrent, _ := New(parallelRoot, NewDatabase(parallelDb), nil)
+		concurrent.SetWorkers(8)
+
+		modify(serial, round)
+		modify(concurrent, round)
+		if have, want := concurrent.IntermediateRoot(false), serial.IntermediateRoot(false); have != want {
+			t.Fatalf("round %d: intermediate root mismatch: have %x, want %x", round, have, want)
+		}
+		modify(serial, round+1)
+		modify(concurrent, round+1)
+
+		var err error
+		if serialRoot, err = serial.Commit(false); err != nil {
+			t.Fatalf("round %d: failed to commit serial state: %v", round, err)
+		}
+		if parallelRoot, err = concurrent.Commit(false); err != nil {
+			t.Fatalf("round %d: failed to commit parallel state: %v", round, err)
+		}
+		if serialRoot != parallelRoot {
+			t.Fatalf("round %d: root mismatch: have %x, want %x", round, parallelRoot, serialRoot)
+		}
+		if serial.StorageUpdated != concurrent.StorageUpdated || serial.StorageDeleted != concurrent.StorageDeleted {
+			t.Fatalf("round %d: storage stats mismatch: have %d/%d, want %d/%d", round, concurrent.StorageUpdated, concurrent.StorageDeleted, serial.StorageUpdated, serial.StorageDeleted)
+		}
+		serial.Database().TrieDB().Commit(serialRoot, false, nil)
+		concurrent.Database().TrieDB().Commit(parallelRoot, false, nil)
+	}
+	it := serialDb.NewIterator(nil, nil)
+	defer it.Release()
+	for it.Next() {
+		if value, _ := parallelDb.Get(it.Key()); !bytes.Equal(value, it.Value()) {
+			t.Errorf("value mismatch at key %x: have %x, want %x", it.Key(), value, it.Value())
+		}
+	}
+}
+
 // TestCopy tests that copying a StateDB object indeed makes the original and
 // the copy independent of each other. This test is a regression test against
 // https://github.com/foreverbit/biternal/pull/15549.
@@ -695,6 +759,68 @@ func TestDeleteCreateRevert(t *testing.T) {
 	}
 }
 
+// Tests that the storage tries of self-destructed and recreated accounts are
+// deleted from disk with the path scheme.
+func TestWipeStorageTrie(t *testing.T) {
+	var (
+		diskdb = rawdb.NewMemoryDatabase()
+		db     = NewDatabaseWithConfig(diskdb, &trie.Config{Scheme: rawdb.PathScheme})
+		addr1  = common.BytesToAddress([]byte("one"))
+		addr2  = common.BytesToAddress([]byte("two"))
+	)
+	stored := func(addr common.Address) int {
+		var count int
+		rawdb.IterateStorageTrieNodes(diskdb, crypto.Keccak256Hash(addr.Bytes()), func(path []byte, blob []byte) {
+			count++
+		})
+		return count
+	}
+	commit := func(state *StateDB) common.Hash {
+		root, err := state.Commit(true)
+		if err != nil {
+			t.Fatalf("failed to commit state: %v", err)
+		}
+		if err := db.TrieDB().Commit(root, false, nil); err != nil {
+			t.Fatalf("failed to commit trie database: %v", err)
+		}
+		return root
+	}
+	state, _ := New(common.Hash{}, db, nil)
+	for _, addr := range []common.Address{addr1, addr2} {
+		state.SetNonce(addr, 1)
+		for i := int64(1); i <= 32; i++ {
+			state.SetState(addr, common.BigToHash(big.NewInt(i)), common.BigToHash(big.NewInt(i)))
+		}
+	}
+	root := commit(state)
+	if stored(addr1) < 2 || stored(addr2) < 2 {
+		t.Fatalf("storage tries not persisted")
+	}
+	// Self-destruct the first account and recreate the second one with a
+	// single slot
+	state, _ = New(root, db, nil)
+	state.Suicide(addr1)
+	state.Finalise(true)
+	state.CreateAccount(addr2)
+	state.SetNonce(addr2, 1)
+	state.SetState(addr2, common.HexToHash("0xff"), common.HexToHash("0xff"))
+	root = commit(state)
+
+	if n := stored(addr1); n != 0 {
+		t.Errorf("self-destructed storage trie left on disk: %d nodes", n)
+	}
+	if n := stored(addr2); n != 1 {
+		t.Errorf("recreated storage trie node count mismatch: have %d, want 1", n)
+	}
+	state, _ = New(root, db, nil)
+	if have := state.GetState(addr2, common.HexToHash("0xff")); have != common.HexToHash("0xff") {
+		t.Errorf("recreated storage mismatch: have %x", have)
+	}
+	if have := state.GetState(addr2, common.BigToHash(big.NewInt(1))); have != (common.Hash{}) {
+		t.Errorf("wiped storage still readable: %x", have)
+	}
+}
+
 // TestMissingTrieNodes tests that if the StateDB fails to load parts of the trie,
 // the Commit operation fails with an error
 // If we are missing trie nodes, we should not continue writing to the trie
@@ -0,0 +1,626 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/core/rawdb"
+	"github.com/foreverbit/biternal/core/state/snapshot"
+	"github.com/foreverbit/biternal/core/types"
+	"github.com/foreverbit/biternal/ethdb"
+	"github.com/foreverbit/biternal/log"
+	"github.com/foreverbit/biternal/rlp"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	// stateDiffCacheLimit is the number of decoded reverse diffs to keep in
+	// memory for serving historic state reads.
+	stateDiffCacheLimit = 256
+
+	// stateHistoryPruneBatch is the maximum number of history items pruned at
+	// once, bounding the memory used when the retention is lowered.
+	stateHistoryPruneBatch = 1024
+)
+
+var (
+	// errStateHistoryDisabled is returned if historic states are requested but
+	// the state history is not maintained.
+	errStateHistoryDisabled = errors.New("state history disabled")
+
+	// errStateHistoryChanged is returned by historic states if the state history
+	// was rewound since their creation, so their content can't be trusted.
+	errStateHistoryChanged = errors.New("state history changed")
+
+	// errStateHistoryPruned is returned by historic states if the reverse diffs
+	// needed to reconstruct them were pruned since their creation.
+	errStateHistoryPruned = errors.New("state history pruned")
+)
+
+// stateHistoryMeta is the metadata of the state history item of a block.
+type stateHistoryMeta struct {
+	Hash       common.Hash // Hash of the block
+	ParentRoot common.Hash // State root of the parent block
+	Root       common.Hash // State root of the block
+}
+
+// stateDiffAccount is an account in a reverse diff.
+type stateDiffAccount struct {
+	Hash common.Hash // Hash of the account address
+	Blob []byte      // Account in slim RLP format, empty if it didn't exist
+}
+
+// stateDiffSlot is a storage slot in a reverse diff.
+type stateDiffSlot struct {
+	Account common.Hash // Hash of the account address
+	Hash    common.Hash // Hash of the storage slot key
+	Blob    []byte      // RLP encoded slot value, empty if it didn't exist
+}
+
+// stateDiff is the reverse diff of a block, the original values of all the
+// accounts and storage slots modified by the block.
+type stateDiff struct {
+	Accounts []stateDiffAccount
+	Storage  []stateDiffSlot
+}
+
+// newStateDiff creates the reverse diff from the original values of the items
+// modified by a block, sorted to get a deterministic encoding.
+func newStateDiff(accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) *stateDiff {
+	diff := new(stateDiff)
+	for hash, blob := range accounts {
+		diff.Accounts = append(diff.Accounts, stateDiffAccount{Hash: hash, Blob: blob})
+	}
+	for account, slots := range storage {
+		for hash, blob := range slots {
+			diff.Storage = append(diff.Storage, stateDiffSlot{Account: account, Hash: hash, Blob: blob})
+		}
+	}
+	sort.Slice(diff.Accounts, func(i, j int) bool {
+		return bytes.Compare(diff.Accounts[i].Hash[:], diff.Accounts[j].Hash[:]) < 0
+	})
+	sort.Slice(diff.Storage, func(i, j int) bool {
+		if diff.Storage[i].Account != diff.Storage[j].Account {
+			return bytes.Compare(diff.Storage[i].Account[:], diff.Storage[j].Account[:]) < 0
+		}
+		return bytes.Compare(diff.Storage[i].Hash[:], diff.Storage[j].Hash[:]) < 0
+	})
+	return diff
+}
+
+// stateDiffSet is the decoded form of a reverse diff for direct lookups.
+type stateDiffSet struct {
+	accounts map[common.Hash][]byte
+	storage  map[common.Hash]map[common.Hash][]byte
+}
+
+// stateHistory maintains the reverse diffs of the recent canonical blocks in a
+// dedicated freezer, along with an index of the blocks modifying each account
+// and storage slot in the key-value store. The state of a block in the history
+// is reconstructed on top of the snapshot of the newest block in the history:
+// the value of an item is the original value in the first reverse diff after
+// the block modifying it, or the current value if it wasn't modified since.
+//
+// The history covers the states of the blocks in [tail-1, head], where tail is
+// the oldest block with a reverse diff. All modifications happen on the chain
+// mutex of the blockchain, while reads are concurrent.
+type stateHistory struct {
+	db      ethdb.Database
+	ancient string         // Root ancient directory containing the freezer
+	snaps   *snapshot.Tree // Snapshot tree providing the original values
+	limit   uint64         // Number of recent blocks to retain reverse diffs for
+
+	freezer  *rawdb.Freezer // Freezer of the reverse diffs, nil if closed
+	head     uint64         // Number of the newest block in the history
+	headHash common.Hash    // Hash of the newest block in the history
+	headRoot common.Hash    // State root of the newest block in the history
+	tail     uint64         // Number of the oldest block with a reverse diff
+	epoch    uint64         // Counter of rewinds, invalidating historic states
+	broken   bool           // Whether a block couldn't be recorded, restarting the history
+
+	diffs *lru.Cache   // Cache of decoded reverse diffs by block number
+	lock  sync.RWMutex // Lock protecting the history against rewinds and pruning
+}
+
+// newStateHistory opens the state history and aligns it with the given chain
+// head, restarting it if it can't be.
+func newStateHistory(db ethdb.Database, snaps *snapshot.Tree, limit uint64, head *types.Block) (*stateHistory, error) {
+	ancient, err := db.AncientDatadir()
+	if err != nil {
+		return nil, err
+	}
+	freezer, err := rawdb.NewStateFreezer(ancient, false)
+	if err != nil {
+		return nil, err
+	}
+	diffs, _ := lru.New(stateDiffCacheLimit)
+	h := &stateHistory{
+		db:      db,
+		ancient: ancient,
+		snaps:   snaps,
+		limit:   limit,
+		freezer: freezer,
+		diffs:   diffs,
+	}
+	if err := h.load(head); err != nil {
+		log.Info("Starting state history", "number", head.NumberU64(), "reason", err)
+		if err := h.reset(head.NumberU64(), head.Hash(), head.Root()); err != nil {
+			return nil, err
+		}
+	}
+	h.prune()
+
+	log.Info("Opened state history", "head", h.head, "tail", h.tail, "limit", limit)
+	return h, nil
+}
+
+// load restores the history markers from the database, rewinding the history
+// to the chain head if it's ahead.
+func (h *stateHistory) load(head *types.Block) error {
+	marker := rawdb.ReadStateHistoryHead(h.db)
+	if marker == nil {
+		return errors.New("no state history")
+	}
+	frozen, _ := h.freezer.Ancients()
+	tail, _ := h.freezer.Tail()
+	if tail > *marker+1 || frozen < *marker+1 {
+		return fmt.Errorf("state history [%d, %d] doesn't contain head %d", tail, frozen, *marker)
+	}
+	// Items appended after the last recorded head are unindexed, drop them
+	if err := h.freezer.TruncateHead(*marker + 1); err != nil {
+		return err
+	}
+	h.head, h.tail = *marker, tail
+
+	// The history must end at the chain head for new blocks to be recorded
+	number := head.NumberU64()
+	if number > h.head {
+		return fmt.Errorf("state history head %d behind chain", h.head)
+	}
+	if number+1 < h.tail {
+		return fmt.Errorf("state history tail %d ahead of chain", h.tail)
+	}
+	if number >= h.tail {
+		meta, err := h.meta(number)
+		if err != nil {
+			return err
+		}
+		if meta.Hash != head.Hash() {
+			return fmt.Errorf("state history block %d mismatch: have %x, want %x", number, meta.Hash, head.Hash())
+		}
+	} else if n := rawdb.ReadStateHistoryNumber(h.db, head.Root()); n == nil || *n != number {
+		return fmt.Errorf("state history block %d root missing", number)
+	}
+	h.headHash, h.headRoot = head.Hash(), head.Root()
+	return h.truncate(number)
+}
+
+// close closes the freezer of the history.
+func (h *stateHistory) close() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.freezer != nil {
+		h.freezer.Close()
+		h.freezer = nil
+	}
+}
+
+// meta retrieves the metadata of the history item of the given block.
+func (h *stateHistory) meta(number uint64) (*stateHistoryMeta, error) {
+	blob := rawdb.ReadStateHistoryMeta(h.freezer, number)
+	if len(blob) == 0 {
+		return nil, fmt.Errorf("state history item %d missing", number)
+	}
+	meta := new(stateHistoryMeta)
+	if err := rlp.DecodeBytes(blob, meta); err != nil {
+		return nil, fmt.Errorf("state history item %d metadata corrupted: %v", number, err)
+	}
+	return meta, nil
+}
+
+// diff retrieves the decoded reverse diff of the given block, either from the
+// cache or from the freezer.
+func (h *stateHistory) diff(number uint64) (*stateDiffSet, error) {
+	if cached, ok := h.diffs.Get(number); ok {
+		return cached.(*stateDiffSet), nil
+	}
+	blob := rawdb.ReadStateHistory(h.freezer, number)
+	if len(blob) == 0 {
+		return nil, fmt.Errorf("state history item %d missing", number)
+	}
+	var diff stateDiff
+	if err := rlp.DecodeBytes(blob, &diff); err != nil {
+		return nil, fmt.Errorf("state history item %d corrupted: %v", number, err)
+	}
+	set := &stateDiffSet{
+		accounts: make(map[common.Hash][]byte, len(diff.Accounts)),
+		storage:  make(map[common.Hash]map[common.Hash][]byte),
+	}
+	for _, account := range diff.Accounts {
+		set.accounts[account.Hash] = account.Blob
+	}
+	for _, slot := range diff.Storage {
+		if set.storage[slot.Account] == nil {
+			set.storage[slot.Account] = make(map[common.Hash][]byte)
+		}
+		set.storage[slot.Account][slot.Hash] = slot.Blob
+	}
+	h.diffs.Add(number, set)
+	return set, nil
+}
+
+// update records the reverse diff of a new canonical head block. The history
+// is rewound first if the block replaces recorded ones, and restarted if the
+// block is not linked to the history or its diff can't be generated.
+func (h *stateHistory) update(block *types.Block, parent *types.Header) {
+	number := block.NumberU64()
+	if number == 0 || parent == nil {
+		if err := h.reset(number, block.Hash(), block.Root()); err != nil {
+			log.Error("Failed to restart state history", "number", number, "err", err)
+		}
+		return
+	}
+	if !h.broken && number == h.head && block.Hash() == h.headHash {
+		return // Same head written again
+	}
+	if !h.broken && number <= h.head {
+		if err := h.truncate(number - 1); err != nil {
+			log.Warn("State history interrupted", "number", number, "err", err)
+			h.broken = true
+		}
+	}
+	if !h.broken && (number != h.head+1 || block.ParentHash() != h.headHash) {
+		log.Warn("State history interrupted", "number", number, "head", h.head, "err", "non-contiguous block")
+		h.broken = true
+	}
+	accounts, storage, err := h.snaps.Origin(block.Root(), parent.Root)
+	if err != nil {
+		if !h.broken {
+			log.Warn("State history interrupted", "number", number, "err", err)
+			h.broken = true
+		}
+		return
+	}
+	if h.broken {
+		if err := h.reset(number-1, block.ParentHash(), parent.Root); err != nil {
+			log.Error("Failed to restart state history", "number", number-1, "err", err)
+			return
+		}
+		log.Info("Restarted state history", "number", number)
+	}
+	if err := h.append(block, parent.Root, newStateDiff(accounts, storage)); err != nil {
+		log.Warn("State history interrupted", "number", number, "err", err)
+		h.broken = true
+		return
+	}
+	h.prune()
+}
+
+// append adds the reverse diff of the block following the current head to the
+// history and indexes it.
+func (h *stateHistory) append(block *types.Block, parentRoot common.Hash, diff *stateDiff) error {
+	meta, err := rlp.EncodeToBytes(&stateHistoryMeta{Hash: block.Hash(), ParentRoot: parentRoot, Root: block.Root()})
+	if err != nil {
+		return err
+	}
+	data, err := rlp.EncodeToBytes(diff)
+	if err != nil {
+		return err
+	}
+	number := block.NumberU64()
+	if err := rawdb.WriteStateHistory(h.freezer, number, meta, data); err != nil {
+		return err
+	}
+	// The index is only written after the item, readers never find index
+	// entries without the corresponding items
+	batch := h.db.NewBatch()
+	for _, account := range diff.Accounts {
+		rawdb.WriteStateHistoryAccountIndex(batch, account.Hash, number)
+	}
+	for _, slot := range diff.Storage {
+		rawdb.WriteStateHistoryStorageIndex(batch, slot.Account, slot.Hash, number)
+	}
+	rawdb.WriteStateHistoryNumber(batch, block.Root(), number)
+	rawdb.WriteStateHistoryHead(batch, number)
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to write state history index", "err", err)
+	}
+	h.lock.Lock()
+	h.head, h.headHash, h.headRoot = number, block.Hash(), block.Root()
+	h.lock.Unlock()
+	return nil
+}
+
+// deleteIndex deletes the index entries of the reverse diff of the given block.
+func (h *stateHistory) deleteIndex(batch ethdb.KeyValueWriter, number uint64, diff *stateDiffSet) {
+	for hash := range diff.accounts {
+		rawdb.DeleteStateHistoryAccountIndex(batch, hash, number)
+	}
+	for account, slots := range diff.storage {
+		for hash := range slots {
+			rawdb.DeleteStateHistoryStorageIndex(batch, account, hash, number)
+		}
+	}
+}
+
+// deleteRoot deletes the mapping of the given state root, unless it belongs to
+// another block sharing the same state.
+func (h *stateHistory) deleteRoot(batch ethdb.KeyValueWriter, root common.Hash, number uint64) {
+	if n := rawdb.ReadStateHistoryNumber(h.db, root); n != nil && *n == number {
+		rawdb.DeleteStateHistoryNumber(batch, root)
+	}
+}
+
+// truncate rewinds the history to the given block, deleting the reverse diffs
+// of all blocks above it. Historic states created before are invalidated.
+func (h *stateHistory) truncate(number uint64) error {
+	if number >= h.head {
+		return nil
+	}
+	if number+1 < h.tail {
+		return fmt.Errorf("rewind to %d below state history tail %d", number, h.tail)
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.epoch++
+	h.diffs.Purge()
+
+	// Delete the index before the items, the items of a crashed truncation are
+	// dropped on startup
+	batch := h.db.NewBatch()
+	var parent *stateHistoryMeta
+	for n := h.head; n > number; n-- {
+		meta, err := h.meta(n)
+		if err != nil {
+			return err
+		}
+		diff, err := h.diff(n)
+		if err != nil {
+			return err
+		}
+		h.deleteIndex(batch, n, diff)
+		h.deleteRoot(batch, meta.Root, n)
+		parent = meta
+	}
+	// Blocks sharing the state of a dropped one lost their mapping
+	rawdb.WriteStateHistoryNumber(batch, parent.ParentRoot, number)
+	rawdb.WriteStateHistoryHead(batch, number)
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to truncate state history index", "err", err)
+	}
+	if err := h.freezer.TruncateHead(number + 1); err != nil {
+		return err
+	}
+	h.diffs.Purge()
+	h.head, h.headRoot = number, parent.ParentRoot
+	if number >= h.tail {
+		meta, err := h.meta(number)
+		if err != nil {
+			return err
+		}
+		h.headHash = meta.Hash
+	} else {
+		h.headHash = rawdb.ReadCanonicalHash(h.db, number)
+	}
+	return nil
+}
+
+// reset deletes the entire history and restarts it from the given block.
+func (h *stateHistory) reset(number uint64, hash common.Hash, root common.Hash) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.epoch++
+	h.diffs.Purge()
+
+	if h.freezer != nil {
+		h.freezer.Close()
+		h.freezer = nil
+	}
+	// Delete the head marker first, so the history restarts if interrupted
+	rawdb.DeleteStateHistoryHead(h.db)
+	if err := rawdb.DeleteStateHistoryIndex(h.db); err != nil {
+		return err
+	}
+	freezer, err := rawdb.ResetStateFreezer(h.ancient, number+1)
+	if err != nil {
+		return err
+	}
+	batch := h.db.NewBatch()
+	rawdb.WriteStateHistoryNumber(batch, root, number)
+	rawdb.WriteStateHistoryHead(batch, number)
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to reset state history", "err", err)
+	}
+	h.freezer, h.broken = freezer, false
+	h.head, h.headHash, h.headRoot = number, hash, root
+	h.tail = number + 1
+	return nil
+}
+
+// prune deletes the reverse diffs of the blocks falling out of the retention
+// window. Historic states of the pruned blocks are invalidated.
+func (h *stateHistory) prune() {
+	for h.head+1 > h.tail+h.limit {
+		var (
+			tail   = h.head + 1 - h.limit
+			diffs  = make(map[uint64]*stateDiffSet)
+			parent = make(map[uint64]common.Hash)
+		)
+		if tail > h.tail+stateHistoryPruneBatch {
+			tail = h.tail + stateHistoryPruneBatch
+		}
+		// Collect the pruned items before deleting them from the freezer, the
+		// index is deleted afterwards as readers never consult entries below
+		// the tail. A crash in between leaves unreachable entries behind.
+		for n := h.tail; n < tail; n++ {
+			meta, err := h.meta(n)
+			if err != nil {
+				log.Error("Failed to prune state history", "number", n, "err", err)
+				return
+			}
+			diff, err := h.diff(n)
+			if err != nil {
+				log.Error("Failed to prune state history", "number", n, "err", err)
+				return
+			}
+			diffs[n], parent[n] = diff, meta.ParentRoot
+		}
+		h.lock.Lock()
+		if err := h.freezer.TruncateTail(tail); err != nil {
+			h.lock.Unlock()
+			log.Error("Failed to prune state history", "tail", tail, "err", err)
+			return
+		}
+		h.tail = tail
+		h.lock.Unlock()
+
+		batch := h.db.NewBatch()
+		for n, diff := range diffs {
+			h.deleteIndex(batch, n, diff)
+			h.deleteRoot(batch, parent[n], n-1)
+			h.diffs.Remove(n)
+		}
+		if err := batch.Write(); err != nil {
+			log.Crit("Failed to prune state history index", "err", err)
+		}
+	}
+}
+
+// snapshot returns the reconstructed snapshot of the state with the given root.
+func (h *stateHistory) snapshot(root common.Hash) (snapshot.Snapshot, error) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	number := rawdb.ReadStateHistoryNumber(h.db, root)
+	if number == nil || *number > h.head || *number+1 < h.tail {
+		return nil, fmt.Errorf("state %#x not in state history", root)
+	}
+	base := h.snaps.Snapshot(h.headRoot)
+	if base == nil {
+		return nil, fmt.Errorf("state history base %#x unavailable", h.headRoot)
+	}
+	return &historicSnapshot{
+		history: h,
+		base:    base,
+		root:    root,
+		number:  *number,
+		head:    h.head,
+		epoch:   h.epoch,
+	}, nil
+}
+
+// historicSnapshot is the snapshot of a state in the state history, serving
+// reads by reverting the snapshot of the head of the history.
+type historicSnapshot struct {
+	history *stateHistory
+	base    snapshot.Snapshot // Snapshot of the history head
+	root    common.Hash       // State root of the block
+	number  uint64            // Number of the block
+	head    uint64            // Number of the history head at creation
+	epoch   uint64            // Rewind counter of the history at creation
+}
+
+// Root implements snapshot.Snapshot, returning the state root.
+func (s *historicSnapshot) Root() common.Hash {
+	return s.root
+}
+
+// Account implements snapshot.Snapshot, retrieving the account associated
+// with a particular hash in the slim data format.
+func (s *historicSnapshot) Account(hash common.Hash) (*snapshot.Account, error) {
+	data, err := s.AccountRLP(hash)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 { // can be both nil and []byte{}
+		return nil, nil
+	}
+	account := new(snapshot.Account)
+	if err := rlp.DecodeBytes(data, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// AccountRLP implements snapshot.Snapshot, retrieving the account RLP
+// associated with a particular hash in the slim data format.
+func (s *historicSnapshot) AccountRLP(hash common.Hash) ([]byte, error) {
+	s.history.lock.RLock()
+	defer s.history.lock.RUnlock()
+
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	n, ok := rawdb.FindStateHistoryAccountIndex(s.history.db, hash, s.number+1)
+	if !ok || n > s.head {
+		return s.base.AccountRLP(hash)
+	}
+	diff, err := s.history.diff(n)
+	if err != nil {
+		return nil, err
+	}
+	blob, ok := diff.accounts[hash]
+	if !ok {
+		return nil, fmt.Errorf("account %#x missing in state history item %d", hash, n)
+	}
+	return blob, nil
+}
+
+// Storage implements snapshot.Snapshot, retrieving the storage data associated
+// with a particular hash, within a particular account.
+func (s *historicSnapshot) Storage(accountHash, storageHash common.Hash) ([]byte, error) {
+	s.history.lock.RLock()
+	defer s.history.lock.RUnlock()
+
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	n, ok := rawdb.FindStateHistoryStorageIndex(s.history.db, accountHash, storageHash, s.number+1)
+	if !ok || n > s.head {
+		return s.base.Storage(accountHash, storageHash)
+	}
+	diff, err := s.history.diff(n)
+	if err != nil {
+		return nil, err
+	}
+	blob, ok := diff.storage[accountHash][storageHash]
+	if !ok {
+		return nil, fmt.Errorf("slot %#x of account %#x missing in state history item %d", storageHash, accountHash, n)
+	}
+	return blob, nil
+}
+
+// check returns an error if the history can't be used to reconstruct the
+// state anymore. The read lock of the history is assumed to be held.
+func (s *historicSnapshot) check() error {
+	if s.history.epoch != s.epoch || s.history.freezer == nil {
+		return errStateHistoryChanged
+	}
+	if s.number+1 < s.history.tail {
+		return errStateHistoryPruned
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/consensus/ethash"
+	"github.com/foreverbit/biternal/core/rawdb"
+	"github.com/foreverbit/biternal/core/state"
+	"github.com/foreverbit/biternal/core/types"
+	"github.com/foreverbit/biternal/core/vm"
+	"github.com/foreverbit/biternal/crypto"
+	"github.com/foreverbit/biternal/params"
+)
+
+var (
+	historyTestKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	historyTestAddr   = crypto.PubkeyToAddress(historyTestKey.PublicKey)
+
+	// historyTestCounter stores the block number in slot 0 and its parity in
+	// slot 1, clearing the latter on even blocks.
+	historyTestCounter = common.Address{0xc0}
+
+	// historyTestDestruct self-destructs when called, clearing its storage.
+	historyTestDestruct = common.Address{0xde}
+)
+
+// newHistoryTestChain generates a chain of blocks transferring funds to a
+// rotating set of accounts and modifying contract storage, destructing the
+// storage of a contract at the given block.
+func newHistoryTestChain(n int, destruct int) (*Genesis, []*types.Block) {
+	genesis := &Genesis{
+		Config:  params.TestChainConfig,
+		BaseFee: big.NewInt(params.InitialBaseFee),
+		Alloc: GenesisAlloc{
+			historyTestAddr:    {Balance: big.NewInt(params.Ether)},
+			historyTestCounter: {Balance: common.Big0, Code: common.Hex2Bytes("436000556002430660015500")},
+			historyTestDestruct: {
+				Balance: common.Big1,
+				Code:    common.Hex2Bytes("33ff"),
+				Storage: map[common.Hash]common.Hash{{0x01}: {0x01}, {0x02}: {0x02}},
+			},
+		},
+	}
+	db := rawdb.NewMemoryDatabase()
+	signer := types.LatestSigner(params.TestChainConfig)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis.MustCommit(db), ethash.NewFaker(), db, n, func(i int, b *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(historyTestAddr), common.Address{byte(i % 16)}, big.NewInt(int64(i+1)), params.TxGas, b.header.BaseFee, nil), signer, historyTestKey)
+		b.AddTx(tx)
+
+		to := historyTestCounter
+		if i+1 == destruct {
+			to = historyTestDestruct
+		}
+		tx, _ = types.SignTx(types.NewTransaction(b.TxNonce(historyTestAddr), to, common.Big0, 100000, b.header.BaseFee, nil), signer, historyTestKey)
+		b.AddTx(tx)
+	})
+	return genesis, blocks
+}
+
+// checkHistoricState compares the accounts and storage touched by the history
+// test chain in the given state against the reference one.
+func checkHistoricState(t *testing.T, number uint64, have, want *state.StateDB) {
+	t.Helper()
+
+	accounts := []common.Address{historyTestAddr, historyTestCounter, historyTestDestruct}
+	for i := 0; i < 16; i++ {
+		accounts = append(accounts, common.Address{byte(i)})
+	}
+	for _, addr := range accounts {
+		if have, want := have.GetBalance(addr), want.GetBalance(addr); have.Cmp(want) != 0 {
+			t.Errorf("block %d: balance mismatch for %x: have %v, want %v", number, addr, have, want)
+		}
+		if have, want := have.GetNonce(addr), want.GetNonce(addr); have != want {
+			t.Errorf("block %d: nonce mismatch for %x: have %d, want %d", number, addr, have, want)
+		}
+		if have, want := have.Exist(addr), want.Exist(addr); have != want {
+			t.Errorf("block %d: existence mismatch for %x: have %v, want %v", number, addr, have, want)
+		}
+	}
+	for _, addr := range []common.Address{historyTestCounter, historyTestDestruct} {
+		for _, slot := range []common.Hash{{}, {0x01}, {0x02}, common.BigToHash(common.Big1)} {
+			if have, want := have.GetState(addr, slot), want.GetState(addr, slot); have != want {
+				t.Errorf("block %d: slot %x mismatch for %x: have %x, want %x", number, slot, addr, have, want)
+			}
+		}
+	}
+	if err := have.Error(); err != nil {
+		t.Errorf("block %d: historic state failed: %v", number, err)
+	}
+}
+
+// Tests that the states of recent blocks are served from the state history,
+// including after reorgs, restarts and lowering the retention.
+func TestStateHistory(t *testing.T) {
+	genesis, blocks := newHistoryTestChain(160, 40)
+
+	// Import the chain into an archive node to serve the reference states
+	archiveDb := rawdb.NewMemoryDatabase()
+	genesis.MustCommit(archiveDb)
+	archive, err := NewBlockChain(archiveDb, &CacheConfig{TrieCleanLimit: 256, TrieDirtyDisabled: true}, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create archive chain: %v", err)
+	}
+	defer archive.Stop()
+	if _, err := archive.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import archive chain: %v", err)
+	}
+	// Import the chain into a node maintaining the state history
+	db, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	defer db.Close()
+	genesis.MustCommit(db)
+
+	config := *defaultCacheConfig
+	config.StateHistory = 150
+	chain, err := NewBlockChain(db, &config, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	check := func(chain *BlockChain, blocks []*types.Block, from int) {
+		t.Helper()
+		for i, block := range blocks {
+			if i < from {
+				if _, err := chain.HistoricStateAt(block.Root()); err == nil {
+					t.Errorf("block %d: pruned historic state available", block.NumberU64())
+				}
+				continue
+			}
+			have, err := chain.HistoricStateAt(block.Root())
+			if err != nil {
+				t.Errorf("block %d: historic state unavailable: %v", block.NumberU64(), err)
+				continue
+			}
+			want, err := archive.StateAt(block.Root())
+			if err != nil {
+				t.Fatalf("block %d: reference state unavailable: %v", block.NumberU64(), err)
+			}
+			checkHistoricState(t, block.NumberU64(), have, want)
+		}
+	}
+	// Blocks 10 to 160 are covered, the oldest by the parent root of the tail
+	check(chain, blocks, 9)
+
+	// States flushed from memory are served through the regular accessor too
+	statedb, err := chain.StateAt(blocks[19].Root())
+	if err != nil {
+		t.Fatalf("failed to retrieve flushed state: %v", err)
+	}
+	if !statedb.Historic() {
+		t.Fatalf("flushed state not served from state history")
+	}
+	if _, err := statedb.Commit(false); err == nil {
+		t.Fatalf("historic state committed")
+	}
+	// Reorg to a longer fork from block 150, invalidating the replaced states
+	stale, err := chain.HistoricStateAt(blocks[154].Root())
+	if err != nil {
+		t.Fatalf("failed to retrieve replaced state: %v", err)
+	}
+	fork, _ := GenerateChain(params.TestChainConfig, blocks[149], ethash.NewFaker(), archiveDb, 20, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{0xff})
+		signer := types.LatestSigner(params.TestChainConfig)
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(historyTestAddr), common.Address{byte(15 - i%16)}, big.NewInt(int64(i+7)), params.TxGas, b.header.BaseFee, nil), signer, historyTestKey)
+		b.AddTx(tx)
+	})
+	if _, err := archive.InsertChain(fork); err != nil {
+		t.Fatalf("failed to import archive fork: %v", err)
+	}
+	if _, err := chain.InsertChain(fork); err != nil {
+		t.Fatalf("failed to import fork: %v", err)
+	}
+	stale.GetBalance(common.Address{byte(154 % 16)})
+	if stale.Error() == nil {
+		t.Fatalf("replaced historic state still readable")
+	}
+	canon := append(append([]*types.Block{}, blocks[:150]...), fork...)
+	check(chain, canon, 19)
+
+	// Restart the node, the history is restored as is
+	chain.Stop()
+	chain, err = NewBlockChain(db, &config, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to recreate chain: %v", err)
+	}
+	check(chain, canon, 19)
+	chain.Stop()
+
+	// Restart with a lower retention, the history is pruned
+	config.StateHistory = 50
+	chain, err = NewBlockChain(db, &config, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to recreate chain: %v", err)
+	}
+	defer chain.Stop()
+	check(chain, canon, 119)
+}
@@ -79,6 +79,8 @@ type Message interface {
 	IsFake() bool
 	Data() []byte
 	AccessList() types.AccessList
+	At() uint64
+	IsReserve() bool
 }
 
 // ExecutionResult includes all output after executing given evm
@@ -233,6 +235,11 @@ func (st *StateTransition) preCheck() error {
 			return fmt.Errorf("%w: address %v, codehash: %s", ErrSenderNoEOA,
 				st.msg.From().Hex(), codeHash)
 		}
+		// Make sure reserve transactions are only executed in their own block
+		if st.msg.IsReserve() && st.msg.At() != st.evm.Context.BlockNumber.Uint64() {
+			return fmt.Errorf("%w: address %v, at: %d, block: %d", ErrReserveBlockMismatch,
+				st.msg.From().Hex(), st.msg.At(), st.evm.Context.BlockNumber)
+		}
 	}
 	// Make sure that transaction gasFeeCap is greater than the baseFee (post london)
 	if st.evm.ChainConfig().IsLondon(st.evm.Context.BlockNumber) {
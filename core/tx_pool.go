@@ -77,6 +77,10 @@ var (
 	// maximum allowance of the current block.
 	ErrGasLimit = errors.New("exceeds block gas limit")
 
+	// ErrReserveBlockPassed is returned if a reserve transaction is scheduled for
+	// a block that is not in the future anymore.
+	ErrReserveBlockPassed = errors.New("reserve block already passed")
+
 	// ErrNegativeValue is a sanity error to ensure no one is able to specify a
 	// transaction with a negative value.
 	ErrNegativeValue = errors.New("negative value")
@@ -243,10 +247,12 @@ type TxPool struct {
 	istanbul bool // Fork indicator whether we are in the istanbul stage.
 	eip2718  bool // Fork indicator whether we are using EIP-2718 type transactions.
 	eip1559  bool // Fork indicator whether we are using EIP-1559 type transactions.
+	eternal  bool // Fork indicator whether we are using Eternal Live reserve transactions.
 
 	currentState  *state.StateDB // Current state in the blockchain head
 	pendingNonces *txNoncer      // Pending state tracking virtual nonces
 	currentMaxGas uint64         // Current gas limit for transaction caps
+	currentNumber uint64         // Current block number for reserve transaction expiry
 
 	locals  *accountSet // Set of local transaction to exempt from eviction rules
 	journal *txJournal  // Journal of local transaction to back up to disk
@@ -592,6 +598,14 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if !pool.eip1559 && tx.Type() == types.DynamicFeeTxType {
 		return ErrTxTypeNotSupported
 	}
+	// Reject reserve transactions until Eternal Live activates.
+	if !pool.eternal && tx.Type() == types.ReserveTxType {
+		return ErrTxTypeNotSupported
+	}
+	// Accept reserve transactions only while their block is still in the future.
+	if tx.IsReserve() && tx.At() <= pool.currentNumber {
+		return ErrReserveBlockPassed
+	}
 	// Reject transactions over defined size to prevent DOS attacks
 	if uint64(tx.Size()) > txMaxSize {
 		return ErrOversizedData
@@ -1293,6 +1307,7 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	pool.currentState = statedb
 	pool.pendingNonces = newTxNoncer(statedb)
 	pool.currentMaxGas = newHead.GasLimit
+	pool.currentNumber = newHead.Number.Uint64()
 
 	// Inject any transactions discarded due to reorgs
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
@@ -1304,6 +1319,7 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	pool.istanbul = pool.chainconfig.IsIstanbul(next)
 	pool.eip2718 = pool.chainconfig.IsBerlin(next)
 	pool.eip1559 = pool.chainconfig.IsLondon(next)
+	pool.eternal = pool.chainconfig.IsEternal(next)
 }
 
 // promoteExecutables moves transactions that have become processable from the
@@ -1335,6 +1351,15 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 		log.Trace("Removed unpayable queued transactions", "count", len(drops))
 		queuedNofundsMeter.Mark(int64(len(drops)))
 
+		// Drop all reserve transactions whose block has already passed
+		expired, _ := list.Expire(pool.currentNumber)
+		for _, tx := range expired {
+			hash := tx.Hash()
+			pool.all.Remove(hash)
+		}
+		log.Trace("Removed expired queued transactions", "count", len(expired))
+		drops = append(drops, expired...)
+
 		// Gather all executable transactions and promote them
 		readies := list.Ready(pool.pendingNonces.get(addr))
 		for _, tx := range readies {
@@ -1532,6 +1557,15 @@ func (pool *TxPool) demoteUnexecutables() {
 		}
 		pendingNofundsMeter.Mark(int64(len(drops)))
 
+		// Drop all reserve transactions whose block has already passed, and queue any invalids back for later
+		expired, stales := list.Expire(pool.currentNumber)
+		for _, tx := range expired {
+			hash := tx.Hash()
+			log.Trace("Removed expired pending transaction", "hash", hash)
+			pool.all.Remove(hash)
+		}
+		drops, invalids = append(drops, expired...), append(invalids, stales...)
+
 		for _, tx := range invalids {
 			hash := tx.Hash()
 			log.Trace("Demoting pending transaction", "hash", hash)
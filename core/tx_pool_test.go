@@ -44,6 +44,9 @@ var (
 
 	// eip1559Config is a chain config with EIP-1559 enabled at block 0.
 	eip1559Config *params.ChainConfig
+
+	// eternalConfig is a chain config with Eternal Live enabled at block 0.
+	eternalConfig *params.ChainConfig
 )
 
 func init() {
@@ -54,6 +57,10 @@ func init() {
 	eip1559Config = &cpy
 	eip1559Config.BerlinBlock = common.Big0
 	eip1559Config.LondonBlock = common.Big0
+
+	eternal := *eip1559Config
+	eternalConfig = &eternal
+	eternalConfig.EternalBlock = common.Big0
 }
 
 type testBlockChain struct {
@@ -112,6 +119,22 @@ func dynamicFeeTx(nonce uint64, gaslimit uint64, gasFee *big.Int, tip *big.Int,
 	return tx
 }
 
+func reserveTx(nonce uint64, gaslimit uint64, gasFee *big.Int, tip *big.Int, at uint64, key *ecdsa.PrivateKey) *types.Transaction {
+	tx, _ := types.SignNewTx(key, types.LatestSignerForChainID(params.TestChainConfig.ChainID), &types.ReserveTx{
+		ChainID:    params.TestChainConfig.ChainID,
+		Nonce:      nonce,
+		GasTipCap:  tip,
+		GasFeeCap:  gasFee,
+		Gas:        gaslimit,
+		To:         &common.Address{},
+		Value:      big.NewInt(100),
+		Data:       nil,
+		AccessList: nil,
+		At:         at,
+	})
+	return tx
+}
+
 func setupTxPool() (*TxPool, *ecdsa.PrivateKey) {
 	return setupTxPoolWithConfig(params.TestChainConfig)
 }
@@ -393,6 +416,46 @@ func TestTransactionTipAboveFeeCap(t *testing.T) {
 	}
 }
 
+// Tests that reserve transactions are only accepted after Eternal Live is
+// activated and only while their execution block is still in the future.
+func TestTransactionReserveBlock(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPoolWithConfig(eip1559Config)
+	defer pool.Stop()
+
+	// The pre-fork signer can't even recover the sender of a reserve transaction
+	if err := pool.AddRemote(reserveTx(0, 21000, big.NewInt(1), big.NewInt(1), 1, key)); err != ErrInvalidSender {
+		t.Error("expected", ErrInvalidSender, "got", err)
+	}
+
+	pool, key = setupTxPoolWithConfig(eternalConfig)
+	defer pool.Stop()
+
+	testAddBalance(pool, crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000))
+
+	// Reserve transactions without a target block are invalid
+	if err := pool.AddRemote(reserveTx(0, 21000, big.NewInt(1), big.NewInt(1), 0, key)); err != ErrInvalidSender {
+		t.Error("expected", ErrInvalidSender, "got", err)
+	}
+	pool.mu.Lock()
+	pool.currentNumber = 1
+	pool.mu.Unlock()
+
+	if err := pool.AddRemote(reserveTx(0, 21000, big.NewInt(1), big.NewInt(1), 1, key)); err != ErrReserveBlockPassed {
+		t.Error("expected", ErrReserveBlockPassed, "got", err)
+	}
+	if err := pool.addRemoteSync(reserveTx(0, 21000, big.NewInt(1), big.NewInt(1), 2, key)); err != nil {
+		t.Error("expected nil, got", err)
+	}
+	if pending, _ := pool.Stats(); pending != 1 {
+		t.Errorf("pending transactions mismatched: have %d, want %d", pending, 1)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 func TestTransactionVeryHighValues(t *testing.T) {
 	t.Parallel()
 
@@ -37,6 +37,7 @@ var (
 	ErrInvalidTxType        = errors.New("transaction type not valid in this context")
 	ErrTxTypeNotSupported   = errors.New("transaction type not supported")
 	ErrGasFeeCapTooLow      = errors.New("fee cap less than base fee")
+	ErrReserveBlockMissing  = errors.New("reserve transaction without target block")
 	errShortTypedTx         = errors.New("typed transaction too short")
 )
 
@@ -45,6 +46,7 @@ const (
 	LegacyTxType = iota
 	AccessListTxType
 	DynamicFeeTxType
+	ReserveTxType
 )
 
 // Transaction is an Ethereum transaction.
@@ -67,7 +69,7 @@ func NewTx(inner TxData) *Transaction {
 
 // TxData is the underlying data of a transaction.
 //
-// This is implemented by DynamicFeeTx, LegacyTx and AccessListTx.
+// This is implemented by DynamicFeeTx, LegacyTx, AccessListTx and ReserveTx.
 type TxData interface {
 	txType() byte // returns the type ID
 	copy() TxData // creates a deep copy and initializes all fields
@@ -82,6 +84,7 @@ type TxData interface {
 	value() *big.Int
 	nonce() uint64
 	to() *common.Address
+	at() uint64
 
 	rawSignatureValues() (v, r, s *big.Int)
 	setSignatureValues(chainID, v, r, s *big.Int)
@@ -184,6 +187,15 @@ func (tx *Transaction) decodeTyped(b []byte) (TxData, error) {
 		var inner DynamicFeeTx
 		err := rlp.DecodeBytes(b[1:], &inner)
 		return &inner, err
+	case ReserveTxType:
+		var inner ReserveTx
+		if err := rlp.DecodeBytes(b[1:], &inner); err != nil {
+			return nil, err
+		}
+		if inner.At == 0 {
+			return nil, ErrReserveBlockMissing
+		}
+		return &inner, nil
 	default:
 		return nil, ErrTxTypeNotSupported
 	}
@@ -285,6 +297,17 @@ func (tx *Transaction) To() *common.Address {
 	return copyAddressPtr(tx.inner.to())
 }
 
+// Time returns the time the transaction was first seen locally.
+func (tx *Transaction) Time() time.Time { return tx.time }
+
+// At returns the number of the block a reserve transaction must be executed in.
+// For instant transactions, At returns zero.
+func (tx *Transaction) At() uint64 { return tx.inner.at() }
+
+// IsReserve reports whether the transaction is a reserve transaction, i.e. one
+// scheduled for execution in a specific future block.
+func (tx *Transaction) IsReserve() bool { return tx.Type() == ReserveTxType }
+
 // Cost returns gas * gasPrice + value.
 func (tx *Transaction) Cost() *big.Int {
 	total := new(big.Int).Mul(tx.GasPrice(), new(big.Int).SetUint64(tx.Gas()))
@@ -589,6 +612,8 @@ type Message struct {
 	gasTipCap  *big.Int
 	data       []byte
 	accessList AccessList
+	at         uint64
+	reserve    bool
 	isFake     bool
 }
 
@@ -620,6 +645,8 @@ func (tx *Transaction) AsMessage(s Signer, baseFee *big.Int) (Message, error) {
 		amount:     tx.Value(),
 		data:       tx.Data(),
 		accessList: tx.AccessList(),
+		at:         tx.At(),
+		reserve:    tx.IsReserve(),
 		isFake:     false,
 	}
 	// If baseFee provided, set gasPrice to effectiveGasPrice.
@@ -641,6 +668,8 @@ func (m Message) Gas() uint64            { return m.gasLimit }
 func (m Message) Nonce() uint64          { return m.nonce }
 func (m Message) Data() []byte           { return m.data }
 func (m Message) AccessList() AccessList { return m.accessList }
+func (m Message) At() uint64             { return m.at }
+func (m Message) IsReserve() bool        { return m.reserve }
 func (m Message) IsFake() bool           { return m.isFake }
 
 // copyAddressPtr copies an address.
@@ -491,6 +491,100 @@ func TestTransactionCoding(t *testing.T) {
 	}
 }
 
+// TestReserveTransactionCoding tests signing and serializing/de-serializing
+// reserve transactions to/from rlp and JSON.
+func TestReserveTransactionCoding(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+	var (
+		signer    = NewEternalSigner(common.Big1)
+		keyAddr   = crypto.PubkeyToAddress(key.PublicKey)
+		recipient = common.HexToAddress("095e7baea6a6c7c4c2dfeb977efac326af552d87")
+	)
+	tx, err := SignNewTx(key, signer, &ReserveTx{
+		ChainID:   big.NewInt(1),
+		Nonce:     1,
+		To:        &recipient,
+		Gas:       21000,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(10),
+		Value:     big.NewInt(10),
+		At:        1024,
+	})
+	if err != nil {
+		t.Fatalf("could not sign transaction: %v", err)
+	}
+	if !tx.IsReserve() || tx.At() != 1024 {
+		t.Fatalf("reserve fields mismatch: reserve %v, at %d", tx.IsReserve(), tx.At())
+	}
+	if from, err := Sender(signer, tx); err != nil || from != keyAddr {
+		t.Fatalf("sender mismatch: have %x (%v), want %x", from, err, keyAddr)
+	}
+	// The execution block is part of the signature hash
+	rescheduled := NewTx(&ReserveTx{
+		ChainID:   big.NewInt(1),
+		Nonce:     1,
+		To:        &recipient,
+		Gas:       21000,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(10),
+		Value:     big.NewInt(10),
+		At:        1025,
+	})
+	if signer.Hash(tx) == signer.Hash(rescheduled) {
+		t.Fatal("signature hash does not cover the execution block")
+	}
+	// Older signers must reject reserve transactions
+	if _, err := NewLondonSigner(common.Big1).Sender(tx); err != ErrTxTypeNotSupported {
+		t.Fatalf("london signer error mismatch: have %v, want %v", err, ErrTxTypeNotSupported)
+	}
+	for _, codec := range []func(*Transaction) (*Transaction, error){encodeDecodeBinary, encodeDecodeJSON} {
+		parsedTx, err := codec(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := assertEqual(parsedTx, tx); err != nil {
+			t.Fatal(err)
+		}
+		if parsedTx.At() != tx.At() {
+			t.Fatalf("execution block mismatch: have %d, want %d", parsedTx.At(), tx.At())
+		}
+	}
+}
+
+// Tests that reserve transactions without a target block are rejected by both
+// the signer and the decoders.
+func TestReserveTransactionWithoutBlock(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := NewEternalSigner(common.Big1)
+
+	tx, err := SignNewTx(key, signer, &ReserveTx{
+		ChainID:   big.NewInt(1),
+		Nonce:     1,
+		To:        &common.Address{},
+		Gas:       21000,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(10),
+		Value:     big.NewInt(10),
+	})
+	if err != nil {
+		t.Fatalf("could not sign transaction: %v", err)
+	}
+	if _, err := Sender(signer, tx); err != ErrReserveBlockMissing {
+		t.Fatalf("sender error mismatch: have %v, want %v", err, ErrReserveBlockMissing)
+	}
+	bin, _ := tx.MarshalBinary()
+	if err := new(Transaction).UnmarshalBinary(bin); err != ErrReserveBlockMissing {
+		t.Fatalf("rlp decoding error mismatch: have %v, want %v", err, ErrReserveBlockMissing)
+	}
+	js, _ := json.Marshal(tx)
+	if err := new(Transaction).UnmarshalJSON(js); err != ErrReserveBlockMissing {
+		t.Fatalf("json decoding error mismatch: have %v, want %v", err, ErrReserveBlockMissing)
+	}
+}
+
 func encodeDecodeJSON(tx *Transaction) (*Transaction, error) {
 	data, err := json.Marshal(tx)
 	if err != nil {
@@ -0,0 +1,466 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/common/bitutil"
+	"github.com/foreverbit/biternal/core/bloombits"
+	"github.com/foreverbit/biternal/core/rawdb"
+	"github.com/foreverbit/biternal/core/state/snapshot"
+	"github.com/foreverbit/biternal/core/types"
+	"github.com/foreverbit/biternal/ethdb"
+	"github.com/foreverbit/biternal/log"
+	"github.com/foreverbit/biternal/params"
+	"github.com/foreverbit/biternal/trie"
+)
+
+// The kinds of inconsistencies reported by VerifyDatabase.
+const (
+	VerifyCanonical = "canonical" // Missing or unlinked canonical hash mapping
+	VerifyHeader    = "header"    // Missing or corrupted header
+	VerifyNumber    = "number"    // Missing or wrong hash to number mapping
+	VerifyTd        = "td"        // Missing or wrong total difficulty
+	VerifyBody      = "body"      // Missing or corrupted block body
+	VerifyReceipts  = "receipts"  // Missing or corrupted block receipts
+	VerifyTxLookup  = "txlookup"  // Missing or wrong transaction lookup entry
+	VerifyBloombits = "bloombits" // Stale or corrupted bloombits section
+	VerifyFreezer   = "freezer"   // Freezer content beyond the chain head
+	VerifyState     = "state"     // Missing head state
+	VerifySnapshot  = "snapshot"  // Snapshot inconsistent with the head state
+)
+
+// VerifyConfig contains the settings of a database consistency check.
+type VerifyConfig struct {
+	Start     uint64          // Number of the first block to check
+	BloomSize uint64          // Number of blocks per bloombits section (0 = params.BloomBitsBlocks)
+	SkipState bool            // Whether to skip checking the head state and snapshot
+	Repair    bool            // Whether to rebuild the inconsistent derivable data
+	Interrupt <-chan struct{} // Channel to abort the check, which can be resumed later
+}
+
+// VerifyResult contains the outcome of a database consistency check.
+type VerifyResult struct {
+	Next     uint64         // Number of the first unchecked block, to resume an interrupted check
+	Issues   map[string]int // Number of inconsistencies found by kind
+	Repaired map[string]int // Number of inconsistencies repaired by kind
+}
+
+// Unrepaired returns the number of inconsistencies found but not repaired.
+func (r *VerifyResult) Unrepaired() int {
+	var n int
+	for kind, issues := range r.Issues {
+		n += issues - r.Repaired[kind]
+	}
+	return n
+}
+
+// verifier is the state of a database consistency check.
+type verifier struct {
+	db     ethdb.Database
+	config *VerifyConfig
+	chain  *params.ChainConfig
+	batch  ethdb.Batch
+	result *VerifyResult
+	issues int
+
+	frozen  uint64  // Number of blocks in the freezer
+	history uint64  // Number of the first block with body and receipts
+	bodies  uint64  // Number of the last block with body and receipts
+	txTail  *uint64 // Number of the first block with indexed transactions
+
+	bloomDb  ethdb.Database       // Bloombits section metadata
+	sections uint64               // Number of bloombits sections indexed
+	bloomGen *bloombits.Generator // Bloombits generator of the current section
+	parent   *types.Header        // Parent of the block being checked, nil if unknown
+	parentTd *big.Int             // Total difficulty of the parent, nil if unknown
+}
+
+// VerifyDatabase cross-checks the canonical chain data in the database, and the
+// indices derived from it, for consistency. Every canonical block from the
+// configured start to the head header is checked:
+//
+//   - canonical hashes must map to headers linked to their parents
+//   - headers must be mapped back to their numbers and have a total difficulty
+//   - bodies and receipts, if retained, must match the roots in the headers
+//   - indexed transactions must have lookup entries pointing to their blocks
+//   - indexed bloombits sections must match the headers of the canonical chain
+//
+// Finally the head state must be available and match the snapshot, if any.
+// Both leveldb and the freezer are checked. In repair mode, the data derivable
+// from the rest of the chain is rewritten; missing headers, bodies or receipts
+// and anything in the freezer can't be repaired. A broken snapshot is deleted,
+// to be regenerated on the next startup.
+//
+// If interrupted, the check can be resumed from the returned next block.
+func VerifyDatabase(db ethdb.Database, config *VerifyConfig) (*VerifyResult, error) {
+	head := rawdb.ReadHeadHeader(db)
+	if head == nil {
+		return nil, errors.New("missing head header")
+	}
+	chainConfig := rawdb.ReadChainConfig(db, rawdb.ReadCanonicalHash(db, 0))
+	if chainConfig == nil {
+		return nil, errors.New("missing chain config")
+	}
+	frozen, err := db.Ancients()
+	if err != nil {
+		return nil, err
+	}
+	if config.BloomSize == 0 {
+		config.BloomSize = params.BloomBitsBlocks
+	}
+	v := &verifier{
+		db:     db,
+		config: config,
+		chain:  chainConfig,
+		batch:  db.NewBatch(),
+		result: &VerifyResult{
+			Next:     config.Start,
+			Issues:   make(map[string]int),
+			Repaired: make(map[string]int),
+		},
+		frozen:  frozen,
+		history: rawdb.ReadHistoryTail(db),
+		txTail:  rawdb.ReadTxIndexTail(db),
+		bloomDb: rawdb.NewTable(db, string(rawdb.BloomBitsIndexPrefix)),
+	}
+	v.sections = readValidSections(v.bloomDb)
+
+	// Bodies and receipts are only available up to the head (snap) block
+	for _, hash := range []common.Hash{rawdb.ReadHeadBlockHash(db), rawdb.ReadHeadFastBlockHash(db)} {
+		if number := rawdb.ReadHeaderNumber(db, hash); number != nil && *number > v.bodies {
+			v.bodies = *number
+		}
+	}
+	if frozen > head.Number.Uint64()+1 {
+		v.report(VerifyFreezer, head.Number.Uint64(), head.Hash(), fmt.Sprintf("%d blocks frozen beyond the head", frozen-head.Number.Uint64()-1), false)
+	}
+	// Start from the parent of the first block, to check the linkage and difficulty
+	if start := config.Start; start > 0 {
+		if hash := rawdb.ReadCanonicalHash(db, start-1); hash != (common.Hash{}) {
+			v.parent = rawdb.ReadHeader(db, hash, start-1)
+			v.parentTd = rawdb.ReadTd(db, hash, start-1)
+		}
+	}
+	log.Info("Verifying chain data", "start", config.Start, "head", head.Number, "frozen", frozen, "bodies", v.bodies, "sections", v.sections)
+
+	var (
+		start  = time.Now()
+		logged = time.Now()
+	)
+	for number := config.Start; number <= head.Number.Uint64(); number++ {
+		select {
+		case <-config.Interrupt:
+			log.Warn("Chain verification interrupted", "next", number)
+			return v.result, v.flush()
+		default:
+		}
+		if err := v.verifyBlock(number); err != nil {
+			return v.result, err
+		}
+		if v.batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := v.flush(); err != nil {
+				return v.result, err
+			}
+		}
+		v.result.Next = number + 1
+
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Verifying chain data", "number", number, "head", head.Number, "issues", v.issues, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := v.flush(); err != nil {
+		return v.result, err
+	}
+	log.Info("Verified chain data", "blocks", v.result.Next-config.Start, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	if !config.SkipState {
+		v.verifyState()
+	}
+	return v.result, nil
+}
+
+// report records an inconsistency, noting whether it was repaired.
+func (v *verifier) report(kind string, number uint64, hash common.Hash, msg string, repaired bool) {
+	v.issues++
+	v.result.Issues[kind]++
+	if repaired {
+		v.result.Repaired[kind]++
+	}
+	log.Warn("Found database inconsistency", "kind", kind, "number", number, "hash", hash, "err", msg, "repaired", repaired)
+}
+
+// flush writes out the accumulated repairs.
+func (v *verifier) flush() error {
+	if v.batch.ValueSize() == 0 {
+		return nil
+	}
+	if err := v.batch.Write(); err != nil {
+		return err
+	}
+	v.batch.Reset()
+	return nil
+}
+
+// verifyBlock checks the data of a single canonical block and its indices.
+func (v *verifier) verifyBlock(number uint64) error {
+	// Resolve the canonical header and check its linkage
+	parent, parentTd := v.parent, v.parentTd
+	v.parent, v.parentTd = nil, nil
+
+	hash := rawdb.ReadCanonicalHash(v.db, number)
+	if hash == (common.Hash{}) {
+		v.report(VerifyCanonical, number, hash, "missing canonical hash", false)
+		return nil
+	}
+	header := rawdb.ReadHeader(v.db, hash, number)
+	if header == nil || header.Hash() != hash {
+		v.report(VerifyHeader, number, hash, "missing header", false)
+		return nil
+	}
+	if parent != nil && header.ParentHash != parent.Hash() {
+		v.report(VerifyCanonical, number, hash, fmt.Sprintf("parent %x not canonical", header.ParentHash), false)
+		parent, parentTd = nil, nil
+	}
+	v.parent = header
+
+	// Check the mappings and difficulty derived from the header
+	if stored := rawdb.ReadHeaderNumber(v.db, hash); stored == nil || *stored != number {
+		if v.config.Repair {
+			rawdb.WriteHeaderNumber(v.batch, hash, number)
+		}
+		v.report(VerifyNumber, number, hash, "missing hash to number mapping", v.config.Repair)
+	}
+	td := rawdb.ReadTd(v.db, hash, number)
+	switch {
+	case number == 0:
+		if td == nil || td.Cmp(header.Difficulty) != 0 {
+			td = v.repairTd(header, header.Difficulty)
+		}
+	case parentTd != nil:
+		if want := new(big.Int).Add(parentTd, header.Difficulty); td == nil || td.Cmp(want) != 0 {
+			td = v.repairTd(header, want)
+		}
+	case td == nil:
+		v.report(VerifyTd, number, hash, "missing total difficulty", false)
+	}
+	v.parentTd = td
+
+	// Check the bodies, receipts and transaction indices if retained
+	if number >= v.history && number <= v.bodies {
+		v.verifyBody(header)
+	}
+	// Accumulate the blooms of the indexed bloombits sections
+	section := number / v.config.BloomSize
+	if section >= v.sections {
+		return nil
+	}
+	if number%v.config.BloomSize == 0 {
+		gen, err := bloombits.NewGenerator(uint(v.config.BloomSize))
+		if err != nil {
+			return err
+		}
+		v.bloomGen = gen
+	}
+	if v.bloomGen == nil {
+		return nil // Started mid-section
+	}
+	if err := v.bloomGen.AddBloom(uint(number-section*v.config.BloomSize), header.Bloom); err != nil {
+		return err
+	}
+	if (number+1)%v.config.BloomSize == 0 {
+		defer func() { v.bloomGen = nil }()
+		return v.verifyBloomSection(section, header)
+	}
+	return nil
+}
+
+// repairTd reports an inconsistent total difficulty, repairing it if allowed.
+// Difficulties in the freezer can't be repaired. The expected total difficulty
+// is returned to continue the check with.
+func (v *verifier) repairTd(header *types.Header, td *big.Int) *big.Int {
+	number := header.Number.Uint64()
+	repair := v.config.Repair && number >= v.frozen
+	if repair {
+		rawdb.WriteTd(v.batch, header.Hash(), number, td)
+	}
+	v.report(VerifyTd, number, header.Hash(), fmt.Sprintf("missing or wrong total difficulty, want %v", td), repair)
+	return td
+}
+
+// verifyBody checks the body and receipts of a block against its header, along
+// with the lookup entries of its transactions.
+func (v *verifier) verifyBody(header *types.Header) {
+	hash, number := header.Hash(), header.Number.Uint64()
+
+	body := rawdb.ReadBody(v.db, hash, number)
+	if body == nil {
+		v.report(VerifyBody, number, hash, "missing body", false)
+		return
+	}
+	if root := types.DeriveSha(types.Transactions(body.Transactions), trie.NewStackTrie(nil)); root != header.TxHash {
+		v.report(VerifyBody, number, hash, fmt.Sprintf("transaction root mismatch: have %x, want %x", root, header.TxHash), false)
+		return
+	}
+	if uncles := types.CalcUncleHash(body.Uncles); uncles != header.UncleHash {
+		v.report(VerifyBody, number, hash, fmt.Sprintf("uncle hash mismatch: have %x, want %x", uncles, header.UncleHash), false)
+	}
+	if !rawdb.HasReceipts(v.db, hash, number) {
+		v.report(VerifyReceipts, number, hash, "missing receipts", false)
+	} else {
+		receipts := rawdb.ReadRawReceipts(v.db, hash, number)
+		if receipts == nil || receipts.DeriveFields(v.chain, hash, number, body.Transactions) != nil {
+			v.report(VerifyReceipts, number, hash, "corrupted receipts", false)
+		} else if root := types.DeriveSha(receipts, trie.NewStackTrie(nil)); root != header.ReceiptHash {
+			v.report(VerifyReceipts, number, hash, fmt.Sprintf("receipt root mismatch: have %x, want %x", root, header.ReceiptHash), false)
+		}
+	}
+	// Check the transaction lookup entries within the indexed range
+	if v.txTail == nil || number < *v.txTail {
+		return
+	}
+	var broken bool
+	for _, tx := range body.Transactions {
+		entry := rawdb.ReadTxLookupEntry(v.db, tx.Hash())
+		if entry != nil && *entry == number {
+			continue
+		}
+		// Transactions with duplicate hashes are indexed at their last inclusion
+		if entry != nil && *entry > number && v.includesTx(*entry, tx.Hash()) {
+			continue
+		}
+		v.report(VerifyTxLookup, number, hash, fmt.Sprintf("missing lookup of transaction %x", tx.Hash()), v.config.Repair)
+		broken = true
+	}
+	if broken && v.config.Repair {
+		rawdb.WriteTxLookupEntriesByBlock(v.batch, types.NewBlockWithHeader(header).WithBody(body.Transactions, body.Uncles))
+	}
+}
+
+// includesTx reports whether the canonical block with the given number includes
+// a transaction.
+func (v *verifier) includesTx(number uint64, txhash common.Hash) bool {
+	body := rawdb.ReadBody(v.db, rawdb.ReadCanonicalHash(v.db, number), number)
+	if body == nil {
+		return false
+	}
+	for _, tx := range body.Transactions {
+		if tx.Hash() == txhash {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyBloomSection checks an indexed bloombits section against the blooms of
+// the canonical headers accumulated in the generator, given the last header of
+// the section.
+func (v *verifier) verifyBloomSection(section uint64, last *types.Header) error {
+	var (
+		number = last.Number.Uint64()
+		head   = last.Hash()
+		msg    string
+	)
+	if stored := readSectionHead(v.bloomDb, section); stored != head {
+		msg = fmt.Sprintf("section %d head %x not canonical", section, stored)
+	} else {
+		for i := 0; i < types.BloomBitLength; i++ {
+			bits, err := v.bloomGen.Bitset(uint(i))
+			if err != nil {
+				msg = err.Error()
+				break
+			}
+			stored, err := rawdb.ReadBloomBits(v.db, uint(i), section, head)
+			if err != nil || !bytes.Equal(stored, bitutil.CompressBytes(bits)) {
+				msg = fmt.Sprintf("section %d bloom bit %d mismatch", section, i)
+				break
+			}
+		}
+	}
+	if msg == "" {
+		return nil
+	}
+	v.report(VerifyBloombits, number, head, msg, v.config.Repair)
+	if !v.config.Repair {
+		return nil
+	}
+	// Replace the bloom bits of the section, whatever head they were stored for
+	for i := 0; i < types.BloomBitLength; i++ {
+		rawdb.DeleteBloombits(v.db, uint(i), section, section+1)
+	}
+	for i := 0; i < types.BloomBitLength; i++ {
+		bits, _ := v.bloomGen.Bitset(uint(i))
+		rawdb.WriteBloomBits(v.batch, uint(i), section, head, bitutil.CompressBytes(bits))
+	}
+	if err := v.flush(); err != nil {
+		return err
+	}
+	writeSectionHead(v.bloomDb, section, head)
+	return nil
+}
+
+// verifyState checks that the state of the head block is available and matches
+// the snapshot, if there's a fully generated one.
+func (v *verifier) verifyState() {
+	hash := rawdb.ReadHeadBlockHash(v.db)
+	number := rawdb.ReadHeaderNumber(v.db, hash)
+	if number == nil {
+		v.report(VerifyState, 0, hash, "missing head block", false)
+		return
+	}
+	head := rawdb.ReadHeader(v.db, hash, *number)
+	if head == nil {
+		v.report(VerifyState, *number, hash, "missing head block", false)
+		return
+	}
+	triedb := trie.NewDatabase(v.db)
+	if _, err := trie.NewStateTrie(common.Hash{}, head.Root, triedb); err != nil {
+		v.report(VerifyState, *number, hash, fmt.Sprintf("missing head state %x", head.Root), false)
+		return
+	}
+	switch {
+	case rawdb.ReadSnapshotDisabled(v.db) || rawdb.ReadSnapshotRoot(v.db) == (common.Hash{}):
+		log.Info("No snapshot to verify")
+		return
+	case !snapshot.Generated(v.db):
+		log.Info("Snapshot not fully generated, skipping verification")
+		return
+	}
+	log.Info("Verifying snapshot", "number", *number, "root", head.Root)
+	start := time.Now()
+
+	snaptree, err := snapshot.New(v.db, triedb, 256, head.Root, false, false, false)
+	if err == nil {
+		err = snaptree.Verify(head.Root)
+	}
+	if err != nil {
+		// Dropping the snapshot root makes the node regenerate it on startup
+		if v.config.Repair {
+			rawdb.DeleteSnapshotRoot(v.db)
+		}
+		v.report(VerifySnapshot, *number, hash, err.Error(), v.config.Repair)
+		return
+	}
+	log.Info("Verified snapshot", "elapsed", common.PrettyDuration(time.Since(start)))
+}
@@ -0,0 +1,196 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/consensus/ethash"
+	"github.com/foreverbit/biternal/core/rawdb"
+	"github.com/foreverbit/biternal/core/types"
+	"github.com/foreverbit/biternal/core/vm"
+	"github.com/foreverbit/biternal/crypto"
+	"github.com/foreverbit/biternal/ethdb"
+	"github.com/foreverbit/biternal/params"
+)
+
+// newVerifyTestChain creates a database with a snap synced chain of blocks with
+// transactions and logs, half of them in the freezer, and the bloombits sections
+// of the given size indexed.
+func newVerifyTestChain(t *testing.T, n int, bloomSize uint64) (ethdb.Database, []*types.Block) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr    = crypto.PubkeyToAddress(key.PublicKey)
+		logger  = common.Address{0x10}
+		signer  = types.LatestSigner(params.TestChainConfig)
+		genesis = &Genesis{
+			Config:  params.TestChainConfig,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+			Alloc: GenesisAlloc{
+				addr:   {Balance: big.NewInt(params.Ether)},
+				logger: {Balance: common.Big0, Code: common.Hex2Bytes("60006000a0")}, // LOG0
+			},
+		}
+	)
+	gendb := rawdb.NewMemoryDatabase()
+	blocks, receipts := GenerateChain(params.TestChainConfig, genesis.MustCommit(gendb), ethash.NewFaker(), gendb, n, func(i int, b *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(addr), common.Address{byte(i)}, big.NewInt(1), params.TxGas, b.header.BaseFee, nil), signer, key)
+		b.AddTx(tx)
+		if i%3 == 0 {
+			tx, _ = types.SignTx(types.NewTransaction(b.TxNonce(addr), logger, common.Big0, 50000, b.header.BaseFee, nil), signer, key)
+			b.AddTx(tx)
+		}
+	})
+	db, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	genesis.MustCommit(db)
+
+	config := *defaultCacheConfig
+	config.SnapshotWait = true
+	chain, err := NewBlockChain(db, &config, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	if _, err := chain.InsertHeaderChain(headers, 1); err != nil {
+		t.Fatalf("failed to insert headers: %v", err)
+	}
+	if _, err := chain.InsertReceiptChain(blocks, receipts, uint64(n/2)); err != nil {
+		t.Fatalf("failed to insert receipts: %v", err)
+	}
+	chain.Stop()
+
+	// Index the bloombits sections the same way the chain indexer does
+	var (
+		table   = rawdb.NewTable(db, string(rawdb.BloomBitsIndexPrefix))
+		backend = &BloomIndexer{db: db, size: bloomSize}
+		ctx     = context.Background()
+	)
+	sections := uint64(n+1) / bloomSize
+	for section := uint64(0); section < sections; section++ {
+		backend.Reset(ctx, section, common.Hash{})
+		for number := section * bloomSize; number < (section+1)*bloomSize; number++ {
+			backend.Process(ctx, rawdb.ReadHeader(db, rawdb.ReadCanonicalHash(db, number), number))
+		}
+		if err := backend.Commit(); err != nil {
+			t.Fatalf("failed to index bloombits: %v", err)
+		}
+		writeSectionHead(table, section, backend.head)
+	}
+	writeValidSections(table, sections)
+
+	return db, blocks
+}
+
+// Tests that the database verification detects the inconsistencies of the chain
+// data and repairs the derivable ones.
+func TestVerifyDatabase(t *testing.T) {
+	db, blocks := newVerifyTestChain(t, 63, 16)
+
+	verify := func(repair bool) *VerifyResult {
+		t.Helper()
+
+		result, err := VerifyDatabase(db, &VerifyConfig{BloomSize: 16, Repair: repair})
+		if err != nil {
+			t.Fatalf("failed to verify database: %v", err)
+		}
+		if result.Next != uint64(len(blocks)+1) {
+			t.Fatalf("verification incomplete: next %d, want %d", result.Next, len(blocks)+1)
+		}
+		return result
+	}
+	if result := verify(false); len(result.Issues) != 0 {
+		t.Fatalf("issues found in consistent database: %v", result.Issues)
+	}
+	// Break the derivable data of the blocks in leveldb and the freezer
+	rawdb.DeleteTxLookupEntry(db, blocks[9].Transactions()[0].Hash())
+	rawdb.DeleteTxLookupEntry(db, blocks[39].Transactions()[0].Hash())
+	rawdb.DeleteTd(db, blocks[49].Hash(), blocks[49].NumberU64())
+	rawdb.WriteTd(db, blocks[52].Hash(), blocks[52].NumberU64(), big.NewInt(1))
+	rawdb.DeleteHeaderNumber(db, blocks[44].Hash())
+	rawdb.WriteBloomBits(db, 5, 2, blocks[46].Hash(), []byte{0xde, 0xad})
+
+	// Break the non-derivable data and the snapshot
+	rawdb.DeleteReceipts(db, blocks[59].Hash(), blocks[59].NumberU64())
+	rawdb.WriteAccountSnapshot(db, crypto.Keccak256Hash(common.Address{0x10}.Bytes()), []byte{0x01})
+
+	want := map[string]int{
+		VerifyTxLookup:  2,
+		VerifyTd:        2,
+		VerifyNumber:    1,
+		VerifyBloombits: 1,
+		VerifyReceipts:  1,
+		VerifySnapshot:  1,
+	}
+	result := verify(false)
+	if !reflect.DeepEqual(result.Issues, want) {
+		t.Fatalf("issue mismatch: have %v, want %v", result.Issues, want)
+	}
+	if len(result.Repaired) != 0 {
+		t.Fatalf("issues repaired without repair mode: %v", result.Repaired)
+	}
+	// Repair the database and check that only the receipts remain broken
+	result = verify(true)
+	if !reflect.DeepEqual(result.Issues, want) {
+		t.Fatalf("issue mismatch: have %v, want %v", result.Issues, want)
+	}
+	if have := result.Unrepaired(); have != 1 {
+		t.Fatalf("unrepaired issue count mismatch: have %d, want 1", have)
+	}
+	want = map[string]int{VerifyReceipts: 1}
+	if result := verify(false); !reflect.DeepEqual(result.Issues, want) {
+		t.Fatalf("issue mismatch after repair: have %v, want %v", result.Issues, want)
+	}
+	if root := rawdb.ReadSnapshotRoot(db); root != (common.Hash{}) {
+		t.Fatalf("broken snapshot not dropped")
+	}
+}
+
+// Tests that an interrupted database verification can be resumed.
+func TestVerifyDatabaseResume(t *testing.T) {
+	db, blocks := newVerifyTestChain(t, 63, 16)
+	rawdb.DeleteTd(db, blocks[9].Hash(), blocks[9].NumberU64())
+	rawdb.DeleteTd(db, blocks[49].Hash(), blocks[49].NumberU64())
+
+	interrupt := make(chan struct{})
+	close(interrupt)
+	result, err := VerifyDatabase(db, &VerifyConfig{Start: 20, BloomSize: 16, Interrupt: interrupt})
+	if err != nil {
+		t.Fatalf("failed to verify database: %v", err)
+	}
+	if result.Next != 20 || len(result.Issues) != 0 {
+		t.Fatalf("interrupted verification progressed: next %d, issues %v", result.Next, result.Issues)
+	}
+	// Resume mid-section, only the difficulty of the later block is checked
+	result, err = VerifyDatabase(db, &VerifyConfig{Start: 20, BloomSize: 16, SkipState: true})
+	if err != nil {
+		t.Fatalf("failed to verify database: %v", err)
+	}
+	if want := map[string]int{VerifyTd: 1}; !reflect.DeepEqual(result.Issues, want) {
+		t.Fatalf("issue mismatch: have %v, want %v", result.Issues, want)
+	}
+}
@@ -18,6 +18,7 @@
 package eth
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
@@ -45,6 +46,7 @@ import (
 	"github.com/foreverbit/biternal/eth/gasprice"
 	"github.com/foreverbit/biternal/eth/protocols/eth"
 	"github.com/foreverbit/biternal/eth/protocols/snap"
+	"github.com/foreverbit/biternal/eth/tracers"
 	"github.com/foreverbit/biternal/ethdb"
 	"github.com/foreverbit/biternal/event"
 	"github.com/foreverbit/biternal/internal/ethapi"
@@ -70,6 +72,7 @@ type Ethereum struct {
 
 	// Handlers
 	txPool             *core.TxPool
+	bundlePool         *core.BundlePool
 	blockchain         *core.BlockChain
 	handler            *handler
 	ethDialCandidates  enode.Iterator
@@ -126,6 +129,10 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 		}
 		config.TrieDirtyCache = 0
 	}
+	if config.HistoryRetention > 0 && (config.TxLookupLimit == 0 || config.TxLookupLimit > config.HistoryRetention) {
+		log.Warn("Limiting transaction indices to the retained history", "provided", config.TxLookupLimit, "updated", config.HistoryRetention)
+		config.TxLookupLimit = config.HistoryRetention
+	}
 	log.Info("Allocated trie memory caches", "clean", common.StorageSize(config.TrieCleanCache)*1024*1024, "dirty", common.StorageSize(config.TrieDirtyCache)*1024*1024)
 
 	// Transfer mining-related config to the ethash config.
@@ -137,6 +144,21 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	if err != nil {
 		return nil, err
 	}
+	scheme, err := rawdb.SetupStateScheme(chainDb, config.StateScheme)
+	if err != nil {
+		return nil, err
+	}
+	if scheme == rawdb.PathScheme {
+		// The scheme may have been picked up from the database, so fall back
+		// to full sync here instead of when parsing the flags.
+		if config.SyncMode == downloader.SnapSync {
+			log.Warn("Switching to full sync since snap sync is not supported by the path scheme")
+			config.SyncMode = downloader.FullSync
+		}
+		if config.NoPruning {
+			return nil, errors.New("archive mode is not supported by the path scheme")
+		}
+	}
 	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlockWithOverride(chainDb, config.Genesis, config.OverrideTerminalTotalDifficulty, config.OverrideTerminalTotalDifficultyPassed)
 	if _, ok := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !ok {
 		return nil, genesisErr
@@ -201,8 +223,16 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 			TrieTimeLimit:       config.TrieTimeout,
 			SnapshotLimit:       config.SnapshotCache,
 			Preimages:           config.Preimages,
+			ReverseDiffs:        config.StateReverseDiffs,
+			HistoryRetention:    config.HistoryRetention,
+			StateHistory:        config.StateHistory,
 		}
 	)
+	if config.VMTrace != "" {
+		if cacheConfig.LiveTrace, err = newLiveTraceConfig(config); err != nil {
+			return nil, err
+		}
+	}
 	eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, chainConfig, eth.engine, vmConfig, eth.shouldPreserve, &config.TxLookupLimit)
 	if err != nil {
 		return nil, err
@@ -219,6 +249,7 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 		config.TxPool.Journal = stack.ResolvePath(config.TxPool.Journal)
 	}
 	eth.txPool = core.NewTxPool(config.TxPool, chainConfig, eth.blockchain)
+	eth.bundlePool = core.NewBundlePool(chainConfig, eth.blockchain)
 
 	// Permit the downloader to use the trie cache allowance during fast sync
 	cacheLimit := cacheConfig.TrieCleanLimit + cacheConfig.TrieDirtyLimit + cacheConfig.SnapshotLimit
@@ -279,6 +310,36 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	return eth, nil
 }
 
+// newLiveTraceConfig creates the config of the tracer run on the transactions of
+// the imported blocks, checking that the requested tracer can be created.
+func newLiveTraceConfig(config *ethconfig.Config) (*core.LiveTraceConfig, error) {
+	var (
+		name         = config.VMTrace
+		tracerConfig json.RawMessage
+	)
+	if config.VMTraceJsonConfig != "" {
+		tracerConfig = json.RawMessage(config.VMTraceJsonConfig)
+	}
+	if _, err := tracers.New(name, new(tracers.Context), tracerConfig); err != nil {
+		return nil, fmt.Errorf("invalid live tracer %q: %v", name, err)
+	}
+	log.Info("Enabled live transaction tracing", "tracer", name, "config", config.VMTraceJsonConfig, "retention", config.VMTraceRetention)
+
+	return &core.LiveTraceConfig{
+		Tracer:    name,
+		Config:    tracerConfig,
+		Retention: config.VMTraceRetention,
+		New: func(block *types.Block, index int) (core.TxTracer, error) {
+			ctx := &tracers.Context{
+				BlockHash: block.Hash(),
+				TxIndex:   index,
+				TxHash:    block.Transactions()[index].Hash(),
+			}
+			return tracers.New(name, ctx, tracerConfig)
+		},
+	}, nil
+}
+
 func makeExtraData(extra []byte) []byte {
 	if len(extra) == 0 {
 		// create default extradata
@@ -495,6 +556,7 @@ func (s *Ethereum) Miner() *miner.Miner { return s.miner }
 func (s *Ethereum) AccountManager() *accounts.Manager  { return s.accountManager }
 func (s *Ethereum) BlockChain() *core.BlockChain       { return s.blockchain }
 func (s *Ethereum) TxPool() *core.TxPool               { return s.txPool }
+func (s *Ethereum) BundlePool() *core.BundlePool       { return s.bundlePool }
 func (s *Ethereum) EventMux() *event.TypeMux           { return s.eventMux }
 func (s *Ethereum) Engine() consensus.Engine           { return s.engine }
 func (s *Ethereum) ChainDb() ethdb.Database            { return s.chainDb }
@@ -83,6 +83,8 @@ var Defaults = Config{
 	TrieDirtyCache:          256,
 	TrieTimeout:             60 * time.Minute,
 	SnapshotCache:           102,
+	StateReverseDiffs:       1024,
+	VMTraceRetention:        90000,
 	Miner: miner.Config{
 		GasCeil:  30000000,
 		GasPrice: big.NewInt(params.GWei),
@@ -136,7 +138,8 @@ type Config struct {
 	NoPruning  bool // Whether to disable pruning and flush everything to disk
 	NoPrefetch bool // Whether to disable prefetching and only load state on demand
 
-	TxLookupLimit uint64 `toml:",omitempty"` // The maximum number of blocks from head whose tx indices are reserved.
+	TxLookupLimit    uint64 `toml:",omitempty"` // The maximum number of blocks from head whose tx indices are reserved.
+	HistoryRetention uint64 `toml:",omitempty"` // The number of blocks from head whose bodies and receipts are retained, zero retains all.
 
 	// RequiredBlocks is a set of block number -> hash mappings which must be in the
 	// canonical chain of all remote peers. Setting the option makes geth verify the
@@ -171,6 +174,15 @@ type Config struct {
 	SnapshotCache           int
 	Preimages               bool
 
+	StateScheme       string `toml:",omitempty"` // Node scheme of the state trie, hash or path
+	StateReverseDiffs uint64 `toml:",omitempty"` // Number of reverse diffs kept to revert persisted states (path scheme)
+	StateHistory      uint64 `toml:",omitempty"` // Number of recent blocks whose states are served from the state history (0 = disabled)
+
+	// Live tracing options
+	VMTrace           string `toml:",omitempty"` // Name of the tracer run on the transactions of the imported blocks, empty if disabled
+	VMTraceJsonConfig string `toml:",omitempty"` // Json-encoded config of the live tracer
+	VMTraceRetention  uint64 `toml:",omitempty"` // Number of recent blocks whose transaction traces are retained (0 = all blocks)
+
 	// Mining options
 	Miner miner.Config
 
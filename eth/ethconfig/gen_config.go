@@ -26,6 +26,7 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		NoPruning                             bool
 		NoPrefetch                            bool
 		TxLookupLimit                         uint64                 `toml:",omitempty"`
+		HistoryRetention                      uint64                 `toml:",omitempty"`
 		RequiredBlocks                        map[uint64]common.Hash `toml:"-"`
 		LightServ                             int                    `toml:",omitempty"`
 		LightIngress                          int                    `toml:",omitempty"`
@@ -48,6 +49,12 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		TrieTimeout                           time.Duration
 		SnapshotCache                         int
 		Preimages                             bool
+		StateScheme                           string `toml:",omitempty"`
+		StateReverseDiffs                     uint64 `toml:",omitempty"`
+		StateHistory                          uint64 `toml:",omitempty"`
+		VMTrace                               string `toml:",omitempty"`
+		VMTraceJsonConfig                     string `toml:",omitempty"`
+		VMTraceRetention                      uint64 `toml:",omitempty"`
 		Miner                                 miner.Config
 		Ethash                                ethash.Config
 		TxPool                                core.TxPoolConfig
@@ -71,6 +78,7 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.NoPruning = c.NoPruning
 	enc.NoPrefetch = c.NoPrefetch
 	enc.TxLookupLimit = c.TxLookupLimit
+	enc.HistoryRetention = c.HistoryRetention
 	enc.RequiredBlocks = c.RequiredBlocks
 	enc.LightServ = c.LightServ
 	enc.LightIngress = c.LightIngress
@@ -93,6 +101,12 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.TrieTimeout = c.TrieTimeout
 	enc.SnapshotCache = c.SnapshotCache
 	enc.Preimages = c.Preimages
+	enc.StateScheme = c.StateScheme
+	enc.StateReverseDiffs = c.StateReverseDiffs
+	enc.StateHistory = c.StateHistory
+	enc.VMTrace = c.VMTrace
+	enc.VMTraceJsonConfig = c.VMTraceJsonConfig
+	enc.VMTraceRetention = c.VMTraceRetention
 	enc.Miner = c.Miner
 	enc.Ethash = c.Ethash
 	enc.TxPool = c.TxPool
@@ -120,6 +134,7 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		NoPruning                             *bool
 		NoPrefetch                            *bool
 		TxLookupLimit                         *uint64                `toml:",omitempty"`
+		HistoryRetention                      *uint64                `toml:",omitempty"`
 		RequiredBlocks                        map[uint64]common.Hash `toml:"-"`
 		LightServ                             *int                   `toml:",omitempty"`
 		LightIngress                          *int                   `toml:",omitempty"`
@@ -142,6 +157,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		TrieTimeout                           *time.Duration
 		SnapshotCache                         *int
 		Preimages                             *bool
+		StateScheme                           *string `toml:",omitempty"`
+		StateReverseDiffs                     *uint64 `toml:",omitempty"`
+		StateHistory                          *uint64 `toml:",omitempty"`
+		VMTrace                               *string `toml:",omitempty"`
+		VMTraceJsonConfig                     *string `toml:",omitempty"`
+		VMTraceRetention                      *uint64 `toml:",omitempty"`
 		Miner                                 *miner.Config
 		Ethash                                *ethash.Config
 		TxPool                                *core.TxPoolConfig
@@ -184,6 +205,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.TxLookupLimit != nil {
 		c.TxLookupLimit = *dec.TxLookupLimit
 	}
+	if dec.HistoryRetention != nil {
+		c.HistoryRetention = *dec.HistoryRetention
+	}
 	if dec.RequiredBlocks != nil {
 		c.RequiredBlocks = dec.RequiredBlocks
 	}
@@ -250,6 +274,24 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.Preimages != nil {
 		c.Preimages = *dec.Preimages
 	}
+	if dec.StateScheme != nil {
+		c.StateScheme = *dec.StateScheme
+	}
+	if dec.StateReverseDiffs != nil {
+		c.StateReverseDiffs = *dec.StateReverseDiffs
+	}
+	if dec.StateHistory != nil {
+		c.StateHistory = *dec.StateHistory
+	}
+	if dec.VMTrace != nil {
+		c.VMTrace = *dec.VMTrace
+	}
+	if dec.VMTraceJsonConfig != nil {
+		c.VMTraceJsonConfig = *dec.VMTraceJsonConfig
+	}
+	if dec.VMTraceRetention != nil {
+		c.VMTraceRetention = *dec.VMTraceRetention
+	}
 	if dec.Miner != nil {
 		c.Miner = *dec.Miner
 	}
@@ -19,11 +19,13 @@ package filters
 import (
 	"context"
 	"errors"
+	"fmt"
 	"math/big"
 
 	"github.com/foreverbit/biternal/common"
 	"github.com/foreverbit/biternal/core"
 	"github.com/foreverbit/biternal/core/bloombits"
+	"github.com/foreverbit/biternal/core/rawdb"
 	"github.com/foreverbit/biternal/core/types"
 	"github.com/foreverbit/biternal/ethdb"
 	"github.com/foreverbit/biternal/event"
@@ -152,6 +154,11 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	if f.end == rpc.LatestBlockNumber.Int64() || f.end == rpc.PendingBlockNumber.Int64() {
 		end = head
 	}
+	// The logs of the pruned history can't be searched, fail instead of silently
+	// returning partial results
+	if tail := rawdb.ReadHistoryTail(f.db); f.begin >= 0 && uint64(f.begin) < tail {
+		return nil, fmt.Errorf("%w: logs before block #%d are unavailable", core.ErrHistoryPruned, tail)
+	}
 	// Gather all indexed logs, and finish with non indexed ones
 	var (
 		logs           []*types.Log
@@ -18,6 +18,7 @@ package filters
 
 import (
 	"context"
+	"errors"
 	"math/big"
 	"testing"
 
@@ -229,4 +230,18 @@ func TestFilters(t *testing.T) {
 	if len(logs) != 0 {
 		t.Error("expected 0 log, got", len(logs))
 	}
+
+	// Searching the pruned history must fail instead of returning partial results
+	rawdb.WriteHistoryTail(db, 500)
+
+	filter = NewRangeFilter(backend, 0, -1, []common.Address{addr}, [][]common.Hash{{hash1, hash2, hash3, hash4}})
+	if _, err := filter.Logs(context.Background()); !errors.Is(err, core.ErrHistoryPruned) {
+		t.Errorf("expected history pruned error, got %v", err)
+	}
+	filter = NewRangeFilter(backend, 900, 999, []common.Address{addr}, [][]common.Hash{{hash3}})
+
+	logs, _ = filter.Logs(context.Background())
+	if len(logs) != 1 {
+		t.Error("expected 1 log, got", len(logs))
+	}
 }
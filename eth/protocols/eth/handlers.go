@@ -22,6 +22,7 @@ import (
 
 	"github.com/foreverbit/biternal/common"
 	"github.com/foreverbit/biternal/core"
+	"github.com/foreverbit/biternal/core/rawdb"
 	"github.com/foreverbit/biternal/core/types"
 	"github.com/foreverbit/biternal/log"
 	"github.com/foreverbit/biternal/rlp"
@@ -219,6 +220,7 @@ func ServiceGetBlockBodiesQuery(chain *core.BlockChain, query GetBlockBodiesPack
 	var (
 		bytes  int
 		bodies []rlp.RawValue
+		tail   = chain.HistoryTail()
 	)
 	for lookups, hash := range query {
 		if bytes >= softResponseLimit || len(bodies) >= maxBodiesServe ||
@@ -228,11 +230,23 @@ func ServiceGetBlockBodiesQuery(chain *core.BlockChain, query GetBlockBodiesPack
 		if data := chain.GetBodyRLP(hash); len(data) != 0 {
 			bodies = append(bodies, data)
 			bytes += len(data)
+		} else if historyPruned(chain, hash, tail) {
+			break // Keep the response a prefix of the query, nothing to skip to
 		}
 	}
 	return bodies
 }
 
+// historyPruned reports whether the body and receipts of the given block were
+// discarded by the local node, the tail being the oldest retained block.
+func historyPruned(chain *core.BlockChain, hash common.Hash, tail uint64) bool {
+	if tail == 0 {
+		return false
+	}
+	header := chain.GetHeaderByHash(hash)
+	return header != nil && header.Number.Uint64() < tail
+}
+
 func handleGetNodeData66(backend Backend, msg Decoder, peer *Peer) error {
 	// Decode the trie node data retrieval message
 	var query GetNodeDataPacket66
@@ -251,13 +265,23 @@ func ServiceGetNodeDataQuery(chain *core.BlockChain, query GetNodeDataPacket) []
 		bytes int
 		nodes [][]byte
 	)
+	// Trie nodes can't be looked up by hash in the path scheme, only serve
+	// the contract codes then.
+	pathScheme := chain.StateCache().TrieDB().Scheme() == rawdb.PathScheme
+
 	for lookups, hash := range query {
 		if bytes >= softResponseLimit || len(nodes) >= maxNodeDataServe ||
 			lookups >= 2*maxNodeDataServe {
 			break
 		}
 		// Retrieve the requested state entry
-		entry, err := chain.TrieNode(hash)
+		var (
+			entry []byte
+			err   error
+		)
+		if !pathScheme {
+			entry, err = chain.TrieNode(hash)
+		}
 		if len(entry) == 0 || err != nil {
 			// Read the contract code with prefix only to save unnecessary lookups.
 			entry, err = chain.ContractCodeWithPrefix(hash)
@@ -287,6 +311,7 @@ func ServiceGetReceiptsQuery(chain *core.BlockChain, query GetReceiptsPacket) []
 	var (
 		bytes    int
 		receipts []rlp.RawValue
+		tail     = chain.HistoryTail()
 	)
 	for lookups, hash := range query {
 		if bytes >= softResponseLimit || len(receipts) >= maxReceiptsServe ||
@@ -297,6 +322,9 @@ func ServiceGetReceiptsQuery(chain *core.BlockChain, query GetReceiptsPacket) []
 		results := chain.GetReceiptsByHash(hash)
 		if results == nil {
 			if header := chain.GetHeaderByHash(hash); header == nil || header.ReceiptHash != types.EmptyRootHash {
+				if historyPruned(chain, hash, tail) {
+					break // Keep the response a prefix of the query, nothing to skip to
+				}
 				continue
 			}
 		}
@@ -23,6 +23,7 @@ import (
 
 	"github.com/foreverbit/biternal/common"
 	"github.com/foreverbit/biternal/core"
+	"github.com/foreverbit/biternal/core/rawdb"
 	"github.com/foreverbit/biternal/core/state"
 	"github.com/foreverbit/biternal/core/types"
 	"github.com/foreverbit/biternal/core/vm"
@@ -52,6 +53,11 @@ func (eth *Ethereum) StateAtBlock(block *types.Block, reexec uint64, base *state
 		report   = true
 		origin   = block.NumberU64()
 	)
+	// The path scheme keeps a single state on disk, regenerated states must
+	// not be committed into it. Historic states can't be committed at all.
+	if eth.blockchain.StateCache().TrieDB().Scheme() == rawdb.PathScheme || (base != nil && base.Historic()) {
+		return eth.pathStateAtBlock(block, reexec, base)
+	}
 	// Check the live database first if we have the state fully available, use that.
 	if checkLive {
 		statedb, err = eth.blockchain.StateAt(block.Root())
@@ -88,6 +94,10 @@ func (eth *Ethereum) StateAtBlock(block *types.Block, reexec uint64, base *state
 			if err == nil {
 				return statedb, nil
 			}
+			// The state history is isolated from the live database as well
+			if historic, err := eth.blockchain.HistoricStateAt(current.Root()); err == nil {
+				return historic, nil
+			}
 		}
 		// Database does not have the state for the given block, try to regenerate
 		for i := uint64(0); i < reexec; i++ {
@@ -158,6 +168,55 @@ func (eth *Ethereum) StateAtBlock(block *types.Block, reexec uint64, base *state
 	return statedb, nil
 }
 
+// pathStateAtBlock is the StateAtBlock variant for the path scheme and for
+// historic base states. States are only read from the live database, and the
+// blocks are regenerated in memory on top of the closest available ancestor
+// state without committing them.
+func (eth *Ethereum) pathStateAtBlock(block *types.Block, reexec uint64, base *state.StateDB) (*state.StateDB, error) {
+	statedb, err := eth.blockchain.StateAt(block.Root())
+	if err == nil {
+		return statedb, nil
+	}
+	var current *types.Block
+	if base != nil {
+		// The optional base statedb is given, mark the start point as parent block
+		statedb, current = base, eth.blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+		if current == nil {
+			return nil, fmt.Errorf("missing block %v %d", block.ParentHash(), block.NumberU64()-1)
+		}
+	} else {
+		// Otherwise try to find an ancestor with the state available
+		current = block
+		for i := uint64(0); i < reexec; i++ {
+			if current.NumberU64() == 0 {
+				return nil, errors.New("genesis state is missing")
+			}
+			parent := eth.blockchain.GetBlock(current.ParentHash(), current.NumberU64()-1)
+			if parent == nil {
+				return nil, fmt.Errorf("missing block %v %d", current.ParentHash(), current.NumberU64()-1)
+			}
+			current = parent
+
+			if statedb, err = eth.blockchain.StateAt(current.Root()); err == nil {
+				break
+			}
+		}
+		if statedb == nil {
+			return nil, fmt.Errorf("required historical state unavailable (reexec=%d)", reexec)
+		}
+	}
+	for current.NumberU64() < block.NumberU64() {
+		next := current.NumberU64() + 1
+		if current = eth.blockchain.GetBlockByNumber(next); current == nil {
+			return nil, fmt.Errorf("block #%d not found", next)
+		}
+		if _, _, _, err := eth.blockchain.Processor().Process(current, statedb, vm.Config{}); err != nil {
+			return nil, fmt.Errorf("processing block %d failed: %v", current.NumberU64(), err)
+		}
+	}
+	return statedb, nil
+}
+
 // stateAtTransaction returns the execution environment of a certain transaction.
 func (eth *Ethereum) stateAtTransaction(block *types.Block, txIndex int, reexec uint64) (core.Message, vm.BlockContext, *state.StateDB, error) {
 	// Short circuit if it's genesis block.
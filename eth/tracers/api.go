@@ -61,6 +61,16 @@ const (
 	// For non-archive nodes, this limit _will_ be overblown, as disk-backed tries
 	// will only be found every ~15K blocks or so.
 	defaultTracechainMemLimit = common.StorageSize(500 * 1024 * 1024)
+
+	// traceChainResultBudget is the maximum number of blocks traceChain has in
+	// flight, traced or being traced but not yet streamed to the subscriber. It
+	// bounds the results held in memory if the subscriber can't keep up.
+	traceChainResultBudget = 16
+
+	// traceBlockResultBudget is the maximum number of transactions a streamed
+	// block trace has in flight, traced or being traced but not yet streamed to
+	// the subscriber.
+	traceBlockResultBudget = 64
 )
 
 // Backend interface provides the common API services (that are provided by
@@ -220,6 +230,30 @@ type txTraceTask struct {
 	index   int            // Transaction offset in the block
 }
 
+// txStreamTask represents a single transaction trace task when an entire block
+// is being traced and streamed.
+type txStreamTask struct {
+	statedb *state.StateDB      // Intermediate state prepped for tracing
+	index   int                 // Transaction offset in the block
+	result  chan *txTraceResult // Channel delivering the trace result
+}
+
+// txStreamResult represents the result of a single transaction trace when an
+// entire block is being traced and streamed.
+type txStreamResult struct {
+	TxIndex hexutil.Uint `json:"txIndex"`          // Transaction offset in the block
+	TxHash  common.Hash  `json:"txHash"`           // Hash of the traced transaction
+	Result  interface{}  `json:"result,omitempty"` // Trace results produced by the tracer
+	Error   string       `json:"error,omitempty"`  // Trace failure produced by the tracer
+}
+
+// txStreamEnd is the last notification of a streamed block trace, sent after
+// the result of the last transaction or of the first one failing to execute.
+type txStreamEnd struct {
+	Done   bool         `json:"done"`   // Always true, marks the end of the stream
+	Traced hexutil.Uint `json:"traced"` // Number of transaction results streamed
+}
+
 // TraceChain returns the structured logs created during the execution of EVM
 // between two blocks (excluding start) and returns them as a JSON object.
 func (api *API) TraceChain(ctx context.Context, start, end rpc.BlockNumber, config *TraceConfig) (*rpc.Subscription, error) { // Fetch the block interval that we want to trace
@@ -263,6 +297,7 @@ func (api *API) traceChain(ctx context.Context, start, end *types.Block, config
 		pend     = new(sync.WaitGroup)
 		tasks    = make(chan *blockTraceTask, threads)
 		results  = make(chan *blockTraceTask, threads)
+		budget   = make(chan struct{}, traceChainResultBudget)
 		localctx = context.Background()
 	)
 	for th := 0; th < threads; th++ {
@@ -389,7 +424,13 @@ func (api *API) traceChain(ctx context.Context, start, end *types.Block, config
 				failed = err
 				break
 			}
-			// Send the block over to the concurrent tracers (if not in the fast-forward phase)
+			// Wait until the block fits into the result budget, then send it over
+			// to the concurrent tracers
+			select {
+			case budget <- struct{}{}:
+			case <-notifier.Closed():
+				return
+			}
 			txs := next.Transactions()
 			select {
 			case tasks <- &blockTraceTask{statedb: statedb.Copy(), block: next, rootref: block.Root(), results: make([]*txTraceResult, len(txs))}:
@@ -418,13 +459,14 @@ func (api *API) traceChain(ctx context.Context, start, end *types.Block, config
 			derefsMu.Lock()
 			derefTodo = append(derefTodo, res.rootref)
 			derefsMu.Unlock()
-			// Stream completed traces to the user, aborting on the first error
+			// Stream completed traces to the user, releasing their budget
 			for result, ok := done[next]; ok; result, ok = done[next] {
 				if len(result.Traces) > 0 || next == end.NumberU64() {
 					notifier.Notify(sub.ID, result)
 				}
 				delete(done, next)
 				next++
+				<-budget
 			}
 		}
 	}()
@@ -568,6 +610,14 @@ func (api *API) traceBlock(ctx context.Context, block *types.Block, config *Trac
 	if block.NumberU64() == 0 {
 		return nil, errors.New("genesis is not traceable")
 	}
+	// Serve the results stored during the block import if available
+	if stored, ok := api.storedTraces(block.Hash(), block.NumberU64(), config); ok && len(stored) == len(block.Transactions()) {
+		results := make([]*txTraceResult, len(stored))
+		for i, res := range stored {
+			results[i] = &txTraceResult{Result: json.RawMessage(res)}
+		}
+		return results, nil
+	}
 	parent, err := api.blockByNumberAndHash(ctx, rpc.BlockNumber(block.NumberU64()-1), block.ParentHash())
 	if err != nil {
 		return nil, err
@@ -645,6 +695,175 @@ func (api *API) traceBlock(ctx context.Context, block *types.Block, config *Trac
 	return results, nil
 }
 
+// TraceBlockStream is the subscription variant of TraceBlockByNumber and
+// TraceBlockByHash. Instead of returning the traces of all transactions at once,
+// it streams the trace of each transaction in block order as soon as it's done.
+func (api *API) TraceBlockStream(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, config *TraceConfig) (*rpc.Subscription, error) {
+	var (
+		err   error
+		block *types.Block
+	)
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		block, err = api.blockByHash(ctx, hash)
+	} else if number, ok := blockNrOrHash.Number(); ok {
+		block, err = api.blockByNumber(ctx, number)
+	} else {
+		return nil, errors.New("invalid arguments; neither block nor hash specified")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return api.traceBlockStream(ctx, block, config)
+}
+
+// traceBlockStream configures a new tracer according to the provided
+// configuration, and executes all the transactions contained within, streaming
+// one result per transaction to the subscriber. The stream ends with the last
+// transaction, or the first one failing to execute whose result carries the
+// error, followed by a txStreamEnd notification.
+func (api *API) traceBlockStream(ctx context.Context, block *types.Block, config *TraceConfig) (*rpc.Subscription, error) {
+	// Streaming a block trace is only possible with subscriptions
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	if block.NumberU64() == 0 {
+		return nil, errors.New("genesis is not traceable")
+	}
+	// Stream the results stored during the block import if available, otherwise
+	// prepare the state for tracing before accepting the subscription
+	txs := block.Transactions()
+	if stored, ok := api.storedTraces(block.Hash(), block.NumberU64(), config); ok && len(stored) == len(txs) {
+		sub := notifier.CreateSubscription()
+		go func() {
+			for i, res := range stored {
+				result := &txStreamResult{TxIndex: hexutil.Uint(i), TxHash: txs[i].Hash(), Result: json.RawMessage(res)}
+				if err := notifier.Notify(sub.ID, result); err != nil {
+					return
+				}
+			}
+			notifier.Notify(sub.ID, &txStreamEnd{Done: true, Traced: hexutil.Uint(len(stored))})
+		}()
+		return sub, nil
+	}
+	parent, err := api.blockByNumberAndHash(ctx, rpc.BlockNumber(block.NumberU64()-1), block.ParentHash())
+	if err != nil {
+		return nil, err
+	}
+	reexec := defaultTraceReexec
+	if config != nil && config.Reexec != nil {
+		reexec = *config.Reexec
+	}
+	statedb, err := api.backend.StateAtBlock(ctx, parent, reexec, nil, true, false)
+	if err != nil {
+		return nil, err
+	}
+	sub := notifier.CreateSubscription()
+
+	threads := runtime.NumCPU()
+	if threads > len(txs) {
+		threads = len(txs)
+	}
+	var (
+		signer   = types.MakeSigner(api.backend.ChainConfig(), block.Number())
+		localctx = context.Background()
+
+		jobs    = make(chan *txStreamTask, traceBlockResultBudget)
+		pending = make(chan *txStreamTask, traceBlockResultBudget) // Budget of the traces not yet streamed
+		quit    = make(chan struct{})
+	)
+	for th := 0; th < threads; th++ {
+		go func() {
+			blockCtx := core.NewEVMBlockContext(block.Header(), api.chainContext(localctx), nil)
+			// Fetch and execute the next transaction trace tasks, skipping
+			// them if the stream was aborted
+			for task := range jobs {
+				select {
+				case <-quit:
+					continue
+				default:
+				}
+				msg, _ := txs[task.index].AsMessage(signer, block.BaseFee())
+				txctx := &Context{
+					BlockHash: block.Hash(),
+					TxIndex:   task.index,
+					TxHash:    txs[task.index].Hash(),
+				}
+				res, err := api.traceTx(localctx, msg, txctx, blockCtx, task.statedb, config)
+				if err != nil {
+					task.result <- &txTraceResult{Error: err.Error()}
+					continue
+				}
+				task.result <- &txTraceResult{Result: res}
+			}
+		}()
+	}
+	// Feed the transactions into the tracers, blocking while the budget of the
+	// traces not yet streamed is exhausted
+	go func() {
+		defer close(pending)
+		defer close(jobs)
+
+		blockCtx := core.NewEVMBlockContext(block.Header(), api.chainContext(localctx), nil)
+		for i, tx := range txs {
+			task := &txStreamTask{statedb: statedb.Copy(), index: i, result: make(chan *txTraceResult, 1)}
+
+			// Generate the next state snapshot fast without tracing. A failing
+			// transaction is streamed with its error as the last result.
+			msg, _ := tx.AsMessage(signer, block.BaseFee())
+			statedb.Prepare(tx.Hash(), i)
+			vmenv := vm.NewEVM(blockCtx, core.NewEVMTxContext(msg), statedb, api.backend.ChainConfig(), vm.Config{})
+			if _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas())); err != nil {
+				log.Warn("Block tracing aborted", "number", block.NumberU64(), "hash", block.Hash(), "tx", i, "err", err)
+				task.result <- &txTraceResult{Error: err.Error()}
+				select {
+				case pending <- task:
+				case <-quit:
+				}
+				return
+			}
+			select {
+			case pending <- task:
+			case <-quit:
+				return
+			}
+			jobs <- task
+
+			// Finalize the state so any modifications are written to the trie
+			// Only delete empty objects if EIP158/161 (a.k.a Spurious Dragon) is in effect
+			statedb.Finalise(vmenv.ChainConfig().IsEIP158(block.Number()))
+		}
+	}()
+	// Stream the trace results to the user in order, aborting on teardown
+	go func() {
+		defer close(quit)
+
+		var traced int
+		for task := range pending {
+			var res *txTraceResult
+			select {
+			case res = <-task.result:
+			case <-sub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+			result := &txStreamResult{
+				TxIndex: hexutil.Uint(task.index),
+				TxHash:  txs[task.index].Hash(),
+				Result:  res.Result,
+				Error:   res.Error,
+			}
+			if err := notifier.Notify(sub.ID, result); err != nil {
+				return
+			}
+			traced++
+		}
+		notifier.Notify(sub.ID, &txStreamEnd{Done: true, Traced: hexutil.Uint(traced)})
+	}()
+	return sub, nil
+}
+
 // standardTraceBlockToFile configures a new tracer which uses standard JSON output,
 // and traces either a full block or an individual transaction. The return value will
 // be one filename per transaction traced.
@@ -785,6 +1004,10 @@ func (api *API) TraceTransaction(ctx context.Context, hash common.Hash, config *
 	if blockNumber == 0 {
 		return nil, errors.New("genesis is not traceable")
 	}
+	// Serve the result stored during the block import if available
+	if results, ok := api.storedTraces(blockHash, blockNumber, config); ok && int(index) < len(results) {
+		return json.RawMessage(results[index]), nil
+	}
 	reexec := defaultTraceReexec
 	if config != nil && config.Reexec != nil {
 		reexec = *config.Reexec
@@ -858,15 +1081,45 @@ func (api *API) TraceCall(ctx context.Context, args ethapi.TransactionArgs, bloc
 	var traceConfig *TraceConfig
 	if config != nil {
 		traceConfig = &TraceConfig{
-			Config:  config.Config,
-			Tracer:  config.Tracer,
-			Timeout: config.Timeout,
-			Reexec:  config.Reexec,
+			Config:       config.Config,
+			Tracer:       config.Tracer,
+			Timeout:      config.Timeout,
+			Reexec:       config.Reexec,
+			TracerConfig: config.TracerConfig,
 		}
 	}
 	return api.traceTx(ctx, msg, new(Context), vmctx, statedb, traceConfig)
 }
 
+// storedTraces returns the results of the transactions of the block traced
+// during its import, if they were produced by the requested tracer and config.
+func (api *API) storedTraces(hash common.Hash, number uint64, config *TraceConfig) ([][]byte, bool) {
+	if config == nil || config.Tracer == nil {
+		return nil, false
+	}
+	traces := rawdb.ReadTxTraces(api.backend.ChainDb(), hash, number)
+	if traces == nil || traces.Tracer != *config.Tracer || !equalTracerConfig(traces.Config, config.TracerConfig) {
+		return nil, false
+	}
+	return traces.Results, true
+}
+
+// equalTracerConfig reports whether two json-encoded tracer configs are equal,
+// ignoring insignificant whitespace. A missing config equals a null one.
+func equalTracerConfig(a, b json.RawMessage) bool {
+	normalize := func(config json.RawMessage) string {
+		buf := new(bytes.Buffer)
+		if err := json.Compact(buf, config); err != nil {
+			return string(config)
+		}
+		if buf.String() == "null" {
+			return ""
+		}
+		return buf.String()
+	}
+	return normalize(a) == normalize(b)
+}
+
 // traceTx configures a new tracer according to the provided configuration, and
 // executes the given message in the provided environment. The return value will
 // be tracer dependent.
@@ -921,5 +1174,9 @@ func APIs(backend Backend) []rpc.API {
 			Namespace: "debug",
 			Service:   NewAPI(backend),
 		},
+		{
+			Namespace: "trace",
+			Service:   NewTraceAPI(backend),
+		},
 	}
 }
@@ -420,6 +420,223 @@ func TestTraceBlock(t *testing.T) {
 	}
 }
 
+// Tests that the results stored during the block import are served instead of
+// re-executing the transactions, if produced by the requested tracer.
+func TestTraceStoredResults(t *testing.T) {
+	t.Parallel()
+
+	accounts := newAccounts(2)
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+	}}
+	var txs []common.Hash
+	signer := types.HomesteadSigner{}
+	backend := newTestBackend(t, 2, genesis, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(uint64(i), accounts[1].addr, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), signer, accounts[0].key)
+		b.AddTx(tx)
+		txs = append(txs, tx.Hash())
+	})
+	api := NewAPI(backend)
+
+	block := backend.chain.GetBlockByNumber(1)
+	rawdb.WriteTxTraces(backend.chaindb, block.Hash(), block.NumberU64(), &rawdb.TxTraces{
+		Tracer:  flatCallTracer,
+		Config:  []byte(`{ "convertParityErrors": true }`),
+		Results: [][]byte{[]byte(`{"stored":true}`)},
+	})
+	stored := `{"stored":true}`
+	executed := func(tx int) string {
+		return fmt.Sprintf(`[{"action":{"callType":"call","from":"%s","to":"%s"},"transactionHash":"%s","transactionPosition":0,"traceAddress":[],"type":"call"}]`,
+			hexAddress(accounts[0].addr), hexAddress(accounts[1].addr), txs[tx].Hex())
+	}
+	var tests = []struct {
+		tx     int
+		config *TraceConfig
+		want   string
+	}{
+		{0, flatTraceConfig, stored},
+		{0, &TraceConfig{Tracer: flatTraceConfig.Tracer}, executed(0)},
+		{1, flatTraceConfig, executed(1)},
+	}
+	for i, tt := range tests {
+		res, err := api.TraceTransaction(context.Background(), txs[tt.tx], tt.config)
+		if err != nil {
+			t.Errorf("test %d: failed to trace transaction: %v", i, err)
+			continue
+		}
+		if have := string(res.(json.RawMessage)); have != tt.want {
+			t.Errorf("test %d: result mismatch: have %s, want %s", i, have, tt.want)
+		}
+	}
+	results, err := api.TraceBlockByNumber(context.Background(), 1, flatTraceConfig)
+	if err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	if have, _ := json.Marshal(results); string(have) != `[{"result":`+stored+`}]` {
+		t.Errorf("block result mismatch: have %s", have)
+	}
+}
+
+// Tests that the streamed block traces are delivered in transaction order and
+// match the ones returned at once, and that the stream ends with the first
+// transaction failing to execute.
+func TestTraceBlockStream(t *testing.T) {
+	t.Parallel()
+
+	accounts := newAccounts(2)
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+	}}
+	var (
+		txs    []common.Hash
+		nonce  uint64
+		signer = types.HomesteadSigner{}
+	)
+	backend := newTestBackend(t, 2, genesis, func(i int, b *core.BlockGen) {
+		for j := 0; j < 10; j++ {
+			tx, _ := types.SignTx(types.NewTransaction(nonce, accounts[1].addr, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), signer, accounts[0].key)
+			b.AddTx(tx)
+			txs = append(txs, tx.Hash())
+			nonce++
+		}
+	})
+	api := NewAPI(backend)
+	srv := rpc.NewServer()
+	defer srv.Stop()
+	if err := srv.RegisterName("debug", api); err != nil {
+		t.Fatalf("failed to register api: %v", err)
+	}
+	client := rpc.DialInProc(srv)
+	defer client.Close()
+
+	type streamResult struct {
+		TxIndex hexutil.Uint    `json:"txIndex"`
+		TxHash  common.Hash     `json:"txHash"`
+		Result  json.RawMessage `json:"result"`
+		Error   string          `json:"error"`
+		Done    bool            `json:"done"`
+		Traced  hexutil.Uint    `json:"traced"`
+	}
+	// stream collects the streamed results of the block until the end of the
+	// stream is signalled.
+	stream := func(block rpc.BlockNumberOrHash) []*streamResult {
+		results := make(chan *streamResult)
+		sub, err := client.Subscribe(context.Background(), "debug", results, "traceBlockStream", block, nil)
+		if err != nil {
+			t.Fatalf("failed to subscribe: %v", err)
+		}
+		defer sub.Unsubscribe()
+
+		var streamed []*streamResult
+		for {
+			select {
+			case res := <-results:
+				if res.Done {
+					if int(res.Traced) != len(streamed) {
+						t.Fatalf("streamed count mismatch: have %d, want %d", res.Traced, len(streamed))
+					}
+					return streamed
+				}
+				streamed = append(streamed, res)
+			case err := <-sub.Err():
+				t.Fatalf("subscription failed: %v", err)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("result %d: timeout", len(streamed))
+			}
+		}
+	}
+	want, err := api.TraceBlockByNumber(context.Background(), 2, nil)
+	if err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	results := stream(rpc.BlockNumberOrHashWithNumber(2))
+	if len(results) != len(want) {
+		t.Fatalf("result count mismatch: have %d, want %d", len(results), len(want))
+	}
+	for i, res := range results {
+		if int(res.TxIndex) != i || res.TxHash != txs[10+i] || res.Error != "" {
+			t.Fatalf("result %d: mismatch: have %d %x %q, want %d %x", i, res.TxIndex, res.TxHash, res.Error, i, txs[10+i])
+		}
+		blob, _ := json.Marshal(want[i].Result)
+		if string(res.Result) != string(blob) {
+			t.Errorf("result %d: trace mismatch: have %s, want %s", i, res.Result, blob)
+		}
+	}
+	// Replay a transaction of the previous block in the middle of the block,
+	// which fails to execute and ends the stream
+	var (
+		block  = backend.chain.GetBlockByNumber(2)
+		header = types.CopyHeader(block.Header())
+		body   = append(append(append(types.Transactions{}, block.Transactions()[:5]...), backend.chain.GetBlockByNumber(1).Transactions()[0]), block.Transactions()[5:]...)
+	)
+	header.Extra = []byte("invalid")
+	invalid := types.NewBlockWithHeader(header).WithBody(body, nil)
+	rawdb.WriteBlock(backend.chaindb, invalid)
+
+	results = stream(rpc.BlockNumberOrHashWithHash(invalid.Hash(), false))
+	if len(results) != 6 {
+		t.Fatalf("result count mismatch: have %d, want 6", len(results))
+	}
+	for i, res := range results[:5] {
+		if int(res.TxIndex) != i || res.Error != "" {
+			t.Errorf("result %d: mismatch: have %d %q", i, res.TxIndex, res.Error)
+		}
+	}
+	if last := results[5]; int(last.TxIndex) != 5 || last.TxHash != txs[0] || last.Error == "" {
+		t.Errorf("failing result mismatch: have %d %x %q, want 5 %x with error", last.TxIndex, last.TxHash, last.Error, txs[0])
+	}
+}
+
+// Tests that the traced chain is streamed in block order, even if it's longer
+// than the result budget.
+func TestTraceChain(t *testing.T) {
+	t.Parallel()
+
+	accounts := newAccounts(2)
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+	}}
+	var (
+		blocks = 2*traceChainResultBudget + 1
+		signer = types.HomesteadSigner{}
+	)
+	api := NewAPI(newTestBackend(t, blocks, genesis, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(uint64(i), accounts[1].addr, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), signer, accounts[0].key)
+		b.AddTx(tx)
+	}))
+	srv := rpc.NewServer()
+	defer srv.Stop()
+	if err := srv.RegisterName("debug", api); err != nil {
+		t.Fatalf("failed to register api: %v", err)
+	}
+	client := rpc.DialInProc(srv)
+	defer client.Close()
+
+	type chainResult struct {
+		Block  hexutil.Uint64   `json:"block"`
+		Traces []*txTraceResult `json:"traces"`
+	}
+	results := make(chan *chainResult)
+	sub, err := client.Subscribe(context.Background(), "debug", results, "traceChain", hexutil.Uint64(0), hexutil.Uint64(blocks), nil)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	for number := uint64(1); number <= uint64(blocks); number++ {
+		select {
+		case res := <-results:
+			if uint64(res.Block) != number || len(res.Traces) != 1 || res.Traces[0].Error != "" {
+				t.Fatalf("block %d: result mismatch: have block %d, %d traces", number, res.Block, len(res.Traces))
+			}
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("block %d: timeout", number)
+		}
+	}
+}
+
 func TestTracingWithOverrides(t *testing.T) {
 	t.Parallel()
 	// Initialize test accounts
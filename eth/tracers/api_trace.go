@@ -0,0 +1,340 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/common/hexutil"
+	"github.com/foreverbit/biternal/consensus/beacon"
+	"github.com/foreverbit/biternal/consensus/ethash"
+	"github.com/foreverbit/biternal/core/types"
+	"github.com/foreverbit/biternal/rpc"
+)
+
+const (
+	// flatCallTracer is the name of the native tracer producing the parity-style
+	// flat call traces served by the trace namespace.
+	flatCallTracer = "flatCallTracer"
+
+	// maxTraceFilterBlocks is the maximum number of blocks a single trace_filter
+	// request is allowed to scan.
+	maxTraceFilterBlocks = 1000
+)
+
+// flatTraceConfig is the config tracing transactions into parity-style traces.
+var flatTraceConfig = &TraceConfig{
+	Tracer:       func(s string) *string { return &s }(flatCallTracer),
+	TracerConfig: json.RawMessage(`{"convertParityErrors":true}`),
+}
+
+// TraceAPI is the collection of parity-style tracing APIs exposed over the
+// trace namespace.
+type TraceAPI struct {
+	api *API
+}
+
+// NewTraceAPI creates a new API definition for the parity-style tracing methods
+// of the Ethereum service.
+func NewTraceAPI(backend Backend) *TraceAPI {
+	return &TraceAPI{api: NewAPI(backend)}
+}
+
+// flatTrace is a parity-style flat trace, decoded only as far as needed to
+// filter it and re-encoded as it was produced.
+type flatTrace struct {
+	Action struct {
+		From          *common.Address `json:"from"`
+		To            *common.Address `json:"to"`
+		Address       *common.Address `json:"address"`
+		RefundAddress *common.Address `json:"refundAddress"`
+		Author        *common.Address `json:"author"`
+	} `json:"action"`
+	Result *struct {
+		Address *common.Address `json:"address"`
+	} `json:"result"`
+
+	raw json.RawMessage
+}
+
+// UnmarshalJSON decodes a trace, retaining its encoding.
+func (t *flatTrace) UnmarshalJSON(input []byte) error {
+	type trace flatTrace
+	if err := json.Unmarshal(input, (*trace)(t)); err != nil {
+		return err
+	}
+	t.raw = append(json.RawMessage{}, input...)
+	return nil
+}
+
+// MarshalJSON returns the encoding the trace was decoded from.
+func (t *flatTrace) MarshalJSON() ([]byte, error) {
+	return t.raw, nil
+}
+
+// from returns the address originating the traced action, which is the caller
+// of calls and creations, and the self-destructed contract of suicides.
+func (t *flatTrace) from() *common.Address {
+	if t.Action.From != nil {
+		return t.Action.From
+	}
+	return t.Action.Address
+}
+
+// to returns the address targeted by the traced action, which is the callee of
+// calls, the created contract of creations, the beneficiary of suicides and the
+// author of rewards.
+func (t *flatTrace) to() *common.Address {
+	switch {
+	case t.Action.To != nil:
+		return t.Action.To
+	case t.Result != nil && t.Result.Address != nil:
+		return t.Result.Address
+	case t.Action.RefundAddress != nil:
+		return t.Action.RefundAddress
+	}
+	return t.Action.Author
+}
+
+// rewardTrace is a parity-style trace of a block or uncle reward.
+type rewardTrace struct {
+	Action struct {
+		Author     common.Address `json:"author"`
+		RewardType string         `json:"rewardType"`
+		Value      *hexutil.Big   `json:"value"`
+	} `json:"action"`
+	BlockHash           common.Hash  `json:"blockHash"`
+	BlockNumber         uint64       `json:"blockNumber"`
+	Result              *struct{}    `json:"result"`
+	Subtraces           int          `json:"subtraces"`
+	TraceAddress        []int        `json:"traceAddress"`
+	TransactionHash     *common.Hash `json:"transactionHash"`
+	TransactionPosition *uint64      `json:"transactionPosition"`
+	Type                string       `json:"type"`
+}
+
+// TraceFilterArgs are the criteria of the traces returned by trace_filter. A
+// trace matches if it originates from any of the from addresses and targets any
+// of the to addresses, an empty list matching any address.
+type TraceFilterArgs struct {
+	FromBlock   *rpc.BlockNumber `json:"fromBlock"`
+	ToBlock     *rpc.BlockNumber `json:"toBlock"`
+	FromAddress []common.Address `json:"fromAddress"`
+	ToAddress   []common.Address `json:"toAddress"`
+	After       *uint64          `json:"after"` // Number of matching traces to skip
+	Count       *uint64          `json:"count"` // Maximum number of matching traces to return
+}
+
+// matches reports whether the trace matches the address criteria.
+func (args *TraceFilterArgs) matches(trace *flatTrace) bool {
+	return matchAddress(args.FromAddress, trace.from()) && matchAddress(args.ToAddress, trace.to())
+}
+
+// matchAddress reports whether the address is in the list, or the list empty.
+func matchAddress(addrs []common.Address, addr *common.Address) bool {
+	if len(addrs) == 0 {
+		return true
+	}
+	if addr == nil {
+		return false
+	}
+	for _, a := range addrs {
+		if a == *addr {
+			return true
+		}
+	}
+	return false
+}
+
+// Block returns the parity-style traces of all transactions in the block,
+// followed by the traces of the mining rewards.
+func (api *TraceAPI) Block(ctx context.Context, number rpc.BlockNumber) ([]*flatTrace, error) {
+	block, err := api.api.blockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return api.blockTraces(ctx, block)
+}
+
+// Transaction returns the parity-style traces of the transaction.
+func (api *TraceAPI) Transaction(ctx context.Context, hash common.Hash) ([]*flatTrace, error) {
+	res, err := api.api.TraceTransaction(ctx, hash, flatTraceConfig)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFlatTraces(res)
+}
+
+// Filter returns the parity-style traces of the blocks in the given range which
+// match the address criteria, paginated by the after and count arguments.
+func (api *TraceAPI) Filter(ctx context.Context, args TraceFilterArgs) ([]*flatTrace, error) {
+	from, to := rpc.LatestBlockNumber, rpc.LatestBlockNumber
+	if args.FromBlock != nil {
+		from = *args.FromBlock
+	}
+	if args.ToBlock != nil {
+		to = *args.ToBlock
+	}
+	start, err := api.api.blockByNumber(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	end, err := api.api.blockByNumber(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+	if start.NumberU64() > end.NumberU64() {
+		return nil, fmt.Errorf("invalid block range %d-%d", start.NumberU64(), end.NumberU64())
+	}
+	if blocks := end.NumberU64() - start.NumberU64() + 1; blocks > maxTraceFilterBlocks {
+		return nil, fmt.Errorf("block range too large: %d blocks, limit %d", blocks, maxTraceFilterBlocks)
+	}
+	var (
+		traces = []*flatTrace{}
+		skip   uint64
+	)
+	if args.After != nil {
+		skip = *args.After
+	}
+	if args.Count != nil && *args.Count == 0 {
+		return traces, nil
+	}
+	for number := start.NumberU64(); number <= end.NumberU64(); number++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		block := end
+		if number != end.NumberU64() {
+			if block, err = api.api.blockByNumber(ctx, rpc.BlockNumber(number)); err != nil {
+				return nil, err
+			}
+		}
+		blockTraces, err := api.blockTraces(ctx, block)
+		if err != nil {
+			return nil, err
+		}
+		for _, trace := range blockTraces {
+			if !args.matches(trace) {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			traces = append(traces, trace)
+			if args.Count != nil && uint64(len(traces)) >= *args.Count {
+				return traces, nil
+			}
+		}
+	}
+	return traces, nil
+}
+
+// blockTraces traces all transactions in the block and appends the traces of
+// the mining rewards.
+func (api *TraceAPI) blockTraces(ctx context.Context, block *types.Block) ([]*flatTrace, error) {
+	// The genesis block has neither transactions nor rewards
+	if block.NumberU64() == 0 {
+		return []*flatTrace{}, nil
+	}
+	results, err := api.api.traceBlock(ctx, block, flatTraceConfig)
+	if err != nil {
+		return nil, err
+	}
+	traces := []*flatTrace{}
+	for i, result := range results {
+		if result.Error != "" {
+			return nil, fmt.Errorf("tracing transaction %d failed: %s", i, result.Error)
+		}
+		txTraces, err := decodeFlatTraces(result.Result)
+		if err != nil {
+			return nil, err
+		}
+		traces = append(traces, txTraces...)
+	}
+	rewards, err := api.rewardTraces(block)
+	if err != nil {
+		return nil, err
+	}
+	return append(traces, rewards...), nil
+}
+
+// rewardTraces returns the traces of the rewards of the block's miner and the
+// miners of its uncles, if the block was mined by proof-of-work.
+func (api *TraceAPI) rewardTraces(block *types.Block) ([]*flatTrace, error) {
+	engine := api.api.backend.Engine()
+	if b, ok := engine.(*beacon.Beacon); ok {
+		if b.IsPoSHeader(block.Header()) {
+			return nil, nil
+		}
+		engine = b.InnerEngine()
+	}
+	if _, ok := engine.(*ethash.Ethash); !ok {
+		return nil, nil
+	}
+	reward, uncleRewards := ethash.BlockRewards(api.api.backend.ChainConfig(), block.Header(), block.Uncles())
+
+	traces := make([]*flatTrace, 0, 1+len(uncleRewards))
+	add := func(author common.Address, typ string, value *hexutil.Big) error {
+		trace := &rewardTrace{
+			BlockHash:    block.Hash(),
+			BlockNumber:  block.NumberU64(),
+			TraceAddress: []int{},
+			Type:         "reward",
+		}
+		trace.Action.Author = author
+		trace.Action.RewardType = typ
+		trace.Action.Value = value
+
+		blob, err := json.Marshal(trace)
+		if err != nil {
+			return err
+		}
+		flat := new(flatTrace)
+		if err := json.Unmarshal(blob, flat); err != nil {
+			return err
+		}
+		traces = append(traces, flat)
+		return nil
+	}
+	if err := add(block.Coinbase(), "block", (*hexutil.Big)(reward)); err != nil {
+		return nil, err
+	}
+	for i, uncle := range block.Uncles() {
+		if err := add(uncle.Coinbase, "uncle", (*hexutil.Big)(uncleRewards[i])); err != nil {
+			return nil, err
+		}
+	}
+	return traces, nil
+}
+
+// decodeFlatTraces decodes the result of the flat call tracer.
+func decodeFlatTraces(result interface{}) ([]*flatTrace, error) {
+	blob, ok := result.(json.RawMessage)
+	if !ok {
+		return nil, errors.New("unexpected trace result")
+	}
+	var traces []*flatTrace
+	if err := json.Unmarshal(blob, &traces); err != nil {
+		return nil, err
+	}
+	return traces, nil
+}
@@ -0,0 +1,229 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/core"
+	"github.com/foreverbit/biternal/core/types"
+	"github.com/foreverbit/biternal/core/vm"
+	"github.com/foreverbit/biternal/params"
+	"github.com/foreverbit/biternal/rpc"
+)
+
+// The native tracers can't be imported here, register a flat call tracer only
+// reporting the top-level call instead.
+func init() {
+	RegisterLookup(false, func(name string, ctx *Context, cfg json.RawMessage) (Tracer, error) {
+		if name != flatCallTracer {
+			return nil, errors.New("no tracer found")
+		}
+		return &testFlatTracer{ctx: ctx}, nil
+	})
+}
+
+type testFlatTracer struct {
+	ctx      *Context
+	from, to common.Address
+}
+
+func (t *testFlatTracer) CaptureTxStart(gasLimit uint64) {}
+func (t *testFlatTracer) CaptureTxEnd(restGas uint64)    {}
+func (t *testFlatTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.from, t.to = from, to
+}
+func (t *testFlatTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) {}
+func (t *testFlatTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+}
+func (t *testFlatTracer) CaptureExit(output []byte, gasUsed uint64, err error) {}
+func (t *testFlatTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+}
+func (t *testFlatTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
+func (t *testFlatTracer) Stop(err error) {}
+
+func (t *testFlatTracer) GetResult() (json.RawMessage, error) {
+	return json.RawMessage(fmt.Sprintf(`[{"action":{"callType":"call","from":"%s","to":"%s"},"transactionHash":"%s","transactionPosition":%d,"traceAddress":[],"type":"call"}]`,
+		hexAddress(t.from), hexAddress(t.to), t.ctx.TxHash.Hex(), t.ctx.TxIndex)), nil
+}
+
+func hexAddress(addr common.Address) string {
+	return fmt.Sprintf("%#x", addr)
+}
+
+// testTrace is the part of a flat trace checked by the tests.
+type testTrace struct {
+	Action struct {
+		From       common.Address `json:"from"`
+		To         common.Address `json:"to"`
+		Author     common.Address `json:"author"`
+		RewardType string         `json:"rewardType"`
+		Value      string         `json:"value"`
+	} `json:"action"`
+	BlockNumber     uint64       `json:"blockNumber"`
+	TransactionHash *common.Hash `json:"transactionHash"`
+	Type            string       `json:"type"`
+}
+
+func decodeTestTraces(t *testing.T, traces []*flatTrace) []testTrace {
+	t.Helper()
+
+	blob, err := json.Marshal(traces)
+	if err != nil {
+		t.Fatalf("failed to encode traces: %v", err)
+	}
+	var res []testTrace
+	if err := json.Unmarshal(blob, &res); err != nil {
+		t.Fatalf("failed to decode traces: %v", err)
+	}
+	return res
+}
+
+func TestTraceAPI(t *testing.T) {
+	t.Parallel()
+
+	// Initialize test accounts, alternating the recipient of the transfers
+	accounts := newAccounts(3)
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+	}}
+	var (
+		miner = common.Address{0xc0}
+		uncle = common.Address{0xaa}
+		txs   []common.Hash
+	)
+	signer := types.HomesteadSigner{}
+	api := NewTraceAPI(newTestBackend(t, 4, genesis, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(miner)
+		tx, _ := types.SignTx(types.NewTransaction(uint64(i), accounts[1+i%2].addr, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), signer, accounts[0].key)
+		b.AddTx(tx)
+		txs = append(txs, tx.Hash())
+		if i == 2 {
+			b.AddUncle(&types.Header{ParentHash: b.PrevBlock(0).Hash(), Number: big.NewInt(2), Coinbase: uncle})
+		}
+	}))
+	ctx := context.Background()
+
+	// Trace a block with an uncle, the rewards follow the transaction
+	traces, err := api.Block(ctx, 3)
+	if err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	res := decodeTestTraces(t, traces)
+	if len(res) != 3 {
+		t.Fatalf("trace count mismatch: have %d, want 3", len(res))
+	}
+	if res[0].Type != "call" || res[0].Action.To != accounts[1].addr || *res[0].TransactionHash != txs[2] {
+		t.Errorf("transaction trace mismatch: %+v", res[0])
+	}
+	reward := new(big.Int).Add(big.NewInt(2e18), big.NewInt(2e18/32))
+	if res[1].Type != "reward" || res[1].Action.RewardType != "block" || res[1].Action.Author != miner || res[1].Action.Value != fmt.Sprintf("%#x", reward) || res[1].BlockNumber != 3 || res[1].TransactionHash != nil {
+		t.Errorf("block reward trace mismatch: %+v", res[1])
+	}
+	if res[2].Type != "reward" || res[2].Action.RewardType != "uncle" || res[2].Action.Author != uncle || res[2].Action.Value != fmt.Sprintf("%#x", big.NewInt(2e18/8*7)) {
+		t.Errorf("uncle reward trace mismatch: %+v", res[2])
+	}
+	// Trace the genesis block and a single transaction
+	if traces, err := api.Block(ctx, 0); err != nil || len(traces) != 0 {
+		t.Errorf("genesis traces mismatch: %v, %v", traces, err)
+	}
+	traces, err = api.Transaction(ctx, txs[1])
+	if err != nil {
+		t.Fatalf("failed to trace transaction: %v", err)
+	}
+	if res := decodeTestTraces(t, traces); len(res) != 1 || res[0].Action.To != accounts[2].addr {
+		t.Errorf("transaction traces mismatch: %+v", res)
+	}
+	// Filter the traces of the chain
+	var (
+		from  = rpc.BlockNumber(1)
+		to    = rpc.BlockNumber(4)
+		zero  = uint64(0)
+		one   = uint64(1)
+		tests = []struct {
+			args  TraceFilterArgs
+			types []string
+			txs   []common.Hash
+		}{
+			// Transactions sent by an account, excluding the rewards
+			{
+				args:  TraceFilterArgs{FromBlock: &from, ToBlock: &to, FromAddress: []common.Address{accounts[0].addr}},
+				types: []string{"call", "call", "call", "call"},
+				txs:   txs,
+			},
+			// Transactions received by an account
+			{
+				args:  TraceFilterArgs{FromBlock: &from, ToBlock: &to, ToAddress: []common.Address{accounts[2].addr}},
+				types: []string{"call", "call"},
+				txs:   []common.Hash{txs[1], txs[3]},
+			},
+			// Paginated transactions received by an account
+			{
+				args:  TraceFilterArgs{FromBlock: &from, ToBlock: &to, ToAddress: []common.Address{accounts[2].addr}, After: &one, Count: &one},
+				types: []string{"call"},
+				txs:   []common.Hash{txs[3]},
+			},
+			// Empty page of transactions received by an account
+			{
+				args:  TraceFilterArgs{FromBlock: &from, ToBlock: &to, ToAddress: []common.Address{accounts[2].addr}, Count: &zero},
+				types: []string{},
+			},
+			// Rewards of the miners
+			{
+				args:  TraceFilterArgs{FromBlock: &from, ToBlock: &to, ToAddress: []common.Address{miner, uncle}},
+				types: []string{"reward", "reward", "reward", "reward", "reward"},
+			},
+			// Latest block only
+			{
+				args:  TraceFilterArgs{},
+				types: []string{"call", "reward"},
+				txs:   []common.Hash{txs[3]},
+			},
+		}
+	)
+	for i, tt := range tests {
+		traces, err := api.Filter(ctx, tt.args)
+		if err != nil {
+			t.Errorf("test %d: failed to filter traces: %v", i, err)
+			continue
+		}
+		res := decodeTestTraces(t, traces)
+		if len(res) != len(tt.types) {
+			t.Errorf("test %d: trace count mismatch: have %d, want %d", i, len(res), len(tt.types))
+			continue
+		}
+		for j, trace := range res {
+			if trace.Type != tt.types[j] {
+				t.Errorf("test %d, trace %d: type mismatch: have %s, want %s", i, j, trace.Type, tt.types[j])
+			}
+			if j < len(tt.txs) && *trace.TransactionHash != tt.txs[j] {
+				t.Errorf("test %d, trace %d: transaction mismatch: have %x, want %x", i, j, *trace.TransactionHash, tt.txs[j])
+			}
+		}
+	}
+	if _, err := api.Filter(ctx, TraceFilterArgs{FromBlock: &to, ToBlock: &from}); err == nil {
+		t.Errorf("inverted block range accepted")
+	}
+}
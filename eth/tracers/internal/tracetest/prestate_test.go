@@ -0,0 +1,136 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracetest
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/core"
+	"github.com/foreverbit/biternal/core/rawdb"
+	"github.com/foreverbit/biternal/core/types"
+	"github.com/foreverbit/biternal/core/vm"
+	"github.com/foreverbit/biternal/eth/tracers"
+	"github.com/foreverbit/biternal/rlp"
+	"github.com/foreverbit/biternal/tests"
+)
+
+// tracerTest defines a single test to check a tracer against, whose result is
+// compared as plain json.
+type tracerTest struct {
+	Genesis      *core.Genesis   `json:"genesis"`
+	Context      *callContext    `json:"context"`
+	Input        string          `json:"input"`
+	TracerConfig json.RawMessage `json:"tracerConfig"`
+	Result       json.RawMessage `json:"result"`
+}
+
+func TestPrestateTracer(t *testing.T) {
+	testTracer("prestateTracer", "prestate_tracer", t)
+}
+
+func TestPrestateWithDiffModeTracer(t *testing.T) {
+	testTracer("prestateTracer", "prestate_tracer_with_diff_mode", t)
+}
+
+// testTracer iterates over all the input-output datasets in the given directory
+// and runs the tracer against them.
+func testTracer(tracerName string, dirPath string, t *testing.T) {
+	files, err := os.ReadDir(filepath.Join("testdata", dirPath))
+	if err != nil {
+		t.Fatalf("failed to retrieve tracer test suite: %v", err)
+	}
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		file := file // capture range variable
+		t.Run(camel(strings.TrimSuffix(file.Name(), ".json")), func(t *testing.T) {
+			t.Parallel()
+
+			test := new(tracerTest)
+			if blob, err := os.ReadFile(filepath.Join("testdata", dirPath, file.Name())); err != nil {
+				t.Fatalf("failed to read testcase: %v", err)
+			} else if err := json.Unmarshal(blob, test); err != nil {
+				t.Fatalf("failed to parse testcase: %v", err)
+			}
+			res := runTracerTest(t, tracerName, test)
+
+			var have, want interface{}
+			if err := json.Unmarshal(res, &have); err != nil {
+				t.Fatalf("failed to unmarshal trace result: %v", err)
+			}
+			if err := json.Unmarshal(test.Result, &want); err != nil {
+				t.Fatalf("failed to unmarshal expected result: %v", err)
+			}
+			if !reflect.DeepEqual(have, want) {
+				t.Fatalf("trace mismatch:\nhave %s\nwant %s", res, test.Result)
+			}
+		})
+	}
+}
+
+// runTracerTest executes the transaction of the test on top of its pre-state
+// and returns the result of the given tracer.
+func runTracerTest(t *testing.T, tracerName string, test *tracerTest) json.RawMessage {
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(common.FromHex(test.Input), tx); err != nil {
+		t.Fatalf("failed to parse testcase input: %v", err)
+	}
+	var (
+		signer    = types.MakeSigner(test.Genesis.Config, new(big.Int).SetUint64(uint64(test.Context.Number)))
+		origin, _ = signer.Sender(tx)
+		txContext = vm.TxContext{
+			Origin:   origin,
+			GasPrice: tx.GasPrice(),
+		}
+		context = vm.BlockContext{
+			CanTransfer: core.CanTransfer,
+			Transfer:    core.Transfer,
+			Coinbase:    test.Context.Miner,
+			BlockNumber: new(big.Int).SetUint64(uint64(test.Context.Number)),
+			Time:        new(big.Int).SetUint64(uint64(test.Context.Time)),
+			Difficulty:  (*big.Int)(test.Context.Difficulty),
+			GasLimit:    uint64(test.Context.GasLimit),
+		}
+		_, statedb = tests.MakePreState(rawdb.NewMemoryDatabase(), test.Genesis.Alloc, false)
+	)
+	tracer, err := tracers.New(tracerName, &tracers.Context{TxHash: tx.Hash()}, test.TracerConfig)
+	if err != nil {
+		t.Fatalf("failed to create %s: %v", tracerName, err)
+	}
+	evm := vm.NewEVM(context, txContext, statedb, test.Genesis.Config, vm.Config{Debug: true, Tracer: tracer})
+	msg, err := tx.AsMessage(signer, nil)
+	if err != nil {
+		t.Fatalf("failed to prepare transaction for tracing: %v", err)
+	}
+	st := core.NewStateTransition(evm, msg, new(core.GasPool).AddGas(tx.Gas()))
+	if _, err = st.TransitionDb(); err != nil {
+		t.Fatalf("failed to execute transaction: %v", err)
+	}
+	res, err := tracer.GetResult()
+	if err != nil {
+		t.Fatalf("failed to retrieve trace result: %v", err)
+	}
+	return res
+}
@@ -0,0 +1,315 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package native
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/core/vm"
+	"github.com/foreverbit/biternal/eth/tracers"
+)
+
+func init() {
+	register("flatCallTracer", newFlatCallTracer)
+}
+
+// parityErrorMapping maps the errors of the EVM to the ones reported by parity.
+var parityErrorMapping = map[string]string{
+	"contract creation code storage out of gas": "Out of gas",
+	"out of gas":                      "Out of gas",
+	"gas uint64 overflow":             "Out of gas",
+	"max code size exceeded":          "Out of gas",
+	"invalid jump destination":        "Bad jump destination",
+	"execution reverted":              "Reverted",
+	"return data out of bounds":       "Out of bounds",
+	"stack limit reached 1024 (1023)": "Out of stack",
+	"precompiled failed":              "Built-in failed",
+	"invalid input length":            "Built-in failed",
+}
+
+// parityErrorMappingStartingWith maps the errors of the EVM with a variable
+// suffix to the ones reported by parity.
+var parityErrorMappingStartingWith = map[string]string{
+	"invalid opcode:": "Bad instruction",
+	"stack underflow": "Stack underflow",
+}
+
+// flatCallFrame is a single call frame in the parity-style flat format, indexed
+// by its trace address within the call tree.
+type flatCallFrame struct {
+	Action              flatCallAction  `json:"action"`
+	BlockHash           *common.Hash    `json:"blockHash"`
+	BlockNumber         uint64          `json:"blockNumber"`
+	Error               string          `json:"error,omitempty"`
+	Result              *flatCallResult `json:"result,omitempty"`
+	Subtraces           int             `json:"subtraces"`
+	TraceAddress        []int           `json:"traceAddress"`
+	TransactionHash     *common.Hash    `json:"transactionHash"`
+	TransactionPosition uint64          `json:"transactionPosition"`
+	Type                string          `json:"type"`
+}
+
+type flatCallAction struct {
+	SelfDestructed string `json:"address,omitempty"`
+	Balance        string `json:"balance,omitempty"`
+	CallType       string `json:"callType,omitempty"`
+	From           string `json:"from,omitempty"`
+	Gas            string `json:"gas,omitempty"`
+	Init           string `json:"init,omitempty"`
+	Input          string `json:"input,omitempty"`
+	RefundAddress  string `json:"refundAddress,omitempty"`
+	To             string `json:"to,omitempty"`
+	Value          string `json:"value,omitempty"`
+}
+
+type flatCallResult struct {
+	Address string `json:"address,omitempty"`
+	Code    string `json:"code,omitempty"`
+	GasUsed string `json:"gasUsed,omitempty"`
+	Output  string `json:"output,omitempty"`
+}
+
+// flatCallTracer reports the call frames of a tx in the parity-style flat
+// format, as opposed to the nested format of the callTracer it wraps.
+type flatCallTracer struct {
+	tracer      *callTracer
+	config      flatCallTracerConfig
+	ctx         *tracers.Context
+	blockNumber uint64
+	precompiles []common.Address // Precompiles active in the traced block
+}
+
+type flatCallTracerConfig struct {
+	ConvertParityErrors bool `json:"convertParityErrors"` // If true, the errors are converted to the parity format
+	IncludePrecompiles  bool `json:"includePrecompiles"`  // If true, the calls to precompiles are included
+}
+
+// newFlatCallTracer returns a native go tracer which reports the call frames of
+// a tx in the flat format, and implements vm.EVMLogger.
+func newFlatCallTracer(ctx *tracers.Context, cfg json.RawMessage) (tracers.Tracer, error) {
+	var config flatCallTracerConfig
+	if cfg != nil {
+		if err := json.Unmarshal(cfg, &config); err != nil {
+			return nil, err
+		}
+	}
+	return &flatCallTracer{
+		tracer: &callTracer{callstack: make([]callFrame, 1)},
+		config: config,
+		ctx:    ctx,
+	}, nil
+}
+
+// CaptureStart implements the EVMLogger interface to initialize the tracing operation.
+func (t *flatCallTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.tracer.CaptureStart(env, from, to, create, input, gas, value)
+
+	t.blockNumber = env.Context.BlockNumber.Uint64()
+	t.precompiles = vm.ActivePrecompiles(env.ChainConfig().Rules(env.Context.BlockNumber, env.Context.Random != nil))
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *flatCallTracer) CaptureEnd(output []byte, gasUsed uint64, elapsed time.Duration, err error) {
+	t.tracer.CaptureEnd(output, gasUsed, elapsed, err)
+}
+
+// CaptureState implements the EVMLogger interface to trace a single step of VM execution.
+func (t *flatCallTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+}
+
+// CaptureFault implements the EVMLogger interface to trace an execution fault.
+func (t *flatCallTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, _ *vm.ScopeContext, depth int, err error) {
+}
+
+// CaptureEnter is called when EVM enters a new scope (via call, create or selfdestruct).
+func (t *flatCallTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	t.tracer.CaptureEnter(typ, from, to, input, gas, value)
+
+	// Calls always report a value. A delegate call inherits the one of its
+	// parent, while a static call transfers nothing.
+	size := len(t.tracer.callstack)
+	if size < 2 || t.tracer.callstack[size-1].Value != "" {
+		return
+	}
+	switch typ {
+	case vm.DELEGATECALL:
+		t.tracer.callstack[size-1].Value = t.tracer.callstack[size-2].Value
+	case vm.STATICCALL:
+		t.tracer.callstack[size-1].Value = "0x0"
+	}
+}
+
+// CaptureExit is called when EVM exits a scope, even if the scope didn't
+// execute any code.
+func (t *flatCallTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
+	t.tracer.CaptureExit(output, gasUsed, err)
+
+	// Parity doesn't report the calls to precompiles, drop them unless requested
+	size := len(t.tracer.callstack)
+	if t.config.IncludePrecompiles || size < 1 || len(t.tracer.callstack[size-1].Calls) == 0 {
+		return
+	}
+	var (
+		parent = &t.tracer.callstack[size-1]
+		call   = parent.Calls[len(parent.Calls)-1]
+	)
+	if call.Type == vm.CALL.String() || call.Type == vm.STATICCALL.String() {
+		if t.isPrecompiled(common.HexToAddress(call.To)) {
+			parent.Calls = parent.Calls[:len(parent.Calls)-1]
+		}
+	}
+}
+
+func (t *flatCallTracer) CaptureTxStart(gasLimit uint64) {}
+
+func (t *flatCallTracer) CaptureTxEnd(restGas uint64) {}
+
+// GetResult returns the json-encoded flat list of call traces, and any error
+// arising from the encoding or forceful termination (via `Stop`).
+func (t *flatCallTracer) GetResult() (json.RawMessage, error) {
+	if len(t.tracer.callstack) != 1 {
+		return nil, errors.New("incorrect number of top-level calls")
+	}
+	flat, err := t.flatten(&t.tracer.callstack[0], []int{})
+	if err != nil {
+		return nil, err
+	}
+	res, err := json.Marshal(flat)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(res), t.tracer.reason
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (t *flatCallTracer) Stop(err error) {
+	t.tracer.Stop(err)
+}
+
+// isPrecompiled reports whether the address is a precompile active in the
+// traced block.
+func (t *flatCallTracer) isPrecompiled(addr common.Address) bool {
+	for _, p := range t.precompiles {
+		if p == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// flatten converts a call frame and its descendants to the flat format, the
+// frame itself being located at the given trace address.
+func (t *flatCallTracer) flatten(call *callFrame, traceAddress []int) ([]flatCallFrame, error) {
+	var frame *flatCallFrame
+	switch call.Type {
+	case vm.CREATE.String(), vm.CREATE2.String():
+		frame = &flatCallFrame{
+			Type: "create",
+			Action: flatCallAction{
+				From:  call.From,
+				Gas:   call.Gas,
+				Value: call.Value,
+				Init:  call.Input,
+			},
+			Result: &flatCallResult{
+				Address: call.To,
+				Code:    call.Output,
+				GasUsed: call.GasUsed,
+			},
+		}
+	case vm.SELFDESTRUCT.String():
+		frame = &flatCallFrame{
+			Type: "suicide",
+			Action: flatCallAction{
+				SelfDestructed: call.From,
+				Balance:        call.Value,
+				RefundAddress:  call.To,
+			},
+		}
+	case vm.CALL.String(), vm.CALLCODE.String(), vm.DELEGATECALL.String(), vm.STATICCALL.String():
+		frame = &flatCallFrame{
+			Type: "call",
+			Action: flatCallAction{
+				CallType: strings.ToLower(call.Type),
+				From:     call.From,
+				To:       call.To,
+				Gas:      call.Gas,
+				Value:    call.Value,
+				Input:    call.Input,
+			},
+			Result: &flatCallResult{
+				GasUsed: call.GasUsed,
+				Output:  call.Output,
+			},
+		}
+	default:
+		return nil, fmt.Errorf("unrecognized call frame type: %s", call.Type)
+	}
+	// Failed frames have no result, even if reverted with an output
+	if call.Error != "" {
+		frame.Error = call.Error
+		frame.Result = nil
+		if t.config.ConvertParityErrors {
+			frame.Error = parityError(call.Error)
+		}
+	}
+	frame.TraceAddress = traceAddress
+	frame.Subtraces = len(call.Calls)
+	frame.BlockNumber = t.blockNumber
+	if t.ctx != nil {
+		if t.ctx.BlockHash != (common.Hash{}) {
+			frame.BlockHash = &t.ctx.BlockHash
+		}
+		if t.ctx.TxHash != (common.Hash{}) {
+			frame.TransactionHash = &t.ctx.TxHash
+		}
+		frame.TransactionPosition = uint64(t.ctx.TxIndex)
+	}
+	flat := []flatCallFrame{*frame}
+	for i := range call.Calls {
+		childAddress := make([]int, len(traceAddress)+1)
+		copy(childAddress, traceAddress)
+		childAddress[len(traceAddress)] = i
+
+		children, err := t.flatten(&call.Calls[i], childAddress)
+		if err != nil {
+			return nil, err
+		}
+		flat = append(flat, children...)
+	}
+	return flat, nil
+}
+
+// parityError converts an error of the EVM to the one reported by parity, or
+// returns it unchanged if there's no equivalent.
+func parityError(err string) string {
+	if mapped, ok := parityErrorMapping[err]; ok {
+		return mapped
+	}
+	for prefix, mapped := range parityErrorMappingStartingWith {
+		if strings.HasPrefix(err, prefix) {
+			return mapped
+		}
+	}
+	return err
+}
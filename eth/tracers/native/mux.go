@@ -0,0 +1,159 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package native
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/core/vm"
+	"github.com/foreverbit/biternal/eth/tracers"
+)
+
+func init() {
+	register("muxTracer", newMuxTracer)
+}
+
+// muxTracer runs several tracers in a single execution, forwarding every hook
+// to each of them and returning their results keyed by tracer name.
+//
+// Example:
+//
+//	> debug.traceTransaction("0x...", {tracer: "muxTracer", tracerConfig: {callTracer: {onlyTopCall: true}, prestateTracer: {}}})
+//	{
+//	  callTracer: {...},
+//	  prestateTracer: {...}
+//	}
+type muxTracer struct {
+	names   []string
+	tracers []tracers.Tracer
+}
+
+// newMuxTracer returns a tracer running the tracers named in the config, each
+// created with the config it is mapped to.
+func newMuxTracer(ctx *tracers.Context, cfg json.RawMessage) (tracers.Tracer, error) {
+	var config map[string]json.RawMessage
+	if cfg != nil {
+		if err := json.Unmarshal(cfg, &config); err != nil {
+			return nil, err
+		}
+	}
+	// Run the tracers in a deterministic order
+	names := make([]string, 0, len(config))
+	for name := range config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	t := &muxTracer{names: names, tracers: make([]tracers.Tracer, len(names))}
+	for i, name := range names {
+		var tracerCfg json.RawMessage
+		if c := config[name]; len(c) > 0 && string(c) != "null" {
+			tracerCfg = c
+		}
+		tracer, err := tracers.New(name, ctx, tracerCfg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		t.tracers[i] = tracer
+	}
+	return t, nil
+}
+
+// CaptureStart implements the EVMLogger interface to initialize the tracing operation.
+func (t *muxTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	for _, tracer := range t.tracers {
+		tracer.CaptureStart(env, from, to, create, input, gas, value)
+	}
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *muxTracer) CaptureEnd(output []byte, gasUsed uint64, elapsed time.Duration, err error) {
+	for _, tracer := range t.tracers {
+		tracer.CaptureEnd(output, gasUsed, elapsed, err)
+	}
+}
+
+// CaptureState implements the EVMLogger interface to trace a single step of VM execution.
+func (t *muxTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	for _, tracer := range t.tracers {
+		tracer.CaptureState(pc, op, gas, cost, scope, rData, depth, err)
+	}
+}
+
+// CaptureFault implements the EVMLogger interface to trace an execution fault.
+func (t *muxTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+	for _, tracer := range t.tracers {
+		tracer.CaptureFault(pc, op, gas, cost, scope, depth, err)
+	}
+}
+
+// CaptureEnter is called when EVM enters a new scope (via call, create or selfdestruct).
+func (t *muxTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	for _, tracer := range t.tracers {
+		tracer.CaptureEnter(typ, from, to, input, gas, value)
+	}
+}
+
+// CaptureExit is called when EVM exits a scope, even if the scope didn't
+// execute any code.
+func (t *muxTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
+	for _, tracer := range t.tracers {
+		tracer.CaptureExit(output, gasUsed, err)
+	}
+}
+
+func (t *muxTracer) CaptureTxStart(gasLimit uint64) {
+	for _, tracer := range t.tracers {
+		tracer.CaptureTxStart(gasLimit)
+	}
+}
+
+func (t *muxTracer) CaptureTxEnd(restGas uint64) {
+	for _, tracer := range t.tracers {
+		tracer.CaptureTxEnd(restGas)
+	}
+}
+
+// GetResult returns the json-encoded results of the tracers keyed by their
+// names, and the first error arising from any of them.
+func (t *muxTracer) GetResult() (json.RawMessage, error) {
+	results := make(map[string]json.RawMessage, len(t.tracers))
+	for i, tracer := range t.tracers {
+		res, err := tracer.GetResult()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", t.names[i], err)
+		}
+		results[t.names[i]] = res
+	}
+	res, err := json.Marshal(results)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(res), nil
+}
+
+// Stop terminates execution of the tracers at the first opportune moment.
+func (t *muxTracer) Stop(err error) {
+	for _, tracer := range t.tracers {
+		tracer.Stop(err)
+	}
+}
@@ -17,6 +17,7 @@
 package native
 
 import (
+	"bytes"
 	"encoding/json"
 	"math/big"
 	"sync/atomic"
@@ -33,28 +34,74 @@ func init() {
 	register("prestateTracer", newPrestateTracer)
 }
 
-type prestate = map[common.Address]*account
+type state = map[common.Address]*account
 type account struct {
+	Balance *big.Int
+	Nonce   uint64
+	Code    []byte
+	Storage map[common.Hash]common.Hash
+}
+
+// exists reports whether the account has any non-empty field.
+func (a *account) exists() bool {
+	if a.Nonce > 0 || len(a.Code) > 0 || a.Balance.Sign() != 0 {
+		return true
+	}
+	for _, val := range a.Storage {
+		if val != (common.Hash{}) {
+			return true
+		}
+	}
+	return false
+}
+
+// prestateAccount is the json encoding of an account in prestate mode.
+type prestateAccount struct {
 	Balance string                      `json:"balance"`
 	Nonce   uint64                      `json:"nonce"`
 	Code    string                      `json:"code"`
 	Storage map[common.Hash]common.Hash `json:"storage"`
 }
 
+// diffAccount is the json encoding of an account in diff mode, where only the
+// modified fields and storage slots are set.
+type diffAccount struct {
+	Balance *hexutil.Big                `json:"balance,omitempty"`
+	Nonce   *uint64                     `json:"nonce,omitempty"`
+	Code    *hexutil.Bytes              `json:"code,omitempty"`
+	Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
+}
+
 type prestateTracer struct {
 	env       *vm.EVM
-	prestate  prestate
+	pre       state
+	diffPre   map[common.Address]*diffAccount // Modified fields of the modified accounts before the tx
+	diffPost  map[common.Address]*diffAccount // Modified fields of the modified accounts after the tx
 	create    bool
 	to        common.Address
 	gasLimit  uint64 // Amount of gas bought for the whole tx
+	config    prestateTracerConfig
 	interrupt uint32 // Atomic flag to signal execution interruption
 	reason    error  // Textual reason for the interruption
 }
 
-func newPrestateTracer(ctx *tracers.Context, _ json.RawMessage) (tracers.Tracer, error) {
-	// First callframe contains tx context info
-	// and is populated on start and end.
-	return &prestateTracer{prestate: prestate{}}, nil
+type prestateTracerConfig struct {
+	DiffMode bool `json:"diffMode"` // If true, the tracer returns the state modifications of the tx
+}
+
+func newPrestateTracer(ctx *tracers.Context, cfg json.RawMessage) (tracers.Tracer, error) {
+	var config prestateTracerConfig
+	if cfg != nil {
+		if err := json.Unmarshal(cfg, &config); err != nil {
+			return nil, err
+		}
+	}
+	return &prestateTracer{
+		pre:      state{},
+		diffPre:  make(map[common.Address]*diffAccount),
+		diffPost: make(map[common.Address]*diffAccount),
+		config:   config,
+	}, nil
 }
 
 // CaptureStart implements the EVMLogger interface to initialize the tracing operation.
@@ -65,27 +112,33 @@ func (t *prestateTracer) CaptureStart(env *vm.EVM, from common.Address, to commo
 
 	t.lookupAccount(from)
 	t.lookupAccount(to)
-
+	if t.config.DiffMode {
+		// The coinbase receives the fees at the end of the tx.
+		t.lookupAccount(env.Context.Coinbase)
+	}
 	// The recipient balance includes the value transferred.
-	toBal := hexutil.MustDecodeBig(t.prestate[to].Balance)
-	toBal = new(big.Int).Sub(toBal, value)
-	t.prestate[to].Balance = hexutil.EncodeBig(toBal)
+	t.pre[to].Balance = new(big.Int).Sub(t.pre[to].Balance, value)
 
+	// A created contract already has its nonce set, but the
+	// address is guaranteed to have had a zero nonce.
+	if create {
+		t.pre[to].Nonce = 0
+	}
 	// The sender balance is after reducing: value and gasLimit.
 	// We need to re-add them to get the pre-tx balance.
-	fromBal := hexutil.MustDecodeBig(t.prestate[from].Balance)
+	fromBal := new(big.Int).Set(t.pre[from].Balance)
 	gasPrice := env.TxContext.GasPrice
 	consumedGas := new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(t.gasLimit))
 	fromBal.Add(fromBal, new(big.Int).Add(value, consumedGas))
-	t.prestate[from].Balance = hexutil.EncodeBig(fromBal)
-	t.prestate[from].Nonce--
+	t.pre[from].Balance = fromBal
+	t.pre[from].Nonce--
 }
 
 // CaptureEnd is called after the call finishes to finalize the tracing.
 func (t *prestateTracer) CaptureEnd(output []byte, gasUsed uint64, _ time.Duration, err error) {
-	if t.create {
+	if t.create && !t.config.DiffMode {
 		// Exclude created contract.
-		delete(t.prestate, t.to)
+		delete(t.pre, t.to)
 	}
 }
 
@@ -94,27 +147,30 @@ func (t *prestateTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64,
 	stack := scope.Stack
 	stackData := stack.Data()
 	stackLen := len(stackData)
+	caller := scope.Contract.Address()
 	switch {
 	case stackLen >= 1 && (op == vm.SLOAD || op == vm.SSTORE):
 		slot := common.Hash(stackData[stackLen-1].Bytes32())
-		t.lookupStorage(scope.Contract.Address(), slot)
+		t.lookupStorage(caller, slot)
 	case stackLen >= 1 && (op == vm.EXTCODECOPY || op == vm.EXTCODEHASH || op == vm.EXTCODESIZE || op == vm.BALANCE || op == vm.SELFDESTRUCT):
 		addr := common.Address(stackData[stackLen-1].Bytes20())
 		t.lookupAccount(addr)
+		if op == vm.SELFDESTRUCT {
+			t.lookupAccount(caller)
+		}
 	case stackLen >= 5 && (op == vm.DELEGATECALL || op == vm.CALL || op == vm.STATICCALL || op == vm.CALLCODE):
 		addr := common.Address(stackData[stackLen-2].Bytes20())
 		t.lookupAccount(addr)
 	case op == vm.CREATE:
-		addr := scope.Contract.Address()
-		nonce := t.env.StateDB.GetNonce(addr)
-		t.lookupAccount(crypto.CreateAddress(addr, nonce))
+		nonce := t.env.StateDB.GetNonce(caller)
+		t.lookupAccount(crypto.CreateAddress(caller, nonce))
 	case stackLen >= 4 && op == vm.CREATE2:
 		offset := stackData[stackLen-2]
 		size := stackData[stackLen-3]
 		init := scope.Memory.GetCopy(int64(offset.Uint64()), int64(size.Uint64()))
 		inithash := crypto.Keccak256(init)
 		salt := stackData[stackLen-4]
-		t.lookupAccount(crypto.CreateAddress2(scope.Contract.Address(), salt.Bytes32(), inithash))
+		t.lookupAccount(crypto.CreateAddress2(caller, salt.Bytes32(), inithash))
 	}
 }
 
@@ -135,12 +191,98 @@ func (t *prestateTracer) CaptureTxStart(gasLimit uint64) {
 	t.gasLimit = gasLimit
 }
 
-func (t *prestateTracer) CaptureTxEnd(restGas uint64) {}
+// CaptureTxEnd computes the state modifications of the tx in diff mode, once
+// the fees are paid but before the self-destructed accounts are removed.
+func (t *prestateTracer) CaptureTxEnd(restGas uint64) {
+	if !t.config.DiffMode {
+		return
+	}
+	for addr, prev := range t.pre {
+		// A self-destructed account has all of its fields emptied
+		var (
+			deleted = t.env.StateDB.HasSuicided(addr)
+			balance = new(big.Int)
+			nonce   uint64
+			code    []byte
+		)
+		if !deleted {
+			balance = t.env.StateDB.GetBalance(addr)
+			nonce = t.env.StateDB.GetNonce(addr)
+			code = t.env.StateDB.GetCode(addr)
+		}
+		var (
+			pre      = &diffAccount{Storage: make(map[common.Hash]common.Hash)}
+			post     = &diffAccount{Storage: make(map[common.Hash]common.Hash)}
+			modified bool
+		)
+		if balance.Cmp(prev.Balance) != 0 {
+			pre.Balance, post.Balance = (*hexutil.Big)(prev.Balance), (*hexutil.Big)(balance)
+			modified = true
+		}
+		if nonce != prev.Nonce {
+			preNonce, postNonce := prev.Nonce, nonce
+			pre.Nonce, post.Nonce = &preNonce, &postNonce
+			modified = true
+		}
+		if !bytes.Equal(code, prev.Code) {
+			preCode, postCode := hexutil.Bytes(prev.Code), hexutil.Bytes(code)
+			pre.Code, post.Code = &preCode, &postCode
+			modified = true
+		}
+		for key, val := range prev.Storage {
+			var newVal common.Hash
+			if !deleted {
+				newVal = t.env.StateDB.GetState(addr, key)
+			}
+			if newVal == val {
+				continue
+			}
+			// Empty slots are omitted from the pre-state, but cleared
+			// ones are kept in the post-state.
+			if val != (common.Hash{}) {
+				pre.Storage[key] = val
+			}
+			post.Storage[key] = newVal
+			modified = true
+		}
+		if !modified {
+			continue
+		}
+		// Created accounts have no pre-state and deleted ones no post-state
+		if prev.exists() {
+			t.diffPre[addr] = pre
+		}
+		if !deleted {
+			t.diffPost[addr] = post
+		}
+	}
+}
 
-// GetResult returns the json-encoded nested list of call traces, and any
-// error arising from the encoding or forceful termination (via `Stop`).
+// GetResult returns the json-encoded pre-state of the touched accounts, or the
+// pre- and post-state of the modified ones in diff mode, and any error arising
+// from the encoding or forceful termination (via `Stop`).
 func (t *prestateTracer) GetResult() (json.RawMessage, error) {
-	res, err := json.Marshal(t.prestate)
+	var (
+		res []byte
+		err error
+	)
+	if t.config.DiffMode {
+		res, err = json.Marshal(struct {
+			Post map[common.Address]*diffAccount `json:"post"`
+			Pre  map[common.Address]*diffAccount `json:"pre"`
+		}{t.diffPost, t.diffPre})
+	} else {
+		prestate := make(map[common.Address]*prestateAccount, len(t.pre))
+		for addr, acc := range t.pre {
+			prestate[addr] = &prestateAccount{
+				Balance: bigToHex(acc.Balance),
+				Nonce:   acc.Nonce,
+				Code:    bytesToHex(acc.Code),
+				Storage: acc.Storage,
+			}
+		}
+		res, err = json.Marshal(prestate)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -156,13 +298,13 @@ func (t *prestateTracer) Stop(err error) {
 // lookupAccount fetches details of an account and adds it to the prestate
 // if it doesn't exist there.
 func (t *prestateTracer) lookupAccount(addr common.Address) {
-	if _, ok := t.prestate[addr]; ok {
+	if _, ok := t.pre[addr]; ok {
 		return
 	}
-	t.prestate[addr] = &account{
-		Balance: bigToHex(t.env.StateDB.GetBalance(addr)),
+	t.pre[addr] = &account{
+		Balance: new(big.Int).Set(t.env.StateDB.GetBalance(addr)),
 		Nonce:   t.env.StateDB.GetNonce(addr),
-		Code:    bytesToHex(t.env.StateDB.GetCode(addr)),
+		Code:    t.env.StateDB.GetCode(addr),
 		Storage: make(map[common.Hash]common.Hash),
 	}
 }
@@ -171,8 +313,8 @@ func (t *prestateTracer) lookupAccount(addr common.Address) {
 // it to the prestate of the given contract. It assumes `lookupAccount`
 // has been performed on the contract before.
 func (t *prestateTracer) lookupStorage(addr common.Address, key common.Hash) {
-	if _, ok := t.prestate[addr].Storage[key]; ok {
+	if _, ok := t.pre[addr].Storage[key]; ok {
 		return
 	}
-	t.prestate[addr].Storage[key] = t.env.StateDB.GetState(addr, key)
+	t.pre[addr].Storage[key] = t.env.StateDB.GetState(addr, key)
 }
@@ -294,6 +294,9 @@ func TestEthClient(t *testing.T) {
 		"TransactionSender": {
 			func(t *testing.T) { testTransactionSender(t, client) },
 		},
+		"BlockReceipts": {
+			func(t *testing.T) { testBlockReceipts(t, client) },
+		},
 	}
 
 	t.Parallel()
@@ -692,6 +695,43 @@ func testTransactionSender(t *testing.T, client *rpc.Client) {
 	}
 }
 
+func testBlockReceipts(t *testing.T, client *rpc.Client) {
+	ec := NewClient(client)
+	ctx := context.Background()
+
+	// Retrieve the receipts of block #2, both by number and by hash.
+	block2, err := ec.HeaderByNumber(ctx, big.NewInt(2))
+	if err != nil {
+		t.Fatal("can't get block 2:", err)
+	}
+	byNumber, err := ec.BlockReceipts(ctx, rpc.BlockNumberOrHashWithNumber(2))
+	if err != nil {
+		t.Fatal("can't get receipts by number:", err)
+	}
+	byHash, err := ec.BlockReceipts(ctx, rpc.BlockNumberOrHashWithHash(block2.Hash(), false))
+	if err != nil {
+		t.Fatal("can't get receipts by hash:", err)
+	}
+	if len(byNumber) != 2 || len(byHash) != 2 {
+		t.Fatalf("wrong receipt count: %d by number, %d by hash, want 2", len(byNumber), len(byHash))
+	}
+	for i, tx := range []*types.Transaction{testTx1, testTx2} {
+		want, err := ec.TransactionReceipt(ctx, tx.Hash())
+		if err != nil {
+			t.Fatal("can't get receipt:", err)
+		}
+		for _, have := range []*types.Receipt{byNumber[i], byHash[i]} {
+			if !reflect.DeepEqual(have, want) {
+				t.Fatalf("receipt %d mismatch: have %+v, want %+v", i, have, want)
+			}
+		}
+	}
+	// Receipts of unknown blocks should not be found.
+	if _, err := ec.BlockReceipts(ctx, rpc.BlockNumberOrHashWithNumber(100)); err != ethereum.NotFound {
+		t.Fatal("error should be ethereum.NotFound, got", err)
+	}
+}
+
 func sendTransaction(ec *Client) error {
 	chainID, err := ec.ChainID(context.Background())
 	if err != nil {
@@ -118,6 +118,92 @@ func (ec *Client) GetProof(ctx context.Context, account common.Address, keys []s
 	return &result, err
 }
 
+// ProofRequest is an account along with some of its storage keys to prove with
+// GetMultiProof.
+type ProofRequest struct {
+	Address     common.Address `json:"address"`
+	StorageKeys []string       `json:"storageKeys"`
+}
+
+// MultiProofResult is the result of a GetMultiProof operation.
+type MultiProofResult struct {
+	AccountProof []string             `json:"accountProof"`
+	Accounts     []MultiAccountResult `json:"accounts"`
+}
+
+// MultiAccountResult provides the values of an account and its requested
+// storage keys, along with the multiproof of the latter.
+type MultiAccountResult struct {
+	Address      common.Address `json:"address"`
+	Balance      *big.Int       `json:"balance"`
+	CodeHash     common.Hash    `json:"codeHash"`
+	Nonce        uint64         `json:"nonce"`
+	StorageHash  common.Hash    `json:"storageHash"`
+	StorageProof []string       `json:"storageProof"`
+	Storage      []StorageValue `json:"storage"`
+}
+
+// StorageValue is a proven key-value pair.
+type StorageValue struct {
+	Key   string   `json:"key"`
+	Value *big.Int `json:"value"`
+}
+
+// GetMultiProof returns the values of the specified accounts and storage keys
+// including a shared Merkle-proof for all accounts and one per account for all
+// of its storage keys. The block number can be nil, in which case the values are
+// taken from the latest known block.
+func (ec *Client) GetMultiProof(ctx context.Context, requests []ProofRequest, blockNumber *big.Int) (*MultiProofResult, error) {
+	type storageValue struct {
+		Key   string       `json:"key"`
+		Value *hexutil.Big `json:"value"`
+	}
+
+	type accountResult struct {
+		Address      common.Address `json:"address"`
+		Balance      *hexutil.Big   `json:"balance"`
+		CodeHash     common.Hash    `json:"codeHash"`
+		Nonce        hexutil.Uint64 `json:"nonce"`
+		StorageHash  common.Hash    `json:"storageHash"`
+		StorageProof []string       `json:"storageProof"`
+		Storage      []storageValue `json:"storage"`
+	}
+
+	type multiProofResult struct {
+		AccountProof []string        `json:"accountProof"`
+		Accounts     []accountResult `json:"accounts"`
+	}
+
+	var res multiProofResult
+	if err := ec.c.CallContext(ctx, &res, "eth_getMultiProof", requests, toBlockNumArg(blockNumber)); err != nil {
+		return nil, err
+	}
+	// Turn hexutils back to normal datatypes
+	accounts := make([]MultiAccountResult, 0, len(res.Accounts))
+	for _, acc := range res.Accounts {
+		storage := make([]StorageValue, 0, len(acc.Storage))
+		for _, st := range acc.Storage {
+			storage = append(storage, StorageValue{
+				Key:   st.Key,
+				Value: st.Value.ToInt(),
+			})
+		}
+		accounts = append(accounts, MultiAccountResult{
+			Address:      acc.Address,
+			Balance:      acc.Balance.ToInt(),
+			CodeHash:     acc.CodeHash,
+			Nonce:        uint64(acc.Nonce),
+			StorageHash:  acc.StorageHash,
+			StorageProof: acc.StorageProof,
+			Storage:      storage,
+		})
+	}
+	return &MultiProofResult{
+		AccountProof: res.AccountProof,
+		Accounts:     accounts,
+	}, nil
+}
+
 // OverrideAccount specifies the state of an account to be overridden.
 type OverrideAccount struct {
 	Nonce     uint64                      `json:"nonce"`
@@ -24,6 +24,7 @@ import (
 
 	"github.com/foreverbit/biternal"
 	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/common/hexutil"
 	"github.com/foreverbit/biternal/consensus/ethash"
 	"github.com/foreverbit/biternal/core"
 	"github.com/foreverbit/biternal/core/rawdb"
@@ -32,9 +33,12 @@ import (
 	"github.com/foreverbit/biternal/eth"
 	"github.com/foreverbit/biternal/eth/ethconfig"
 	"github.com/foreverbit/biternal/ethclient"
+	"github.com/foreverbit/biternal/ethdb/memorydb"
 	"github.com/foreverbit/biternal/node"
 	"github.com/foreverbit/biternal/params"
+	"github.com/foreverbit/biternal/rlp"
 	"github.com/foreverbit/biternal/rpc"
+	"github.com/foreverbit/biternal/trie"
 )
 
 var (
@@ -110,6 +114,9 @@ func TestGethClient(t *testing.T) {
 		{
 			"TestGetProof",
 			func(t *testing.T) { testGetProof(t, client) },
+		}, {
+			"TestGetMultiProof",
+			func(t *testing.T) { testGetMultiProof(t, client) },
 		}, {
 			"TestGCStats",
 			func(t *testing.T) { testGCStats(t, client) },
@@ -224,6 +231,94 @@ func testGetProof(t *testing.T, client *rpc.Client) {
 	}
 }
 
+func testGetMultiProof(t *testing.T, client *rpc.Client) {
+	ec := New(client)
+	ethcl := ethclient.NewClient(client)
+
+	var (
+		missingAddr = common.Address{0xff}
+		missingSlot = common.HexToHash("0xfeedbeef")
+	)
+	requests := []ProofRequest{
+		{Address: testAddr, StorageKeys: []string{testSlot.String(), missingSlot.String()}},
+		{Address: missingAddr, StorageKeys: []string{testSlot.String()}},
+	}
+	result, err := ec.GetMultiProof(context.Background(), requests, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Accounts) != len(requests) {
+		t.Fatalf("invalid account count, want %d, got %d", len(requests), len(result.Accounts))
+	}
+	// Verify the accounts against the state root
+	header, err := ethcl.HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := trie.VerifyMultiProof(header.Root, [][]byte{crypto.Keccak256(testAddr[:]), crypto.Keccak256(missingAddr[:])}, toProofDb(t, result.AccountProof))
+	if err != nil {
+		t.Fatalf("invalid account proof: %v", err)
+	}
+	if values[1] != nil {
+		t.Fatalf("missing account proven to exist: %x", values[1])
+	}
+	var account types.StateAccount
+	if err := rlp.DecodeBytes(values[0], &account); err != nil {
+		t.Fatalf("invalid account: %v", err)
+	}
+	res := result.Accounts[0]
+	if res.Address != testAddr {
+		t.Fatalf("unexpected address, want: %v got: %v", testAddr, res.Address)
+	}
+	if res.Nonce != account.Nonce || res.Balance.Cmp(account.Balance) != 0 || res.StorageHash != account.Root || !bytes.Equal(res.CodeHash[:], account.CodeHash) {
+		t.Fatalf("account mismatch, proven: %+v, got: %+v", account, res)
+	}
+	balance, _ := ethcl.BalanceAt(context.Background(), testAddr, nil)
+	if res.Balance.Cmp(balance) != 0 {
+		t.Fatalf("invalid balance, want: %v got: %v", balance, res.Balance)
+	}
+	// Verify the storage values against the storage root
+	values, err = trie.VerifyMultiProof(res.StorageHash, [][]byte{crypto.Keccak256(testSlot[:]), crypto.Keccak256(missingSlot[:])}, toProofDb(t, res.StorageProof))
+	if err != nil {
+		t.Fatalf("invalid storage proof: %v", err)
+	}
+	if values[1] != nil {
+		t.Fatalf("missing slot proven to exist: %x", values[1])
+	}
+	var value []byte
+	if err := rlp.DecodeBytes(values[0], &value); err != nil {
+		t.Fatalf("invalid slot value: %v", err)
+	}
+	if len(res.Storage) != 2 {
+		t.Fatalf("invalid storage count, want 2, got %d", len(res.Storage))
+	}
+	if res.Storage[0].Key != testSlot.String() || !bytes.Equal(res.Storage[0].Value.Bytes(), value) {
+		t.Fatalf("invalid storage value, want: %x, got: %x", value, res.Storage[0].Value.Bytes())
+	}
+	if res.Storage[1].Value.Sign() != 0 {
+		t.Fatalf("invalid missing storage value: %v", res.Storage[1].Value)
+	}
+	// The missing account has no storage proof
+	if res := result.Accounts[1]; res.Balance.Sign() != 0 || len(res.StorageProof) != 0 || len(res.Storage) != 1 || res.Storage[0].Value.Sign() != 0 {
+		t.Fatalf("invalid missing account: %+v", res)
+	}
+}
+
+// toProofDb decodes a hex-encoded proof node list into a proof database.
+func toProofDb(t *testing.T, proof []string) *memorydb.Database {
+	t.Helper()
+
+	db := memorydb.New()
+	for _, node := range proof {
+		blob, err := hexutil.Decode(node)
+		if err != nil {
+			t.Fatalf("invalid proof node %s: %v", node, err)
+		}
+		db.Put(crypto.Keccak256(blob), blob)
+	}
+	return db
+}
+
 func testGCStats(t *testing.T, client *rpc.Client) {
 	ec := New(client)
 	_, err := ec.GCStats(context.Background())
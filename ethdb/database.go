@@ -122,6 +122,10 @@ type AncientWriter interface {
 	// will be removed all together.
 	TruncateTail(n uint64) error
 
+	// TruncateTableTail discards the first n items of the given table only, leaving
+	// the other tables intact. The already deleted items are ignored.
+	TruncateTableTail(kind string, n uint64) error
+
 	// Sync flushes all in-memory ancient store data to disk.
 	Sync() error
 
@@ -129,6 +133,11 @@ type AncientWriter interface {
 	// The second argument is a function that takes a raw entry and returns it
 	// in the newest format.
 	MigrateTable(string, func([]byte) ([]byte, error)) error
+
+	// CompressTable rewrites all entries of the given table with the given
+	// compression codec ("none", "snappy" or "zstd"). The level and the dictionary
+	// training only apply to zstd.
+	CompressTable(kind string, codec string, level int, dict bool) error
 }
 
 // AncientWriteOp is given to the function argument of ModifyAncients.
@@ -109,6 +109,10 @@ func (db *Database) TruncateTail(n uint64) error {
 	panic("not supported")
 }
 
+func (db *Database) TruncateTableTail(kind string, n uint64) error {
+	panic("not supported")
+}
+
 func (db *Database) Sync() error {
 	return nil
 }
@@ -117,6 +121,10 @@ func (db *Database) MigrateTable(s string, f func([]byte) ([]byte, error)) error
 	panic("not supported")
 }
 
+func (db *Database) CompressTable(kind string, codec string, level int, dict bool) error {
+	panic("not supported")
+}
+
 func (db *Database) NewBatch() ethdb.Batch {
 	panic("not supported")
 }
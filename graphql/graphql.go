@@ -180,6 +180,95 @@ func (at *AccessTuple) StorageKeys(ctx context.Context) []common.Hash {
 	return at.storageKeys
 }
 
+// Receipt represents the receipt of a transaction included in a block.
+type Receipt struct {
+	tx      *Transaction
+	receipt *types.Receipt
+}
+
+func (r *Receipt) TransactionHash(ctx context.Context) common.Hash {
+	return r.tx.hash
+}
+
+func (r *Receipt) TransactionIndex(ctx context.Context) int32 {
+	return int32(r.tx.index)
+}
+
+func (r *Receipt) Block(ctx context.Context) *Block {
+	return r.tx.block
+}
+
+func (r *Receipt) From(ctx context.Context, args BlockNumberArgs) (*Account, error) {
+	return r.tx.From(ctx, args)
+}
+
+func (r *Receipt) To(ctx context.Context, args BlockNumberArgs) (*Account, error) {
+	return r.tx.To(ctx, args)
+}
+
+func (r *Receipt) GasUsed(ctx context.Context) Long {
+	return Long(r.receipt.GasUsed)
+}
+
+func (r *Receipt) CumulativeGasUsed(ctx context.Context) Long {
+	return Long(r.receipt.CumulativeGasUsed)
+}
+
+func (r *Receipt) EffectiveGasPrice(ctx context.Context) (hexutil.Big, error) {
+	price, err := r.tx.EffectiveGasPrice(ctx)
+	if err != nil || price == nil {
+		return hexutil.Big{}, err
+	}
+	return *price, nil
+}
+
+func (r *Receipt) ContractAddress(ctx context.Context, args BlockNumberArgs) (*Account, error) {
+	if r.receipt.ContractAddress == (common.Address{}) {
+		return nil, nil
+	}
+	return &Account{
+		r:             r.tx.r,
+		address:       r.receipt.ContractAddress,
+		blockNrOrHash: args.NumberOrLatest(),
+	}, nil
+}
+
+func (r *Receipt) Logs(ctx context.Context) []*Log {
+	ret := make([]*Log, 0, len(r.receipt.Logs))
+	for _, log := range r.receipt.Logs {
+		ret = append(ret, &Log{
+			r:           r.tx.r,
+			transaction: r.tx,
+			log:         log,
+		})
+	}
+	return ret
+}
+
+func (r *Receipt) LogsBloom(ctx context.Context) hexutil.Bytes {
+	return r.receipt.Bloom.Bytes()
+}
+
+func (r *Receipt) Type(ctx context.Context) int32 {
+	return int32(r.receipt.Type)
+}
+
+func (r *Receipt) Root(ctx context.Context) *common.Hash {
+	if len(r.receipt.PostState) == 0 {
+		return nil
+	}
+	root := common.BytesToHash(r.receipt.PostState)
+	return &root
+}
+
+func (r *Receipt) Status(ctx context.Context) *Long {
+	if len(r.receipt.PostState) != 0 {
+		return nil
+	}
+	status := Long(r.receipt.Status)
+	return &status
+}
+
 // Transaction represents an Ethereum transaction.
 // backend and hash are mandatory; all others will be fetched when required.
 type Transaction struct {
@@ -239,7 +328,7 @@ func (t *Transaction) GasPrice(ctx context.Context) (hexutil.Big, error) {
 	switch tx.Type() {
 	case types.AccessListTxType:
 		return hexutil.Big(*tx.GasPrice()), nil
-	case types.DynamicFeeTxType:
+	case types.DynamicFeeTxType, types.ReserveTxType:
 		if t.block != nil {
 			if baseFee, _ := t.block.BaseFeePerGas(ctx); baseFee != nil {
 				// price = min(tip, gasFeeCap - baseFee) + baseFee
@@ -279,7 +368,7 @@ func (t *Transaction) MaxFeePerGas(ctx context.Context) (*hexutil.Big, error) {
 	switch tx.Type() {
 	case types.AccessListTxType:
 		return nil, nil
-	case types.DynamicFeeTxType:
+	case types.DynamicFeeTxType, types.ReserveTxType:
 		return (*hexutil.Big)(tx.GasFeeCap()), nil
 	default:
 		return nil, nil
@@ -294,7 +383,7 @@ func (t *Transaction) MaxPriorityFeePerGas(ctx context.Context) (*hexutil.Big, e
 	switch tx.Type() {
 	case types.AccessListTxType:
 		return nil, nil
-	case types.DynamicFeeTxType:
+	case types.DynamicFeeTxType, types.ReserveTxType:
 		return (*hexutil.Big)(tx.GasTipCap()), nil
 	default:
 		return nil, nil
@@ -823,6 +912,51 @@ func (b *Block) Raw(ctx context.Context) (hexutil.Bytes, error) {
 	return rlp.EncodeToBytes(block)
 }
 
+func (b *Block) RawReceipts(ctx context.Context) ([]hexutil.Bytes, error) {
+	receipts, err := b.resolveReceipts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]hexutil.Bytes, 0, len(receipts))
+	for _, receipt := range receipts {
+		raw, err := receipt.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, raw)
+	}
+	return ret, nil
+}
+
+func (b *Block) Receipts(ctx context.Context) ([]*Receipt, error) {
+	block, err := b.resolve(ctx)
+	if err != nil || block == nil {
+		return nil, err
+	}
+	receipts, err := b.resolveReceipts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	txs := block.Transactions()
+	if len(receipts) != len(txs) {
+		return nil, fmt.Errorf("receipt count %d doesn't match transaction count %d", len(receipts), len(txs))
+	}
+	ret := make([]*Receipt, 0, len(receipts))
+	for i, receipt := range receipts {
+		ret = append(ret, &Receipt{
+			tx: &Transaction{
+				r:     b.r,
+				hash:  txs[i].Hash(),
+				tx:    txs[i],
+				block: b,
+				index: uint64(i),
+			},
+			receipt: receipt,
+		})
+	}
+	return ret, nil
+}
+
 // BlockNumberArgs encapsulates arguments to accessors that specify a block number.
 type BlockNumberArgs struct {
 	// TODO: Ideally we could use input unions to allow the query to specify the
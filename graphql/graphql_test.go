@@ -84,6 +84,11 @@ func TestGraphQLBlockSerialization(t *testing.T) {
 			want: `{"data":{"block":{"number":0,"gasUsed":0,"gasLimit":11500000}}}`,
 			code: 200,
 		},
+		{
+			body: `{"query": "{block(number:0){number,rawReceipts}}","variables": null}`,
+			want: `{"data":{"block":{"number":0,"rawReceipts":[]}}}`,
+			code: 200,
+		},
 		{
 			body: `{"query": "{block(number:-1){number,gasUsed,gasLimit}}","variables": null}`,
 			want: `{"data":{"block":null}}`,
@@ -177,6 +182,11 @@ func TestGraphQLBlockSerializationEIP2718(t *testing.T) {
 			want: `{"data":{"block":{"number":1,"transactions":[{"from":{"address":"0x71562b71999873db5b286df957af199ec94617f7"},"to":{"address":"0x0000000000000000000000000000000000000dad"},"value":"0x64","hash":"0xd864c9d7d37fade6b70164740540c06dd58bb9c3f6b46101908d6339db6a6a7b","type":0,"accessList":[],"index":0},{"from":{"address":"0x71562b71999873db5b286df957af199ec94617f7"},"to":{"address":"0x0000000000000000000000000000000000000dad"},"value":"0x32","hash":"0x19b35f8187b4e15fb59a9af469dca5dfa3cd363c11d372058c12f6482477b474","type":1,"accessList":[{"address":"0x0000000000000000000000000000000000000dad","storageKeys":["0x0000000000000000000000000000000000000000000000000000000000000000"]}],"index":1}]}}}`,
 			code: 200,
 		},
+		{
+			body: `{"query": "{block {receipts { transactionHash transactionIndex from { address } to { address } gasUsed cumulativeGasUsed contractAddress { address } logs { index } type root status }}}"}`,
+			want: `{"data":{"block":{"receipts":[{"transactionHash":"0xd864c9d7d37fade6b70164740540c06dd58bb9c3f6b46101908d6339db6a6a7b","transactionIndex":0,"from":{"address":"0x71562b71999873db5b286df957af199ec94617f7"},"to":{"address":"0x0000000000000000000000000000000000000dad"},"gasUsed":25204,"cumulativeGasUsed":25204,"contractAddress":null,"logs":[],"type":0,"root":null,"status":1},{"transactionHash":"0x19b35f8187b4e15fb59a9af469dca5dfa3cd363c11d372058c12f6482477b474","transactionIndex":1,"from":{"address":"0x71562b71999873db5b286df957af199ec94617f7"},"to":{"address":"0x0000000000000000000000000000000000000dad"},"gasUsed":27504,"cumulativeGasUsed":52708,"contractAddress":null,"logs":[],"type":1,"root":null,"status":1}]}}}`,
+			code: 200,
+		},
 	} {
 		resp, err := http.Post(fmt.Sprintf("%s/graphql", stack.HTTPEndpoint()), "application/json", strings.NewReader(tt.body))
 		if err != nil {
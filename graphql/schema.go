@@ -149,6 +149,45 @@ const schema string = `
         rawReceipt: Bytes!
     }
 
+    # Receipt is the receipt of a transaction included in a block, carrying the
+    # same fields as eth_getTransactionReceipt.
+    type Receipt {
+        # TransactionHash is the hash of the transaction.
+        transactionHash: Bytes32!
+        # TransactionIndex is the index of the transaction in the block.
+        transactionIndex: Int!
+        # Block is the block the transaction was included in.
+        block: Block!
+        # From is the account that sent the transaction.
+        from(block: Long): Account!
+        # To is the account the transaction was sent to. This is null for
+        # contract-creating transactions.
+        to(block: Long): Account
+        # GasUsed is the amount of gas that was used processing the transaction.
+        gasUsed: Long!
+        # CumulativeGasUsed is the total gas used in the block up to and including
+        # the transaction.
+        cumulativeGasUsed: Long!
+        # EffectiveGasPrice is actual value per gas deducted from the sender's
+        # account.
+        effectiveGasPrice: BigInt!
+        # ContractAddress is the account created by a contract creation
+        # transaction, null otherwise.
+        contractAddress(block: Long): Account
+        # Logs is the list of log entries emitted by the transaction.
+        logs: [Log!]!
+        # LogsBloom is the bloom filter of the logs of the transaction.
+        logsBloom: Bytes!
+        # Type is the envelope type of the transaction.
+        type: Int!
+        # Root is the intermediate state root after the transaction before
+        # Byzantium, null afterwards.
+        root: Bytes32
+        # Status is the return status of the transaction, 1 if it succeeded and
+        # 0 if it failed. This is null before Byzantium.
+        status: Long
+    }
+
     # BlockFilterCriteria encapsulates log filter criteria for a filter applied
     # to a single block.
     input BlockFilterCriteria {
@@ -246,6 +285,12 @@ const schema string = `
         rawHeader: Bytes!
         # Raw is the RLP encoding of the block.
         raw: Bytes!
+        # RawReceipts is the canonical encoding of the receipts of all the
+        # transactions in this block, in the order of the transactions.
+        rawReceipts: [Bytes!]!
+        # Receipts are the receipts of all the transactions in this block, in the
+        # order of the transactions.
+        receipts: [Receipt!]!
     }
 
     # CallData represents the data associated with a local contract call.
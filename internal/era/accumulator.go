@@ -0,0 +1,106 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package era
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/foreverbit/biternal/common"
+)
+
+// accumulatorDepth is the depth of the merkle tree the header records of an
+// archive are accumulated in, fitting exactly MaxSize leaves.
+const accumulatorDepth = 13
+
+// zeroHashes contains the roots of the empty subtrees at every depth of the
+// accumulator tree.
+var zeroHashes = func() [accumulatorDepth + 1][32]byte {
+	var hashes [accumulatorDepth + 1][32]byte
+	for i := 1; i <= accumulatorDepth; i++ {
+		hashes[i] = hashPair(hashes[i-1], hashes[i-1])
+	}
+	return hashes
+}()
+
+func hashPair(a, b [32]byte) [32]byte {
+	return sha256.Sum256(append(a[:], b[:]...))
+}
+
+// ComputeAccumulator calculates the root of the header records of an archive,
+// each record being the pair of a block hash and the total difficulty of the
+// chain up to and including the block.
+//
+// The root is the SSZ hash tree root of a List[HeaderRecord, MaxSize], where a
+// header record is a container of the block hash and the total difficulty as
+// a little endian uint256.
+func ComputeAccumulator(hashes []common.Hash, tds []*big.Int) (common.Hash, error) {
+	if len(hashes) != len(tds) {
+		return common.Hash{}, fmt.Errorf("header record mismatch: %d hashes, %d total difficulties", len(hashes), len(tds))
+	}
+	if len(hashes) > MaxSize {
+		return common.Hash{}, fmt.Errorf("too many header records: %d > %d", len(hashes), MaxSize)
+	}
+	layer := make([][32]byte, 0, len(hashes)+1)
+	for i, hash := range hashes {
+		if tds[i].Sign() < 0 || tds[i].BitLen() > 256 {
+			return common.Hash{}, fmt.Errorf("invalid total difficulty %v", tds[i])
+		}
+		layer = append(layer, hashPair(hash, encodeTD(tds[i])))
+	}
+	root := zeroHashes[accumulatorDepth]
+	if len(layer) > 0 {
+		for depth := 0; depth < accumulatorDepth; depth++ {
+			if len(layer)%2 == 1 {
+				layer = append(layer, zeroHashes[depth])
+			}
+			for i := 0; i < len(layer)/2; i++ {
+				layer[i] = hashPair(layer[2*i], layer[2*i+1])
+			}
+			layer = layer[:len(layer)/2]
+		}
+		root = layer[0]
+	}
+	// Mix in the length of the list
+	var length [32]byte
+	binary.LittleEndian.PutUint64(length[:8], uint64(len(hashes)))
+	return hashPair(root, length), nil
+}
+
+// encodeTD encodes the given total difficulty as a little endian uint256.
+func encodeTD(td *big.Int) [32]byte {
+	var enc [32]byte
+	td.FillBytes(enc[:])
+	for i, j := 0, len(enc)-1; i < j; i, j = i+1, j-1 {
+		enc[i], enc[j] = enc[j], enc[i]
+	}
+	return enc
+}
+
+// decodeTD decodes a little endian uint256 total difficulty.
+func decodeTD(enc []byte) (*big.Int, error) {
+	if len(enc) != 32 {
+		return nil, fmt.Errorf("invalid total difficulty length %d", len(enc))
+	}
+	be := make([]byte, len(enc))
+	for i := range enc {
+		be[len(enc)-1-i] = enc[i]
+	}
+	return new(big.Int).SetBytes(be), nil
+}
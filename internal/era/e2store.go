@@ -0,0 +1,105 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package era
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// headerSize is the size of the type-length header preceding every entry.
+const headerSize = 8
+
+// Entry is a single type-length-value record of an e2store file.
+//
+// The header of an entry is made of a 2 byte type, a 4 byte little endian
+// length of the value and 2 reserved bytes that must be zero.
+type Entry struct {
+	Type  uint16
+	Value []byte
+}
+
+// entryWriter appends entries to an e2store file.
+type entryWriter struct {
+	w io.Writer
+}
+
+// write appends an entry with the given type and value, returning the number
+// of bytes written.
+func (w *entryWriter) write(typ uint16, value []byte) (int, error) {
+	if uint64(len(value)) > uint64(^uint32(0)) {
+		return 0, fmt.Errorf("entry value too large: %d bytes", len(value))
+	}
+	var header [headerSize]byte
+	binary.LittleEndian.PutUint16(header[:2], typ)
+	binary.LittleEndian.PutUint32(header[2:6], uint32(len(value)))
+	if n, err := w.w.Write(header[:]); err != nil {
+		return n, err
+	}
+	n, err := w.w.Write(value)
+	return headerSize + n, err
+}
+
+// entryReader reads entries from an e2store file at arbitrary offsets.
+type entryReader struct {
+	r io.ReaderAt
+}
+
+// readHeader reads the header of the entry at the given offset, returning the
+// entry type and the length of its value.
+func (r *entryReader) readHeader(off int64) (uint16, uint32, error) {
+	var header [headerSize]byte
+	if _, err := r.r.ReadAt(header[:], off); err != nil {
+		return 0, 0, err
+	}
+	if header[6] != 0 || header[7] != 0 {
+		return 0, 0, errors.New("reserved bytes are non-zero")
+	}
+	return binary.LittleEndian.Uint16(header[:2]), binary.LittleEndian.Uint32(header[2:6]), nil
+}
+
+// read reads the entry at the given offset, returning it along with its total
+// size on disk.
+func (r *entryReader) read(off int64) (*Entry, int64, error) {
+	typ, length, err := r.readHeader(off)
+	if err != nil {
+		return nil, 0, err
+	}
+	entry := &Entry{Type: typ, Value: make([]byte, length)}
+	if _, err := r.r.ReadAt(entry.Value, off+headerSize); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, 0, err
+	}
+	return entry, headerSize + int64(length), nil
+}
+
+// readType reads the entry at the given offset and ensures it's of the given
+// type, returning its value.
+func (r *entryReader) readType(off int64, typ uint16) ([]byte, int64, error) {
+	entry, n, err := r.read(off)
+	if err != nil {
+		return nil, 0, err
+	}
+	if entry.Type != typ {
+		return nil, 0, fmt.Errorf("invalid entry type at offset %d: have %#04x, want %#04x", off, entry.Type, typ)
+	}
+	return entry.Value, n, nil
+}
@@ -0,0 +1,445 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package era implements a portable archive format for chain history.
+//
+// An archive is an e2store file holding a contiguous range of at most MaxSize
+// blocks along with their receipts and total difficulties:
+//
+//	archive := Version | block-tuple* | Accumulator | BlockIndex
+//	block-tuple := CompressedHeader | CompressedBody | CompressedReceipts | TotalDifficulty
+//
+// Headers, bodies and receipts are stored RLP encoded and snappy compressed
+// in framed format. The accumulator is the root of the header records of the
+// archive (see ComputeAccumulator), allowing a file to be verified before it's
+// imported. The block index at the end of the file holds the number of the
+// first block and the offsets of all block tuples relative to the start of the
+// index, followed by the number of blocks, enabling random access.
+package era
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/core/types"
+	"github.com/foreverbit/biternal/rlp"
+	"github.com/foreverbit/biternal/trie"
+	"github.com/golang/snappy"
+)
+
+// MaxSize is the maximum number of blocks an archive can contain.
+const MaxSize = 8192
+
+// The list of entry types an archive is made of.
+const (
+	typeVersion            uint16 = 0x3265
+	typeCompressedHeader   uint16 = 0x03
+	typeCompressedBody     uint16 = 0x04
+	typeCompressedReceipts uint16 = 0x05
+	typeTotalDifficulty    uint16 = 0x06
+	typeAccumulator        uint16 = 0x07
+	typeBlockIndex         uint16 = 0x3266
+)
+
+// Filename returns the name of the archive of the given network and epoch,
+// tagged with the first bytes of its accumulator root.
+func Filename(network string, epoch int, root common.Hash) string {
+	return fmt.Sprintf("%s-%05d-%x.era1", network, epoch, root[:4])
+}
+
+// ReadDir returns the names of the archives of the given network in the given
+// directory, ordered by epoch. An error is returned if the epochs aren't
+// consecutive.
+func ReadDir(dir, network string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		names  []string
+		epochs = make(map[string]int)
+	)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".era1" || !strings.HasPrefix(name, network+"-") {
+			continue
+		}
+		parts := strings.Split(strings.TrimSuffix(name, ".era1"), "-")
+		if len(parts) != 3 || parts[0] != network {
+			return nil, fmt.Errorf("malformed archive name %q", name)
+		}
+		epoch, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("malformed archive epoch %q: %v", name, err)
+		}
+		names, epochs[name] = append(names, name), epoch
+	}
+	sort.Slice(names, func(i, j int) bool { return epochs[names[i]] < epochs[names[j]] })
+	for i := 1; i < len(names); i++ {
+		if epochs[names[i]] != epochs[names[i-1]]+1 {
+			return nil, fmt.Errorf("missing archive between epochs %d and %d", epochs[names[i-1]], epochs[names[i]])
+		}
+	}
+	return names, nil
+}
+
+// Builder writes an archive to an output stream.
+type Builder struct {
+	w       entryWriter
+	written uint64
+
+	start   *uint64
+	offsets []uint64
+	hashes  []common.Hash
+	tds     []*big.Int
+
+	buf    *bytes.Buffer
+	snappy *snappy.Writer
+}
+
+// NewBuilder creates a builder writing an archive to the given stream.
+func NewBuilder(w io.Writer) *Builder {
+	buf := new(bytes.Buffer)
+	return &Builder{
+		w:      entryWriter{w: w},
+		buf:    buf,
+		snappy: snappy.NewBufferedWriter(buf),
+	}
+}
+
+// Add appends a block along with its receipts and the total difficulty of the
+// chain up to and including it. Blocks must be added in ascending order.
+func (b *Builder) Add(block *types.Block, receipts types.Receipts, td *big.Int) error {
+	header, err := rlp.EncodeToBytes(block.Header())
+	if err != nil {
+		return err
+	}
+	body, err := rlp.EncodeToBytes(block.Body())
+	if err != nil {
+		return err
+	}
+	rs, err := rlp.EncodeToBytes(receipts)
+	if err != nil {
+		return err
+	}
+	return b.AddRLP(header, body, rs, block.NumberU64(), block.Hash(), td)
+}
+
+// AddRLP appends the RLP encoded header, body and receipts of a block along
+// with the total difficulty of the chain up to and including it. Blocks must be
+// added in ascending order.
+func (b *Builder) AddRLP(header, body, receipts []byte, number uint64, hash common.Hash, td *big.Int) error {
+	if len(b.offsets) >= MaxSize {
+		return fmt.Errorf("archive full: %d blocks", MaxSize)
+	}
+	if b.start == nil {
+		if err := b.write(typeVersion, nil); err != nil {
+			return err
+		}
+		b.start = &number
+	}
+	if want := *b.start + uint64(len(b.offsets)); number != want {
+		return fmt.Errorf("non contiguous block #%d, want #%d", number, want)
+	}
+	if td.Sign() < 0 || td.BitLen() > 256 {
+		return fmt.Errorf("invalid total difficulty %v", td)
+	}
+	b.offsets = append(b.offsets, b.written)
+	b.hashes = append(b.hashes, hash)
+	b.tds = append(b.tds, new(big.Int).Set(td))
+
+	for _, item := range []struct {
+		typ  uint16
+		blob []byte
+	}{
+		{typeCompressedHeader, header},
+		{typeCompressedBody, body},
+		{typeCompressedReceipts, receipts},
+	} {
+		if err := b.writeCompressed(item.typ, item.blob); err != nil {
+			return err
+		}
+	}
+	enc := encodeTD(td)
+	return b.write(typeTotalDifficulty, enc[:])
+}
+
+// Finalize writes the accumulator and the block index of the archive, returning
+// the accumulator root.
+func (b *Builder) Finalize() (common.Hash, error) {
+	if b.start == nil {
+		return common.Hash{}, errors.New("archive is empty")
+	}
+	root, err := ComputeAccumulator(b.hashes, b.tds)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := b.write(typeAccumulator, root[:]); err != nil {
+		return common.Hash{}, err
+	}
+	// Block tuple offsets are relative to the start of the index entry
+	index := make([]byte, 16+8*len(b.offsets))
+	binary.LittleEndian.PutUint64(index, *b.start)
+	for i, offset := range b.offsets {
+		binary.LittleEndian.PutUint64(index[8+8*i:], uint64(int64(offset)-int64(b.written)))
+	}
+	binary.LittleEndian.PutUint64(index[len(index)-8:], uint64(len(b.offsets)))
+	if err := b.write(typeBlockIndex, index); err != nil {
+		return common.Hash{}, err
+	}
+	return root, nil
+}
+
+// write appends an entry to the archive.
+func (b *Builder) write(typ uint16, value []byte) error {
+	n, err := b.w.write(typ, value)
+	b.written += uint64(n)
+	return err
+}
+
+// writeCompressed appends an entry to the archive with its value compressed.
+func (b *Builder) writeCompressed(typ uint16, value []byte) error {
+	b.buf.Reset()
+	b.snappy.Reset(b.buf)
+	if _, err := b.snappy.Write(value); err != nil {
+		return err
+	}
+	if err := b.snappy.Flush(); err != nil {
+		return err
+	}
+	return b.write(typ, b.buf.Bytes())
+}
+
+// ReadAtSeekCloser is the interface an archive is read through.
+type ReadAtSeekCloser interface {
+	io.ReaderAt
+	io.Seeker
+	io.Closer
+}
+
+// Era is a read-only archive providing random access to its blocks.
+type Era struct {
+	f       ReadAtSeekCloser
+	r       entryReader
+	start   uint64
+	offsets []int64 // Absolute offsets of the block tuples
+}
+
+// Open opens the archive at the given path.
+func Open(path string) (*Era, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	e, err := From(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+// From opens an archive from the given stream, taking ownership of it.
+func From(f ReadAtSeekCloser) (*Era, error) {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	r := entryReader{r: f}
+	if _, _, err := r.readType(0, typeVersion); err != nil {
+		return nil, fmt.Errorf("invalid archive version: %v", err)
+	}
+	// Locate the block index from the block count at the end of the file
+	if size < 8 {
+		return nil, errors.New("archive too short")
+	}
+	var count [8]byte
+	if _, err := f.ReadAt(count[:], size-8); err != nil {
+		return nil, err
+	}
+	n := binary.LittleEndian.Uint64(count[:])
+	if n == 0 || n > MaxSize {
+		return nil, fmt.Errorf("invalid archive block count %d", n)
+	}
+	pos := size - headerSize - int64(16+8*n)
+	if pos < 0 {
+		return nil, errors.New("archive too short")
+	}
+	index, _, err := r.readType(pos, typeBlockIndex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid block index: %v", err)
+	}
+	offsets := make([]int64, n)
+	for i := range offsets {
+		offsets[i] = pos + int64(binary.LittleEndian.Uint64(index[8+8*i:]))
+		if offsets[i] <= 0 || offsets[i] >= pos {
+			return nil, fmt.Errorf("invalid offset of block tuple %d", i)
+		}
+	}
+	return &Era{
+		f:       f,
+		r:       r,
+		start:   binary.LittleEndian.Uint64(index),
+		offsets: offsets,
+	}, nil
+}
+
+// Close closes the archive.
+func (e *Era) Close() error {
+	return e.f.Close()
+}
+
+// Start returns the number of the first block in the archive.
+func (e *Era) Start() uint64 {
+	return e.start
+}
+
+// Count returns the number of blocks in the archive.
+func (e *Era) Count() uint64 {
+	return uint64(len(e.offsets))
+}
+
+// Accumulator returns the accumulator root stored in the archive.
+func (e *Era) Accumulator() (common.Hash, error) {
+	// The accumulator follows the last block tuple
+	off := e.offsets[len(e.offsets)-1]
+	for i := 0; i < 4; i++ {
+		_, length, err := e.r.readHeader(off)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		off += headerSize + int64(length)
+	}
+	root, _, err := e.r.readType(off, typeAccumulator)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if len(root) != common.HashLength {
+		return common.Hash{}, fmt.Errorf("invalid accumulator length %d", len(root))
+	}
+	return common.BytesToHash(root), nil
+}
+
+// GetRawTupleByNumber returns the decompressed RLP encoded header, body and
+// receipts of the block with the given number, along with its total difficulty.
+func (e *Era) GetRawTupleByNumber(number uint64) (header, body, receipts []byte, td *big.Int, err error) {
+	if number < e.start || number-e.start >= uint64(len(e.offsets)) {
+		return nil, nil, nil, nil, fmt.Errorf("block #%d out of range [%d, %d]", number, e.start, e.start+uint64(len(e.offsets))-1)
+	}
+	off := e.offsets[number-e.start]
+
+	var blobs [3][]byte
+	for i, typ := range []uint16{typeCompressedHeader, typeCompressedBody, typeCompressedReceipts} {
+		value, n, err := e.r.readType(off, typ)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		if blobs[i], err = io.ReadAll(snappy.NewReader(bytes.NewReader(value))); err != nil {
+			return nil, nil, nil, nil, err
+		}
+		off += n
+	}
+	value, _, err := e.r.readType(off, typeTotalDifficulty)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if td, err = decodeTD(value); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	return blobs[0], blobs[1], blobs[2], td, nil
+}
+
+// GetBlockByNumber returns the block with the given number along with its
+// receipts and total difficulty.
+func (e *Era) GetBlockByNumber(number uint64) (*types.Block, types.Receipts, *big.Int, error) {
+	hblob, bblob, rblob, td, err := e.GetRawTupleByNumber(number)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var (
+		header   types.Header
+		body     types.Body
+		receipts types.Receipts
+	)
+	if err := rlp.DecodeBytes(hblob, &header); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid header of block #%d: %v", number, err)
+	}
+	if err := rlp.DecodeBytes(bblob, &body); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid body of block #%d: %v", number, err)
+	}
+	if err := rlp.DecodeBytes(rblob, &receipts); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid receipts of block #%d: %v", number, err)
+	}
+	if header.Number == nil || header.Number.Uint64() != number {
+		return nil, nil, nil, fmt.Errorf("block number mismatch: have %v, want %d", header.Number, number)
+	}
+	block := types.NewBlockWithHeader(&header).WithBody(body.Transactions, body.Uncles)
+	return block, receipts, td, nil
+}
+
+// Verify checks the integrity of the archive: the accumulator root must match
+// the header records of the blocks, the blocks must be linked and their bodies
+// and receipts must match the roots in their headers.
+func (e *Era) Verify() error {
+	var (
+		hashes = make([]common.Hash, 0, len(e.offsets))
+		tds    = make([]*big.Int, 0, len(e.offsets))
+		parent common.Hash
+		hasher = trie.NewStackTrie(nil)
+	)
+	for number := e.start; number < e.start+e.Count(); number++ {
+		block, receipts, td, err := e.GetBlockByNumber(number)
+		if err != nil {
+			return err
+		}
+		if number > e.start && block.ParentHash() != parent {
+			return fmt.Errorf("block #%d not linked to its parent %x", number, parent)
+		}
+		if hash := types.DeriveSha(block.Transactions(), hasher); hash != block.TxHash() {
+			return fmt.Errorf("transaction root mismatch of block #%d: have %x, want %x", number, hash, block.TxHash())
+		}
+		if hash := types.CalcUncleHash(block.Uncles()); hash != block.UncleHash() {
+			return fmt.Errorf("uncle root mismatch of block #%d: have %x, want %x", number, hash, block.UncleHash())
+		}
+		if hash := types.DeriveSha(receipts, hasher); hash != block.ReceiptHash() {
+			return fmt.Errorf("receipt root mismatch of block #%d: have %x, want %x", number, hash, block.ReceiptHash())
+		}
+		parent = block.Hash()
+		hashes, tds = append(hashes, parent), append(tds, td)
+	}
+	want, err := e.Accumulator()
+	if err != nil {
+		return err
+	}
+	root, err := ComputeAccumulator(hashes, tds)
+	if err != nil {
+		return err
+	}
+	if root != want {
+		return fmt.Errorf("accumulator root mismatch: have %x, want %x", root, want)
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package era
+
+import (
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/core/types"
+	"github.com/foreverbit/biternal/trie"
+)
+
+// makeTestChain creates n linked blocks starting at the given number, each with
+// a single transaction and its receipt.
+func makeTestChain(start uint64, n int) ([]*types.Block, []types.Receipts, []*big.Int) {
+	var (
+		blocks   []*types.Block
+		receipts []types.Receipts
+		tds      []*big.Int
+		parent   common.Hash
+		td       = big.NewInt(0)
+	)
+	for i := 0; i < n; i++ {
+		number := start + uint64(i)
+		tx := types.NewTransaction(number, common.Address{0x01}, big.NewInt(int64(number)), 21000, big.NewInt(1), []byte{byte(i)})
+		receipt := &types.Receipt{
+			Status:            types.ReceiptStatusSuccessful,
+			CumulativeGasUsed: 21000,
+			Logs:              []*types.Log{{Address: common.Address{0x02}, Topics: []common.Hash{{byte(i)}}, Data: []byte{0xff}}},
+		}
+		receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+
+		header := &types.Header{
+			ParentHash: parent,
+			Number:     new(big.Int).SetUint64(number),
+			Difficulty: big.NewInt(131072),
+			GasLimit:   8000000,
+			GasUsed:    21000,
+			Time:       number * 10,
+		}
+		block := types.NewBlock(header, []*types.Transaction{tx}, nil, []*types.Receipt{receipt}, trie.NewStackTrie(nil))
+		td = new(big.Int).Add(td, block.Difficulty())
+
+		blocks, receipts, tds = append(blocks, block), append(receipts, types.Receipts{receipt}), append(tds, td)
+		parent = block.Hash()
+	}
+	return blocks, receipts, tds
+}
+
+// writeTestArchive writes the given blocks into an archive in the given
+// directory, returning its path and accumulator root.
+func writeTestArchive(t *testing.T, dir string, blocks []*types.Block, receipts []types.Receipts, tds []*big.Int) (string, common.Hash) {
+	path := filepath.Join(dir, "test.era1")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create archive: %v", err)
+	}
+	defer f.Close()
+
+	builder := NewBuilder(f)
+	for i, block := range blocks {
+		if err := builder.Add(block, receipts[i], tds[i]); err != nil {
+			t.Fatalf("failed to add block #%d: %v", block.NumberU64(), err)
+		}
+	}
+	root, err := builder.Finalize()
+	if err != nil {
+		t.Fatalf("failed to finalize archive: %v", err)
+	}
+	return path, root
+}
+
+// Tests that blocks written into an archive can be read back at random and
+// that the archive verifies.
+func TestArchiveRoundtrip(t *testing.T) {
+	blocks, receipts, tds := makeTestChain(1000, 128)
+	path, root := writeTestArchive(t, t.TempDir(), blocks, receipts, tds)
+
+	e, err := Open(path)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer e.Close()
+
+	if e.Start() != 1000 || e.Count() != 128 {
+		t.Fatalf("range mismatch: have [%d, +%d], want [1000, +128]", e.Start(), e.Count())
+	}
+	if have, err := e.Accumulator(); err != nil || have != root {
+		t.Fatalf("accumulator mismatch: have %x, want %x (err %v)", have, root, err)
+	}
+	for _, i := range []int{127, 0, 64, 3} {
+		block, rs, td, err := e.GetBlockByNumber(blocks[i].NumberU64())
+		if err != nil {
+			t.Fatalf("failed to read block #%d: %v", blocks[i].NumberU64(), err)
+		}
+		if block.Hash() != blocks[i].Hash() {
+			t.Errorf("block #%d hash mismatch: have %x, want %x", blocks[i].NumberU64(), block.Hash(), blocks[i].Hash())
+		}
+		if block.Transactions()[0].Hash() != blocks[i].Transactions()[0].Hash() {
+			t.Errorf("block #%d transaction mismatch", blocks[i].NumberU64())
+		}
+		if !reflect.DeepEqual(rs[0].Logs[0].Topics, receipts[i][0].Logs[0].Topics) || rs[0].Bloom != receipts[i][0].Bloom {
+			t.Errorf("block #%d receipt mismatch", blocks[i].NumberU64())
+		}
+		if td.Cmp(tds[i]) != 0 {
+			t.Errorf("block #%d total difficulty mismatch: have %v, want %v", blocks[i].NumberU64(), td, tds[i])
+		}
+	}
+	if _, _, _, err := e.GetBlockByNumber(999); err == nil {
+		t.Errorf("out of range block retrieved")
+	}
+	if _, _, _, err := e.GetBlockByNumber(1128); err == nil {
+		t.Errorf("out of range block retrieved")
+	}
+	if err := e.Verify(); err != nil {
+		t.Fatalf("failed to verify archive: %v", err)
+	}
+}
+
+// Tests that an archive with tampered content fails verification.
+func TestArchiveVerify(t *testing.T) {
+	blocks, receipts, tds := makeTestChain(0, 16)
+
+	// Tamper with the total difficulty of a block
+	tampered := append([]*big.Int{}, tds...)
+	tampered[7] = new(big.Int).Add(tds[7], common.Big1)
+	path, _ := writeTestArchive(t, t.TempDir(), blocks, receipts, tds)
+
+	root, err := ComputeAccumulator(hashesOf(blocks), tampered)
+	if err != nil {
+		t.Fatalf("failed to compute accumulator: %v", err)
+	}
+	e, err := Open(path)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	if have, _ := e.Accumulator(); have == root {
+		t.Fatalf("accumulator insensitive to total difficulty")
+	}
+	e.Close()
+
+	// Swap the receipts of two blocks
+	swapped := append([]types.Receipts{}, receipts...)
+	swapped[3], swapped[4] = swapped[4], swapped[3]
+	path, _ = writeTestArchive(t, t.TempDir(), blocks, swapped, tds)
+	if e, err = Open(path); err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	if err := e.Verify(); err == nil {
+		t.Fatalf("archive with mismatching receipts verified")
+	}
+	e.Close()
+
+	// Corrupt the accumulator root at the end of the file
+	path, root = writeTestArchive(t, t.TempDir(), blocks, receipts, tds)
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read archive: %v", err)
+	}
+	pos := len(blob) - headerSize - (16 + 8*len(blocks)) - common.HashLength
+	if common.BytesToHash(blob[pos:pos+common.HashLength]) != root {
+		t.Fatalf("accumulator not found at expected position")
+	}
+	blob[pos] ^= 0xff
+	if err := os.WriteFile(path, blob, 0644); err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+	if e, err = Open(path); err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer e.Close()
+	if err := e.Verify(); err == nil {
+		t.Fatalf("archive with corrupted accumulator verified")
+	}
+}
+
+// Tests that the builder rejects non contiguous and oversized block ranges.
+func TestBuilderLimits(t *testing.T) {
+	blocks, receipts, tds := makeTestChain(0, 3)
+
+	builder := NewBuilder(io.Discard)
+	if _, err := builder.Finalize(); err == nil {
+		t.Fatalf("empty archive finalized")
+	}
+	if err := builder.Add(blocks[0], receipts[0], tds[0]); err != nil {
+		t.Fatalf("failed to add block: %v", err)
+	}
+	if err := builder.Add(blocks[2], receipts[2], tds[2]); err == nil {
+		t.Fatalf("non contiguous block added")
+	}
+	builder = NewBuilder(io.Discard)
+	for i := 0; i < MaxSize; i++ {
+		if err := builder.AddRLP(nil, nil, nil, uint64(i), common.Hash{}, common.Big0); err != nil {
+			t.Fatalf("failed to add block #%d: %v", i, err)
+		}
+	}
+	if err := builder.AddRLP(nil, nil, nil, MaxSize, common.Hash{}, common.Big0); err == nil {
+		t.Fatalf("block added to full archive")
+	}
+}
+
+// Tests the accumulator root against known values.
+func TestComputeAccumulator(t *testing.T) {
+	empty, err := ComputeAccumulator(nil, nil)
+	if err != nil {
+		t.Fatalf("failed to compute empty accumulator: %v", err)
+	}
+	// hash_tree_root of an empty list is the zero subtree root mixed in with zero
+	if want := common.Hash(hashPair(zeroHashes[accumulatorDepth], [32]byte{})); empty != want {
+		t.Fatalf("empty accumulator mismatch: have %x, want %x", empty, want)
+	}
+	hash, td := common.Hash{0x01}, big.NewInt(0x0102)
+	single, err := ComputeAccumulator([]common.Hash{hash}, []*big.Int{td})
+	if err != nil {
+		t.Fatalf("failed to compute accumulator: %v", err)
+	}
+	var enc [32]byte
+	enc[0], enc[1] = 0x02, 0x01
+	node := hashPair(hash, enc)
+	for depth := 0; depth < accumulatorDepth; depth++ {
+		node = hashPair(node, zeroHashes[depth])
+	}
+	var length [32]byte
+	length[0] = 1
+	if want := common.Hash(hashPair(node, length)); single != want {
+		t.Fatalf("single accumulator mismatch: have %x, want %x", single, want)
+	}
+	if _, err := ComputeAccumulator(make([]common.Hash, MaxSize+1), make([]*big.Int, MaxSize+1)); err == nil {
+		t.Fatalf("oversized accumulator computed")
+	}
+}
+
+// Tests that archives are listed in epoch order and gaps are detected.
+func TestReadDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"mainnet-00002-aabbccdd.era1", "mainnet-00000-00112233.era1", "mainnet-00001-44556677.era1", "goerli-00000-8899aabb.era1", "checksums.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names, err := ReadDir(dir, "mainnet")
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+	want := []string{"mainnet-00000-00112233.era1", "mainnet-00001-44556677.era1", "mainnet-00002-aabbccdd.era1"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("archive list mismatch: have %v, want %v", names, want)
+	}
+	os.Remove(filepath.Join(dir, "mainnet-00001-44556677.era1"))
+	if _, err := ReadDir(dir, "mainnet"); err == nil {
+		t.Fatalf("missing epoch not detected")
+	}
+	if name := Filename("mainnet", 3, common.Hash{0xde, 0xad, 0xbe, 0xef, 0x01}); name != "mainnet-00003-deadbeef.era1" {
+		t.Fatalf("filename mismatch: have %s", name)
+	}
+}
+
+func hashesOf(blocks []*types.Block) []common.Hash {
+	hashes := make([]common.Hash, len(blocks))
+	for i, block := range blocks {
+		hashes[i] = block.Hash()
+	}
+	return hashes
+}
@@ -703,6 +703,111 @@ func (s *BlockChainAPI) GetProof(ctx context.Context, address common.Address, st
 	}, state.Error()
 }
 
+// ProofRequest is an account along with some of its storage keys to prove with
+// GetMultiProof.
+type ProofRequest struct {
+	Address     common.Address `json:"address"`
+	StorageKeys []string       `json:"storageKeys"`
+}
+
+// Result structs for GetMultiProof
+type MultiProofResult struct {
+	AccountProof []string             `json:"accountProof"`
+	Accounts     []MultiAccountResult `json:"accounts"`
+}
+
+type MultiAccountResult struct {
+	Address      common.Address       `json:"address"`
+	Balance      *hexutil.Big         `json:"balance"`
+	CodeHash     common.Hash          `json:"codeHash"`
+	Nonce        hexutil.Uint64       `json:"nonce"`
+	StorageHash  common.Hash          `json:"storageHash"`
+	StorageProof []string             `json:"storageProof"`
+	Storage      []StorageValueResult `json:"storage"`
+}
+
+type StorageValueResult struct {
+	Key   string       `json:"key"`
+	Value *hexutil.Big `json:"value"`
+}
+
+// maxMultiProofKeys is the maximum number of accounts and storage keys that can
+// be proven in a single GetMultiProof request.
+const maxMultiProofKeys = 1024
+
+// GetMultiProof returns the Merkle-proofs for a batch of accounts and some of
+// their storage keys. Instead of a proof per key, the proof nodes are shared:
+// a single deduplicated node list proves all accounts, and another one per
+// account proves all of its requested storage keys.
+func (s *BlockChainAPI) GetMultiProof(ctx context.Context, requests []ProofRequest, blockNrOrHash rpc.BlockNumberOrHash) (*MultiProofResult, error) {
+	count := len(requests)
+	for _, req := range requests {
+		count += len(req.StorageKeys)
+	}
+	if count > maxMultiProofKeys {
+		return nil, fmt.Errorf("too many keys to prove: have %d, max %d", count, maxMultiProofKeys)
+	}
+	state, _, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	var (
+		addrs    = make([]common.Address, len(requests))
+		accounts = make([]MultiAccountResult, len(requests))
+	)
+	for i, req := range requests {
+		addrs[i] = req.Address
+
+		storageTrie := state.StorageTrie(req.Address)
+		storageHash := types.EmptyRootHash
+		codeHash := state.GetCodeHash(req.Address)
+		storageProof := []string{}
+		storage := make([]StorageValueResult, len(req.StorageKeys))
+
+		// if we have a storageTrie, (which means the account exists), we can update the storagehash
+		if storageTrie != nil {
+			storageHash = storageTrie.Hash()
+
+			keys := make([]common.Hash, len(req.StorageKeys))
+			for j, key := range req.StorageKeys {
+				keys[j] = common.HexToHash(key)
+			}
+			proof, err := state.GetStorageMultiProof(req.Address, keys)
+			if err != nil {
+				return nil, err
+			}
+			storageProof = toHexSlice(proof)
+			for j, key := range keys {
+				storage[j] = StorageValueResult{req.StorageKeys[j], (*hexutil.Big)(state.GetState(req.Address, key).Big())}
+			}
+		} else {
+			// no storageTrie means the account does not exist, so the codeHash is the hash of an empty bytearray.
+			codeHash = crypto.Keccak256Hash(nil)
+			for j, key := range req.StorageKeys {
+				storage[j] = StorageValueResult{key, &hexutil.Big{}}
+			}
+		}
+		accounts[i] = MultiAccountResult{
+			Address:      req.Address,
+			Balance:      (*hexutil.Big)(state.GetBalance(req.Address)),
+			CodeHash:     codeHash,
+			Nonce:        hexutil.Uint64(state.GetNonce(req.Address)),
+			StorageHash:  storageHash,
+			StorageProof: storageProof,
+			Storage:      storage,
+		}
+	}
+	// create the shared accountProof
+	accountProof, err := state.GetMultiProof(addrs)
+	if err != nil {
+		return nil, err
+	}
+	return &MultiProofResult{
+		AccountProof: toHexSlice(accountProof),
+		Accounts:     accounts,
+	}, state.Error()
+}
+
 // GetHeaderByNumber returns the requested canonical block header.
 // * When blockNr is -1 the chain head is returned.
 // * When blockNr is -2 the pending chain head is returned.
@@ -1258,6 +1363,7 @@ type RPCTransaction struct {
 	Type             hexutil.Uint64    `json:"type"`
 	Accesses         *types.AccessList `json:"accessList,omitempty"`
 	ChainID          *hexutil.Big      `json:"chainId,omitempty"`
+	At               *hexutil.Uint64   `json:"at,omitempty"`
 	V                *hexutil.Big      `json:"v"`
 	R                *hexutil.Big      `json:"r"`
 	S                *hexutil.Big      `json:"s"`
@@ -1298,12 +1404,16 @@ func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber
 		al := tx.AccessList()
 		result.Accesses = &al
 		result.ChainID = (*hexutil.Big)(tx.ChainId())
-	case types.DynamicFeeTxType:
+	case types.DynamicFeeTxType, types.ReserveTxType:
 		al := tx.AccessList()
 		result.Accesses = &al
 		result.ChainID = (*hexutil.Big)(tx.ChainId())
 		result.GasFeeCap = (*hexutil.Big)(tx.GasFeeCap())
 		result.GasTipCap = (*hexutil.Big)(tx.GasTipCap())
+		if tx.IsReserve() {
+			at := tx.At()
+			result.At = (*hexutil.Uint64)(&at)
+		}
 		// if the transaction has been mined, compute the effective gas price
 		if baseFee != nil && blockHash != (common.Hash{}) {
 			// price = min(tip, gasFeeCap - baseFee) + baseFee
@@ -1593,13 +1703,64 @@ func (s *TransactionAPI) GetTransactionReceipt(ctx context.Context, hash common.
 	// Derive the sender.
 	bigblock := new(big.Int).SetUint64(blockNumber)
 	signer := types.MakeSigner(s.b.ChainConfig(), bigblock)
+
+	var baseFee *big.Int
+	if s.b.ChainConfig().IsLondon(bigblock) {
+		header, err := s.b.HeaderByHash(ctx, blockHash)
+		if err != nil {
+			return nil, err
+		}
+		baseFee = header.BaseFee
+	}
+	return marshalReceipt(receipt, blockHash, blockNumber, signer, tx, int(index), baseFee), nil
+}
+
+// GetBlockReceipts returns the receipts of all the transactions in the given block.
+func (s *TransactionAPI) GetBlockReceipts(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) ([]map[string]interface{}, error) {
+	var (
+		block    *types.Block
+		receipts types.Receipts
+		err      error
+	)
+	if number, ok := blockNrOrHash.Number(); ok && number == rpc.PendingBlockNumber {
+		block, receipts = s.b.PendingBlockAndReceipts()
+	} else {
+		block, err = s.b.BlockByNumberOrHash(ctx, blockNrOrHash)
+		if block == nil || err != nil {
+			return nil, err
+		}
+		receipts, err = s.b.GetReceipts(ctx, block.Hash())
+		if err != nil {
+			return nil, err
+		}
+	}
+	if block == nil {
+		return nil, nil
+	}
+	txs := block.Transactions()
+	if len(txs) != len(receipts) {
+		return nil, fmt.Errorf("receipts length mismatch: %d vs %d", len(txs), len(receipts))
+	}
+	// Derive the sender.
+	signer := types.MakeSigner(s.b.ChainConfig(), block.Number())
+
+	result := make([]map[string]interface{}, len(receipts))
+	for i, receipt := range receipts {
+		result[i] = marshalReceipt(receipt, block.Hash(), block.NumberU64(), signer, txs[i], i, block.BaseFee())
+	}
+	return result, nil
+}
+
+// marshalReceipt marshals a transaction receipt into a JSON object. The base fee
+// is nil before the London fork.
+func marshalReceipt(receipt *types.Receipt, blockHash common.Hash, blockNumber uint64, signer types.Signer, tx *types.Transaction, txIndex int, baseFee *big.Int) map[string]interface{} {
 	from, _ := types.Sender(signer, tx)
 
 	fields := map[string]interface{}{
 		"blockHash":         blockHash,
 		"blockNumber":       hexutil.Uint64(blockNumber),
-		"transactionHash":   hash,
-		"transactionIndex":  hexutil.Uint64(index),
+		"transactionHash":   tx.Hash(),
+		"transactionIndex":  hexutil.Uint64(txIndex),
 		"from":              from,
 		"to":                tx.To(),
 		"gasUsed":           hexutil.Uint64(receipt.GasUsed),
@@ -1610,14 +1771,10 @@ func (s *TransactionAPI) GetTransactionReceipt(ctx context.Context, hash common.
 		"type":              hexutil.Uint(tx.Type()),
 	}
 	// Assign the effective gas price paid
-	if !s.b.ChainConfig().IsLondon(bigblock) {
+	if baseFee == nil {
 		fields["effectiveGasPrice"] = hexutil.Uint64(tx.GasPrice().Uint64())
 	} else {
-		header, err := s.b.HeaderByHash(ctx, blockHash)
-		if err != nil {
-			return nil, err
-		}
-		gasPrice := new(big.Int).Add(header.BaseFee, tx.EffectiveGasTipValue(header.BaseFee))
+		gasPrice := new(big.Int).Add(baseFee, tx.EffectiveGasTipValue(baseFee))
 		fields["effectiveGasPrice"] = hexutil.Uint64(gasPrice.Uint64())
 	}
 	// Assign receipt status or post state.
@@ -1633,7 +1790,7 @@ func (s *TransactionAPI) GetTransactionReceipt(ctx context.Context, hash common.
 	if receipt.ContractAddress != (common.Address{}) {
 		fields["contractAddress"] = receipt.ContractAddress
 	}
-	return fields, nil
+	return fields
 }
 
 // sign is a helper function that signs a transaction with the private key of the given address.
@@ -1738,6 +1895,42 @@ func (s *TransactionAPI) SendRawTransaction(ctx context.Context, input hexutil.B
 	return SubmitTransaction(ctx, s.b, tx)
 }
 
+// SendBundleArgs represents the arguments to submit a bundle of transactions.
+type SendBundleArgs struct {
+	Txs         []hexutil.Bytes `json:"txs"`
+	BlockNumber *hexutil.Uint64 `json:"blockNumber"`
+}
+
+// SendBundle will add the signed transactions to the bundle pool. The transactions
+// are either included together and in the given order in the target block, or not
+// at all. If no block number is specified, the bundle targets the next block.
+func (s *TransactionAPI) SendBundle(ctx context.Context, args SendBundleArgs) (common.Hash, error) {
+	txs := make(types.Transactions, len(args.Txs))
+	for i, input := range args.Txs {
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(input); err != nil {
+			return common.Hash{}, fmt.Errorf("transaction %d: %w", i, err)
+		}
+		if err := checkTxFee(tx.GasPrice(), tx.Gas(), s.b.RPCTxFeeCap()); err != nil {
+			return common.Hash{}, fmt.Errorf("transaction %d: %w", i, err)
+		}
+		if !s.b.UnprotectedAllowed() && !tx.Protected() {
+			return common.Hash{}, fmt.Errorf("transaction %d: only replay-protected (EIP-155) transactions allowed over RPC", i)
+		}
+		txs[i] = tx
+	}
+	number := s.b.CurrentBlock().NumberU64() + 1
+	if args.BlockNumber != nil {
+		number = uint64(*args.BlockNumber)
+	}
+	bundle := core.NewBundle(txs, number)
+	if err := s.b.SendBundle(ctx, bundle); err != nil {
+		return common.Hash{}, err
+	}
+	log.Info("Submitted bundle", "hash", bundle.Hash(), "block", number, "txs", len(txs))
+	return bundle.Hash(), nil
+}
+
 // Sign calculates an ECDSA signature for:
 // keccak256("\x19Ethereum Signed Message:\n" + len(message) + message).
 //
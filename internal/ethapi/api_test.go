@@ -0,0 +1,34 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"testing"
+
+	"github.com/foreverbit/biternal/rpc"
+)
+
+// Tests that multiproof requests for too many keys are rejected before the
+// state is accessed.
+func TestGetMultiProofKeyLimit(t *testing.T) {
+	api := NewBlockChainAPI(nil)
+	requests := []ProofRequest{{StorageKeys: make([]string, maxMultiProofKeys)}}
+	if _, err := api.GetMultiProof(context.Background(), requests, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)); err == nil {
+		t.Fatal("oversized multiproof request accepted")
+	}
+}
@@ -0,0 +1,288 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/common/hexutil"
+	"github.com/foreverbit/biternal/consensus/misc"
+	"github.com/foreverbit/biternal/core"
+	"github.com/foreverbit/biternal/core/state"
+	"github.com/foreverbit/biternal/core/types"
+	"github.com/foreverbit/biternal/core/vm"
+	"github.com/foreverbit/biternal/log"
+	"github.com/foreverbit/biternal/rpc"
+)
+
+// maxSimulateBlocks is the maximum number of blocks that can be simulated in a
+// single request.
+const maxSimulateBlocks = 256
+
+// SimulatedBlock is a batch of calls to execute one after the other in the same
+// simulated block, on top of the state left behind by the previous ones.
+type SimulatedBlock struct {
+	BlockOverrides *BlockOverrides   `json:"blockOverrides"`
+	StateOverrides *StateOverride    `json:"stateOverrides"`
+	Calls          []TransactionArgs `json:"calls"`
+}
+
+// simCallResult is the outcome of a single simulated call.
+type simCallResult struct {
+	ReturnValue hexutil.Bytes  `json:"returnData"`
+	Logs        []*types.Log   `json:"logs"`
+	GasUsed     hexutil.Uint64 `json:"gasUsed"`
+	Status      hexutil.Uint64 `json:"status"`
+	Error       string         `json:"error,omitempty"`
+}
+
+// simBlockResult is the outcome of a simulated block.
+type simBlockResult struct {
+	Number       hexutil.Uint64   `json:"number"`
+	Hash         common.Hash      `json:"hash"`
+	Timestamp    hexutil.Uint64   `json:"timestamp"`
+	GasLimit     hexutil.Uint64   `json:"gasLimit"`
+	GasUsed      hexutil.Uint64   `json:"gasUsed"`
+	FeeRecipient common.Address   `json:"feeRecipient"`
+	BaseFee      *hexutil.Big     `json:"baseFeePerGas,omitempty"`
+	Calls        []*simCallResult `json:"calls"`
+}
+
+// Simulate executes a sequence of simulated blocks, each with its own block and
+// state overrides and list of calls, on top of the state of the given block. The
+// state modifications of every call carry over to the following calls and blocks.
+//
+// Note, the simulated blocks are not part of the chain, so the BLOCKHASH opcode
+// cannot resolve their hashes.
+func (s *BlockChainAPI) Simulate(ctx context.Context, blocks []SimulatedBlock, blockNrOrHash *rpc.BlockNumberOrHash) ([]*simBlockResult, error) {
+	bNrOrHash := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+	if blockNrOrHash != nil {
+		bNrOrHash = *blockNrOrHash
+	}
+	return DoSimulate(ctx, s.b, blocks, bNrOrHash, s.b.RPCEVMTimeout(), s.b.RPCGasCap())
+}
+
+// DoSimulate executes the simulated blocks on top of the given block. The timeout
+// applies to the whole simulation and the gas cap to the sum of all the calls.
+func DoSimulate(ctx context.Context, b Backend, blocks []SimulatedBlock, blockNrOrHash rpc.BlockNumberOrHash, timeout time.Duration, globalGasCap uint64) ([]*simBlockResult, error) {
+	defer func(start time.Time) { log.Debug("Executing simulated blocks finished", "runtime", time.Since(start)) }(time.Now())
+
+	if len(blocks) == 0 {
+		return nil, errors.New("no blocks to simulate")
+	}
+	if len(blocks) > maxSimulateBlocks {
+		return nil, fmt.Errorf("too many blocks to simulate: have %d, max %d", len(blocks), maxSimulateBlocks)
+	}
+	state, parent, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	// Setup context so it may be cancelled the simulation has completed
+	// or, in case of unmetered gas, setup a context with a timeout.
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	// Make sure the context is cancelled when the simulation has completed
+	// this makes sure resources are cleaned up.
+	defer cancel()
+
+	var (
+		results = make([]*simBlockResult, 0, len(blocks))
+		gasCap  = globalGasCap
+	)
+	for i, block := range blocks {
+		header, err := makeSimulatedHeader(b, parent, block.BlockOverrides)
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %w", i, err)
+		}
+		if err := block.StateOverrides.Apply(state); err != nil {
+			return nil, fmt.Errorf("block %d: %w", i, err)
+		}
+		var (
+			calls = make([]*simCallResult, 0, len(block.Calls))
+			gp    = new(core.GasPool).AddGas(header.GasLimit)
+		)
+		for j, args := range block.Calls {
+			if globalGasCap != 0 && gasCap == 0 {
+				return nil, fmt.Errorf("block %d call %d: gas cap %d exhausted", i, j, globalGasCap)
+			}
+			result, logs, err := applySimulatedCall(ctx, b, state, header, gp, args, j, gasCap)
+			if err != nil {
+				return nil, fmt.Errorf("block %d call %d: %w", i, j, err)
+			}
+			if globalGasCap != 0 {
+				gasCap -= result.UsedGas
+			}
+			header.GasUsed += result.UsedGas
+
+			call := &simCallResult{
+				ReturnValue: result.Return(),
+				Logs:        logs,
+				GasUsed:     hexutil.Uint64(result.UsedGas),
+				Status:      hexutil.Uint64(types.ReceiptStatusSuccessful),
+			}
+			if result.Failed() {
+				call.Status = hexutil.Uint64(types.ReceiptStatusFailed)
+				call.Error = result.Err.Error()
+				if len(result.Revert()) > 0 {
+					call.ReturnValue = result.Revert()
+					call.Error = newRevertError(result).Error()
+				}
+			}
+			calls = append(calls, call)
+		}
+		// The hash of the block is only known after all the calls executed
+		hash := header.Hash()
+		for _, call := range calls {
+			for _, l := range call.Logs {
+				l.BlockHash = hash
+			}
+		}
+		result := &simBlockResult{
+			Number:       hexutil.Uint64(header.Number.Uint64()),
+			Hash:         hash,
+			Timestamp:    hexutil.Uint64(header.Time),
+			GasLimit:     hexutil.Uint64(header.GasLimit),
+			GasUsed:      hexutil.Uint64(header.GasUsed),
+			FeeRecipient: header.Coinbase,
+			Calls:        calls,
+		}
+		if header.BaseFee != nil {
+			result.BaseFee = (*hexutil.Big)(header.BaseFee)
+		}
+		results = append(results, result)
+		parent = header
+	}
+	return results, nil
+}
+
+// makeSimulatedHeader creates the header of the simulated block following the
+// given parent, with the requested overrides applied.
+func makeSimulatedHeader(b Backend, parent *types.Header, overrides *BlockOverrides) (*types.Header, error) {
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Coinbase:   parent.Coinbase,
+		Difficulty: parent.Difficulty,
+		Number:     new(big.Int).Add(parent.Number, common.Big1),
+		GasLimit:   parent.GasLimit,
+		Time:       parent.Time + 1,
+		MixDigest:  parent.MixDigest,
+	}
+	if b.ChainConfig().IsLondon(header.Number) {
+		header.BaseFee = misc.CalcBaseFee(b.ChainConfig(), parent)
+	}
+	if overrides == nil {
+		return header, nil
+	}
+	if overrides.Number != nil {
+		if overrides.Number.ToInt().Cmp(parent.Number) <= 0 {
+			return nil, fmt.Errorf("block number %v not after parent %v", overrides.Number.ToInt(), parent.Number)
+		}
+		header.Number = overrides.Number.ToInt()
+	}
+	if overrides.Difficulty != nil {
+		header.Difficulty = overrides.Difficulty.ToInt()
+	}
+	if overrides.Time != nil {
+		if !overrides.Time.ToInt().IsUint64() || overrides.Time.ToInt().Uint64() <= parent.Time {
+			return nil, fmt.Errorf("block timestamp %v not after parent %d", overrides.Time.ToInt(), parent.Time)
+		}
+		header.Time = overrides.Time.ToInt().Uint64()
+	}
+	if overrides.GasLimit != nil {
+		header.GasLimit = uint64(*overrides.GasLimit)
+	}
+	if overrides.Coinbase != nil {
+		header.Coinbase = *overrides.Coinbase
+	}
+	if overrides.Random != nil {
+		header.MixDigest = *overrides.Random
+	}
+	if overrides.BaseFee != nil {
+		header.BaseFee = overrides.BaseFee.ToInt()
+	}
+	return header, nil
+}
+
+// applySimulatedCall executes a single call of a simulated block, leaving the
+// resulting state modifications in place. The gas of the call is charged to the
+// gas pool of the block, calls without gas limit default to the gas left.
+func applySimulatedCall(ctx context.Context, b Backend, state *state.StateDB, header *types.Header, gp *core.GasPool, args TransactionArgs, index int, gasCap uint64) (*core.ExecutionResult, []*types.Log, error) {
+	if args.Gas == nil {
+		gas := hexutil.Uint64(gp.Gas())
+		args.Gas = &gas
+	}
+	msg, err := args.ToMessage(gasCap, header.BaseFee)
+	if err != nil {
+		return nil, nil, err
+	}
+	if msg.Gas() > gp.Gas() {
+		return nil, nil, fmt.Errorf("%w: call gas %d, block gas left %d of %d", core.ErrGasLimitReached, msg.Gas(), gp.Gas(), header.GasLimit)
+	}
+	// Calls have no signature, identify them by their unsigned transaction hash
+	hash := types.NewTx(&types.LegacyTx{
+		Nonce:    msg.Nonce(),
+		GasPrice: msg.GasPrice(),
+		Gas:      msg.Gas(),
+		To:       msg.To(),
+		Value:    msg.Value(),
+		Data:     msg.Data(),
+	}).Hash()
+	state.Prepare(hash, index)
+	logs := len(state.GetLogs(hash, common.Hash{})) // Identical calls share the hash
+
+	evm, vmError, err := b.GetEVM(ctx, msg, state, header, &vm.Config{NoBaseFee: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	// Wait for the context to be done and cancel the evm. Even if the
+	// EVM has finished, cancelling may be done (repeatedly)
+	go func() {
+		<-ctx.Done()
+		evm.Cancel()
+	}()
+
+	result, err := core.ApplyMessage(evm, msg, gp)
+	if err := vmError(); err != nil {
+		return nil, nil, err
+	}
+	// If the timer caused an abort, return an appropriate error message
+	if evm.Cancelled() {
+		return nil, nil, errors.New("execution aborted (timeout)")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("err: %w (supplied gas %d)", err, msg.Gas())
+	}
+	state.Finalise(b.ChainConfig().IsEIP158(header.Number))
+
+	// Copy the logs of the call, the state shares them with all identical calls
+	created := state.GetLogs(hash, common.Hash{})[logs:]
+	copied := make([]*types.Log, 0, len(created))
+	for _, l := range created {
+		cpy := *l
+		copied = append(copied, &cpy)
+	}
+	return result, copied, nil
+}
@@ -0,0 +1,233 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/common/hexutil"
+	"github.com/foreverbit/biternal/core"
+	"github.com/foreverbit/biternal/core/rawdb"
+	"github.com/foreverbit/biternal/core/state"
+	"github.com/foreverbit/biternal/core/types"
+	"github.com/foreverbit/biternal/core/vm"
+	"github.com/foreverbit/biternal/ethdb"
+	"github.com/foreverbit/biternal/params"
+	"github.com/foreverbit/biternal/rpc"
+)
+
+var (
+	// simCounter is a contract incrementing its first storage slot, logging and
+	// returning the new value.
+	simCounter     = common.Address{0xc0}
+	simCounterCode = common.FromHex("0x600054600101806000556000526020600060206000a0f3")
+
+	// simReverter is a contract reverting with a single word of data.
+	simReverter     = common.Address{0xee}
+	simReverterCode = common.FromHex("0x602a60005260206000fd")
+)
+
+// simBackend is a backend serving the state of its genesis block, for running
+// simulations on top of it.
+type simBackend struct {
+	*backendMock
+	db      ethdb.Database
+	genesis *types.Block
+}
+
+func newSimBackend() *simBackend {
+	db := rawdb.NewMemoryDatabase()
+	gspec := &core.Genesis{
+		Config:   params.TestChainConfig,
+		GasLimit: 30_000_000,
+		BaseFee:  big.NewInt(params.InitialBaseFee),
+		Alloc: core.GenesisAlloc{
+			simCounter:  {Code: simCounterCode, Balance: common.Big0},
+			simReverter: {Code: simReverterCode, Balance: common.Big0},
+		},
+	}
+	return &simBackend{backendMock: newBackendMock(), db: db, genesis: gspec.MustCommit(db)}
+}
+
+func (b *simBackend) ChainConfig() *params.ChainConfig { return params.TestChainConfig }
+
+func (b *simBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error) {
+	statedb, err := state.New(b.genesis.Root(), state.NewDatabase(b.db), nil)
+	return statedb, b.genesis.Header(), err
+}
+
+func (b *simBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmConfig *vm.Config) (*vm.EVM, func() error, error) {
+	context := core.NewEVMBlockContext(header, nil, &header.Coinbase)
+	return vm.NewEVM(context, core.NewEVMTxContext(msg), state, b.ChainConfig(), *vmConfig), func() error { return nil }, nil
+}
+
+// TestMakeSimulatedHeader tests that simulated headers follow their parent and
+// honour the block overrides.
+func TestMakeSimulatedHeader(t *testing.T) {
+	b := newBackendMock()
+	b.activateLondon()
+	parent := b.CurrentHeader()
+
+	// Without overrides the header should simply follow its parent
+	header, err := makeSimulatedHeader(b, parent, nil)
+	if err != nil {
+		t.Fatalf("failed to make header: %v", err)
+	}
+	if header.ParentHash != parent.Hash() {
+		t.Errorf("parent hash mismatch: have %x, want %x", header.ParentHash, parent.Hash())
+	}
+	if want := new(big.Int).Add(parent.Number, common.Big1); header.Number.Cmp(want) != 0 {
+		t.Errorf("number mismatch: have %v, want %v", header.Number, want)
+	}
+	if header.Time != parent.Time+1 {
+		t.Errorf("timestamp mismatch: have %d, want %d", header.Time, parent.Time+1)
+	}
+	if header.BaseFee == nil {
+		t.Errorf("base fee missing after london")
+	}
+	// Overrides should be applied, as long as the block is after its parent
+	var (
+		number   = hexutil.Big(*big.NewInt(2000))
+		time     = hexutil.Big(*big.NewInt(1000))
+		coinbase = common.Address{0x01}
+		gasLimit = hexutil.Uint64(1_000_000)
+	)
+	header, err = makeSimulatedHeader(b, parent, &BlockOverrides{Number: &number, Time: &time, Coinbase: &coinbase, GasLimit: &gasLimit})
+	if err != nil {
+		t.Fatalf("failed to make header: %v", err)
+	}
+	if header.Number.Uint64() != 2000 || header.Time != 1000 || header.Coinbase != coinbase || header.GasLimit != 1_000_000 {
+		t.Errorf("overrides not applied: number %v, time %d, coinbase %x, gas limit %d", header.Number, header.Time, header.Coinbase, header.GasLimit)
+	}
+	past := hexutil.Big(*parent.Number)
+	if _, err := makeSimulatedHeader(b, parent, &BlockOverrides{Number: &past}); err == nil {
+		t.Errorf("expected error for number not after parent")
+	}
+	past = hexutil.Big(*new(big.Int).SetUint64(parent.Time))
+	if _, err := makeSimulatedHeader(b, parent, &BlockOverrides{Time: &past}); err == nil {
+		t.Errorf("expected error for timestamp not after parent")
+	}
+}
+
+// Tests that simulated calls are executed one after the other on top of the
+// state left behind by the previous calls and blocks.
+func TestSimulate(t *testing.T) {
+	var (
+		b      = newSimBackend()
+		latest = rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+		slot   = map[common.Hash]common.Hash{{}: common.BigToHash(big.NewInt(10))}
+	)
+	blocks := []SimulatedBlock{
+		{Calls: []TransactionArgs{{To: &simCounter}, {To: &simCounter}}},
+		{Calls: []TransactionArgs{{To: &simCounter}, {To: &simReverter}}},
+		{
+			StateOverrides: &StateOverride{simCounter: OverrideAccount{StateDiff: &slot}},
+			Calls:          []TransactionArgs{{To: &simCounter}},
+		},
+	}
+	results, err := DoSimulate(context.Background(), b, blocks, latest, time.Second, 0)
+	if err != nil {
+		t.Fatalf("failed to simulate: %v", err)
+	}
+	if len(results) != len(blocks) {
+		t.Fatalf("block count mismatch: have %d, want %d", len(results), len(blocks))
+	}
+	// Every counter call should see the state left behind by the previous ones
+	counted := []struct {
+		block, call int
+		value       int64
+	}{
+		{0, 0, 1}, {0, 1, 2}, {1, 0, 3}, {2, 0, 11},
+	}
+	for _, c := range counted {
+		call := results[c.block].Calls[c.call]
+		want := common.BigToHash(big.NewInt(c.value)).Bytes()
+		if call.Status != hexutil.Uint64(types.ReceiptStatusSuccessful) || call.Error != "" {
+			t.Fatalf("block %d call %d: failed: %s", c.block, c.call, call.Error)
+		}
+		if string(call.ReturnValue) != string(want) {
+			t.Errorf("block %d call %d: return value mismatch: have %x, want %x", c.block, c.call, call.ReturnValue, want)
+		}
+		if len(call.Logs) != 1 {
+			t.Fatalf("block %d call %d: log count mismatch: have %d, want 1", c.block, c.call, len(call.Logs))
+		}
+		if log := call.Logs[0]; log.Address != simCounter || string(log.Data) != string(want) || log.BlockHash != results[c.block].Hash {
+			t.Errorf("block %d call %d: log mismatch: address %x, data %x, block %x", c.block, c.call, log.Address, log.Data, log.BlockHash)
+		}
+	}
+	// The reverting call should report its revert data
+	revert := results[1].Calls[1]
+	if revert.Status != hexutil.Uint64(types.ReceiptStatusFailed) || revert.Error != "execution reverted" {
+		t.Errorf("revert status mismatch: have %d %q", revert.Status, revert.Error)
+	}
+	if want := common.BigToHash(big.NewInt(42)).Bytes(); string(revert.ReturnValue) != string(want) {
+		t.Errorf("revert data mismatch: have %x, want %x", revert.ReturnValue, want)
+	}
+	if len(revert.Logs) != 0 {
+		t.Errorf("reverted call left %d logs", len(revert.Logs))
+	}
+	// The blocks should be chained and account for the gas of their calls
+	parent := b.genesis.Header()
+	for i, block := range results {
+		if uint64(block.Number) != parent.Number.Uint64()+uint64(i)+1 {
+			t.Errorf("block %d: number mismatch: have %d, want %d", i, block.Number, parent.Number.Uint64()+uint64(i)+1)
+		}
+		var used uint64
+		for _, call := range block.Calls {
+			used += uint64(call.GasUsed)
+		}
+		if uint64(block.GasUsed) != used {
+			t.Errorf("block %d: gas used mismatch: have %d, want %d", i, block.GasUsed, used)
+		}
+	}
+}
+
+// Tests that invalid simulation requests and the ones exceeding the limits are
+// rejected.
+func TestSimulateErrors(t *testing.T) {
+	var (
+		b      = newSimBackend()
+		latest = rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+		user   = common.Address{0x01}
+		past   = hexutil.Big(*common.Big0)
+		limit  = hexutil.Uint64(30000)
+		gas    = hexutil.Uint64(params.TxGas)
+	)
+	tests := []struct {
+		blocks []SimulatedBlock
+		gasCap uint64
+		err    string
+	}{
+		{nil, 0, "no blocks to simulate"},
+		{make([]SimulatedBlock, maxSimulateBlocks+1), 0, "too many blocks to simulate"},
+		{[]SimulatedBlock{{}, {BlockOverrides: &BlockOverrides{Number: &past}}}, 0, "block 1: block number 0 not after parent 1"},
+		{[]SimulatedBlock{{Calls: []TransactionArgs{{To: &user}, {To: &user}, {To: &user}}}}, 2 * params.TxGas, "block 0 call 2: gas cap 42000 exhausted"},
+		{[]SimulatedBlock{{Calls: []TransactionArgs{{To: &user, Gas: new(hexutil.Uint64)}}}}, 0, "block 0 call 0: err: intrinsic gas too low"},
+		{[]SimulatedBlock{{BlockOverrides: &BlockOverrides{GasLimit: &limit}, Calls: []TransactionArgs{{To: &user, Gas: &gas}, {To: &user, Gas: &gas}}}}, 0, "block 0 call 1: gas limit reached: call gas 21000, block gas left 9000 of 30000"},
+	}
+	for i, tt := range tests {
+		_, err := DoSimulate(context.Background(), b, tt.blocks, latest, time.Second, tt.gasCap)
+		if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %s", i, err, tt.err)
+		}
+	}
+}
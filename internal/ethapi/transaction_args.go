@@ -52,6 +52,9 @@ type TransactionArgs struct {
 	// Introduced by AccessListTxType transaction.
 	AccessList *types.AccessList `json:"accessList,omitempty"`
 	ChainID    *hexutil.Big      `json:"chainId,omitempty"`
+
+	// Introduced by ReserveTxType transaction.
+	At *hexutil.Uint64 `json:"at,omitempty"`
 }
 
 // from retrieves the transaction sender address.
@@ -75,6 +78,20 @@ func (args *TransactionArgs) data() []byte {
 
 // setDefaults fills in default values for unspecified tx fields.
 func (args *TransactionArgs) setDefaults(ctx context.Context, b Backend) error {
+	// Reserve transactions are checked before the fee defaults are filled in,
+	// which would set a gasPrice on pre-London chains.
+	if args.At != nil {
+		head := b.CurrentHeader()
+		if !b.ChainConfig().IsEternal(head.Number) {
+			return errors.New("reserve transactions are not supported before Eternal Live")
+		}
+		if args.GasPrice != nil {
+			return errors.New("gasPrice is not valid for reserve transactions, use maxFeePerGas and maxPriorityFeePerGas")
+		}
+		if uint64(*args.At) <= head.Number.Uint64() {
+			return fmt.Errorf("reserve block %d is not in the future (head=%d)", uint64(*args.At), head.Number)
+		}
+	}
 	if err := args.setFeeDefaults(ctx, b); err != nil {
 		return err
 	}
@@ -272,6 +289,23 @@ func (args *TransactionArgs) ToMessage(globalGasCap uint64, baseFee *big.Int) (t
 func (args *TransactionArgs) toTransaction() *types.Transaction {
 	var data types.TxData
 	switch {
+	case args.At != nil:
+		al := types.AccessList{}
+		if args.AccessList != nil {
+			al = *args.AccessList
+		}
+		data = &types.ReserveTx{
+			To:         args.To,
+			ChainID:    (*big.Int)(args.ChainID),
+			Nonce:      uint64(*args.Nonce),
+			Gas:        uint64(*args.Gas),
+			GasFeeCap:  (*big.Int)(args.MaxFeePerGas),
+			GasTipCap:  (*big.Int)(args.MaxPriorityFeePerGas),
+			Value:      (*big.Int)(args.Value),
+			Data:       args.data(),
+			AccessList: al,
+			At:         uint64(*args.At),
+		}
 	case args.MaxFeePerGas != nil:
 		al := types.AccessList{}
 		if args.AccessList != nil {
@@ -18,6 +18,7 @@ package ethapi
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/big"
 	"reflect"
@@ -207,6 +208,55 @@ func TestSetFeeDefaults(t *testing.T) {
 	}
 }
 
+// TestSetReserveDefaults tests that invalid reserve transactions are rejected
+// before any default is filled in.
+func TestSetReserveDefaults(t *testing.T) {
+	var (
+		b        = newBackendMock()
+		fortytwo = (*hexutil.Big)(big.NewInt(42))
+		past     = hexutil.Uint64(1000)
+		future   = hexutil.Uint64(1200)
+	)
+	tests := []struct {
+		name      string
+		isEternal bool
+		in        *TransactionArgs
+		err       error
+	}{
+		{
+			"reserve tx pre-Eternal",
+			false,
+			&TransactionArgs{At: &future},
+			errors.New("reserve transactions are not supported before Eternal Live"),
+		},
+		{
+			"reserve tx with gas price",
+			true,
+			&TransactionArgs{At: &future, GasPrice: fortytwo},
+			errors.New("gasPrice is not valid for reserve transactions, use maxFeePerGas and maxPriorityFeePerGas"),
+		},
+		{
+			"reserve tx in the past",
+			true,
+			&TransactionArgs{At: &past, MaxFeePerGas: fortytwo},
+			errors.New("reserve block 1000 is not in the future (head=1100)"),
+		},
+	}
+	for i, test := range tests {
+		// London is active on the mock chain in all cases
+		b.activateLondon()
+		if test.isEternal {
+			b.config.EternalBlock = big.NewInt(1050)
+		} else {
+			b.config.EternalBlock = nil
+		}
+		err := test.in.setDefaults(context.Background(), b)
+		if err == nil || err.Error() != test.err.Error() {
+			t.Fatalf("test %d (%s): error mismatch: have %v, want %v", i, test.name, err, test.err)
+		}
+	}
+}
+
 type backendMock struct {
 	current *types.Header
 	config  *params.ChainConfig
@@ -310,6 +360,7 @@ func (b *backendMock) SubscribeChainSideEvent(ch chan<- core.ChainSideEvent) eve
 	return nil
 }
 func (b *backendMock) SendTx(ctx context.Context, signedTx *types.Transaction) error { return nil }
+func (b *backendMock) SendBundle(ctx context.Context, bundle *core.Bundle) error     { return nil }
 func (b *backendMock) GetTransaction(ctx context.Context, txHash common.Hash) (*types.Transaction, common.Hash, uint64, uint64, error) {
 	return nil, [32]byte{}, 0, 0, nil
 }
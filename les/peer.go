@@ -1022,6 +1022,15 @@ func (p *clientPeer) Handshake(td *big.Int, head common.Hash, headNum uint64, ge
 			*lists = (*lists).add("serveChainSince", uint64(0))
 			*lists = (*lists).add("serveStateSince", uint64(0))
 
+			// If the chain history is pruned, only the recent chain can be served.
+			if retention := server.config.HistoryRetention; retention > 0 {
+				chainRecent := uint64(1) // zero would advertise the entire chain
+				if retention > blockSafetyMargin {
+					chainRecent = retention - blockSafetyMargin
+				}
+				*lists = (*lists).add("serveRecentChain", chainRecent)
+			}
+
 			// If local ethereum node is running in archive mode, advertise ourselves we have
 			// all version state data. Otherwise only recent state is available.
 			stateRecent := uint64(core.TriesInMemory - blockSafetyMargin)
@@ -179,6 +179,10 @@ func (t *odrTrie) Prove(key []byte, fromLevel uint, proofDb ethdb.KeyValueWriter
 	return errors.New("not implemented, needs client/server interface split")
 }
 
+func (t *odrTrie) ProveMulti(keys [][]byte, fromLevel uint, proofDb ethdb.KeyValueWriter) error {
+	return errors.New("not implemented, needs client/server interface split")
+}
+
 // do tries and retries to execute a function until it returns with no error or
 // an error type other than MissingNodeError
 func (t *odrTrie) do(key []byte, fn func() error) error {
@@ -40,6 +40,7 @@ import (
 type Backend interface {
 	BlockChain() *core.BlockChain
 	TxPool() *core.TxPool
+	BundlePool() *core.BundlePool
 }
 
 // Config is the configuration parameters of mining.
@@ -53,6 +54,9 @@ type Config struct {
 	GasPrice   *big.Int       // Minimum gas price for mining a transaction
 	Recommit   time.Duration  // The time interval for miner to re-create mining work.
 	Noverify   bool           // Disable remote mining solution verification(only useful in ethash).
+	TxOrdering string         `toml:",omitempty"` // Transaction ordering policy for mined blocks (price, fifo or reserve)
+
+	Ordering TxOrderingPolicy `toml:"-"` // Custom transaction ordering policy, takes precedence over TxOrdering
 }
 
 // Miner creates blocks and searches for proof-of-work values.
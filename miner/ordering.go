@@ -0,0 +1,223 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"container/heap"
+	"fmt"
+	"math/big"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/core/types"
+)
+
+// Names of the built-in transaction ordering policies.
+const (
+	PriceAndNonceOrdering = "price"   // Most profitable first, today's default behaviour
+	FIFOOrdering          = "fifo"    // Earliest arrival first
+	ReserveFirstOrdering  = "reserve" // Reserve transactions first, then instant ones by price
+)
+
+// TransactionSet is a set of pending transactions which can be retrieved one
+// by one in a policy defined order, while honouring the nonce ordering of the
+// transactions of each individual account.
+type TransactionSet interface {
+	// Peek returns the next transaction to include, nil if the set is empty.
+	Peek() *types.Transaction
+
+	// Shift replaces the current head with the next one from the same account.
+	Shift()
+
+	// Pop removes the current head, *not* replacing it with the next one from
+	// the same account. This should be used when a transaction cannot be executed
+	// and hence all subsequent ones should be discarded from the same account.
+	Pop()
+}
+
+// TxOrderingPolicy decides in which order the worker tries to include pending
+// transactions into the block being sealed.
+type TxOrderingPolicy interface {
+	// Name returns the identifier of the policy as used in the miner config.
+	Name() string
+
+	// Order assembles a transaction set over the given nonce sorted per account
+	// transactions. The input map is reowned by the set, the caller should not
+	// interact with it any more after providing it.
+	Order(signer types.Signer, txs map[common.Address]types.Transactions, baseFee *big.Int) TransactionSet
+}
+
+// LocalsMerger is an optional interface of the ordering policies, which need the
+// local and remote transactions ordered as a single set.
+type LocalsMerger interface {
+	// MergeLocals reports whether the local transactions must be ordered along
+	// with the remote ones instead of ahead of them.
+	MergeLocals() bool
+}
+
+// NewTxOrderingPolicy returns the built-in transaction ordering policy with the
+// given name. An empty name selects the default price-and-nonce ordering.
+func NewTxOrderingPolicy(name string) (TxOrderingPolicy, error) {
+	switch name {
+	case "", PriceAndNonceOrdering:
+		return priceAndNonceOrdering{}, nil
+	case FIFOOrdering:
+		return fifoOrdering{}, nil
+	case ReserveFirstOrdering:
+		return reserveFirstOrdering{}, nil
+	default:
+		return nil, fmt.Errorf("unknown transaction ordering policy %q", name)
+	}
+}
+
+// priceAndNonceOrdering retrieves transactions in a profit-maximizing order.
+type priceAndNonceOrdering struct{}
+
+func (priceAndNonceOrdering) Name() string { return PriceAndNonceOrdering }
+
+func (priceAndNonceOrdering) Order(signer types.Signer, txs map[common.Address]types.Transactions, baseFee *big.Int) TransactionSet {
+	return types.NewTransactionsByPriceAndNonce(signer, txs, baseFee)
+}
+
+// fifoOrdering retrieves transactions in the order they arrived at the node.
+type fifoOrdering struct{}
+
+func (fifoOrdering) Name() string { return FIFOOrdering }
+
+func (fifoOrdering) Order(signer types.Signer, txs map[common.Address]types.Transactions, baseFee *big.Int) TransactionSet {
+	return newOrderedTransactions(signer, txs, baseFee, func(a, b *orderedHead) bool {
+		return a.tx.Time().Before(b.tx.Time())
+	})
+}
+
+// reserveFirstOrdering retrieves reserve transactions before instant ones, as
+// required by Eternal Live. Transactions of the same class are retrieved in a
+// profit-maximizing order.
+type reserveFirstOrdering struct{}
+
+func (reserveFirstOrdering) Name() string { return ReserveFirstOrdering }
+
+// MergeLocals implements LocalsMerger, ordering the local and remote transactions
+// separately would let local instant transactions go ahead of remote reserve ones.
+func (reserveFirstOrdering) MergeLocals() bool { return true }
+
+func (reserveFirstOrdering) Order(signer types.Signer, txs map[common.Address]types.Transactions, baseFee *big.Int) TransactionSet {
+	return newOrderedTransactions(signer, txs, baseFee, func(a, b *orderedHead) bool {
+		if ra, rb := a.tx.IsReserve(), b.tx.IsReserve(); ra != rb {
+			return ra
+		}
+		if cmp := a.fee.Cmp(b.fee); cmp != 0 {
+			return cmp > 0
+		}
+		return a.tx.Time().Before(b.tx.Time())
+	})
+}
+
+// mergesLocals reports whether the policy has to order the local and remote
+// transactions as a single set.
+func mergesLocals(policy TxOrderingPolicy) bool {
+	merger, ok := policy.(LocalsMerger)
+	return ok && merger.MergeLocals()
+}
+
+// orderedHead is the next transaction of an account along with its effective
+// miner tip.
+type orderedHead struct {
+	tx   *types.Transaction
+	from common.Address
+	fee  *big.Int
+}
+
+// orderedHeads is a heap of account heads sorted by a policy specific function.
+type orderedHeads struct {
+	heads []*orderedHead
+	less  func(a, b *orderedHead) bool
+}
+
+func (h *orderedHeads) Len() int           { return len(h.heads) }
+func (h *orderedHeads) Less(i, j int) bool { return h.less(h.heads[i], h.heads[j]) }
+func (h *orderedHeads) Swap(i, j int)      { h.heads[i], h.heads[j] = h.heads[j], h.heads[i] }
+
+func (h *orderedHeads) Push(x interface{}) {
+	h.heads = append(h.heads, x.(*orderedHead))
+}
+
+func (h *orderedHeads) Pop() interface{} {
+	old := h.heads
+	n := len(old)
+	x := old[n-1]
+	h.heads = old[0 : n-1]
+	return x
+}
+
+// orderedTransactions is a TransactionSet retrieving account heads by an arbitrary
+// comparison function.
+type orderedTransactions struct {
+	txs     map[common.Address]types.Transactions // Per account nonce-sorted list of transactions
+	heads   *orderedHeads                         // Next transaction for each unique account
+	baseFee *big.Int                              // Current base fee
+}
+
+// newOrderedTransactions creates a transaction set retrieving transactions in
+// the order defined by less, while honouring the nonce of each account.
+func newOrderedTransactions(signer types.Signer, txs map[common.Address]types.Transactions, baseFee *big.Int, less func(a, b *orderedHead) bool) *orderedTransactions {
+	heads := &orderedHeads{heads: make([]*orderedHead, 0, len(txs)), less: less}
+	for from, accTxs := range txs {
+		acc, _ := types.Sender(signer, accTxs[0])
+		fee, err := accTxs[0].EffectiveGasTip(baseFee)
+		// Remove transaction if sender doesn't match from, or if the tip is negative.
+		if acc != from || err != nil {
+			delete(txs, from)
+			continue
+		}
+		heads.heads = append(heads.heads, &orderedHead{tx: accTxs[0], from: from, fee: fee})
+		txs[from] = accTxs[1:]
+	}
+	heap.Init(heads)
+
+	return &orderedTransactions{
+		txs:     txs,
+		heads:   heads,
+		baseFee: baseFee,
+	}
+}
+
+// Peek returns the next transaction in policy order.
+func (t *orderedTransactions) Peek() *types.Transaction {
+	if t.heads.Len() == 0 {
+		return nil
+	}
+	return t.heads.heads[0].tx
+}
+
+// Shift replaces the current head with the next one from the same account.
+func (t *orderedTransactions) Shift() {
+	head := t.heads.heads[0]
+	if txs, ok := t.txs[head.from]; ok && len(txs) > 0 {
+		if fee, err := txs[0].EffectiveGasTip(t.baseFee); err == nil {
+			head.tx, head.fee, t.txs[head.from] = txs[0], fee, txs[1:]
+			heap.Fix(t.heads, 0)
+			return
+		}
+	}
+	heap.Pop(t.heads)
+}
+
+// Pop removes the current head, *not* replacing it with the next one from the
+// same account.
+func (t *orderedTransactions) Pop() {
+	heap.Pop(t.heads)
+}
@@ -0,0 +1,159 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/core/types"
+	"github.com/foreverbit/biternal/crypto"
+)
+
+// signOrderingTx creates a signed transaction, arriving strictly after all the
+// previously created ones.
+func signOrderingTx(t *testing.T, signer types.Signer, key *ecdsa.PrivateKey, nonce uint64, tip int64, at uint64) *types.Transaction {
+	var txdata types.TxData = &types.DynamicFeeTx{Nonce: nonce, GasTipCap: big.NewInt(tip), GasFeeCap: big.NewInt(tip), Gas: 21000, To: &common.Address{}}
+	if at != 0 {
+		txdata = &types.ReserveTx{Nonce: nonce, GasTipCap: big.NewInt(tip), GasFeeCap: big.NewInt(tip), Gas: 21000, To: &common.Address{}, At: at}
+	}
+	tx, err := types.SignNewTx(key, signer, txdata)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	return tx
+}
+
+func TestTxOrderingPolicies(t *testing.T) {
+	var (
+		signer  = types.LatestSignerForChainID(big.NewInt(1))
+		key1, _ = crypto.GenerateKey()
+		key2, _ = crypto.GenerateKey()
+		key3, _ = crypto.GenerateKey()
+		addr1   = crypto.PubkeyToAddress(key1.PublicKey)
+		addr2   = crypto.PubkeyToAddress(key2.PublicKey)
+		addr3   = crypto.PubkeyToAddress(key3.PublicKey)
+	)
+	// Create transactions in arrival order: a cheap reserve one, a mid priced
+	// instant pair and an expensive instant one.
+	reserve := signOrderingTx(t, signer, key1, 0, 1, 10)
+	mid0 := signOrderingTx(t, signer, key2, 0, 5, 0)
+	mid1 := signOrderingTx(t, signer, key2, 1, 5, 0)
+	high := signOrderingTx(t, signer, key3, 0, 10, 0)
+
+	tests := []struct {
+		policy string
+		want   []*types.Transaction
+	}{
+		{PriceAndNonceOrdering, []*types.Transaction{high, mid0, mid1, reserve}},
+		{FIFOOrdering, []*types.Transaction{reserve, mid0, mid1, high}},
+		{ReserveFirstOrdering, []*types.Transaction{reserve, high, mid0, mid1}},
+	}
+	for _, tt := range tests {
+		policy, err := NewTxOrderingPolicy(tt.policy)
+		if err != nil {
+			t.Fatalf("policy %s: failed to create: %v", tt.policy, err)
+		}
+		if policy.Name() != tt.policy {
+			t.Errorf("policy %s: name mismatch: have %s", tt.policy, policy.Name())
+		}
+		set := policy.Order(signer, map[common.Address]types.Transactions{
+			addr1: {reserve},
+			addr2: {mid0, mid1},
+			addr3: {high},
+		}, nil)
+
+		var have []*types.Transaction
+		for tx := set.Peek(); tx != nil; tx = set.Peek() {
+			have = append(have, tx)
+			set.Shift()
+		}
+		if len(have) != len(tt.want) {
+			t.Fatalf("policy %s: transaction count mismatch: have %d, want %d", tt.policy, len(have), len(tt.want))
+		}
+		for i := range have {
+			if have[i].Hash() != tt.want[i].Hash() {
+				t.Errorf("policy %s: transaction %d mismatch: have %x, want %x", tt.policy, i, have[i].Hash(), tt.want[i].Hash())
+			}
+		}
+	}
+	if _, err := NewTxOrderingPolicy("random"); err == nil {
+		t.Error("expected error for unknown ordering policy")
+	}
+}
+
+// Tests that the local transactions only go ahead of the remote ones as long as
+// the ordering policy allows it.
+func TestOrderPendingLocals(t *testing.T) {
+	var (
+		signer  = types.LatestSignerForChainID(big.NewInt(1))
+		instant = signOrderingTx(t, signer, testBankKey, 0, 10, 0)
+		reserve = signOrderingTx(t, signer, testUserKey, 0, 1, 10)
+		env     = &environment{signer: signer, header: &types.Header{}}
+	)
+	price, _ := NewTxOrderingPolicy(PriceAndNonceOrdering)
+	reserveFirst, _ := NewTxOrderingPolicy(ReserveFirstOrdering)
+
+	tests := []struct {
+		policy TxOrderingPolicy
+		sets   int
+		want   []*types.Transaction
+	}{
+		{price, 2, []*types.Transaction{instant, reserve}},
+		{reserveFirst, 1, []*types.Transaction{reserve, instant}},
+		{&mergingOrdering{price}, 1, []*types.Transaction{instant, reserve}},
+	}
+	for _, tt := range tests {
+		w := &worker{ordering: tt.policy}
+
+		sets := w.orderPending(env, map[common.Address]types.Transactions{
+			testBankAddress: {instant},
+			testUserAddress: {reserve},
+		}, []common.Address{testBankAddress})
+		if len(sets) != tt.sets {
+			t.Fatalf("policy %s: transaction set count mismatch: have %d, want %d", tt.policy.Name(), len(sets), tt.sets)
+		}
+		var have []*types.Transaction
+		for _, set := range sets {
+			for tx := set.Peek(); tx != nil; tx = set.Peek() {
+				have = append(have, tx)
+				set.Shift()
+			}
+		}
+		if len(have) != len(tt.want) {
+			t.Fatalf("policy %s: transaction count mismatch: have %d, want %d", tt.policy.Name(), len(have), len(tt.want))
+		}
+		for i := range have {
+			if have[i].Hash() != tt.want[i].Hash() {
+				t.Errorf("policy %s: transaction %d mismatch: have %x, want %x", tt.policy.Name(), i, have[i].Hash(), tt.want[i].Hash())
+			}
+		}
+	}
+}
+
+// mergingOrdering wraps an ordering policy, ordering the local and remote
+// transactions as a single set.
+type mergingOrdering struct {
+	TxOrderingPolicy
+}
+
+func (*mergingOrdering) Name() string      { return "merging" }
+func (*mergingOrdering) MergeLocals() bool { return true }
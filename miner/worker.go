@@ -80,6 +80,7 @@ const (
 var (
 	errBlockInterruptedByNewHead  = errors.New("new head arrived while building block")
 	errBlockInterruptedByRecommit = errors.New("recommit interrupt while building block")
+	errBundleTxReverted           = errors.New("bundle transaction reverted")
 )
 
 // environment is the worker's current environment and holds all
@@ -91,9 +92,11 @@ type environment struct {
 	ancestors mapset.Set     // ancestor set (used for checking uncle parent validity)
 	family    mapset.Set     // family set (used for checking uncle invalidity)
 	tcount    int            // tx count in cycle
-	gasPool   *core.GasPool  // available gas used to pack transactions
+	gasPool   *core.GasPool  // available gas used to pack (instant) transactions
 	coinbase  common.Address
 
+	reservePool *core.GasPool // available gas used to pack reserve transactions, nil before Eternal Live
+
 	header   *types.Header
 	txs      []*types.Transaction
 	receipts []*types.Receipt
@@ -116,6 +119,10 @@ func (env *environment) copy() *environment {
 		gasPool := *env.gasPool
 		cpy.gasPool = &gasPool
 	}
+	if env.reservePool != nil {
+		reservePool := *env.reservePool
+		cpy.reservePool = &reservePool
+	}
 	// The content of txs and uncles are immutable, unnecessary
 	// to do the expensive deep copy for them.
 	cpy.txs = make([]*types.Transaction, len(env.txs))
@@ -127,6 +134,24 @@ func (env *environment) copy() *environment {
 	return cpy
 }
 
+// gasPoolFor returns the gas pool the given transaction is charged against.
+// Reserve transactions draw from their own budget once Eternal Live is active.
+func (env *environment) gasPoolFor(tx *types.Transaction) *core.GasPool {
+	if env.reservePool != nil && tx.IsReserve() {
+		return env.reservePool
+	}
+	return env.gasPool
+}
+
+// gasLeft returns the total amount of gas still available in the block.
+func (env *environment) gasLeft() uint64 {
+	gas := env.gasPool.Gas()
+	if env.reservePool != nil {
+		gas += env.reservePool.Gas()
+	}
+	return gas
+}
+
 // unclelist returns the contained uncles as the list format.
 func (env *environment) unclelist() []*types.Header {
 	var uncles []*types.Header
@@ -188,6 +213,7 @@ type worker struct {
 	engine      consensus.Engine
 	eth         Backend
 	chain       *core.BlockChain
+	ordering    TxOrderingPolicy
 
 	// Feeds
 	pendingLogsFeed event.Feed
@@ -282,6 +308,16 @@ func newWorker(config *Config, chainConfig *params.ChainConfig, engine consensus
 	worker.chainHeadSub = eth.BlockChain().SubscribeChainHeadEvent(worker.chainHeadCh)
 	worker.chainSideSub = eth.BlockChain().SubscribeChainSideEvent(worker.chainSideCh)
 
+	// Resolve the transaction ordering policy, falling back to the default one.
+	worker.ordering = worker.config.Ordering
+	if worker.ordering == nil {
+		ordering, err := NewTxOrderingPolicy(worker.config.TxOrdering)
+		if err != nil {
+			log.Warn("Sanitizing miner transaction ordering", "provided", worker.config.TxOrdering, "updated", PriceAndNonceOrdering, "err", err)
+			ordering = priceAndNonceOrdering{}
+		}
+		worker.ordering = ordering
+	}
 	// Sanitize recommit interval if the user-specified one is too short.
 	recommit := worker.config.Recommit
 	if recommit < minRecommitInterval {
@@ -587,7 +623,7 @@ func (w *worker) mainLoop() {
 			// be automatically eliminated.
 			if !w.isRunning() && w.current != nil {
 				// If block is already full, abort
-				if gp := w.current.gasPool; gp != nil && gp.Gas() < params.TxGas {
+				if w.current.gasPool != nil && w.current.gasLeft() < params.TxGas {
 					continue
 				}
 				txs := make(map[common.Address]types.Transactions)
@@ -595,7 +631,7 @@ func (w *worker) mainLoop() {
 					acc, _ := types.Sender(w.current.signer, tx)
 					txs[acc] = append(txs[acc], tx)
 				}
-				txset := types.NewTransactionsByPriceAndNonce(w.current.signer, txs, w.current.header.BaseFee)
+				txset := w.ordering.Order(w.current.signer, txs, w.current.header.BaseFee)
 				tcount := w.current.tcount
 				w.commitTransactions(w.current, txset, nil)
 
@@ -825,7 +861,7 @@ func (w *worker) updateSnapshot(env *environment) {
 func (w *worker) commitTransaction(env *environment, tx *types.Transaction) ([]*types.Log, error) {
 	snap := env.state.Snapshot()
 
-	receipt, err := core.ApplyTransaction(w.chainConfig, w.chain, &env.coinbase, env.gasPool, env.state, env.header, tx, &env.header.GasUsed, *w.chain.GetVMConfig())
+	receipt, err := core.ApplyTransaction(w.chainConfig, w.chain, &env.coinbase, env.gasPoolFor(tx), env.state, env.header, tx, &env.header.GasUsed, *w.chain.GetVMConfig())
 	if err != nil {
 		env.state.RevertToSnapshot(snap)
 		return nil, err
@@ -836,11 +872,126 @@ func (w *worker) commitTransaction(env *environment, tx *types.Transaction) ([]*
 	return receipt.Logs, nil
 }
 
-func (w *worker) commitTransactions(env *environment, txs *types.TransactionsByPriceAndNonce, interrupt *int32) error {
-	gasLimit := env.header.GasLimit
-	if env.gasPool == nil {
-		env.gasPool = new(core.GasPool).AddGas(gasLimit)
+// commitBundle executes all the transactions of a bundle on top of the given
+// environment. If any of them fails or reverts, all the changes made by the
+// bundle are rolled back and the bundle is dropped as a whole.
+func (w *worker) commitBundle(env *environment, bundle *core.Bundle) ([]*types.Log, error) {
+	// Transactions finalise the state, flushing the journal, so a snapshot
+	// can't span the bundle: keep a full copy to roll back to instead.
+	var (
+		statedb = env.state.Copy()
+		gasPool = *env.gasPool
+		gasUsed = env.header.GasUsed
+		tcount  = env.tcount
+		txs     = len(env.txs)
+
+		reservePool core.GasPool
+		coalesced   []*types.Log
+	)
+	if env.reservePool != nil {
+		reservePool = *env.reservePool
 	}
+	for _, tx := range bundle.Txs {
+		// Ensure the transaction is includable at all before executing it
+		err := core.ErrTxTypeNotSupported
+		if !tx.Protected() || w.chainConfig.IsEIP155(env.header.Number) {
+			env.state.Prepare(tx.Hash(), env.tcount)
+
+			var logs []*types.Log
+			if logs, err = w.commitTransaction(env, tx); err == nil {
+				if env.receipts[len(env.receipts)-1].Status == types.ReceiptStatusFailed {
+					err = errBundleTxReverted
+				}
+				coalesced = append(coalesced, logs...)
+				env.tcount++
+			}
+		}
+		if err != nil {
+			// Roll back every member of the bundle executed so far
+			env.state.StopPrefetcher()
+			env.state = statedb
+			*env.gasPool = gasPool
+			if env.reservePool != nil {
+				*env.reservePool = reservePool
+			}
+			env.header.GasUsed = gasUsed
+			env.tcount = tcount
+			env.txs, env.receipts = env.txs[:txs], env.receipts[:txs]
+
+			return nil, fmt.Errorf("transaction %x: %w", tx.Hash(), err)
+		}
+	}
+	return coalesced, nil
+}
+
+// commitBundles simulates the given bundles one after the other against the
+// pending environment, including those that fully succeed and atomically
+// dropping the rest.
+func (w *worker) commitBundles(env *environment, bundles []*core.Bundle, interrupt *int32) error {
+	w.initGasPools(env)
+
+	var coalescedLogs []*types.Log
+	for _, bundle := range bundles {
+		// Abort on a new head, leave the recommit handling to the transactions
+		if interrupt != nil {
+			if signal := atomic.LoadInt32(interrupt); signal == commitInterruptNewHead {
+				return errBlockInterruptedByNewHead
+			} else if signal != commitInterruptNone {
+				break
+			}
+		}
+		if env.gasLeft() < params.TxGas {
+			log.Trace("Not enough gas for further bundles", "have", env.gasLeft(), "want", params.TxGas)
+			break
+		}
+		logs, err := w.commitBundle(env, bundle)
+		if err != nil {
+			log.Debug("Bundle failed, discarded", "hash", bundle.Hash(), "err", err)
+			continue
+		}
+		coalescedLogs = append(coalescedLogs, logs...)
+	}
+	w.notifyPendingLogs(coalescedLogs)
+	return nil
+}
+
+// initGasPools creates the gas pools of the environment, splitting the block
+// gas limit into the reserve and instant budgets after the Eternal fork.
+func (w *worker) initGasPools(env *environment) {
+	if env.gasPool != nil {
+		return
+	}
+	env.gasPool = new(core.GasPool).AddGas(env.header.GasLimit)
+	if w.chainConfig.IsEternal(env.header.Number) {
+		reserve, instant := core.CalcGasBudgets(env.header.GasLimit)
+		env.gasPool, env.reservePool = new(core.GasPool).AddGas(instant), new(core.GasPool).AddGas(reserve)
+	}
+}
+
+// notifyPendingLogs pushes the logs of freshly packed transactions to the
+// pending log subscribers.
+func (w *worker) notifyPendingLogs(logs []*types.Log) {
+	if !w.isRunning() && len(logs) > 0 {
+		// We don't push the pendingLogsEvent while we are sealing. The reason is that
+		// when we are sealing, the worker will regenerate a sealing block every 3 seconds.
+		// In order to avoid pushing the repeated pendingLog, we disable the pending log pushing.
+
+		// make a copy, the state caches the logs and these logs get "upgraded" from pending to mined
+		// logs by filling in the block hash when the block was mined by the local miner. This can
+		// cause a race condition if a log was "upgraded" before the PendingLogsEvent is processed.
+		cpy := make([]*types.Log, len(logs))
+		for i, l := range logs {
+			cpy[i] = new(types.Log)
+			*cpy[i] = *l
+		}
+		w.pendingLogsFeed.Send(cpy)
+	}
+}
+
+func (w *worker) commitTransactions(env *environment, txs TransactionSet, interrupt *int32) error {
+	w.initGasPools(env)
+
+	gasLimit := env.header.GasLimit
 	var coalescedLogs []*types.Log
 
 	for {
@@ -853,7 +1004,7 @@ func (w *worker) commitTransactions(env *environment, txs *types.TransactionsByP
 		if interrupt != nil && atomic.LoadInt32(interrupt) != commitInterruptNone {
 			// Notify resubmit loop to increase resubmitting interval due to too frequent commits.
 			if atomic.LoadInt32(interrupt) == commitInterruptResubmit {
-				ratio := float64(gasLimit-env.gasPool.Gas()) / float64(gasLimit)
+				ratio := float64(gasLimit-env.gasLeft()) / float64(gasLimit)
 				if ratio < 0.1 {
 					ratio = 0.1
 				}
@@ -866,8 +1017,8 @@ func (w *worker) commitTransactions(env *environment, txs *types.TransactionsByP
 			return errBlockInterruptedByNewHead
 		}
 		// If we don't have enough gas for any further transactions then we're done
-		if env.gasPool.Gas() < params.TxGas {
-			log.Trace("Not enough gas for further transactions", "have", env.gasPool, "want", params.TxGas)
+		if env.gasLeft() < params.TxGas {
+			log.Trace("Not enough gas for further transactions", "have", env.gasLeft(), "want", params.TxGas)
 			break
 		}
 		// Retrieve the next transaction and abort if all done
@@ -888,6 +1039,21 @@ func (w *worker) commitTransactions(env *environment, txs *types.TransactionsByP
 			txs.Pop()
 			continue
 		}
+		// Reserve transactions can only be included in the block they are scheduled
+		// for, ignore the sender until then.
+		if tx.IsReserve() && tx.At() != env.header.Number.Uint64() {
+			log.Trace("Ignoring unscheduled reserve transaction", "hash", tx.Hash(), "at", tx.At())
+
+			txs.Pop()
+			continue
+		}
+		// If the budget of the transaction's class is exhausted, skip the account
+		if gp := env.gasPoolFor(tx); gp.Gas() < params.TxGas {
+			log.Trace("Not enough gas for further transactions of class", "reserve", tx.IsReserve(), "have", gp, "want", params.TxGas)
+
+			txs.Pop()
+			continue
+		}
 		// Start executing the transaction
 		env.state.Prepare(tx.Hash(), env.tcount)
 
@@ -927,21 +1093,8 @@ func (w *worker) commitTransactions(env *environment, txs *types.TransactionsByP
 		}
 	}
 
-	if !w.isRunning() && len(coalescedLogs) > 0 {
-		// We don't push the pendingLogsEvent while we are sealing. The reason is that
-		// when we are sealing, the worker will regenerate a sealing block every 3 seconds.
-		// In order to avoid pushing the repeated pendingLog, we disable the pending log pushing.
+	w.notifyPendingLogs(coalescedLogs)
 
-		// make a copy, the state caches the logs and these logs get "upgraded" from pending to mined
-		// logs by filling in the block hash when the block was mined by the local miner. This can
-		// cause a race condition if a log was "upgraded" before the PendingLogsEvent is processed.
-		cpy := make([]*types.Log, len(coalescedLogs))
-		for i, l := range coalescedLogs {
-			cpy[i] = new(types.Log)
-			*cpy[i] = *l
-		}
-		w.pendingLogsFeed.Send(cpy)
-	}
 	// Notify resubmit loop to decrease resubmitting interval if current interval is larger
 	// than the user-specified one.
 	if interrupt != nil {
@@ -1045,32 +1198,87 @@ func (w *worker) prepareWork(genParams *generateParams) (*environment, error) {
 }
 
 // fillTransactions retrieves the pending transactions from the txpool and fills them
-// into the given sealing block. The transaction selection and ordering strategy can
-// be customized with the plugin in the future.
+// into the given sealing block. The reserve transactions are included first, then
+// the bundles targeting the block and finally the instant transactions. Transactions
+// are ordered by the configured TxOrderingPolicy, locals first and then remotes
+// unless the policy orders them together.
 func (w *worker) fillTransactions(interrupt *int32, env *environment) error {
-	// Split the pending transactions into locals and remotes
+	var (
+		pool    = w.eth.TxPool()
+		pending = pool.Pending(true)
+		locals  = pool.Locals()
+	)
+	// Execute the reserve transactions first, ahead of the bundles and the
+	// instant transactions.
+	for _, txs := range w.orderPending(env, splitReserve(pending), locals) {
+		if err := w.commitTransactions(env, txs, interrupt); err != nil {
+			return err
+		}
+	}
+	if pool := w.eth.BundlePool(); pool != nil {
+		if bundles := pool.Pending(env.header.Number.Uint64()); len(bundles) > 0 {
+			if err := w.commitBundles(env, bundles, interrupt); err != nil {
+				return err
+			}
+		}
+	}
 	// Fill the block with all available pending transactions.
-	pending := w.eth.TxPool().Pending(true)
+	for _, txs := range w.orderPending(env, pending, locals) {
+		if err := w.commitTransactions(env, txs, interrupt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitReserve moves the leading reserve transactions of every account out of
+// the pending ones, which are left with the transactions following them.
+func splitReserve(pending map[common.Address]types.Transactions) map[common.Address]types.Transactions {
+	reserve := make(map[common.Address]types.Transactions)
+	for addr, txs := range pending {
+		n := 0
+		for n < len(txs) && txs[n].IsReserve() {
+			n++
+		}
+		if n == 0 {
+			continue
+		}
+		reserve[addr] = txs[:n]
+		if n == len(txs) {
+			delete(pending, addr)
+		} else {
+			pending[addr] = txs[n:]
+		}
+	}
+	return reserve
+}
+
+// orderPending assembles the transaction sets to commit in turn from the pending
+// transactions: the ones of the local accounts first and then the remote ones,
+// unless the ordering policy has to span both groups.
+func (w *worker) orderPending(env *environment, pending map[common.Address]types.Transactions, locals []common.Address) []TransactionSet {
+	if len(pending) == 0 {
+		return nil
+	}
+	if mergesLocals(w.ordering) {
+		return []TransactionSet{w.ordering.Order(env.signer, pending, env.header.BaseFee)}
+	}
+	// Split the pending transactions into locals and remotes
 	localTxs, remoteTxs := make(map[common.Address]types.Transactions), pending
-	for _, account := range w.eth.TxPool().Locals() {
+	for _, account := range locals {
 		if txs := remoteTxs[account]; len(txs) > 0 {
 			delete(remoteTxs, account)
 			localTxs[account] = txs
 		}
 	}
+	var sets []TransactionSet
 	if len(localTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(env.signer, localTxs, env.header.BaseFee)
-		if err := w.commitTransactions(env, txs, interrupt); err != nil {
-			return err
-		}
+		sets = append(sets, w.ordering.Order(env.signer, localTxs, env.header.BaseFee))
 	}
 	if len(remoteTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(env.signer, remoteTxs, env.header.BaseFee)
-		if err := w.commitTransactions(env, txs, interrupt); err != nil {
-			return err
-		}
+		sets = append(sets, w.ordering.Order(env.signer, remoteTxs, env.header.BaseFee))
 	}
-	return nil
+	return sets
 }
 
 // generateWork generates a sealing block based on the given parameters.
@@ -112,6 +112,7 @@ func init() {
 type testWorkerBackend struct {
 	db         ethdb.Database
 	txPool     *core.TxPool
+	bundlePool *core.BundlePool
 	chain      *core.BlockChain
 	genesis    *core.Genesis
 	uncleBlock *types.Block
@@ -160,6 +161,7 @@ func newTestWorkerBackend(t *testing.T, chainConfig *params.ChainConfig, engine
 		db:         db,
 		chain:      chain,
 		txPool:     txpool,
+		bundlePool: core.NewBundlePool(chainConfig, chain),
 		genesis:    &gspec,
 		uncleBlock: blocks[0],
 	}
@@ -167,6 +169,7 @@ func newTestWorkerBackend(t *testing.T, chainConfig *params.ChainConfig, engine
 
 func (b *testWorkerBackend) BlockChain() *core.BlockChain { return b.chain }
 func (b *testWorkerBackend) TxPool() *core.TxPool         { return b.txPool }
+func (b *testWorkerBackend) BundlePool() *core.BundlePool { return b.bundlePool }
 func (b *testWorkerBackend) StateAtBlock(block *types.Block, reexec uint64, base *state.StateDB, checkLive bool, preferDisk bool) (statedb *state.StateDB, err error) {
 	return nil, errors.New("not supported")
 }
@@ -670,3 +673,159 @@ func testGetSealingWork(t *testing.T, chainConfig *params.ChainConfig, engine co
 		}
 	}
 }
+
+// Tests that bundles are either included as a whole and in order, or dropped
+// without leaving any trace in the sealing block.
+func TestCommitBundles(t *testing.T) {
+	engine := ethash.NewFaker()
+	defer engine.Close()
+
+	w, _ := newTestWorker(t, ethashChainConfig, engine, rawdb.NewMemoryDatabase(), 0)
+	defer w.close()
+
+	env, err := w.prepareWork(&generateParams{timestamp: uint64(time.Now().Unix()), coinbase: testUserAddress})
+	if err != nil {
+		t.Fatalf("failed to prepare work: %v", err)
+	}
+	defer env.discard()
+
+	gasPrice := big.NewInt(10 * params.InitialBaseFee)
+	transfer := func(nonce uint64) *types.Transaction {
+		tx, _ := types.SignTx(types.NewTransaction(nonce, testUserAddress, big.NewInt(1000), params.TxGas, gasPrice, nil), types.HomesteadSigner{}, testBankKey)
+		return tx
+	}
+	// Deploying an INVALID opcode as init code reverts the transaction
+	revert, _ := types.SignTx(types.NewContractCreation(3, big.NewInt(0), 100000, gasPrice, []byte{0xfe}), types.HomesteadSigner{}, testBankKey)
+
+	bundles := []*core.Bundle{
+		core.NewBundle(types.Transactions{transfer(0), transfer(1)}, 1),
+		core.NewBundle(types.Transactions{transfer(2), transfer(4)}, 1), // nonce gap
+		core.NewBundle(types.Transactions{transfer(2), revert}, 1),      // reverting member
+		core.NewBundle(types.Transactions{transfer(2)}, 1),
+	}
+	if err := w.commitBundles(env, bundles, nil); err != nil {
+		t.Fatalf("failed to commit bundles: %v", err)
+	}
+	if len(env.txs) != 3 || len(env.receipts) != 3 || env.tcount != 3 {
+		t.Fatalf("included transaction count mismatch: have %d/%d/%d, want 3", len(env.txs), len(env.receipts), env.tcount)
+	}
+	for i, tx := range env.txs {
+		if tx.Nonce() != uint64(i) {
+			t.Errorf("transaction %d: nonce mismatch: have %d, want %d", i, tx.Nonce(), i)
+		}
+	}
+	if nonce := env.state.GetNonce(testBankAddress); nonce != 3 {
+		t.Errorf("sender nonce mismatch: have %d, want %d", nonce, 3)
+	}
+	if used := env.header.GasUsed; used != 3*params.TxGas {
+		t.Errorf("gas used mismatch: have %d, want %d", used, 3*params.TxGas)
+	}
+	if left := env.gasLeft(); left != env.header.GasLimit-3*params.TxGas {
+		t.Errorf("gas left mismatch: have %d, want %d", left, env.header.GasLimit-3*params.TxGas)
+	}
+}
+
+// Tests that the reserve transactions are included ahead of the bundles.
+func TestFillTransactionsReserveFirst(t *testing.T) {
+	engine := ethash.NewFaker()
+	defer engine.Close()
+
+	config := *ethashChainConfig
+	config.EternalBlock = common.Big0
+
+	backend := newTestWorkerBackend(t, &config, engine, rawdb.NewMemoryDatabase(), 0)
+	w := newWorker(testConfig, &config, engine, backend, new(event.TypeMux), nil, false)
+	defer w.close()
+
+	var (
+		signer  = types.LatestSigner(&config)
+		fee     = big.NewInt(10 * params.InitialBaseFee)
+		reserve = types.MustSignNewTx(testBankKey, signer, &types.ReserveTx{
+			ChainID: config.ChainID, Nonce: 0, GasTipCap: fee, GasFeeCap: fee, Gas: params.TxGas, To: &testUserAddress, Value: big.NewInt(1), At: 1,
+		})
+		bundled = types.MustSignNewTx(testBankKey, signer, &types.DynamicFeeTx{
+			ChainID: config.ChainID, Nonce: 1, GasTipCap: fee, GasFeeCap: fee, Gas: params.TxGas, To: &testUserAddress, Value: big.NewInt(1),
+		})
+	)
+	// The bundle only succeeds on top of the reserve transaction
+	if err := backend.txPool.AddLocal(reserve); err != nil {
+		t.Fatalf("failed to add reserve transaction: %v", err)
+	}
+	if err := backend.bundlePool.Add(core.NewBundle(types.Transactions{bundled}, 1)); err != nil {
+		t.Fatalf("failed to add bundle: %v", err)
+	}
+	env, err := w.prepareWork(&generateParams{timestamp: uint64(time.Now().Unix()), coinbase: testUserAddress})
+	if err != nil {
+		t.Fatalf("failed to prepare work: %v", err)
+	}
+	defer env.discard()
+
+	if err := w.fillTransactions(nil, env); err != nil {
+		t.Fatalf("failed to fill transactions: %v", err)
+	}
+	want := []common.Hash{reserve.Hash(), bundled.Hash()}
+	if len(env.txs) != len(want) {
+		t.Fatalf("included transaction count mismatch: have %d, want %d", len(env.txs), len(want))
+	}
+	for i, tx := range env.txs {
+		if tx.Hash() != want[i] {
+			t.Errorf("transaction %d: hash mismatch: have %x, want %x", i, tx.Hash(), want[i])
+		}
+	}
+}
+
+// Tests that the worker stops packing each class of transactions once its share
+// of the block gas limit is used up, without affecting the other class.
+func TestCommitTransactionsGasBudgets(t *testing.T) {
+	engine := ethash.NewFaker()
+	defer engine.Close()
+
+	config := *ethashChainConfig
+	config.EternalBlock = common.Big0
+
+	w, _ := newTestWorker(t, &config, engine, rawdb.NewMemoryDatabase(), 0)
+	defer w.close()
+
+	env, err := w.prepareWork(&generateParams{timestamp: uint64(time.Now().Unix()), coinbase: testUserAddress})
+	if err != nil {
+		t.Fatalf("failed to prepare work: %v", err)
+	}
+	defer env.discard()
+
+	// Leave room for two transfers in each budget
+	env.header.GasLimit = 4 * params.TxGas
+	env.state.AddBalance(testUserAddress, testBankFunds)
+
+	var (
+		fee      = big.NewInt(10 * params.InitialBaseFee)
+		number   = env.header.Number.Uint64()
+		reserves types.Transactions
+		instants types.Transactions
+	)
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		reserves = append(reserves, types.MustSignNewTx(testBankKey, env.signer, &types.ReserveTx{
+			ChainID: config.ChainID, Nonce: nonce, GasTipCap: fee, GasFeeCap: fee, Gas: params.TxGas, To: &testUserAddress, Value: big.NewInt(1), At: number,
+		}))
+		instants = append(instants, types.MustSignNewTx(testUserKey, env.signer, &types.DynamicFeeTx{
+			ChainID: config.ChainID, Nonce: nonce, GasTipCap: fee, GasFeeCap: fee, Gas: params.TxGas, To: &testBankAddress, Value: big.NewInt(1),
+		}))
+	}
+	pending := map[common.Address]types.Transactions{testBankAddress: reserves, testUserAddress: instants}
+	if err := w.commitTransactions(env, types.NewTransactionsByPriceAndNonce(env.signer, pending, env.header.BaseFee), nil); err != nil {
+		t.Fatalf("failed to commit transactions: %v", err)
+	}
+	var included [2]int
+	for _, tx := range env.txs {
+		if tx.IsReserve() {
+			included[0]++
+		} else {
+			included[1]++
+		}
+	}
+	if included != [2]int{2, 2} {
+		t.Errorf("included reserve/instant transactions mismatch: have %v, want [2 2]", included)
+	}
+	if env.reservePool.Gas() != 0 || env.gasPool.Gas() != 0 {
+		t.Errorf("gas pools not exhausted: reserve %d, instant %d", env.reservePool.Gas(), env.gasPool.Gas())
+	}
+}
@@ -176,6 +176,8 @@ func (api *adminAPI) StartHTTP(host *string, port *int, cors *string, apis *stri
 		CorsAllowedOrigins: api.node.config.HTTPCors,
 		Vhosts:             api.node.config.HTTPVirtualHosts,
 		Modules:            api.node.config.HTTPModules,
+		batchItemLimit:     api.node.config.BatchRequestLimit,
+		batchResponseSize:  api.node.config.BatchResponseMaxSize,
 	}
 	if cors != nil {
 		config.CorsAllowedOrigins = nil
@@ -247,8 +249,10 @@ func (api *adminAPI) StartWS(host *string, port *int, allowedOrigins *string, ap
 
 	// Determine config.
 	config := wsConfig{
-		Modules: api.node.config.WSModules,
-		Origins: api.node.config.WSOrigins,
+		Modules:           api.node.config.WSModules,
+		Origins:           api.node.config.WSOrigins,
+		batchItemLimit:    api.node.config.BatchRequestLimit,
+		batchResponseSize: api.node.config.BatchResponseMaxSize,
 		// ExposeAll: api.node.config.WSExposeAll,
 	}
 	if apis != nil {
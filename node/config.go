@@ -203,6 +203,18 @@ type Config struct {
 
 	// JWTSecret is the hex-encoded jwt secret.
 	JWTSecret string `toml:",omitempty"`
+
+	// BatchRequestLimit is the maximum number of requests in a batch served over
+	// HTTP or WebSocket. Zero means no limit.
+	BatchRequestLimit int `toml:",omitempty"`
+
+	// BatchResponseMaxSize is the maximum number of bytes returned for a batch
+	// served over HTTP or WebSocket. Zero means no limit.
+	BatchResponseMaxSize int `toml:",omitempty"`
+
+	// DBEngine is the key-value database engine to use ("leveldb" or "pebble").
+	// If empty, the engine of an existing database is used, leveldb otherwise.
+	DBEngine string `toml:",omitempty"`
 }
 
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
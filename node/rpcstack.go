@@ -41,14 +41,18 @@ type httpConfig struct {
 	Vhosts             []string
 	prefix             string // path prefix on which to mount http handler
 	jwtSecret          []byte // optional JWT secret
+	batchItemLimit     int    // maximum number of requests in a batch
+	batchResponseSize  int    // maximum number of bytes in a batch response
 }
 
 // wsConfig is the JSON-RPC/Websocket configuration
 type wsConfig struct {
-	Origins   []string
-	Modules   []string
-	prefix    string // path prefix on which to mount ws handler
-	jwtSecret []byte // optional JWT secret
+	Origins           []string
+	Modules           []string
+	prefix            string // path prefix on which to mount ws handler
+	jwtSecret         []byte // optional JWT secret
+	batchItemLimit    int    // maximum number of requests in a batch
+	batchResponseSize int    // maximum number of bytes in a batch response
 }
 
 type rpcHandler struct {
@@ -293,6 +297,7 @@ func (h *httpServer) enableRPC(apis []rpc.API, config httpConfig) error {
 
 	// Create RPC server and handler.
 	srv := rpc.NewServer()
+	srv.SetBatchLimits(config.batchItemLimit, config.batchResponseSize)
 	if err := RegisterApis(apis, config.Modules, srv); err != nil {
 		return err
 	}
@@ -324,6 +329,7 @@ func (h *httpServer) enableWS(apis []rpc.API, config wsConfig) error {
 	}
 	// Create RPC server and handler.
 	srv := rpc.NewServer()
+	srv.SetBatchLimits(config.batchItemLimit, config.batchResponseSize)
 	if err := RegisterApis(apis, config.Modules, srv); err != nil {
 		return err
 	}
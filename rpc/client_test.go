@@ -144,6 +144,151 @@ func TestClientBatchRequest(t *testing.T) {
 	}
 }
 
+func TestClientBatchRequestLimits(t *testing.T) {
+	dial := func(itemLimit, maxResponseSize int) *Client {
+		server := newTestServer()
+		server.SetBatchLimits(itemLimit, maxResponseSize)
+		t.Cleanup(server.Stop)
+
+		client := DialInProc(server)
+		t.Cleanup(client.Close)
+		return client
+	}
+	makeBatch := func(n int) []BatchElem {
+		batch := make([]BatchElem, n)
+		for i := range batch {
+			batch[i] = BatchElem{
+				Method: "test_echo",
+				Args:   []interface{}{"hello", i, &echoArgs{"world"}},
+				Result: new(echoResult),
+			}
+		}
+		return batch
+	}
+	// Batches over the item limit should be answered by a single error, without
+	// executing any request
+	batch := makeBatch(3)
+	if err := dial(2, 0).BatchCall(batch); err != nil {
+		t.Fatal(err)
+	}
+	if err, ok := batch[0].Error.(Error); !ok || err.ErrorCode() != -32600 {
+		t.Errorf("item 0: error mismatch: have %v, want batch too large", batch[0].Error)
+	}
+	for i, elem := range batch[1:] {
+		if elem.Error != ErrMissingBatchResponse {
+			t.Errorf("item %d: error mismatch: have %v, want %v", i+1, elem.Error, ErrMissingBatchResponse)
+		}
+	}
+	// Responses over the size limit should fail the remaining requests
+	batch = makeBatch(5)
+	if err := dial(0, 200).BatchCall(batch); err != nil {
+		t.Fatal(err)
+	}
+	for i, elem := range batch {
+		if i < 2 {
+			if elem.Error != nil {
+				t.Errorf("item %d: unexpected error: %v", i, elem.Error)
+			} else if have := elem.Result.(*echoResult).Int; have != i {
+				t.Errorf("item %d: result mismatch: have %d, want %d", i, have, i)
+			}
+			continue
+		}
+		if err, ok := elem.Error.(Error); !ok || err.ErrorCode() != -32003 {
+			t.Errorf("item %d: error mismatch: have %v, want response too large", i, elem.Error)
+		}
+	}
+}
+
+// Tests that batch responses larger than the streaming threshold are delivered
+// intact.
+func TestClientBatchRequestStreaming(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	var (
+		str   = strings.Repeat("x", 1024*1024)
+		batch = make([]BatchElem, batchStreamThreshold/len(str)+2)
+	)
+	for i := range batch {
+		batch[i] = BatchElem{
+			Method: "test_echo",
+			Args:   []interface{}{str, i, &echoArgs{"world"}},
+			Result: new(echoResult),
+		}
+	}
+	if err := client.BatchCall(batch); err != nil {
+		t.Fatal(err)
+	}
+	for i, elem := range batch {
+		if elem.Error != nil {
+			t.Fatalf("item %d: unexpected error: %v", i, elem.Error)
+		}
+		if have := elem.Result.(*echoResult); have.String != str || have.Int != i {
+			t.Fatalf("item %d: result mismatch", i)
+		}
+	}
+}
+
+// Tests that messages written while a batch response is streamed don't block and
+// are sent once the batch is complete, as long as they fit into the limit.
+func TestBatchStreamDefersMessages(t *testing.T) {
+	p1, p2 := net.Pipe()
+	defer p2.Close()
+
+	codec := NewCodec(p1).(*jsonCodec)
+	defer codec.close()
+
+	// Read the streamed batch and the message following it
+	read := make(chan []json.RawMessage, 1)
+	go func() {
+		var (
+			dec  = json.NewDecoder(p2)
+			msgs []json.RawMessage
+		)
+		for i := 0; i < 2; i++ {
+			var msg json.RawMessage
+			if err := dec.Decode(&msg); err != nil {
+				break
+			}
+			msgs = append(msgs, msg)
+		}
+		read <- msgs
+	}()
+	ctx := context.Background()
+	stream := codec.streamBatch(ctx, 32)
+	if stream == nil {
+		t.Fatal("codec does not support streaming")
+	}
+	if codec.streamBatch(ctx, 32) != nil {
+		t.Fatal("second batch streamed concurrently")
+	}
+	stream.writeItem(json.RawMessage(`1`))
+
+	written := make(chan error, 1)
+	go func() { written <- codec.writeJSON(ctx, "notification") }()
+	select {
+	case err := <-written:
+		if err != nil {
+			t.Fatalf("failed to write message: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message write blocked by the streamed batch")
+	}
+	if err := codec.writeJSON(ctx, strings.Repeat("x", 32)); err != errDeferredTooLarge {
+		t.Fatalf("oversized message error mismatch: have %v, want %v", err, errDeferredTooLarge)
+	}
+	stream.writeItem(json.RawMessage(`2`))
+	if err := stream.close(); err != nil {
+		t.Fatalf("failed to close stream: %v", err)
+	}
+	msgs := <-read
+	if len(msgs) != 2 || string(msgs[0]) != "[1,2]" || string(msgs[1]) != `"notification"` {
+		t.Fatalf("written messages mismatch: have %s", msgs)
+	}
+}
+
 func TestClientNotify(t *testing.T) {
 	server := newTestServer()
 	defer server.Stop()
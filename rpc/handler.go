@@ -60,6 +60,7 @@ type handler struct {
 	conn           jsonWriter                     // where responses will be sent
 	log            log.Logger
 	allowSubscribe bool
+	batch          batchConfig
 
 	subLock    sync.Mutex
 	serverSubs map[ID]*Subscription
@@ -70,7 +71,7 @@ type callProc struct {
 	notifiers []*Notifier
 }
 
-func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *serviceRegistry) *handler {
+func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *serviceRegistry, batch batchConfig) *handler {
 	rootCtx, cancelRoot := context.WithCancel(connCtx)
 	h := &handler{
 		reg:            reg,
@@ -81,6 +82,7 @@ func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *
 		rootCtx:        rootCtx,
 		cancelRoot:     cancelRoot,
 		allowSubscribe: true,
+		batch:          batch,
 		serverSubs:     make(map[ID]*Subscription),
 		log:            log.Root(),
 	}
@@ -100,35 +102,126 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage) {
 		})
 		return
 	}
+	// Reject batches with too many items without executing any of them. The
+	// protocol has no way of failing a whole batch, so the error is attached to
+	// the first call.
+	if h.batch.itemLimit != 0 && len(msgs) > h.batch.itemLimit {
+		h.startCallProc(func(cp *callProc) {
+			for _, msg := range msgs {
+				if msg.isCall() {
+					answer := msg.errorResponse(&invalidRequestError{"batch too large"})
+					h.conn.writeJSON(cp.ctx, []*jsonrpcMessage{answer})
+					return
+				}
+			}
+		})
+		return
+	}
 
 	// Handle non-call messages first:
+	answered := h.batchRequestOps(msgs)
 	calls := make([]*jsonrpcMessage, 0, len(msgs))
 	for _, msg := range msgs {
 		if handled := h.handleImmediate(msg); !handled {
 			calls = append(calls, msg)
 		}
 	}
+	for op := range answered {
+		h.dropMissingResponses(op)
+	}
 	if len(calls) == 0 {
 		return
 	}
 	// Process calls on a goroutine because they may block indefinitely:
 	h.startCallProc(func(cp *callProc) {
-		answers := make([]*jsonrpcMessage, 0, len(msgs))
+		resp := newBatchResponse(h.conn, h.batch.responseMaxSize)
 		for _, msg := range calls {
+			// Once the response grew too large, don't bother executing the rest
+			if resp.full {
+				if !msg.isNotification() {
+					resp.add(cp.ctx, msg, msg.errorResponse(&responseTooLargeError{}))
+				}
+				continue
+			}
 			if answer := h.handleCallMsg(cp, msg); answer != nil {
-				answers = append(answers, answer)
+				resp.add(cp.ctx, msg, answer)
 			}
 		}
 		h.addSubscriptions(cp.notifiers)
-		if len(answers) > 0 {
-			h.conn.writeJSON(cp.ctx, answers)
-		}
+		resp.flush(cp.ctx)
 		for _, n := range cp.notifiers {
 			n.activate()
 		}
 	})
 }
 
+// batchStreamThreshold is the size of the buffered answers of a batch, above which
+// the rest of the response is streamed to connections supporting it.
+const batchStreamThreshold = 4 * 1024 * 1024
+
+// batchResponse collects the answers to a batch request. It enforces the response
+// size limit, and once the answers grow large, it streams them to the connection
+// instead of holding the whole response in memory.
+type batchResponse struct {
+	conn  jsonWriter
+	limit int  // maximum size of the response, zero for no limit
+	size  int  // size of the answers so far
+	full  bool // whether the size limit was reached
+
+	answers  []json.RawMessage // answers buffered until streaming starts
+	buffered int               // size of the buffered answers
+	stream   *jsonBatchStream  // stream of the response, nil if not streaming
+}
+
+func newBatchResponse(conn jsonWriter, limit int) *batchResponse {
+	return &batchResponse{conn: conn, limit: limit}
+}
+
+// add appends the answer to a message to the response. If the answer does not
+// fit into the size limit, it's replaced by an error.
+func (b *batchResponse) add(ctx context.Context, msg *jsonrpcMessage, answer *jsonrpcMessage) {
+	enc, err := json.Marshal(answer)
+	if err != nil {
+		enc, _ = json.Marshal(msg.errorResponse(err))
+	}
+	if b.limit != 0 && b.size+len(enc) > b.limit {
+		b.full = true
+		enc, _ = json.Marshal(msg.errorResponse(&responseTooLargeError{}))
+	}
+	b.size += len(enc)
+
+	if b.stream != nil {
+		b.stream.writeItem(enc)
+		return
+	}
+	b.answers = append(b.answers, enc)
+	b.buffered += len(enc)
+
+	// Switch to streaming once the buffered answers grow large
+	if b.buffered < batchStreamThreshold {
+		return
+	}
+	if streamer, ok := b.conn.(batchStreamer); ok {
+		if b.stream = streamer.streamBatch(ctx, b.limit); b.stream != nil {
+			for _, answer := range b.answers {
+				b.stream.writeItem(answer)
+			}
+			b.answers, b.buffered = nil, 0
+		}
+	}
+}
+
+// flush writes out the remainder of the response.
+func (b *batchResponse) flush(ctx context.Context) {
+	if b.stream != nil {
+		b.stream.close()
+		return
+	}
+	if len(b.answers) > 0 {
+		b.conn.writeJSON(ctx, b.answers)
+	}
+}
+
 // handleMsg handles a single message.
 func (h *handler) handleMsg(msg *jsonrpcMessage) {
 	if ok := h.handleImmediate(msg); ok {
@@ -258,6 +351,37 @@ func (h *handler) handleSubscriptionResult(msg *jsonrpcMessage) {
 	}
 }
 
+// batchRequestOps returns the pending batch requests answered by messages of the
+// given batch.
+func (h *handler) batchRequestOps(msgs []*jsonrpcMessage) map[*requestOp]struct{} {
+	ops := make(map[*requestOp]struct{})
+	for _, msg := range msgs {
+		if !msg.isResponse() {
+			continue
+		}
+		if op := h.respWait[string(msg.ID)]; op != nil && op.sub == nil {
+			ops[op] = struct{}{}
+		}
+	}
+	return ops
+}
+
+// dropMissingResponses stops waiting for the calls of a batch request which were
+// left unanswered by the response batch. BatchCall is notified by closing the
+// response channel.
+func (h *handler) dropMissingResponses(op *requestOp) {
+	var missing bool
+	for _, id := range op.ids {
+		if h.respWait[string(id)] == op {
+			delete(h.respWait, string(id))
+			missing = true
+		}
+	}
+	if missing {
+		close(op.resp)
+	}
+}
+
 // handleResponse processes method call responses.
 func (h *handler) handleResponse(msg *jsonrpcMessage) {
 	op := h.respWait[string(msg.ID)]
@@ -173,9 +173,10 @@ func (c *Client) sendBatchHTTP(ctx context.Context, op *requestOp, msgs []*jsonr
 	if err := json.NewDecoder(respBody).Decode(&respmsgs); err != nil {
 		return err
 	}
-	for i := 0; i < len(respmsgs); i++ {
+	for i := 0; i < len(respmsgs) && i < len(msgs); i++ {
 		op.resp <- &respmsgs[i]
 	}
+	close(op.resp)
 	return nil
 }
 
@@ -41,6 +41,8 @@ const (
 
 var null = json.RawMessage("null")
 
+var errDeferredTooLarge = errors.New("too many messages held back by streamed batch response")
+
 type subscriptionResult struct {
 	ID     string          `json:"subscription"`
 	Result json.RawMessage `json:"result,omitempty"`
@@ -171,6 +173,12 @@ type jsonCodec struct {
 	encMu   sync.Mutex                // guards the encoder
 	encode  func(v interface{}) error // encoder to allow multiple transports
 	conn    deadlineCloser
+
+	stream     func() (io.WriteCloser, error) // opens a raw writer for streaming a batch, nil if unsupported
+	streaming  bool                           // whether a batch response is being streamed
+	deferred   []json.RawMessage              // messages held back until the streamed batch ends
+	deferSize  int                            // size of the held back messages
+	deferLimit int                            // maximum size of the held back messages
 }
 
 // NewFuncCodec creates a codec which uses the given functions to read and write. If conn
@@ -195,7 +203,10 @@ func NewCodec(conn Conn) ServerCodec {
 	enc := json.NewEncoder(conn)
 	dec := json.NewDecoder(conn)
 	dec.UseNumber()
-	return NewFuncCodec(conn, enc.Encode, dec.Decode)
+
+	codec := NewFuncCodec(conn, enc.Encode, dec.Decode).(*jsonCodec)
+	codec.stream = func() (io.WriteCloser, error) { return nopWriteCloser{conn}, nil }
+	return codec
 }
 
 func (c *jsonCodec) peerInfo() PeerInfo {
@@ -229,6 +240,20 @@ func (c *jsonCodec) writeJSON(ctx context.Context, v interface{}) error {
 	c.encMu.Lock()
 	defer c.encMu.Unlock()
 
+	// Writing in between the items of a streamed batch would corrupt it, hold
+	// the message back until the batch ends.
+	if c.streaming {
+		enc, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if c.deferSize+len(enc) > c.deferLimit {
+			return errDeferredTooLarge
+		}
+		c.deferred = append(c.deferred, enc)
+		c.deferSize += len(enc)
+		return nil
+	}
 	deadline, ok := ctx.Deadline()
 	if !ok {
 		deadline = time.Now().Add(defaultWriteTimeout)
@@ -237,6 +262,30 @@ func (c *jsonCodec) writeJSON(ctx context.Context, v interface{}) error {
 	return c.encode(v)
 }
 
+// streamBatch starts writing a batch response item by item. It returns nil if the
+// connection does not support streaming or is already streaming another batch.
+// The connection is only locked while writing, the other messages written until
+// the stream is closed are held back and sent after the batch. At most limit
+// bytes of messages are held back, or batchStreamThreshold if limit is zero.
+func (c *jsonCodec) streamBatch(ctx context.Context, limit int) *jsonBatchStream {
+	c.encMu.Lock()
+	defer c.encMu.Unlock()
+
+	if c.stream == nil || c.streaming {
+		return nil
+	}
+	s := &jsonBatchStream{codec: c, ctx: ctx}
+	if s.w, s.err = c.stream(); s.err != nil {
+		return nil
+	}
+	if limit == 0 {
+		limit = batchStreamThreshold
+	}
+	c.streaming, c.deferLimit = true, limit
+	s.write([]byte{'['})
+	return s
+}
+
 func (c *jsonCodec) close() {
 	c.closer.Do(func() {
 		close(c.closeCh)
@@ -249,6 +298,67 @@ func (c *jsonCodec) closed() <-chan interface{} {
 	return c.closeCh
 }
 
+// jsonBatchStream writes the items of a batch response to a connection as they
+// become available. Write errors are sticky, dropping all subsequent items.
+type jsonBatchStream struct {
+	codec *jsonCodec
+	ctx   context.Context
+	w     io.WriteCloser
+	items int
+	err   error
+}
+
+// writeItem writes the next item of the batch.
+func (s *jsonBatchStream) writeItem(item json.RawMessage) {
+	s.codec.encMu.Lock()
+	defer s.codec.encMu.Unlock()
+
+	if s.items > 0 {
+		s.write([]byte{','})
+	}
+	s.write(item)
+	s.items++
+}
+
+// close terminates the batch and writes out the messages held back meanwhile.
+func (s *jsonBatchStream) close() error {
+	c := s.codec
+	c.encMu.Lock()
+	defer c.encMu.Unlock()
+
+	s.write([]byte("]\n"))
+	if err := s.w.Close(); s.err == nil {
+		s.err = err
+	}
+	c.streaming = false
+	for _, v := range c.deferred {
+		c.conn.SetWriteDeadline(time.Now().Add(defaultWriteTimeout))
+		c.encode(v)
+	}
+	c.deferred, c.deferSize = nil, 0
+	return s.err
+}
+
+func (s *jsonBatchStream) write(data []byte) {
+	if s.err != nil {
+		return
+	}
+	deadline, ok := s.ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultWriteTimeout)
+	}
+	s.codec.conn.SetWriteDeadline(deadline)
+	_, s.err = s.w.Write(data)
+}
+
+// nopWriteCloser turns a connection into the writer of a streamed batch, which
+// needs no closing.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
 // parseMessage parses raw bytes as a (batch of) JSON-RPC message(s). There are no error
 // checks in this function because the raw message has already been syntax-checked when it
 // is called. Any non-JSON-RPC messages in the input return the zero value of
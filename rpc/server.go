@@ -45,10 +45,17 @@ const (
 type Server struct {
 	services serviceRegistry
 	idgen    func() ID
+	batch    batchConfig
 	run      int32
 	codecs   mapset.Set
 }
 
+// batchConfig holds the limits applied to incoming batch requests.
+type batchConfig struct {
+	itemLimit       int // maximum number of requests in a batch, zero for no limit
+	responseMaxSize int // maximum number of bytes in a batch response, zero for no limit
+}
+
 // NewServer creates a new server instance with no registered handlers.
 func NewServer() *Server {
 	server := &Server{idgen: randomIDGenerator(), codecs: mapset.NewSet(), run: 1}
@@ -59,6 +66,16 @@ func NewServer() *Server {
 	return server
 }
 
+// SetBatchLimits sets the limits applied to batch requests. Batches of more than
+// itemLimit requests are rejected with a single error. Once the responses of a batch
+// grow beyond maxResponseSize bytes, the remaining requests are answered with an
+// error instead of being executed. A zero value disables the respective limit.
+//
+// This method should be called before the server starts processing requests.
+func (s *Server) SetBatchLimits(itemLimit, maxResponseSize int) {
+	s.batch = batchConfig{itemLimit: itemLimit, responseMaxSize: maxResponseSize}
+}
+
 // RegisterName creates a service for the given receiver type under the given name. When no
 // methods on the given receiver match the criteria to be either a RPC method or a
 // subscription an error is returned. Otherwise a new service is created and added to the
@@ -84,7 +101,7 @@ func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
 	s.codecs.Add(codec)
 	defer s.codecs.Remove(codec)
 
-	c := initClient(codec, s.idgen, &s.services)
+	c := initClient(codec, s.idgen, &s.services, s.batch)
 	<-codec.closed()
 	c.Close()
 }
@@ -98,7 +115,7 @@ func (s *Server) serveSingleRequest(ctx context.Context, codec ServerCodec) {
 		return
 	}
 
-	h := newHandler(ctx, codec, s.idgen, &s.services)
+	h := newHandler(ctx, codec, s.idgen, &s.services, s.batch)
 	h.allowSubscribe = false
 	defer h.close(io.EOF, nil)
 
@@ -58,6 +58,13 @@ type jsonWriter interface {
 	remoteAddr() string
 }
 
+// batchStreamer is implemented by connections able to write the items of a batch
+// response one by one, instead of all at once. Messages written while a batch is
+// streamed are held back until it ends, up to limit bytes.
+type batchStreamer interface {
+	streamBatch(ctx context.Context, limit int) *jsonBatchStream
+}
+
 type BlockNumber int64
 
 const (
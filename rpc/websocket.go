@@ -20,6 +20,7 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
@@ -60,7 +61,7 @@ func (s *Server) WebsocketHandler(allowedOrigins []string) http.Handler {
 			log.Debug("WebSocket upgrade failed", "err", err)
 			return
 		}
-		codec := newWebsocketCodec(conn, r.Host, r.Header)
+		codec := newWebsocketCodec(conn, r.Host, r.Header, wsPingInterval)
 		s.ServeCodec(codec, 0)
 	})
 }
@@ -197,7 +198,7 @@ func DialWebsocketWithDialer(ctx context.Context, endpoint, origin string, diale
 			}
 			return nil, hErr
 		}
-		return newWebsocketCodec(conn, endpoint, header), nil
+		return newWebsocketCodec(conn, endpoint, header, wsPingInterval), nil
 	})
 }
 
@@ -237,25 +238,31 @@ type websocketCodec struct {
 	conn *websocket.Conn
 	info PeerInfo
 
-	wg        sync.WaitGroup
-	pingReset chan struct{}
+	wg           sync.WaitGroup
+	pingReset    chan struct{}
+	pingInterval time.Duration
 }
 
-func newWebsocketCodec(conn *websocket.Conn, host string, req http.Header) ServerCodec {
+func newWebsocketCodec(conn *websocket.Conn, host string, req http.Header, pingInterval time.Duration) ServerCodec {
 	conn.SetReadLimit(wsMessageSizeLimit)
 	conn.SetPongHandler(func(appData string) error {
 		conn.SetReadDeadline(time.Time{})
 		return nil
 	})
 	wc := &websocketCodec{
-		jsonCodec: NewFuncCodec(conn, conn.WriteJSON, conn.ReadJSON).(*jsonCodec),
-		conn:      conn,
-		pingReset: make(chan struct{}, 1),
+		jsonCodec:    NewFuncCodec(conn, conn.WriteJSON, conn.ReadJSON).(*jsonCodec),
+		conn:         conn,
+		pingReset:    make(chan struct{}, 1),
+		pingInterval: pingInterval,
 		info: PeerInfo{
 			Transport:  "ws",
 			RemoteAddr: conn.RemoteAddr().String(),
 		},
 	}
+	// Stream batches as a single message written in fragments
+	wc.jsonCodec.stream = func() (io.WriteCloser, error) {
+		return conn.NextWriter(websocket.TextMessage)
+	}
 	// Fill in connection details.
 	wc.info.HTTP.Host = host
 	wc.info.HTTP.Origin = req.Get("Origin")
@@ -288,8 +295,11 @@ func (wc *websocketCodec) writeJSON(ctx context.Context, v interface{}) error {
 }
 
 // pingLoop sends periodic ping frames when the connection is idle.
+//
+// Pings are sent as control frames, which may be interleaved with the fragments
+// of a streamed batch without closing its writer.
 func (wc *websocketCodec) pingLoop() {
-	var timer = time.NewTimer(wsPingInterval)
+	var timer = time.NewTimer(wc.pingInterval)
 	defer wc.wg.Done()
 	defer timer.Stop()
 
@@ -301,14 +311,11 @@ func (wc *websocketCodec) pingLoop() {
 			if !timer.Stop() {
 				<-timer.C
 			}
-			timer.Reset(wsPingInterval)
+			timer.Reset(wc.pingInterval)
 		case <-timer.C:
-			wc.jsonCodec.encMu.Lock()
-			wc.conn.SetWriteDeadline(time.Now().Add(wsPingWriteTimeout))
-			wc.conn.WriteMessage(websocket.PingMessage, nil)
+			wc.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsPingWriteTimeout))
 			wc.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
-			wc.jsonCodec.encMu.Unlock()
-			timer.Reset(wsPingInterval)
+			timer.Reset(wc.pingInterval)
 		}
 	}
 }
@@ -117,6 +117,113 @@ func TestWebsocketLargeCall(t *testing.T) {
 	}
 }
 
+// Tests that batch responses larger than the streaming threshold are delivered
+// intact as a single websocket message.
+func TestWebsocketBatchStreaming(t *testing.T) {
+	t.Parallel()
+
+	var (
+		srv     = newTestServer()
+		httpsrv = httptest.NewServer(srv.WebsocketHandler([]string{"*"}))
+		wsURL   = "ws:" + strings.TrimPrefix(httpsrv.URL, "http:")
+	)
+	defer srv.Stop()
+	defer httpsrv.Close()
+
+	client, err := DialWebsocket(context.Background(), wsURL, "")
+	if err != nil {
+		t.Fatalf("can't dial: %v", err)
+	}
+	defer client.Close()
+
+	var (
+		str   = strings.Repeat("x", 1024*1024)
+		batch = make([]BatchElem, batchStreamThreshold/len(str)+2)
+	)
+	for i := range batch {
+		batch[i] = BatchElem{
+			Method: "test_echo",
+			Args:   []interface{}{str, i},
+			Result: new(echoResult),
+		}
+	}
+	if err := client.BatchCall(batch); err != nil {
+		t.Fatal(err)
+	}
+	for i, elem := range batch {
+		if elem.Error != nil {
+			t.Fatalf("item %d: unexpected error: %v", i, elem.Error)
+		}
+		if have := elem.Result.(*echoResult); have.String != str || have.Int != i {
+			t.Fatalf("item %d: result mismatch", i)
+		}
+	}
+}
+
+// Tests that pings sent while a batch response is streamed don't cut the
+// response short.
+func TestWebsocketBatchStreamingPing(t *testing.T) {
+	t.Parallel()
+
+	var (
+		srv      = newTestServer()
+		upgrader = websocket.Upgrader{ReadBufferSize: wsReadBuffer, WriteBufferSize: wsWriteBuffer}
+		httpsrv  = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			srv.ServeCodec(newWebsocketCodec(conn, r.Host, r.Header, 10*time.Millisecond), 0)
+		}))
+		wsURL = "ws:" + strings.TrimPrefix(httpsrv.URL, "http:")
+	)
+	defer srv.Stop()
+	defer httpsrv.Close()
+
+	client, err := DialWebsocket(context.Background(), wsURL, "")
+	if err != nil {
+		t.Fatalf("can't dial: %v", err)
+	}
+	defer client.Close()
+
+	// Push the response over the streaming threshold, then stall it for a few
+	// ping intervals.
+	var (
+		str   = strings.Repeat("x", 1024*1024)
+		batch []BatchElem
+	)
+	for i := 0; i < batchStreamThreshold/len(str)+1; i++ {
+		batch = append(batch, BatchElem{
+			Method: "test_echo",
+			Args:   []interface{}{str, i},
+			Result: new(echoResult),
+		})
+	}
+	for i := 0; i < 5; i++ {
+		batch = append(batch, BatchElem{
+			Method: "test_sleep",
+			Args:   []interface{}{30 * time.Millisecond},
+			Result: new(interface{}),
+		})
+	}
+	batch = append(batch, BatchElem{
+		Method: "test_echo",
+		Args:   []interface{}{"last", 0},
+		Result: new(echoResult),
+	})
+	if err := client.BatchCall(batch); err != nil {
+		t.Fatal(err)
+	}
+	for i, elem := range batch {
+		if elem.Error != nil {
+			t.Fatalf("item %d: unexpected error: %v", i, elem.Error)
+		}
+	}
+	if have := batch[len(batch)-1].Result.(*echoResult); have.String != "last" {
+		t.Fatalf("last item: result mismatch: %q", have.String)
+	}
+}
+
 func TestWebsocketPeerInfo(t *testing.T) {
 	var (
 		s     = newTestServer()
@@ -87,6 +87,8 @@ type Database struct {
 	childrenSize common.StorageSize // Storage size of the external children tracking
 	preimages    *preimageStore     // The store for caching preimages
 
+	path *pathDatabase // Backend of the path scheme, nil for the hash scheme
+
 	lock sync.RWMutex
 }
 
@@ -262,9 +264,11 @@ func expandNode(hash hashNode, n node) node {
 
 // Config defines all necessary options for database.
 type Config struct {
-	Cache     int    // Memory allowance (MB) to use for caching trie nodes in memory
-	Journal   string // Journal of clean cache to survive node restarts
-	Preimages bool   // Flag whether the preimage of trie key is recorded
+	Cache        int    // Memory allowance (MB) to use for caching trie nodes in memory
+	Journal      string // Journal of clean cache to survive node restarts
+	Preimages    bool   // Flag whether the preimage of trie key is recorded
+	Scheme       string // Node scheme to use, the one recorded in the database if empty
+	ReverseDiffs uint64 // Number of reverse diffs to retain with the path scheme
 }
 
 // NewDatabase creates a new trie database to store ephemeral trie content before
@@ -298,9 +302,49 @@ func NewDatabaseWithConfig(diskdb ethdb.KeyValueStore, config *Config) *Database
 		}},
 		preimages: preimage,
 	}
+	scheme := rawdb.ReadStateScheme(diskdb)
+	if config != nil && config.Scheme != "" {
+		scheme = config.Scheme
+	}
+	if scheme == rawdb.PathScheme {
+		var history uint64
+		if config != nil {
+			history = config.ReverseDiffs
+		}
+		db.path = newPathDatabase(diskdb, cleans, history)
+	}
 	return db
 }
 
+// Scheme returns the node scheme used by the database.
+func (db *Database) Scheme() string {
+	if db.path != nil {
+		return rawdb.PathScheme
+	}
+	return rawdb.HashScheme
+}
+
+// nodeAt retrieves the trie node with the given hash at the given path of
+// the trie identified by owner, nil if it's not available. The owner and
+// path are only used by the path scheme.
+func (db *Database) nodeAt(owner common.Hash, path []byte, hash common.Hash) node {
+	if db.path != nil {
+		return db.path.node(owner, path, hash)
+	}
+	return db.node(hash)
+}
+
+// blobAt retrieves the rlp-encoded trie node with the given hash at the given
+// path of the trie identified by owner, nil if it's not available. The owner
+// and path are only used by the path scheme.
+func (db *Database) blobAt(owner common.Hash, path []byte, hash common.Hash) []byte {
+	if db.path != nil {
+		return db.path.blob(owner, path, hash)
+	}
+	blob, _ := db.Node(hash)
+	return blob
+}
+
 // DiskDB retrieves the persistent storage backing the trie database.
 func (db *Database) DiskDB() ethdb.KeyValueStore {
 	return db.diskdb
@@ -375,12 +419,18 @@ func (db *Database) node(hash common.Hash) node {
 }
 
 // Node retrieves an encoded cached trie node from memory. If it cannot be found
-// cached, the method queries the persistent database for the content.
+// cached, the method queries the persistent database for the content. The path
+// scheme doesn't support looking up nodes by hash, ErrPathSchemeNode is
+// returned then.
 func (db *Database) Node(hash common.Hash) ([]byte, error) {
 	// It doesn't make sense to retrieve the metaroot
 	if hash == (common.Hash{}) {
 		return nil, errors.New("not found")
 	}
+	// Nodes can't be looked up by hash alone in the path scheme
+	if db.path != nil {
+		return nil, ErrPathSchemeNode
+	}
 	// Retrieve the node from the clean cache if available
 	if db.cleans != nil {
 		if enc := db.cleans.Get(nil, hash[:]); enc != nil {
@@ -435,6 +485,9 @@ func (db *Database) Nodes() []common.Hash {
 // and external node(e.g. storage trie root), all internal trie nodes
 // are referenced together by database itself.
 func (db *Database) Reference(child common.Hash, parent common.Hash) {
+	if db.path != nil {
+		return // no reference counting in the path scheme
+	}
 	db.lock.Lock()
 	defer db.lock.Unlock()
 
@@ -469,6 +522,9 @@ func (db *Database) Dereference(root common.Hash) {
 		log.Error("Attempted to dereference the trie cache meta root")
 		return
 	}
+	if db.path != nil {
+		return // no reference counting in the path scheme
+	}
 	db.lock.Lock()
 	defer db.lock.Unlock()
 
@@ -543,6 +599,14 @@ func (db *Database) dereference(child common.Hash, parent common.Hash) {
 // Note, this method is a non-synchronized mutator. It is unsafe to call this
 // concurrently with other mutators.
 func (db *Database) Cap(limit common.StorageSize) error {
+	if db.path != nil {
+		if db.preimages != nil {
+			if err := db.preimages.commit(false); err != nil {
+				return err
+			}
+		}
+		return db.path.capBuffer(limit)
+	}
 	// Create a database batch to flush persistent data out. It is important that
 	// outside code doesn't see an inconsistent state (referenced data removed from
 	// memory cache during commit but not yet in persistent storage). This is ensured
@@ -628,6 +692,20 @@ func (db *Database) Cap(limit common.StorageSize) error {
 // Note, this method is a non-synchronized mutator. It is unsafe to call this
 // concurrently with other mutators.
 func (db *Database) Commit(node common.Hash, report bool, callback func(common.Hash)) error {
+	if db.path != nil {
+		if db.preimages != nil {
+			if err := db.preimages.commit(true); err != nil {
+				return err
+			}
+		}
+		if err := db.path.commit(node); err != nil {
+			return err
+		}
+		if report {
+			log.Info("Persisted trie from memory database", "root", node)
+		}
+		return nil
+	}
 	// Create a database batch to flush persistent data out. It is important that
 	// outside code doesn't see an inconsistent state (referenced data removed from
 	// memory cache during commit but not yet in persistent storage). This is ensured
@@ -763,6 +841,9 @@ func (c *cleaner) Delete(key []byte) error {
 // Update inserts the dirty nodes in provided nodeset into database and
 // link the account trie with multiple storage tries if necessary.
 func (db *Database) Update(nodes *MergedNodeSet) error {
+	if db.path != nil {
+		return errors.New("path scheme requires the state transition, use UpdateState")
+	}
 	db.lock.Lock()
 	defer db.lock.Unlock()
 
@@ -776,6 +857,9 @@ func (db *Database) Update(nodes *MergedNodeSet) error {
 			if !ok {
 				return fmt.Errorf("missing node %x %v", owner, path)
 			}
+			if n.isDeleted() {
+				continue // stale nodes are garbage collected by reference counting
+			}
 			db.insert(n.hash, int(n.size), n.node)
 		}
 	}
@@ -795,9 +879,54 @@ func (db *Database) Update(nodes *MergedNodeSet) error {
 	return nil
 }
 
+// UpdateState inserts the dirty nodes of the state transition from parent to
+// root into the database. The hash scheme only needs the nodes, while the path
+// scheme keeps the transition as a diff layer on top of its parent state.
+func (db *Database) UpdateState(root common.Hash, parent common.Hash, nodes *MergedNodeSet) error {
+	if db.path == nil {
+		return db.Update(nodes)
+	}
+	if parent == (common.Hash{}) {
+		parent = emptyRoot
+	}
+	if err := db.path.update(root, parent, nodes); err != nil {
+		return err
+	}
+	if db.preimages != nil {
+		return db.preimages.commit(false)
+	}
+	return nil
+}
+
+// Recoverable reports whether the persisted state can be reverted to the one
+// with the given root, using the retained reverse diffs of the path scheme.
+func (db *Database) Recoverable(root common.Hash) bool {
+	if db.path == nil {
+		return false
+	}
+	return db.path.recoverable(root)
+}
+
+// Recover reverts the persisted state to the one with the given root, using
+// the retained reverse diffs of the path scheme. All the states kept in memory
+// are discarded.
+func (db *Database) Recover(root common.Hash) error {
+	if db.path == nil {
+		return errors.New("state recovery is only supported by the path scheme")
+	}
+	return db.path.recover(root)
+}
+
 // Size returns the current storage size of the memory cache in front of the
 // persistent database layer.
 func (db *Database) Size() (common.StorageSize, common.StorageSize) {
+	if db.path != nil {
+		var preimageSize common.StorageSize
+		if db.preimages != nil {
+			preimageSize = db.preimages.size()
+		}
+		return db.path.size(), preimageSize
+	}
 	db.lock.RLock()
 	defer db.lock.RUnlock()
 
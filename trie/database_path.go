@@ -0,0 +1,592 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/fastcache"
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/core/rawdb"
+	"github.com/foreverbit/biternal/crypto"
+	"github.com/foreverbit/biternal/ethdb"
+	"github.com/foreverbit/biternal/log"
+	"github.com/foreverbit/biternal/rlp"
+)
+
+const (
+	// maxDiffLayers is the number of state transitions kept in memory on top
+	// of the disk state. The bottom-most one is persisted once exceeded.
+	maxDiffLayers = 128
+
+	// pathBufferSize is the amount of persisted trie nodes accumulated in
+	// memory before they are written out to disk in one batch.
+	pathBufferSize = 64 * 1024 * 1024
+)
+
+// errStateUnavailable is returned if a state is neither in memory nor on disk.
+var errStateUnavailable = errors.New("state not available")
+
+// diffLayer is a state transition kept in memory, holding all the trie nodes
+// changed by it.
+type diffLayer struct {
+	root   common.Hash
+	parent *diffLayer                             // Parent layer, nil if the parent is the disk state
+	nodes  map[common.Hash]map[string]*memoryNode // Changed nodes keyed by owner and path, deletions included
+	wiped  map[common.Hash]struct{}               // Owners whose stored trie is deleted before applying the nodes
+	size   common.StorageSize                     // Approximate size of the changed nodes
+}
+
+// reverseDiff holds the previous content of the trie nodes changed by a
+// persisted state transition, which is enough to revert it.
+type reverseDiff struct {
+	Parent common.Hash // State root before the transition
+	Root   common.Hash // State root after the transition
+	Nodes  []reverseDiffNode
+}
+
+// reverseDiffNode is the previous content of a single trie node, empty if the
+// node didn't exist.
+type reverseDiffNode struct {
+	Owner common.Hash
+	Path  []byte
+	Blob  []byte
+}
+
+// pathDatabase stores the trie nodes keyed by owner and path. A single state
+// is kept on disk, stale nodes being overwritten in place. The recent state
+// transitions are kept in memory as diff layers on top of it, and a bounded
+// number of reverse diffs is kept on disk to revert the persisted state.
+//
+// Since the content of a node is defined by its hash, nodes are looked up by
+// owner, path and hash. Any node matching all three is valid to use, no matter
+// which state it was written by.
+type pathDatabase struct {
+	diskdb  ethdb.KeyValueStore
+	cleans  *fastcache.Cache // Clean nodes keyed by owner and path, prefixed by their hash
+	history uint64           // Number of reverse diffs to retain, zero disables them
+
+	layers map[common.Hash]*diffLayer // Diff layers keyed by state root
+	index  map[string][]*memoryNode   // Nodes of all diff layers keyed by owner and path
+
+	root       common.Hash            // Root of the disk state, including the buffer
+	id         uint64                 // Id of the disk state, including the buffer
+	tail       uint64                 // Id of the oldest reverse diff on disk, zero if none
+	buffer     map[string]*memoryNode // Persisted nodes not yet written out, keyed by owner and path
+	bufferSize common.StorageSize     // Approximate size of the buffered nodes and reverse diffs
+	diffs      []*reverseDiff         // Reverse diffs not yet written out, the last one is for id
+
+	lock sync.RWMutex
+}
+
+// newPathDatabase opens the state stored with the path scheme in the database.
+func newPathDatabase(diskdb ethdb.KeyValueStore, cleans *fastcache.Cache, history uint64) *pathDatabase {
+	db := &pathDatabase{
+		diskdb:  diskdb,
+		cleans:  cleans,
+		history: history,
+		layers:  make(map[common.Hash]*diffLayer),
+		index:   make(map[string][]*memoryNode),
+		root:    emptyRoot,
+		id:      rawdb.ReadPersistentStateID(diskdb),
+		tail:    rawdb.ReadReverseDiffTail(diskdb),
+		buffer:  make(map[string]*memoryNode),
+	}
+	if blob := rawdb.ReadAccountTrieNode(diskdb, nil); len(blob) > 0 {
+		db.root = crypto.Keccak256Hash(blob)
+	}
+	return db
+}
+
+// nodeKey returns the key of a node in the buffer and the caches.
+func nodeKey(owner common.Hash, path string) string {
+	return string(owner.Bytes()) + path
+}
+
+// readNode retrieves the node stored on disk at the given owner and path.
+func readNode(db ethdb.KeyValueReader, owner common.Hash, path []byte) []byte {
+	if owner == (common.Hash{}) {
+		return rawdb.ReadAccountTrieNode(db, path)
+	}
+	return rawdb.ReadStorageTrieNode(db, owner, path)
+}
+
+// writeNode stores the node at the given owner and path, deleting it if the
+// blob is empty.
+func writeNode(db ethdb.KeyValueWriter, owner common.Hash, path []byte, blob []byte) {
+	switch {
+	case owner == (common.Hash{}) && len(blob) == 0:
+		rawdb.DeleteAccountTrieNode(db, path)
+	case owner == (common.Hash{}):
+		rawdb.WriteAccountTrieNode(db, path, blob)
+	case len(blob) == 0:
+		rawdb.DeleteStorageTrieNode(db, owner, path)
+	default:
+		rawdb.WriteStorageTrieNode(db, owner, path, blob)
+	}
+}
+
+// dirty retrieves the node with the given hash from the diff layers or the
+// buffer. The returned flag reports whether the lookup is conclusive, which
+// is the case if the node was found, or the buffer overrides the disk.
+func (db *pathDatabase) dirty(key string, hash common.Hash) (*memoryNode, bool) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	for _, n := range db.index[key] {
+		if n.hash == hash {
+			memcacheDirtyHitMeter.Mark(1)
+			memcacheDirtyReadMeter.Mark(int64(n.size))
+			return n, true
+		}
+	}
+	if n, ok := db.buffer[key]; ok {
+		if n.hash != hash {
+			return nil, true
+		}
+		memcacheDirtyHitMeter.Mark(1)
+		memcacheDirtyReadMeter.Mark(int64(n.size))
+		return n, true
+	}
+	memcacheDirtyMissMeter.Mark(1)
+	return nil, false
+}
+
+// clean retrieves the node with the given hash from the clean cache or the
+// disk, nil if the stored node doesn't match the hash.
+func (db *pathDatabase) clean(key string, owner common.Hash, path []byte, hash common.Hash) []byte {
+	if db.cleans != nil {
+		if enc := db.cleans.Get(nil, []byte(key)); len(enc) > common.HashLength && common.BytesToHash(enc[:common.HashLength]) == hash {
+			memcacheCleanHitMeter.Mark(1)
+			memcacheCleanReadMeter.Mark(int64(len(enc) - common.HashLength))
+			return enc[common.HashLength:]
+		}
+	}
+	blob := readNode(db.diskdb, owner, path)
+	if len(blob) == 0 || crypto.Keccak256Hash(blob) != hash {
+		return nil
+	}
+	if db.cleans != nil {
+		db.cleans.Set([]byte(key), append(hash.Bytes(), blob...))
+		memcacheCleanMissMeter.Mark(1)
+		memcacheCleanWriteMeter.Mark(int64(len(blob)))
+	}
+	return blob
+}
+
+// node retrieves the trie node with the given hash at the given owner and
+// path, nil if it's not available.
+func (db *pathDatabase) node(owner common.Hash, path []byte, hash common.Hash) node {
+	key := nodeKey(owner, string(path))
+	if n, ok := db.dirty(key, hash); ok {
+		if n == nil {
+			return nil
+		}
+		return expandNode(hash[:], n.node)
+	}
+	if blob := db.clean(key, owner, path, hash); blob != nil {
+		return mustDecodeNode(hash[:], blob)
+	}
+	return nil
+}
+
+// blob retrieves the rlp-encoded trie node with the given hash at the given
+// owner and path, nil if it's not available.
+func (db *pathDatabase) blob(owner common.Hash, path []byte, hash common.Hash) []byte {
+	key := nodeKey(owner, string(path))
+	if n, ok := db.dirty(key, hash); ok {
+		if n == nil {
+			return nil
+		}
+		return nodeToBytes(n.node)
+	}
+	return db.clean(key, owner, path, hash)
+}
+
+// update adds a new diff layer for the state transition from parent to root.
+// The bottom-most layer is persisted if the number of layers is exceeded.
+func (db *pathDatabase) update(root common.Hash, parent common.Hash, nodes *MergedNodeSet) error {
+	if root == parent {
+		return nil
+	}
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if _, ok := db.layers[root]; ok || root == db.root {
+		return nil
+	}
+	layer := &diffLayer{
+		root:  root,
+		nodes: make(map[common.Hash]map[string]*memoryNode),
+		wiped: make(map[common.Hash]struct{}),
+	}
+	if parent != db.root {
+		if layer.parent = db.layers[parent]; layer.parent == nil {
+			return fmt.Errorf("%w: parent %x", errStateUnavailable, parent)
+		}
+	}
+	for owner, set := range nodes.sets {
+		subset := make(map[string]*memoryNode, len(set.nodes))
+		for path, n := range set.nodes {
+			subset[path] = n
+			layer.size += common.StorageSize(len(path) + int(n.size))
+			if !n.isDeleted() {
+				key := nodeKey(owner, path)
+				db.index[key] = append(db.index[key], n)
+			}
+		}
+		layer.nodes[owner] = subset
+		if set.wiped {
+			layer.wiped[owner] = struct{}{}
+		}
+	}
+	db.layers[root] = layer
+
+	for {
+		depth, bottom := 0, layer
+		for l := layer; l != nil; l = l.parent {
+			depth, bottom = depth+1, l
+		}
+		if depth <= maxDiffLayers {
+			return nil
+		}
+		if err := db.persist(bottom); err != nil {
+			return err
+		}
+	}
+}
+
+// persist merges the given diff layer, whose parent is the disk state, into
+// the buffer and drops all the layers not descending from it.
+func (db *pathDatabase) persist(bottom *diffLayer) error {
+	diff := &reverseDiff{Parent: db.root, Root: bottom.root}
+
+	// Delete all the stored nodes of the wiped tries, apart from the ones
+	// overwritten by the layer anyway.
+	for owner := range bottom.wiped {
+		paths, err := db.storedPaths(owner)
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			if _, ok := bottom.nodes[owner][path]; !ok {
+				db.stage(diff, owner, path, &memoryNode{})
+			}
+		}
+	}
+	for owner, subset := range bottom.nodes {
+		for path, n := range subset {
+			db.stage(diff, owner, path, n)
+		}
+	}
+	db.root, db.id = bottom.root, db.id+1
+	db.diffs = append(db.diffs, diff)
+
+	// Drop the persisted layer along with the ones on the other branches,
+	// the remaining ones are linked up with the disk state.
+	for root, layer := range db.layers {
+		l := layer
+		for l != nil && l != bottom {
+			l = l.parent
+		}
+		if l == nil || layer == bottom {
+			db.drop(layer)
+			delete(db.layers, root)
+		}
+	}
+	for _, layer := range db.layers {
+		if layer.parent == bottom {
+			layer.parent = nil
+		}
+	}
+	if db.bufferSize > pathBufferSize {
+		return db.flush()
+	}
+	return nil
+}
+
+// stage adds the node at the given owner and path to the buffer, recording
+// its previous content in the reverse diff.
+func (db *pathDatabase) stage(diff *reverseDiff, owner common.Hash, path string, n *memoryNode) {
+	key := nodeKey(owner, path)
+	prev, ok := db.buffer[key]
+	if ok {
+		db.bufferSize -= common.StorageSize(len(key) + int(prev.size))
+	}
+	var blob []byte
+	switch {
+	case !ok:
+		blob = readNode(db.diskdb, owner, []byte(path))
+	case !prev.isDeleted():
+		blob = nodeToBytes(prev.node)
+	}
+	diff.Nodes = append(diff.Nodes, reverseDiffNode{Owner: owner, Path: []byte(path), Blob: blob})
+
+	db.buffer[key] = n
+	db.bufferSize += common.StorageSize(len(key) + int(n.size) + len(blob))
+}
+
+// storedPaths returns the paths of all the nodes of the given storage trie in
+// the disk state, the buffered ones included.
+func (db *pathDatabase) storedPaths(owner common.Hash) ([]string, error) {
+	var (
+		paths  []string
+		prefix = nodeKey(owner, "")
+	)
+	for key, n := range db.buffer {
+		if strings.HasPrefix(key, prefix) && !n.isDeleted() {
+			paths = append(paths, key[len(prefix):])
+		}
+	}
+	err := rawdb.IterateStorageTrieNodes(db.diskdb, owner, func(path []byte, blob []byte) {
+		if _, ok := db.buffer[nodeKey(owner, string(path))]; !ok {
+			paths = append(paths, string(path))
+		}
+	})
+	return paths, err
+}
+
+// drop removes the nodes of the given layer from the index.
+func (db *pathDatabase) drop(layer *diffLayer) {
+	for owner, subset := range layer.nodes {
+		for path, n := range subset {
+			if n.isDeleted() {
+				continue
+			}
+			key := nodeKey(owner, path)
+			nodes := db.index[key]
+			for i, m := range nodes {
+				if m == n {
+					nodes = append(nodes[:i], nodes[i+1:]...)
+					break
+				}
+			}
+			if len(nodes) == 0 {
+				delete(db.index, key)
+			} else {
+				db.index[key] = nodes
+			}
+		}
+	}
+}
+
+// flush writes out the buffered nodes and reverse diffs in a single batch,
+// pruning the reverse diffs beyond the retention limit.
+func (db *pathDatabase) flush() error {
+	if len(db.diffs) == 0 {
+		return nil
+	}
+	var (
+		start = time.Now()
+		batch = db.diskdb.NewBatch()
+		blobs = make(map[string][]byte, len(db.buffer))
+	)
+	for key, n := range db.buffer {
+		var blob []byte
+		if !n.isDeleted() {
+			blob = nodeToBytes(n.node)
+		}
+		writeNode(batch, common.BytesToHash([]byte(key[:common.HashLength])), []byte(key[common.HashLength:]), blob)
+		blobs[key] = blob
+	}
+	first := db.id - uint64(len(db.diffs)) + 1
+	if db.history > 0 {
+		for i, diff := range db.diffs {
+			enc, err := rlp.EncodeToBytes(diff)
+			if err != nil {
+				return err
+			}
+			rawdb.WriteReverseDiff(batch, first+uint64(i), enc)
+			rawdb.WriteStateID(batch, diff.Root, first+uint64(i))
+		}
+		if db.tail == 0 {
+			db.tail = first
+		}
+	}
+	// Prune the reverse diffs beyond the limit. The parent state of a pruned
+	// diff can't be reverted to anymore.
+	for db.tail != 0 && db.tail+db.history <= db.id {
+		var parent common.Hash
+		if db.tail >= first {
+			parent = db.diffs[db.tail-first].Parent
+		} else if blob := rawdb.ReadReverseDiff(db.diskdb, db.tail); len(blob) > 0 {
+			var diff reverseDiff
+			if err := rlp.DecodeBytes(blob, &diff); err != nil {
+				return err
+			}
+			parent = diff.Parent
+		}
+		if db.tail >= first+1 && db.diffs[db.tail-first-1].Root == parent {
+			rawdb.DeleteStateID(batch, parent) // written by this very batch
+		} else if id := rawdb.ReadStateID(db.diskdb, parent); id != nil && *id == db.tail-1 {
+			rawdb.DeleteStateID(batch, parent)
+		}
+		rawdb.DeleteReverseDiff(batch, db.tail)
+		if db.tail++; db.tail > db.id {
+			db.tail = 0
+		}
+	}
+	rawdb.WritePersistentStateID(batch, db.id)
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	if db.cleans != nil {
+		for key, blob := range blobs {
+			if len(blob) == 0 {
+				db.cleans.Del([]byte(key))
+			} else {
+				db.cleans.Set([]byte(key), append(db.buffer[key].hash.Bytes(), blob...))
+			}
+		}
+	}
+	memcacheFlushTimeTimer.Update(time.Since(start))
+	memcacheFlushNodesMeter.Mark(int64(len(db.buffer)))
+	memcacheFlushSizeMeter.Mark(int64(db.bufferSize))
+
+	log.Debug("Persisted trie nodes", "nodes", len(db.buffer), "size", db.bufferSize, "states", len(db.diffs), "id", db.id, "time", common.PrettyDuration(time.Since(start)))
+	db.buffer, db.bufferSize, db.diffs = make(map[string]*memoryNode), 0, nil
+	return nil
+}
+
+// commit persists all the diff layers up to the given state and writes out
+// the buffer.
+func (db *pathDatabase) commit(root common.Hash) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if root != db.root {
+		layer := db.layers[root]
+		if layer == nil {
+			return fmt.Errorf("%w: %x", errStateUnavailable, root)
+		}
+		var chain []*diffLayer
+		for l := layer; l != nil; l = l.parent {
+			chain = append(chain, l)
+		}
+		for i := len(chain) - 1; i >= 0; i-- {
+			if err := db.persist(chain[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return db.flush()
+}
+
+// stateID returns the id of the given state if it can be reverted to with
+// the available reverse diffs.
+func (db *pathDatabase) stateID(root common.Hash) (uint64, bool) {
+	if db.history == 0 || root == db.root || db.layers[root] != nil {
+		return 0, false
+	}
+	for i := len(db.diffs) - 1; i >= 0; i-- {
+		if db.diffs[i].Parent == root {
+			return db.id - uint64(len(db.diffs)-i), true
+		}
+	}
+	id := rawdb.ReadStateID(db.diskdb, root)
+	if id == nil || *id >= db.id || db.tail == 0 || db.tail > *id+1 {
+		return 0, false
+	}
+	return *id, true
+}
+
+// recoverable reports whether the disk state can be reverted to the given one.
+func (db *pathDatabase) recoverable(root common.Hash) bool {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	_, ok := db.stateID(root)
+	return ok
+}
+
+// recover reverts the disk state to the given one by applying the reverse
+// diffs. All the diff layers are discarded.
+func (db *pathDatabase) recover(root common.Hash) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	target, ok := db.stateID(root)
+	if !ok {
+		return fmt.Errorf("%w: %x", errStateUnavailable, root)
+	}
+	if err := db.flush(); err != nil {
+		return err
+	}
+	if db.tail == 0 || db.tail > target+1 {
+		return fmt.Errorf("%w: %x", errStateUnavailable, root)
+	}
+	db.layers, db.index = make(map[common.Hash]*diffLayer), make(map[string][]*memoryNode)
+
+	for db.id > target {
+		blob := rawdb.ReadReverseDiff(db.diskdb, db.id)
+		if len(blob) == 0 {
+			return fmt.Errorf("reverse diff %d is missing", db.id)
+		}
+		var diff reverseDiff
+		if err := rlp.DecodeBytes(blob, &diff); err != nil {
+			return err
+		}
+		batch := db.diskdb.NewBatch()
+		for _, n := range diff.Nodes {
+			writeNode(batch, n.Owner, n.Path, n.Blob)
+		}
+		rawdb.DeleteReverseDiff(batch, db.id)
+		if id := rawdb.ReadStateID(db.diskdb, diff.Root); id != nil && *id == db.id {
+			rawdb.DeleteStateID(batch, diff.Root)
+		}
+		rawdb.WritePersistentStateID(batch, db.id-1)
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		db.root, db.id = diff.Parent, db.id-1
+	}
+	if db.tail > db.id {
+		db.tail = 0
+	}
+	if db.cleans != nil {
+		db.cleans.Reset()
+	}
+	log.Info("Reverted persisted state", "root", db.root, "id", db.id)
+	return nil
+}
+
+// size returns the approximate size of the diff layers and the buffer.
+func (db *pathDatabase) size() common.StorageSize {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	size := db.bufferSize
+	for _, layer := range db.layers {
+		size += layer.size
+	}
+	return size
+}
+
+// capBuffer writes out the buffer if the memory used exceeds the limit.
+func (db *pathDatabase) capBuffer(limit common.StorageSize) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if db.bufferSize <= limit {
+		return nil
+	}
+	return db.flush()
+}
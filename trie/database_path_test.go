@@ -0,0 +1,347 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/core/rawdb"
+	"github.com/foreverbit/biternal/ethdb"
+)
+
+// pathTestState is a state generated for the path scheme tests along with
+// the expected content of its trie.
+type pathTestState struct {
+	root   common.Hash
+	values map[string][]byte
+}
+
+// makePathStates generates n consecutive random states on top of the given one,
+// updating and deleting a few keys in each transition.
+func makePathStates(t *testing.T, db *Database, base pathTestState, n int, rng *rand.Rand) []pathTestState {
+	var states []pathTestState
+	for i := 0; i < n; i++ {
+		tr, err := New(common.Hash{}, base.root, db)
+		if err != nil {
+			t.Fatalf("failed to open state %x: %v", base.root, err)
+		}
+		values := make(map[string][]byte, len(base.values))
+		for k, v := range base.values {
+			values[k] = v
+		}
+		for j := 0; j < 5; j++ {
+			key := fmt.Sprintf("key-%02d", rng.Intn(64))
+			val := make([]byte, 1+rng.Intn(40))
+			rng.Read(val)
+			tr.Update([]byte(key), val)
+			values[key] = val
+		}
+		for key := range values {
+			tr.Delete([]byte(key))
+			delete(values, key)
+			break
+		}
+		root, set, err := tr.Commit(false)
+		if err != nil {
+			t.Fatalf("failed to commit trie: %v", err)
+		}
+		if set != nil {
+			if err := db.UpdateState(root, base.root, NewWithNodeSet(set)); err != nil {
+				t.Fatalf("failed to update state %x: %v", root, err)
+			}
+		}
+		base = pathTestState{root: root, values: values}
+		states = append(states, base)
+	}
+	return states
+}
+
+// checkPathState verifies that the given state is fully readable from the
+// database with the expected content.
+func checkPathState(db *Database, state pathTestState) error {
+	tr, err := New(common.Hash{}, state.root, db)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < 64; i++ {
+		key := fmt.Sprintf("key-%02d", i)
+		val, err := tr.TryGet([]byte(key))
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(val, state.values[key]) {
+			return fmt.Errorf("value mismatch for %s: have %x, want %x", key, val, state.values[key])
+		}
+	}
+	return nil
+}
+
+func newPathTestDatabase(diskdb ethdb.KeyValueStore, history uint64) *Database {
+	return NewDatabaseWithConfig(diskdb, &Config{Scheme: rawdb.PathScheme, ReverseDiffs: history})
+}
+
+// Tests that the states kept in memory are all readable, and only the committed
+// one remains available once the database is reopened.
+func TestPathDatabaseCommit(t *testing.T) {
+	var (
+		diskdb = rawdb.NewMemoryDatabase()
+		db     = newPathTestDatabase(diskdb, 0)
+		rng    = rand.New(rand.NewSource(1))
+		states = makePathStates(t, db, pathTestState{root: emptyRoot}, 32, rng)
+	)
+	for i, state := range states {
+		if err := checkPathState(db, state); err != nil {
+			t.Fatalf("state %d: %v", i, err)
+		}
+	}
+	if _, err := db.Node(states[0].root); err != ErrPathSchemeNode {
+		t.Fatalf("hash based node retrieval succeeded")
+	}
+	head := states[len(states)/2]
+	if err := db.Commit(head.root, false, nil); err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	// The descendants of the committed state are still available in memory
+	for i, state := range states[len(states)/2:] {
+		if err := checkPathState(db, state); err != nil {
+			t.Fatalf("state %d: %v", i+len(states)/2, err)
+		}
+	}
+	db = newPathTestDatabase(diskdb, 0)
+	if err := checkPathState(db, head); err != nil {
+		t.Fatalf("committed state unavailable after reopen: %v", err)
+	}
+	if err := checkPathState(db, states[0]); err == nil {
+		t.Fatalf("stale state available after reopen")
+	}
+}
+
+// Tests that the bottom-most layers are persisted once the number of layers
+// kept in memory is exceeded, keeping the recent states readable.
+func TestPathDatabaseLayerLimit(t *testing.T) {
+	var (
+		db     = newPathTestDatabase(rawdb.NewMemoryDatabase(), 0)
+		rng    = rand.New(rand.NewSource(2))
+		states = makePathStates(t, db, pathTestState{root: emptyRoot}, maxDiffLayers+16, rng)
+	)
+	if n := len(db.path.layers); n != maxDiffLayers {
+		t.Fatalf("layer count mismatch: have %d, want %d", n, maxDiffLayers)
+	}
+	for i := len(states) - maxDiffLayers - 1; i < len(states); i++ {
+		if err := checkPathState(db, states[i]); err != nil {
+			t.Fatalf("state %d: %v", i, err)
+		}
+	}
+	if err := checkPathState(db, states[0]); err == nil {
+		t.Fatalf("overwritten state still available")
+	}
+	// Writing out the buffer must not change what's readable
+	if err := db.Cap(0); err != nil {
+		t.Fatalf("failed to cap database: %v", err)
+	}
+	for i := len(states) - maxDiffLayers - 1; i < len(states); i++ {
+		if err := checkPathState(db, states[i]); err != nil {
+			t.Fatalf("state %d after cap: %v", i, err)
+		}
+	}
+}
+
+// Tests that persisted states can be reverted with the reverse diffs, and only
+// as far as they are retained.
+func TestPathDatabaseRecover(t *testing.T) {
+	var (
+		diskdb = rawdb.NewMemoryDatabase()
+		db     = newPathTestDatabase(diskdb, 8)
+		rng    = rand.New(rand.NewSource(3))
+		states []pathTestState
+		base   = pathTestState{root: emptyRoot}
+	)
+	for i := 0; i < 16; i++ {
+		base = makePathStates(t, db, base, 1, rng)[0]
+		if err := db.Commit(base.root, false, nil); err != nil {
+			t.Fatalf("failed to commit state %d: %v", i, err)
+		}
+		states = append(states, base)
+	}
+	if db.Recoverable(states[len(states)-1].root) {
+		t.Fatalf("disk state reported recoverable")
+	}
+	for i := 0; i < len(states)-9; i++ {
+		if db.Recoverable(states[i].root) {
+			t.Fatalf("state %d reported recoverable beyond the retained diffs", i)
+		}
+	}
+	// Reopen the database to make sure the reverse diffs were persisted
+	db = newPathTestDatabase(diskdb, 8)
+
+	target := states[len(states)-9]
+	if !db.Recoverable(target.root) {
+		t.Fatalf("state not recoverable")
+	}
+	if err := db.Recover(target.root); err != nil {
+		t.Fatalf("failed to recover state: %v", err)
+	}
+	if err := checkPathState(db, target); err != nil {
+		t.Fatalf("recovered state: %v", err)
+	}
+	if id := rawdb.ReadPersistentStateID(diskdb); id != uint64(len(states)-8) {
+		t.Fatalf("persistent state id mismatch: have %d, want %d", id, len(states)-8)
+	}
+	// New states can be built on top of the recovered one
+	next := makePathStates(t, db, target, 1, rng)[0]
+	if err := db.Commit(next.root, false, nil); err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if err := checkPathState(newPathTestDatabase(diskdb, 8), next); err != nil {
+		t.Fatalf("state after recovery: %v", err)
+	}
+}
+
+// Tests that the reverse diffs beyond the retention limit are pruned from disk.
+func TestPathDatabasePruneReverseDiffs(t *testing.T) {
+	var (
+		diskdb = rawdb.NewMemoryDatabase()
+		db     = newPathTestDatabase(diskdb, 4)
+		rng    = rand.New(rand.NewSource(4))
+		base   = pathTestState{root: emptyRoot}
+	)
+	for i := 0; i < 10; i++ {
+		base = makePathStates(t, db, base, 1, rng)[0]
+		if err := db.Commit(base.root, false, nil); err != nil {
+			t.Fatalf("failed to commit state %d: %v", i, err)
+		}
+	}
+	if tail := rawdb.ReadReverseDiffTail(diskdb); tail != 7 {
+		t.Fatalf("reverse diff tail mismatch: have %d, want %d", tail, 7)
+	}
+	for id := uint64(1); id <= 10; id++ {
+		if have := len(rawdb.ReadReverseDiff(diskdb, id)) > 0; have != (id >= 7) {
+			t.Fatalf("reverse diff %d presence mismatch: have %v", id, have)
+		}
+	}
+	// Disabling the reverse diffs drops all of them on the next write
+	db = newPathTestDatabase(diskdb, 0)
+	base = makePathStates(t, db, base, 1, rng)[0]
+	if err := db.Commit(base.root, false, nil); err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if tail := rawdb.ReadReverseDiffTail(diskdb); tail != 0 {
+		t.Fatalf("reverse diffs retained: tail %d", tail)
+	}
+}
+
+// Tests that the stored nodes of wiped storage tries are deleted when the state
+// is persisted, and restored when it's reverted.
+func TestPathDatabaseWipeStorage(t *testing.T) {
+	var (
+		diskdb = rawdb.NewMemoryDatabase()
+		db     = newPathTestDatabase(diskdb, 8)
+		owner  = common.HexToHash("0x01")
+	)
+	stored := func() map[string][]byte {
+		nodes := make(map[string][]byte)
+		rawdb.IterateStorageTrieNodes(diskdb, owner, func(path []byte, blob []byte) {
+			nodes[string(path)] = blob
+		})
+		return nodes
+	}
+	// commit creates a state with the given storage, wiping the previous one
+	// if requested
+	commit := func(parent common.Hash, account string, values []string, wipe bool) (common.Hash, common.Hash) {
+		st, _ := New(owner, emptyRoot, db)
+		for _, v := range values {
+			st.Update([]byte(v), []byte(v))
+		}
+		sroot, sset, err := st.Commit(false)
+		if err != nil {
+			t.Fatalf("failed to commit storage trie: %v", err)
+		}
+		if sset == nil {
+			sset = NewNodeSet(owner)
+		}
+		if wipe {
+			sset.MarkWiped()
+		}
+		tr, _ := New(common.Hash{}, parent, db)
+		tr.Update([]byte("account"), append([]byte(account), sroot.Bytes()...))
+		root, set, err := tr.Commit(false)
+		if err != nil {
+			t.Fatalf("failed to commit account trie: %v", err)
+		}
+		nodes := NewWithNodeSet(set)
+		nodes.Merge(sset)
+		if err := db.UpdateState(root, parent, nodes); err != nil {
+			t.Fatalf("failed to update state: %v", err)
+		}
+		if err := db.Commit(root, false, nil); err != nil {
+			t.Fatalf("failed to commit state: %v", err)
+		}
+		return root, sroot
+	}
+	var keys []string
+	for i := 0; i < 32; i++ {
+		keys = append(keys, fmt.Sprintf("key-%02d", i))
+	}
+	root1, sroot1 := commit(emptyRoot, "a", keys, false)
+	before := stored()
+	if len(before) < 2 {
+		t.Fatalf("storage trie not persisted: %d nodes", len(before))
+	}
+	// Recreate the storage with a single slot, only its node may remain
+	root2, sroot2 := commit(root1, "b", []string{"other"}, true)
+	if after := stored(); len(after) != 1 {
+		t.Fatalf("wiped storage trie nodes left on disk: have %d nodes, want 1", len(after))
+	}
+	st, err := New(owner, sroot2, db)
+	if err != nil {
+		t.Fatalf("failed to open recreated storage trie: %v", err)
+	}
+	if val, _ := st.TryGet([]byte("other")); string(val) != "other" {
+		t.Fatalf("recreated storage slot mismatch: have %q", val)
+	}
+	// Delete the storage entirely
+	commit(root2, "c", nil, true)
+	if after := stored(); len(after) != 0 {
+		t.Fatalf("wiped storage trie nodes left on disk: have %d nodes, want 0", len(after))
+	}
+	// Reverting to the first state restores all the nodes
+	if err := db.Recover(root1); err != nil {
+		t.Fatalf("failed to recover state: %v", err)
+	}
+	after := stored()
+	if len(after) != len(before) {
+		t.Fatalf("restored storage trie nodes mismatch: have %d, want %d", len(after), len(before))
+	}
+	for path, blob := range before {
+		if !bytes.Equal(after[path], blob) {
+			t.Fatalf("restored storage trie node %x mismatch", path)
+		}
+	}
+	st, err = New(owner, sroot1, db)
+	if err != nil {
+		t.Fatalf("failed to open restored storage trie: %v", err)
+	}
+	for _, key := range keys {
+		if val, _ := st.TryGet([]byte(key)); string(val) != key {
+			t.Fatalf("restored storage slot %s mismatch: have %q", key, val)
+		}
+	}
+}
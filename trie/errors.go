@@ -17,11 +17,16 @@
 package trie
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/foreverbit/biternal/common"
 )
 
+// ErrPathSchemeNode is returned when looking up a trie node by its hash alone,
+// which isn't supported by the path scheme.
+var ErrPathSchemeNode = errors.New("trie node lookup by hash is not supported by the path scheme")
+
 // MissingNodeError is returned by the trie functions (TryGet, TryUpdate, TryDelete)
 // in the case where a trie node is not present in the local database. It contains
 // information necessary for retrieving the missing node.
@@ -30,6 +30,11 @@ type memoryNode struct {
 	node node        // Cached collapsed trie node, or raw rlp data
 }
 
+// isDeleted returns the indicator if the node is marked as deleted.
+func (n *memoryNode) isDeleted() bool {
+	return n.hash == (common.Hash{})
+}
+
 // NodeSet contains all dirty nodes collected during the commit operation.
 // Each node is keyed by path. It's not thread-safe to use.
 type NodeSet struct {
@@ -37,6 +42,7 @@ type NodeSet struct {
 	paths  []string               // the path of dirty nodes, sort by insertion order
 	nodes  map[string]*memoryNode // the map of dirty nodes, keyed by node path
 	leaves []*leaf                // the list of dirty leaves
+	wiped  bool                   // whether the previously stored trie was wiped
 }
 
 // NewNodeSet initializes an empty node set to be used for tracking dirty nodes
@@ -55,6 +61,20 @@ func (set *NodeSet) add(path string, node *memoryNode) {
 	set.nodes[path] = node
 }
 
+// markDeleted marks the node at the provided path as deleted. The deletion
+// is only tracked for tries backed by the path scheme.
+func (set *NodeSet) markDeleted(path string) {
+	set.paths = append(set.paths, path)
+	set.nodes[path] = &memoryNode{}
+}
+
+// MarkWiped marks the whole trie previously stored for the owner as deleted,
+// before the dirty nodes of the set are applied. Like the deleted nodes, the
+// wipe is only tracked for tries backed by the path scheme.
+func (set *NodeSet) MarkWiped() {
+	set.wiped = true
+}
+
 // addLeaf caches the provided leaf node.
 func (set *NodeSet) addLeaf(node *leaf) {
 	set.leaves = append(set.leaves, node)
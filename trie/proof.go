@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"sort"
 
 	"github.com/foreverbit/biternal/common"
 	"github.com/foreverbit/biternal/core/rawdb"
@@ -108,16 +109,29 @@ func (t *StateTrie) Prove(key []byte, fromLevel uint, proofDb ethdb.KeyValueWrit
 // key in a trie with the given root hash. VerifyProof returns an error if the
 // proof contains invalid trie nodes or the wrong value.
 func VerifyProof(rootHash common.Hash, key []byte, proofDb ethdb.KeyValueReader) (value []byte, err error) {
+	return verifyProof(rootHash, key, proofDb, nil)
+}
+
+// verifyProof checks the merkle proof of a single key, reusing and filling the
+// given cache of decoded proof nodes if it's not nil.
+func verifyProof(rootHash common.Hash, key []byte, proofDb ethdb.KeyValueReader, decoded map[common.Hash]node) ([]byte, error) {
 	key = keybytesToHex(key)
 	wantHash := rootHash
 	for i := 0; ; i++ {
-		buf, _ := proofDb.Get(wantHash[:])
-		if buf == nil {
-			return nil, fmt.Errorf("proof node %d (hash %064x) missing", i, wantHash)
-		}
-		n, err := decodeNode(wantHash[:], buf)
-		if err != nil {
-			return nil, fmt.Errorf("bad proof node %d: %v", i, err)
+		n, ok := decoded[wantHash]
+		if !ok {
+			buf, _ := proofDb.Get(wantHash[:])
+			if buf == nil {
+				return nil, fmt.Errorf("proof node %d (hash %064x) missing", i, wantHash)
+			}
+			var err error
+			n, err = decodeNode(wantHash[:], buf)
+			if err != nil {
+				return nil, fmt.Errorf("bad proof node %d: %v", i, err)
+			}
+			if decoded != nil {
+				decoded[wantHash] = n
+			}
 		}
 		keyrest, cld := get(n, key, true)
 		switch cld := cld.(type) {
@@ -133,6 +147,120 @@ func VerifyProof(rootHash common.Hash, key []byte, proofDb ethdb.KeyValueReader)
 	}
 }
 
+// ProveMulti constructs a merkle multiproof for the given keys. The result is
+// the union of the proofs of the individual keys as constructed by Prove, with
+// every node included once: nodes shared by the paths of several keys, such as
+// the root, are only resolved and written once.
+//
+// The nodes are written in depth-first order, visiting the keys in ascending
+// order.
+func (t *Trie) ProveMulti(keys [][]byte, fromLevel uint, proofDb ethdb.KeyValueWriter) error {
+	hexKeys := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		hexKeys = append(hexKeys, keybytesToHex(key))
+	}
+	sort.Slice(hexKeys, func(i, j int) bool {
+		return bytes.Compare(hexKeys[i], hexKeys[j]) < 0
+	})
+	hasher := newHasher(false)
+	defer returnHasherToPool(hasher)
+
+	return t.proveMulti(t.root, nil, hexKeys, 0, fromLevel, hasher, proofDb)
+}
+
+// proveMulti collects the proof nodes of the given sorted keys, relative to the
+// node at the given prefix and depth.
+func (t *Trie) proveMulti(tn node, prefix []byte, keys [][]byte, level, fromLevel uint, hasher *hasher, proofDb ethdb.KeyValueWriter) error {
+	switch n := tn.(type) {
+	case nil, valueNode:
+		return nil
+	case hashNode:
+		resolved, err := t.resolveHash(n, prefix)
+		if err != nil {
+			log.Error(fmt.Sprintf("Unhandled trie error: %v", err))
+			return err
+		}
+		return t.proveMulti(resolved, prefix, keys, level, fromLevel, hasher, proofDb)
+	case *shortNode:
+		proveNode(n, level, fromLevel, hasher, proofDb)
+
+		// Keys diverging from the node are proven absent by the node itself
+		var rest [][]byte
+		for _, key := range keys {
+			if len(key) >= len(n.Key) && bytes.Equal(n.Key, key[:len(n.Key)]) {
+				rest = append(rest, key[len(n.Key):])
+			}
+		}
+		if len(rest) == 0 {
+			return nil
+		}
+		return t.proveMulti(n.Val, append(common.CopyBytes(prefix), n.Key...), rest, level+1, fromLevel, hasher, proofDb)
+	case *fullNode:
+		proveNode(n, level, fromLevel, hasher, proofDb)
+
+		// Descend into every child once, with all the keys routed through it
+		for i := 0; i < len(keys); {
+			j, nibble := i, keys[i][0]
+			for j < len(keys) && keys[j][0] == nibble {
+				j++
+			}
+			rest := make([][]byte, 0, j-i)
+			for _, key := range keys[i:j] {
+				rest = append(rest, key[1:])
+			}
+			if err := t.proveMulti(n.Children[nibble], append(common.CopyBytes(prefix), nibble), rest, level+1, fromLevel, hasher, proofDb); err != nil {
+				return err
+			}
+			i = j
+		}
+		return nil
+	default:
+		panic(fmt.Sprintf("%T: invalid node: %v", tn, tn))
+	}
+}
+
+// proveNode writes the given node into the proof if it's referenced by hash,
+// or if it's the root node.
+func proveNode(n node, level, fromLevel uint, hasher *hasher, proofDb ethdb.KeyValueWriter) {
+	if level < fromLevel {
+		return
+	}
+	n, hn := hasher.proofHash(n)
+	if hash, ok := hn.(hashNode); ok || level == 0 {
+		enc := nodeToBytes(n)
+		if !ok {
+			hash = hasher.hashData(enc)
+		}
+		proofDb.Put(hash, enc)
+	}
+}
+
+// ProveMulti constructs a merkle multiproof for the given keys, containing every
+// node on the paths to the values at the keys once.
+func (t *StateTrie) ProveMulti(keys [][]byte, fromLevel uint, proofDb ethdb.KeyValueWriter) error {
+	return t.trie.ProveMulti(keys, fromLevel, proofDb)
+}
+
+// VerifyMultiProof checks a merkle multiproof of the given keys, as constructed
+// by ProveMulti, against the given root hash. The values of the keys are
+// returned in the same order, nil for the keys not present in the trie. An
+// error is returned if the proof contains invalid trie nodes or lacks the nodes
+// of any of the keys.
+func VerifyMultiProof(rootHash common.Hash, keys [][]byte, proofDb ethdb.KeyValueReader) ([][]byte, error) {
+	var (
+		values  = make([][]byte, len(keys))
+		decoded = make(map[common.Hash]node)
+	)
+	for i, key := range keys {
+		value, err := verifyProof(rootHash, key, proofDb, decoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %x: %v", key, err)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
 // proofToPath converts a merkle proof to trie node path. The main purpose of
 // this function is recovering a node path from the merkle proof stream. All
 // necessary nodes will be resolved and leave the remaining as hashnode.
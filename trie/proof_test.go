@@ -151,6 +151,84 @@ func TestMissingKeyProof(t *testing.T) {
 	}
 }
 
+// Tests that multiproofs contain exactly the nodes of the individual proofs of
+// the keys, both existent and missing ones, and can be verified.
+func TestMultiProof(t *testing.T) {
+	mem, vals := randomTrie(500)
+	root, nodes, _ := mem.Copy().Commit(false)
+
+	triedb := NewDatabase(rawdb.NewMemoryDatabase())
+	triedb.Update(NewWithNodeSet(nodes))
+	disk, _ := New(common.Hash{}, root, triedb)
+
+	for i, trie := range []*Trie{mem, disk} {
+		var (
+			keys [][]byte
+			want [][]byte
+		)
+		for _, kv := range vals {
+			if mrand.Intn(4) == 0 {
+				keys, want = append(keys, kv.k), append(want, kv.v)
+			}
+		}
+		for j := 0; j < 20; j++ {
+			keys, want = append(keys, randBytes(32)), append(want, nil)
+		}
+		keys, want = append(keys, keys[0]), append(want, want[0]) // duplicate key
+
+		proof := memorydb.New()
+		if err := trie.ProveMulti(keys, 0, proof); err != nil {
+			t.Fatalf("trie %d: failed to construct multiproof: %v", i, err)
+		}
+		union := memorydb.New()
+		for _, key := range keys {
+			trie.Prove(key, 0, union)
+		}
+		if proof.Len() != union.Len() {
+			t.Fatalf("trie %d: multiproof size mismatch: have %d, want %d", i, proof.Len(), union.Len())
+		}
+		values, err := VerifyMultiProof(root, keys, proof)
+		if err != nil {
+			t.Fatalf("trie %d: failed to verify multiproof: %v", i, err)
+		}
+		for j := range keys {
+			if !bytes.Equal(values[j], want[j]) {
+				t.Fatalf("trie %d: value mismatch for key %x: have %x, want %x", i, keys[j], values[j], want[j])
+			}
+		}
+		// Dropping any node must break the proof of some key
+		it := proof.NewIterator(nil, nil)
+		for d := mrand.Intn(proof.Len()); d >= 0; d-- {
+			it.Next()
+		}
+		proof.Delete(it.Key())
+		it.Release()
+
+		if _, err := VerifyMultiProof(root, keys, proof); err == nil {
+			t.Fatalf("trie %d: incomplete multiproof verified", i)
+		}
+	}
+}
+
+// Tests that multiproofs honour the level from which nodes are included.
+func TestMultiProofFromLevel(t *testing.T) {
+	trie, vals := randomTrie(100)
+	var keys [][]byte
+	for _, kv := range vals {
+		keys = append(keys, kv.k)
+	}
+	for level := uint(0); level < 3; level++ {
+		proof, union := memorydb.New(), memorydb.New()
+		trie.ProveMulti(keys, level, proof)
+		for _, key := range keys {
+			trie.Prove(key, level, union)
+		}
+		if proof.Len() != union.Len() {
+			t.Fatalf("level %d: multiproof size mismatch: have %d, want %d", level, proof.Len(), union.Len())
+		}
+	}
+}
+
 type entrySlice []*kv
 
 func (p entrySlice) Len() int           { return len(p) }
@@ -23,6 +23,7 @@ import (
 	"fmt"
 
 	"github.com/foreverbit/biternal/common"
+	"github.com/foreverbit/biternal/core/rawdb"
 	"github.com/foreverbit/biternal/crypto"
 	"github.com/foreverbit/biternal/log"
 )
@@ -103,7 +104,11 @@ func New(owner common.Hash, root common.Hash, db *Database) (*Trie, error) {
 	trie := &Trie{
 		owner: owner,
 		db:    db,
-		//tracer: newTracer(),
+	}
+	// The path scheme overwrites nodes in place, track the deleted ones so
+	// they can be removed from disk as well.
+	if db != nil && db.Scheme() == rawdb.PathScheme {
+		trie.tracer = newTracer()
 	}
 	if root != (common.Hash{}) && root != emptyRoot {
 		rootnode, err := trie.resolveHash(root[:], nil)
@@ -219,7 +224,7 @@ func (t *Trie) tryGetNode(origNode node, path []byte, pos int) (item []byte, new
 		if hash == nil {
 			return nil, origNode, 0, errors.New("non-consensus node")
 		}
-		blob, err := t.db.Node(common.BytesToHash(hash))
+		blob, err := t.resolveBlob(hash, path[:pos])
 		return blob, origNode, 1, err
 	}
 	// Path still needs to be traversed, descend into children
@@ -557,7 +562,7 @@ func (t *Trie) resolve(n node, prefix []byte) (node, error) {
 // node hash and path prefix.
 func (t *Trie) resolveHash(n hashNode, prefix []byte) (node, error) {
 	hash := common.BytesToHash(n)
-	if node := t.db.node(hash); node != nil {
+	if node := t.db.nodeAt(t.owner, prefix, hash); node != nil {
 		return node, nil
 	}
 	return nil, &MissingNodeError{Owner: t.owner, NodeHash: hash, Path: prefix}
@@ -567,8 +572,7 @@ func (t *Trie) resolveHash(n hashNode, prefix []byte) (node, error) {
 // with the provided node hash and path prefix.
 func (t *Trie) resolveBlob(n hashNode, prefix []byte) ([]byte, error) {
 	hash := common.BytesToHash(n)
-	blob, _ := t.db.Node(hash)
-	if len(blob) != 0 {
+	if blob := t.db.blobAt(t.owner, prefix, hash); len(blob) != 0 {
 		return blob, nil
 	}
 	return nil, &MissingNodeError{Owner: t.owner, NodeHash: hash, Path: prefix}
@@ -591,8 +595,18 @@ func (t *Trie) Hash() common.Hash {
 func (t *Trie) Commit(collectLeaf bool) (common.Hash, *NodeSet, error) {
 	defer t.tracer.reset()
 
+	// The deleted nodes are only tracked for the path scheme. They are
+	// returned even if the trie was emptied entirely.
+	deleted := t.tracer.deleteList()
 	if t.root == nil {
-		return emptyRoot, nil, nil
+		if len(deleted) == 0 {
+			return emptyRoot, nil, nil
+		}
+		nodes := NewNodeSet(t.owner)
+		for _, path := range deleted {
+			nodes.markDeleted(string(path))
+		}
+		return emptyRoot, nodes, nil
 	}
 	// Derive the hash for all dirty nodes first. We hold the assumption
 	// in the following procedure that all nodes are hashed.
@@ -611,6 +625,12 @@ func (t *Trie) Commit(collectLeaf bool) (common.Hash, *NodeSet, error) {
 	if err != nil {
 		return common.Hash{}, nil, err
 	}
+	for _, path := range deleted {
+		// Skip the paths which were taken over by a new node
+		if _, ok := nodes.nodes[string(path)]; !ok {
+			nodes.markDeleted(string(path))
+		}
+	}
 	t.root = newRoot
 	return rootHash, nodes, nil
 }